@@ -0,0 +1,95 @@
+package pgmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+)
+
+// returningPrimaryKey appends RETURNING <primary key columns> to a single UPDATE or DELETE
+// that has no RETURNING clause (query.return_affected_keys). Other statements, and tables
+// without a primary key, are returned unchanged with ok false.
+func returningPrimaryKey(ctx context.Context, tx pgx.Tx, sql string) (rewritten string, ok bool, err error) {
+	tree, err := pg_query.Parse(sql)
+	if err != nil || len(tree.Stmts) != 1 {
+		return sql, false, nil
+	}
+	var rel *pg_query.RangeVar
+	switch n := tree.Stmts[0].Stmt.Node.(type) {
+	case *pg_query.Node_UpdateStmt:
+		if len(n.UpdateStmt.ReturningList) == 0 {
+			rel = n.UpdateStmt.Relation
+		}
+	case *pg_query.Node_DeleteStmt:
+		if len(n.DeleteStmt.ReturningList) == 0 {
+			rel = n.DeleteStmt.Relation
+		}
+	}
+	if rel == nil {
+		return sql, false, nil
+	}
+	return appendReturningKeys(ctx, tx, sql, tree.Stmts[0], rel, false)
+}
+
+// returningGeneratedKeys appends RETURNING <generated primary key columns> to a single
+// INSERT that has no RETURNING clause (query.auto_return_generated_keys). Generated means
+// the column has a default (serial, bigserial, nextval) or is an identity column. Other
+// statements, and tables without such a key column, are returned unchanged with ok false.
+func returningGeneratedKeys(ctx context.Context, tx pgx.Tx, sql string) (rewritten string, ok bool, err error) {
+	tree, err := pg_query.Parse(sql)
+	if err != nil || len(tree.Stmts) != 1 {
+		return sql, false, nil
+	}
+	n, isInsert := tree.Stmts[0].Stmt.Node.(*pg_query.Node_InsertStmt)
+	if !isInsert || len(n.InsertStmt.ReturningList) > 0 {
+		return sql, false, nil
+	}
+	return appendReturningKeys(ctx, tx, sql, tree.Stmts[0], n.InsertStmt.Relation, true)
+}
+
+// appendReturningKeys looks up rel's primary key columns (only those with a default or
+// identity when generatedOnly) and appends them to stmt as a RETURNING clause.
+func appendReturningKeys(ctx context.Context, tx pgx.Tx, sql string, stmt *pg_query.RawStmt, rel *pg_query.RangeVar, generatedOnly bool) (string, bool, error) {
+	table := quoteIdent(rel.Relname)
+	if rel.Schemaname != "" {
+		table = quoteIdent(rel.Schemaname) + "." + table
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT a.attname
+		FROM pg_catalog.pg_index i
+		JOIN pg_catalog.pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+		WHERE i.indrelid = to_regclass($1) AND i.indisprimary
+			AND (NOT $2 OR a.atthasdef OR a.attidentity <> '')
+		ORDER BY array_position(i.indkey::int2[], a.attnum)`, table, generatedOnly)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to look up primary key: %w", err)
+	}
+	keys, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return "", false, fmt.Errorf("failed to look up primary key: %w", err)
+	}
+	if len(keys) == 0 {
+		return sql, false, nil
+	}
+
+	// Qualify with the alias (or table name) so keys cannot clash with columns of
+	// UPDATE ... FROM / DELETE ... USING tables.
+	ref := rel.Relname
+	if rel.Alias != nil {
+		ref = rel.Alias.Aliasname
+	}
+	cols := make([]string, len(keys))
+	for i, key := range keys {
+		cols[i] = quoteIdent(ref) + "." + quoteIdent(key)
+	}
+	// RETURNING is the last clause of INSERT, UPDATE and DELETE, so it goes right after
+	// the statement, before any trailing semicolon. The newline ends a trailing -- comment.
+	end := len(sql)
+	if stmt.StmtLen > 0 {
+		end = int(stmt.StmtLocation + stmt.StmtLen)
+	}
+	return sql[:end] + "\nRETURNING " + strings.Join(cols, ", ") + sql[end:], true, nil
+}
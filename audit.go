@@ -0,0 +1,80 @@
+package pgmcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditRecord.Outcome values.
+const (
+	AuditOutcomeSuccess = "success"
+	// AuditOutcomeBlocked means the query never reached the database: empty or too-long
+	// SQL, the tenant/semaphore limit and shutdown, a failed startup check, a BeforeQuery
+	// hook rejection, or a protection rule.
+	AuditOutcomeBlocked = "blocked"
+	// AuditOutcomeError covers every other failure (Postgres errors, timeouts, AfterQuery
+	// hook rejections, query.max_rows_affected, ...).
+	AuditOutcomeError = "error"
+)
+
+// AuditRecord describes one Query call (Config.AuditSink).
+type AuditRecord struct {
+	Time         time.Time       `json:"time"`                // when Query was called
+	OriginalSQL  string          `json:"original_sql"`        // QueryInput.SQL as received
+	FinalSQL     string          `json:"final_sql,omitempty"` // as executed, after hooks, auto_limit, and statement_comment; empty if blocked
+	Plan         json.RawMessage `json:"plan,omitempty"`      // EXPLAIN (FORMAT JSON) output, only with audit_explain
+	Outcome      string          `json:"outcome"`             // AuditOutcomeSuccess, AuditOutcomeBlocked, or AuditOutcomeError
+	Error        string          `json:"error,omitempty"`     // QueryOutput.Error, including appended error prompts
+	RowCount     int             `json:"row_count"`
+	RowsAffected int64           `json:"rows_affected"`
+	Duration     time.Duration   `json:"duration_ns"`
+}
+
+// AuditSink receives a record after every Query call. Record runs synchronously before
+// Query returns, so slow sinks slow down queries; errors are logged and do not affect the
+// query's result.
+type AuditSink interface {
+	Record(ctx context.Context, record AuditRecord) error
+}
+
+// JSONLinesAuditSink appends each record as one JSON object per line to a file.
+// Safe for concurrent use.
+type JSONLinesAuditSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewJSONLinesAuditSink opens (creating if needed) path for appending.
+func NewJSONLinesAuditSink(path string) (*JSONLinesAuditSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &JSONLinesAuditSink{file: f}, nil
+}
+
+// Record writes record as a single line.
+func (s *JSONLinesAuditSink) Record(_ context.Context, record AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	line = append(line, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+// Close closes the underlying file.
+func (s *JSONLinesAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,53 @@
+package pgmcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJSONLinesAuditSink_AppendsOneLinePerRecord(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	ctx := context.Background()
+
+	// Reopening appends rather than truncating.
+	for i, sql := range []string{"SELECT 1", "DROP TABLE users"} {
+		sink, err := NewJSONLinesAuditSink(path)
+		if err != nil {
+			t.Fatalf("failed to open sink: %v", err)
+		}
+		record := AuditRecord{Time: time.Unix(int64(i), 0).UTC(), OriginalSQL: sql, Outcome: AuditOutcomeSuccess}
+		if err := sink.Record(ctx, record); err != nil {
+			t.Fatalf("failed to record: %v", err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("failed to close sink: %v", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer f.Close()
+	var records []AuditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 || records[0].OriginalSQL != "SELECT 1" || records[1].OriginalSQL != "DROP TABLE users" {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+	if records[1].Time.Unix() != 1 || records[1].Outcome != AuditOutcomeSuccess {
+		t.Fatalf("unexpected record: %+v", records[1])
+	}
+}
@@ -0,0 +1,118 @@
+package pgmcp
+
+import (
+	"math"
+	"strconv"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+	"github.com/rickchristie/postgres-mcp/internal/protection"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// aggregateFuncs are the built-in aggregate functions recognised when deciding whether
+// a SELECT is aggregate-only. User-defined aggregates are not detected.
+var aggregateFuncs = map[string]bool{
+	"count": true, "sum": true, "avg": true, "min": true, "max": true,
+	"array_agg": true, "string_agg": true, "json_agg": true, "jsonb_agg": true,
+	"json_object_agg": true, "jsonb_object_agg": true, "bool_and": true, "bool_or": true,
+	"every": true, "bit_and": true, "bit_or": true, "bit_xor": true, "xmlagg": true,
+	"stddev": true, "stddev_pop": true, "stddev_samp": true,
+	"variance": true, "var_pop": true, "var_samp": true,
+	"corr": true, "covar_pop": true, "covar_samp": true,
+	"regr_avgx": true, "regr_avgy": true, "regr_count": true, "regr_intercept": true,
+	"regr_r2": true, "regr_slope": true, "regr_sxx": true, "regr_sxy": true, "regr_syy": true,
+	"percentile_cont": true, "percentile_disc": true, "mode": true,
+	"rank": true, "dense_rank": true, "percent_rank": true, "cume_dist": true,
+	"range_agg": true, "range_intersect_agg": true, "any_value": true,
+}
+
+// applyAutoLimit rewrites a top-level SELECT so it returns at most limit rows: a LIMIT is
+// added when missing (or LIMIT ALL), and a constant LIMIT larger than limit is reduced.
+// Aggregate-only queries (aggregates without GROUP BY), SELECT INTO, non-SELECT statements,
+// and non-constant limits are left alone. Returns the SQL to execute and whether it was
+// rewritten. The SQL has already passed protection checks (single statement, parsed successfully).
+func applyAutoLimit(sql string, limit int) (string, bool) {
+	tree, err := pg_query.Parse(sql)
+	if err != nil || len(tree.Stmts) != 1 {
+		return sql, false
+	}
+	sel := tree.Stmts[0].Stmt.GetSelectStmt()
+	if sel == nil || sel.IntoClause != nil || isAggregateOnly(sel) {
+		return sql, false
+	}
+
+	if sel.LimitCount != nil {
+		existing, ok := constLimit(sel.LimitCount)
+		if !ok || existing <= float64(limit) {
+			return sql, false
+		}
+	}
+	sel.LimitCount = pg_query.MakeAConstIntNode(int64(limit), -1)
+	if sel.LimitOption == pg_query.LimitOption_LIMIT_OPTION_UNDEFINED || sel.LimitOption == pg_query.LimitOption_LIMIT_OPTION_DEFAULT {
+		sel.LimitOption = pg_query.LimitOption_LIMIT_OPTION_COUNT
+	}
+
+	rewritten, err := pg_query.Deparse(tree)
+	if err != nil {
+		return sql, false
+	}
+	return rewritten, true
+}
+
+// constLimit returns the value of a constant LIMIT. LIMIT ALL and LIMIT NULL report
+// +Inf, so they are replaced like a missing LIMIT. ok is false for non-constant expressions.
+func constLimit(node *pg_query.Node) (value float64, ok bool) {
+	c := node.GetAConst()
+	if c == nil {
+		return 0, false
+	}
+	if c.Isnull {
+		return math.Inf(1), true
+	}
+	switch v := c.Val.(type) {
+	case *pg_query.A_Const_Ival:
+		return float64(v.Ival.Ival), true
+	case *pg_query.A_Const_Fval:
+		// Integers beyond int32 are parsed as Float nodes.
+		f, err := strconv.ParseFloat(v.Fval.Fval, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// isAggregateOnly reports whether sel returns at most one row because its target list
+// aggregates without GROUP BY (e.g. SELECT count(*) FROM t).
+func isAggregateOnly(sel *pg_query.SelectStmt) bool {
+	if sel.Op != pg_query.SetOperation_SET_OPERATION_UNDEFINED && sel.Op != pg_query.SetOperation_SETOP_NONE {
+		return false
+	}
+	if len(sel.GroupClause) > 0 || len(sel.ValuesLists) > 0 {
+		return false
+	}
+	for _, target := range sel.TargetList {
+		if containsAggregate(target.ProtoReflect()) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAggregate reports whether m contains a call to a known aggregate function that
+// is not a window call. Subqueries are not searched: their aggregates don't collapse the outer rows.
+func containsAggregate(m protoreflect.Message) bool {
+	found := false
+	protection.Walk(m, func(m protoreflect.Message) error {
+		switch n := m.Interface().(type) {
+		case *pg_query.SubLink:
+			return protection.SkipChildren
+		case *pg_query.FuncCall:
+			if n.Over == nil && len(n.Funcname) > 0 && aggregateFuncs[n.Funcname[len(n.Funcname)-1].GetString_().GetSval()] {
+				found = true
+				return protection.StopWalk
+			}
+		}
+		return nil
+	})
+	return found
+}
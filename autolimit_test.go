@@ -0,0 +1,39 @@
+package pgmcp
+
+import "testing"
+
+func TestApplyAutoLimit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		sql         string
+		expected    string
+		wantApplied bool
+	}{
+		{"adds missing limit", "SELECT id FROM users", "SELECT id FROM users LIMIT 100", true},
+		{"reduces larger limit", "SELECT id FROM users LIMIT 5000", "SELECT id FROM users LIMIT 100", true},
+		{"reduces bigint limit", "SELECT id FROM users LIMIT 10000000000", "SELECT id FROM users LIMIT 100", true},
+		{"replaces limit all", "SELECT id FROM users LIMIT ALL", "SELECT id FROM users LIMIT 100", true},
+		{"keeps smaller limit", "SELECT id FROM users LIMIT 10", "SELECT id FROM users LIMIT 10", false},
+		{"keeps equal limit", "SELECT id FROM users LIMIT 100", "SELECT id FROM users LIMIT 100", false},
+		{"keeps offset", "SELECT id FROM users OFFSET 20", "SELECT id FROM users LIMIT 100 OFFSET 20", true},
+		{"limits union as a whole", "SELECT 1 UNION SELECT 2", "SELECT 1 UNION SELECT 2 LIMIT 100", true},
+		{"keeps parameterized limit", "SELECT id FROM users LIMIT (SELECT 5000)", "SELECT id FROM users LIMIT (SELECT 5000)", false},
+		{"exempts count", "SELECT count(*) FROM users", "SELECT count(*) FROM users", false},
+		{"exempts nested aggregate", "SELECT coalesce(max(id), 0) + 1 FROM users", "SELECT coalesce(max(id), 0) + 1 FROM users", false},
+		{"limits grouped aggregate", "SELECT status, count(*) FROM users GROUP BY status", "SELECT status, count(*) FROM users GROUP BY status LIMIT 100", true},
+		{"limits window function", "SELECT id, count(*) OVER () FROM users", "SELECT id, count(*) OVER () FROM users LIMIT 100", true},
+		{"limits aggregate in subquery only", "SELECT id, (SELECT max(id) FROM users) FROM users", "SELECT id, (SELECT max(id) FROM users) FROM users LIMIT 100", true},
+		{"ignores select into", "SELECT id INTO copy FROM users", "SELECT id INTO copy FROM users", false},
+		{"ignores writes", "DELETE FROM users", "DELETE FROM users", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, applied := applyAutoLimit(tt.sql, 100)
+			if got != tt.expected || applied != tt.wantApplied {
+				t.Fatalf("applyAutoLimit(%q) = (%q, %v), want (%q, %v)", tt.sql, got, applied, tt.expected, tt.wantApplied)
+			}
+		})
+	}
+}
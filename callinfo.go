@@ -0,0 +1,72 @@
+package pgmcp
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// CallInfo identifies who issued a Query. Its fields are available to the
+// Config.StatementComment template, e.g. "pgmcp agent={{.Agent}} req={{.RequestID}}".
+type CallInfo struct {
+	Agent     string // client name; set from the MCP client's initialize request in server mode
+	Tenant    string // tenant for max_concurrent_per_tenant; Agent is used when empty
+	SessionID string // MCP session ID, if any
+	RequestID string // defaults to a per-instance sequence number when empty
+}
+
+type callInfoKey struct{}
+
+// WithCallInfo returns a context carrying info for Query to render into the statement comment.
+// RegisterMCPTools sets it for tool calls; library callers can set it themselves.
+func WithCallInfo(ctx context.Context, info CallInfo) context.Context {
+	return context.WithValue(ctx, callInfoKey{}, info)
+}
+
+// CallInfoFromContext returns the CallInfo set with WithCallInfo, or the zero value.
+func CallInfoFromContext(ctx context.Context) CallInfo {
+	info, _ := ctx.Value(callInfoKey{}).(CallInfo)
+	return info
+}
+
+// commentSanitizer replaces every '*' and '/' in CallInfo values, so no value (or
+// value next to template text) can end the comment early or open a nested one
+// (Postgres block comments nest) and smuggle in SQL that protection never saw.
+var commentSanitizer = strings.NewReplacer("*", "_", "/", "_", "\x00", "")
+
+// statementComment renders Config.StatementComment for ctx as "/* ... */ ", or "" when
+// no template is configured.
+func (p *PostgresMcp) statementComment(ctx context.Context) (string, error) {
+	if p.commentTmpl == nil {
+		return "", nil
+	}
+	info := CallInfoFromContext(ctx)
+	if info.RequestID == "" {
+		info.RequestID = strconv.FormatUint(p.requestSeq.Add(1), 10)
+	}
+	info.Agent = commentSanitizer.Replace(info.Agent)
+	info.Tenant = commentSanitizer.Replace(info.Tenant)
+	info.SessionID = commentSanitizer.Replace(info.SessionID)
+	info.RequestID = commentSanitizer.Replace(info.RequestID)
+	var b strings.Builder
+	if err := p.commentTmpl.Execute(&b, info); err != nil {
+		return "", err
+	}
+	// Template text around an empty value can still form a delimiter, e.g. "*{{.Agent}}/".
+	out := b.String()
+	if strings.Contains(out, "/*") || strings.Contains(out, "*/") || strings.ContainsRune(out, 0) {
+		return "", errors.New("statement_comment rendered a comment delimiter")
+	}
+	return "/* " + out + " */ ", nil
+}
+
+// parseStatementComment compiles a Config.StatementComment template. Template text may
+// not contain a comment delimiter.
+func parseStatementComment(text string) (*template.Template, error) {
+	if strings.Contains(text, "/*") || strings.Contains(text, "*/") {
+		return nil, errors.New("must not contain /* or */")
+	}
+	return template.New("statement_comment").Option("missingkey=error").Parse(text)
+}
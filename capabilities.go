@@ -0,0 +1,96 @@
+package pgmcp
+
+import "context"
+
+// capabilityProbes are representative statements for each operation Capabilities reports.
+// Each is run through the protection checker, so the report always matches what Query
+// would accept. Writes are reported blocked in read-only mode, where Postgres rejects them,
+// and noTx statements always, since Postgres refuses them inside the transaction every
+// query runs in (SQLSTATE 25001).
+var capabilityProbes = []struct {
+	operation string
+	sql       string
+	write     bool
+	noTx      bool
+}{
+	{"SELECT", "SELECT 1", false, false},
+	{"INSERT/UPDATE/DELETE", "INSERT INTO t VALUES (1)", true, false},
+	{"UPDATE without WHERE", "UPDATE t SET a = 1", true, false},
+	{"DELETE without WHERE", "DELETE FROM t", true, false},
+	{"INSERT ... ON CONFLICT", "INSERT INTO t VALUES (1) ON CONFLICT DO NOTHING", true, false},
+	{"MERGE", "MERGE INTO t USING s ON t.id = s.id WHEN MATCHED THEN UPDATE SET a = 1", true, false},
+	{"MERGE ... THEN DELETE", "MERGE INTO t USING s ON t.id = s.id WHEN MATCHED THEN DELETE", true, false},
+	{"CREATE/ALTER (DDL)", "CREATE TABLE t (id int)", true, false},
+	{"CREATE TEMP TABLE", "CREATE TEMP TABLE t (id int)", false, false},
+	{"DROP", "DROP TABLE t", true, false},
+	{"TRUNCATE", "TRUNCATE t", true, false},
+	{"COPY FROM", "COPY t FROM STDIN", true, false},
+	{"COPY TO", "COPY t TO STDOUT", false, false},
+	{"CREATE FUNCTION/PROCEDURE", "CREATE FUNCTION f() RETURNS int LANGUAGE sql AS 'SELECT 1'", true, false},
+	{"CREATE TRIGGER", "CREATE TRIGGER tr AFTER INSERT ON t FOR EACH ROW EXECUTE FUNCTION f()", true, false},
+	{"CREATE RULE", "CREATE RULE r AS ON INSERT TO t DO INSTEAD NOTHING", true, false},
+	{"CREATE/ALTER EXTENSION", "CREATE EXTENSION e", true, false},
+	{"SET/RESET", "SET work_mem = '64MB'", false, false},
+	{"SET LOCAL", "SET LOCAL work_mem = '64MB'", false, false},
+	{"PREPARE/EXECUTE/DEALLOCATE", "PREPARE p AS SELECT 1", false, false},
+	{"DISCARD PLANS/SEQUENCES/TEMP", "DISCARD TEMP", false, false},
+	{"DISCARD ALL", "DISCARD ALL", false, true},
+	{"GRANT/REVOKE", "GRANT SELECT ON t TO r", true, false},
+	{"CREATE/ALTER/DROP ROLE", "CREATE ROLE r", true, false},
+	{"ALTER SYSTEM", "ALTER SYSTEM SET work_mem = '64MB'", false, true},
+	{"VACUUM", "VACUUM t", false, true},
+	{"ANALYZE/CLUSTER/REINDEX/REFRESH MATERIALIZED VIEW", "ANALYZE t", false, false},
+	{"DO blocks", "DO $$ BEGIN END $$", false, false},
+	{"LISTEN/NOTIFY", "LISTEN c", false, false},
+	{"LOCK TABLE", "LOCK TABLE t", true, false},
+	{"COMMENT ON", "COMMENT ON TABLE t IS 'x'", true, false},
+	{"EXPLAIN ANALYZE", "EXPLAIN ANALYZE SELECT 1", false, false},
+	{"regex operators (~, ~*, !~, !~*, regexp_*)", "SELECT 1 WHERE 'a' ~ 'a'", false, false},
+	{"SIMILAR TO and trigram similarity", "SELECT 1 WHERE 'a' SIMILAR TO 'a'", false, false},
+	{"SQL comments", "SELECT 1 -- comment", false, false},
+}
+
+// Capabilities reports which operations the protection rules allow and block, whether the
+// server is read-only, and the query limits, so an agent can avoid writing statements that
+// will be rejected. Hooks can still reject queries the report lists as allowed.
+func (p *PostgresMcp) Capabilities(ctx context.Context) *CapabilitiesOutput {
+	output := &CapabilitiesOutput{
+		ReadOnly: p.config.ReadOnly,
+		Allowed:  []string{},
+		Blocked:  []string{},
+		Limits: CapabilityLimits{
+			MaxSQLLength:          p.config.Query.MaxSQLLength,
+			MaxSQLLengthUnit:      MaxSQLLengthBytes,
+			MaxResultLength:       p.config.Query.MaxResultLength,
+			AutoLimit:             p.config.Query.AutoLimit,
+			MaxRowsAffected:       p.config.Query.MaxRowsAffected,
+			DefaultTimeoutSeconds: p.config.Query.DefaultTimeoutSeconds,
+			MaxInListItems:        p.config.Protection.MaxInListItems,
+			MaxValuesRows:         p.config.Protection.MaxValuesRows,
+		},
+		AllowedExtensions: p.config.Protection.AllowedExtensions,
+	}
+	if p.config.Query.MaxSQLLengthUnit == MaxSQLLengthRunes {
+		output.Limits.MaxSQLLengthUnit = MaxSQLLengthRunes
+	}
+
+	for _, probe := range capabilityProbes {
+		sql := probe.sql
+		if probe.operation == "CREATE/ALTER EXTENSION" && len(p.config.Protection.AllowedExtensions) > 0 {
+			sql = "CREATE EXTENSION " + quoteIdent(p.config.Protection.AllowedExtensions[0])
+		}
+		if !probe.noTx && (!probe.write || !p.config.ReadOnly) && p.protection.Check(sql) == nil {
+			output.Allowed = append(output.Allowed, probe.operation)
+		} else {
+			output.Blocked = append(output.Blocked, probe.operation)
+		}
+	}
+	// These need the catalog at query time, so they are reported from the config.
+	if p.config.Protection.BlockSecurityDefinerCalls {
+		output.Blocked = append(output.Blocked, "SECURITY DEFINER function calls")
+	}
+	if p.config.Protection.BlockVolatileInReadOnly && p.config.ReadOnly {
+		output.Blocked = append(output.Blocked, "volatile function calls")
+	}
+	return output
+}
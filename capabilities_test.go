@@ -0,0 +1,125 @@
+package pgmcp
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/rickchristie/postgres-mcp/internal/protection"
+)
+
+// newCapabilitiesTestInstance builds just enough of a PostgresMcp for Capabilities, which
+// never touches the database.
+func newCapabilitiesTestInstance(config Config) *PostgresMcp {
+	protectionConfig := mapProtectionConfig(config.Protection)
+	protectionConfig.ReadOnly = config.ReadOnly
+	protectionConfig.BlockExplainAnalyze = config.Query.BlockExplainAnalyze
+	return &PostgresMcp{config: config, protection: protection.NewChecker(protectionConfig)}
+}
+
+func TestCapabilities_MatchesProtectionConfig(t *testing.T) {
+	t.Parallel()
+	p := newCapabilitiesTestInstance(Config{
+		Protection: ProtectionConfig{
+			AllowDDL:                  true,
+			AllowMerge:                true,
+			AllowSetLocal:             true,
+			BlockRegexOperators:       true,
+			BlockSecurityDefinerCalls: true,
+			MaxInListItems:            50,
+		},
+		Query: QueryConfig{
+			DefaultTimeoutSeconds: 30,
+			MaxSQLLength:          1000,
+			MaxSQLLengthUnit:      MaxSQLLengthRunes,
+			MaxResultLength:       5000,
+			AutoLimit:             200,
+			BlockExplainAnalyze:   true,
+		},
+	})
+	out := p.Capabilities(context.Background())
+
+	if out.ReadOnly {
+		t.Fatal("expected read_only false")
+	}
+	for _, op := range []string{"SELECT", "INSERT/UPDATE/DELETE", "INSERT ... ON CONFLICT", "CREATE/ALTER (DDL)", "MERGE", "SET LOCAL", "SIMILAR TO and trigram similarity"} {
+		if !slices.Contains(out.Allowed, op) {
+			t.Errorf("expected %q allowed, got allowed=%v", op, out.Allowed)
+		}
+	}
+	for _, op := range []string{"DROP", "TRUNCATE", "DELETE without WHERE", "MERGE ... THEN DELETE", "SET/RESET", "EXPLAIN ANALYZE", "regex operators (~, ~*, !~, !~*, regexp_*)", "SECURITY DEFINER function calls"} {
+		if !slices.Contains(out.Blocked, op) {
+			t.Errorf("expected %q blocked, got blocked=%v", op, out.Blocked)
+		}
+	}
+	if len(out.Allowed)+len(out.Blocked) != len(capabilityProbes)+1 {
+		t.Errorf("expected every operation reported once, got %d allowed and %d blocked", len(out.Allowed), len(out.Blocked))
+	}
+	want := CapabilityLimits{
+		MaxSQLLength:          1000,
+		MaxSQLLengthUnit:      MaxSQLLengthRunes,
+		MaxResultLength:       5000,
+		AutoLimit:             200,
+		DefaultTimeoutSeconds: 30,
+		MaxInListItems:        50,
+	}
+	if out.Limits != want {
+		t.Errorf("expected limits %+v, got %+v", want, out.Limits)
+	}
+}
+
+func TestCapabilities_ReadOnly(t *testing.T) {
+	t.Parallel()
+	p := newCapabilitiesTestInstance(Config{
+		ReadOnly:   true,
+		Protection: ProtectionConfig{AllowDDL: true, AllowTempTables: true},
+	})
+	out := p.Capabilities(context.Background())
+
+	if !out.ReadOnly {
+		t.Fatal("expected read_only true")
+	}
+	for _, op := range []string{"INSERT/UPDATE/DELETE", "CREATE/ALTER (DDL)"} {
+		if !slices.Contains(out.Blocked, op) {
+			t.Errorf("expected %q blocked in read-only mode, got blocked=%v", op, out.Blocked)
+		}
+	}
+	for _, op := range []string{"SELECT", "CREATE TEMP TABLE"} {
+		if !slices.Contains(out.Allowed, op) {
+			t.Errorf("expected %q allowed in read-only mode, got allowed=%v", op, out.Allowed)
+		}
+	}
+}
+
+func TestCapabilities_StatementsRefusedInTransactions(t *testing.T) {
+	t.Parallel()
+	p := newCapabilitiesTestInstance(Config{
+		Protection: ProtectionConfig{AllowAlterSystem: true, AllowMaintenance: true, AllowDiscard: true},
+	})
+	out := p.Capabilities(context.Background())
+	// Protection accepts them, but every query runs in a transaction, where Postgres refuses them.
+	for _, op := range []string{"ALTER SYSTEM", "VACUUM", "DISCARD ALL"} {
+		if !slices.Contains(out.Blocked, op) {
+			t.Errorf("expected %q blocked, got blocked=%v", op, out.Blocked)
+		}
+	}
+	for _, op := range []string{"ANALYZE/CLUSTER/REINDEX/REFRESH MATERIALIZED VIEW", "DISCARD PLANS/SEQUENCES/TEMP"} {
+		if !slices.Contains(out.Allowed, op) {
+			t.Errorf("expected %q allowed, got allowed=%v", op, out.Allowed)
+		}
+	}
+}
+
+func TestCapabilities_AllowedExtensions(t *testing.T) {
+	t.Parallel()
+	p := newCapabilitiesTestInstance(Config{
+		Protection: ProtectionConfig{AllowCreateExtension: true, AllowedExtensions: []string{"pg_trgm"}},
+	})
+	out := p.Capabilities(context.Background())
+	if !slices.Contains(out.Allowed, "CREATE/ALTER EXTENSION") {
+		t.Fatalf("expected CREATE/ALTER EXTENSION allowed, got blocked=%v", out.Blocked)
+	}
+	if !slices.Equal(out.AllowedExtensions, []string{"pg_trgm"}) {
+		t.Fatalf("expected allowed_extensions [pg_trgm], got %v", out.AllowedExtensions)
+	}
+}
@@ -0,0 +1,15 @@
+package pgmcp
+
+import "github.com/rickchristie/postgres-mcp/internal/protection"
+
+// CheckSQL validates sql against the protection rules in cfg without a database
+// connection, using the same checker as Query. It returns nil if the statement is
+// allowed, or the same error Query would report (before error prompts are appended).
+//
+// Config.ReadOnly, Config.SessionRole, and Query.BlockExplainAnalyze are not part of
+// ProtectionConfig, so the read-only, session-role, and EXPLAIN ANALYZE checks do not apply here.
+// ProtectionConfig.BlockSecurityDefinerCalls and BlockVolatileInReadOnly need the catalog, so
+// they are not checked either.
+func CheckSQL(sql string, cfg ProtectionConfig) error {
+	return protection.NewChecker(mapProtectionConfig(cfg)).Check(sql)
+}
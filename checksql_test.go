@@ -0,0 +1,92 @@
+package pgmcp_test
+
+import (
+	"strings"
+	"testing"
+
+	pgmcp "github.com/rickchristie/postgres-mcp"
+)
+
+func TestCheckSQL_AllowsSelect(t *testing.T) {
+	t.Parallel()
+	if err := pgmcp.CheckSQL("SELECT id, name FROM users WHERE id = 1", pgmcp.ProtectionConfig{}); err != nil {
+		t.Fatalf("expected SELECT to be allowed, got %v", err)
+	}
+}
+
+func TestCheckSQL_BlocksDropByDefault(t *testing.T) {
+	t.Parallel()
+	err := pgmcp.CheckSQL("DROP TABLE users", pgmcp.ProtectionConfig{})
+	if err == nil || err.Error() != "DROP statements are not allowed: permanently removes database objects and their data" {
+		t.Fatalf("expected DROP to be blocked, got %v", err)
+	}
+}
+
+func TestCheckSQL_AllowDropConfig(t *testing.T) {
+	t.Parallel()
+	if err := pgmcp.CheckSQL("DROP TABLE users", pgmcp.ProtectionConfig{AllowDrop: true}); err != nil {
+		t.Fatalf("expected DROP to be allowed with AllowDrop, got %v", err)
+	}
+}
+
+func TestCheckSQL_BlocksDeleteWithoutWhere(t *testing.T) {
+	t.Parallel()
+	err := pgmcp.CheckSQL("DELETE FROM users", pgmcp.ProtectionConfig{})
+	if err == nil || err.Error() != "DELETE without WHERE clause is not allowed: deletes every row of the table" {
+		t.Fatalf("expected DELETE without WHERE to be blocked, got %v", err)
+	}
+}
+
+func TestCheckSQL_BlocksMultiStatement(t *testing.T) {
+	t.Parallel()
+	err := pgmcp.CheckSQL("SELECT 1; SELECT 2", pgmcp.ProtectionConfig{})
+	if err == nil || err.Error() != "multi-statement queries are not allowed: found 2 statements" {
+		t.Fatalf("expected multi-statement to be blocked, got %v", err)
+	}
+}
+
+func TestCheckSQL_BlocksDeleteInsideCTE(t *testing.T) {
+	t.Parallel()
+	// Data-modifying CTEs are checked recursively, same as in Query.
+	err := pgmcp.CheckSQL("WITH d AS (DELETE FROM users RETURNING *) SELECT * FROM d", pgmcp.ProtectionConfig{})
+	if err == nil || err.Error() != "DELETE without WHERE clause is not allowed: deletes every row of the table" {
+		t.Fatalf("expected DELETE in CTE to be blocked, got %v", err)
+	}
+}
+
+func TestCheckSQL_ParseError(t *testing.T) {
+	t.Parallel()
+	err := pgmcp.CheckSQL("SELEC 1", pgmcp.ProtectionConfig{})
+	if err == nil || !strings.HasPrefix(err.Error(), "SQL parse error:") {
+		t.Fatalf("expected parse error, got %v", err)
+	}
+}
+
+func TestCheckSQL_AllowUpsertDefaultsToTrue(t *testing.T) {
+	t.Parallel()
+	upsert := "INSERT INTO users (id, name) VALUES (1, 'a') ON CONFLICT (id) DO UPDATE SET name = 'a'"
+	if err := pgmcp.CheckSQL(upsert, pgmcp.ProtectionConfig{}); err != nil {
+		t.Fatalf("expected upsert to be allowed when allow_upsert is unset, got %v", err)
+	}
+
+	allow := false
+	err := pgmcp.CheckSQL(upsert, pgmcp.ProtectionConfig{AllowUpsert: &allow})
+	if err == nil || !strings.Contains(err.Error(), "ON CONFLICT DO UPDATE is not allowed") {
+		t.Fatalf("expected upsert to be blocked with allow_upsert false, got %v", err)
+	}
+	if err := pgmcp.CheckSQL("INSERT INTO users (id, name) VALUES (1, 'a')", pgmcp.ProtectionConfig{AllowUpsert: &allow}); err != nil {
+		t.Fatalf("expected plain INSERT to be allowed, got %v", err)
+	}
+}
+
+func TestCheckSQL_BlockComments(t *testing.T) {
+	t.Parallel()
+	config := pgmcp.ProtectionConfig{BlockComments: true}
+	err := pgmcp.CheckSQL("SELECT * FROM users -- WHERE admin = true", config)
+	if err == nil || err.Error() != "SQL comments are not allowed: comments can hide intent from reviewers and log readers; remove the comment at offset 20 and retry" {
+		t.Fatalf("expected comment to be blocked, got %v", err)
+	}
+	if err := pgmcp.CheckSQL("SELECT '--' AS dashes", config); err != nil {
+		t.Fatalf("expected -- inside a literal to be allowed, got %v", err)
+	}
+}
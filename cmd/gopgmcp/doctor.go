@@ -1,17 +1,23 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"regexp"
+	"time"
 
 	pgmcp "github.com/rickchristie/postgres-mcp"
 	"github.com/rickchristie/postgres-mcp/internal/meta"
+	"github.com/rs/zerolog"
 )
 
+// doctorDatabaseTimeout bounds the database checks, so an unreachable server does not
+// hang doctor.
+const doctorDatabaseTimeout = 10 * time.Second
+
 func runDoctor() error {
 	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
 	configPath := fs.String("config", ".gopgmcp/config.json", "Path to configuration file")
@@ -33,6 +39,13 @@ func doctor(w io.Writer, useColor bool, configPath string) error {
 		return nil
 	}
 
+	// Check the database when it can be reached without prompting for credentials
+	if connString := os.Getenv("GOPGMCP_PG_CONNSTRING"); connString != "" {
+		doctorCheckDatabase(w, useColor, config, connString)
+	} else {
+		printWarn(w, useColor, "database checks skipped: set GOPGMCP_PG_CONNSTRING to also check the connection and pool.max_conns against max_connections")
+	}
+
 	// Print agent connection snippets
 	fmt.Fprintln(w)
 	printAgentSnippets(w, useColor, config)
@@ -53,8 +66,8 @@ func doctorValidateConfig(w io.Writer, useColor bool, configPath string) (*pgmcp
 	}
 	printCheck(w, useColor, true, fmt.Sprintf("Config file readable (%s)", configPath))
 
-	var config pgmcp.ServerConfig
-	if err := json.Unmarshal(data, &config); err != nil {
+	config, err := pgmcp.ParseServerConfig(data)
+	if err != nil {
 		printCheck(w, useColor, false, fmt.Sprintf("Config file is valid JSON: %v", err))
 		allPassed = false
 		return nil, allPassed
@@ -69,6 +82,25 @@ func doctorValidateConfig(w io.Writer, useColor bool, configPath string) (*pgmcp
 		printCheck(w, useColor, true, fmt.Sprintf("connection.dbname is set (%s)", config.Connection.DBName))
 	}
 
+	// Check 2b: connection.host and connection.socket are mutually exclusive
+	if config.Connection.Host != "" && config.Connection.Socket != "" {
+		printCheck(w, useColor, false, "connection.host and connection.socket are not both set")
+		allPassed = false
+	}
+
+	// Check 2c: TLS certificate files exist and parse
+	if config.Connection.SSLCert != "" || config.Connection.SSLKey != "" || config.Connection.SSLRootCert != "" {
+		if err := validateTLSFiles(config.Connection); err != nil {
+			printCheck(w, useColor, false, fmt.Sprintf("connection TLS files are valid: %v", err))
+			allPassed = false
+		} else {
+			printCheck(w, useColor, true, "connection TLS files are valid")
+		}
+	}
+	if isVerifySSLMode(config.Connection.SSLMode) && config.Connection.SSLRootCert == "" {
+		printWarn(w, useColor, fmt.Sprintf("connection.sslmode is %s but connection.sslrootcert is not set (server certificate will be verified against system roots)", config.Connection.SSLMode))
+	}
+
 	// Check 3: server.port > 0
 	if config.Server.Port <= 0 {
 		printCheck(w, useColor, false, "server.port is > 0")
@@ -134,7 +166,38 @@ func doctorValidateConfig(w io.Writer, useColor bool, configPath string) (*pgmcp
 		printCheck(w, useColor, true, "All regex patterns compile")
 	}
 
-	return &config, allPassed
+	return config, allPassed
+}
+
+// doctorCheckDatabase connects with connString and compares pool.max_conns with the
+// server's max_connections (unless pool.max_connections_check is "off"). A problem fails
+// the check in "error" mode and is a warning otherwise, as at startup.
+func doctorCheckDatabase(w io.Writer, useColor bool, config *pgmcp.ServerConfig, connString string) {
+	ctx, cancel := context.WithTimeout(context.Background(), doctorDatabaseTimeout)
+	defer cancel()
+	pgMcp, err := pgmcp.New(ctx, connString, config.Config, zerolog.Nop())
+	if err != nil {
+		printCheck(w, useColor, false, fmt.Sprintf("Database connection config is valid: %v", err))
+		return
+	}
+	defer pgMcp.Close(ctx)
+	if err := pgMcp.Ping(ctx); err != nil {
+		printCheck(w, useColor, false, fmt.Sprintf("Database is reachable: %v", err))
+		return
+	}
+	printCheck(w, useColor, true, "Database is reachable")
+
+	if config.Pool.MaxConnectionsCheck == pgmcp.MaxConnectionsCheckOff {
+		return
+	}
+	switch err := pgMcp.CheckMaxConnections(ctx); {
+	case err == nil:
+		printCheck(w, useColor, true, fmt.Sprintf("pool.max_conns (%d) fits the server's max_connections", config.Pool.MaxConns))
+	case config.Pool.MaxConnectionsCheck == pgmcp.MaxConnectionsCheckError:
+		printCheck(w, useColor, false, err.Error())
+	default:
+		printWarn(w, useColor, err.Error())
+	}
 }
 
 // printCheck prints a colored ✓ or ✗ check line.
@@ -154,6 +217,15 @@ func printCheck(w io.Writer, useColor bool, pass bool, msg string) {
 	}
 }
 
+// printWarn prints a colored ! warning line. Warnings do not fail the doctor run.
+func printWarn(w io.Writer, useColor bool, msg string) {
+	if useColor {
+		fmt.Fprintf(w, "  \033[33m!\033[0m %s\n", msg)
+	} else {
+		fmt.Fprintf(w, "  ! %s\n", msg)
+	}
+}
+
 // printAgentSnippets prints MCP connection config snippets for various AI agents.
 func printAgentSnippets(w io.Writer, useColor bool, config *pgmcp.ServerConfig) {
 	port := config.Server.Port
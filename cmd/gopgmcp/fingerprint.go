@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	pgmcp "github.com/rickchristie/postgres-mcp"
+)
+
+// runFingerprint prints the fingerprint of a query for protection.allowed_query_fingerprints.
+// The query is the remaining arguments, or stdin when there are none.
+func runFingerprint() error {
+	return printFingerprint(os.Args[2:], os.Stdin, os.Stdout)
+}
+
+func printFingerprint(args []string, stdin io.Reader, w io.Writer) error {
+	sql := strings.Join(args, " ")
+	if len(args) == 0 {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read query from stdin: %w", err)
+		}
+		sql = string(data)
+	}
+	if strings.TrimSpace(sql) == "" {
+		return fmt.Errorf("no query given: pass it as an argument or on stdin")
+	}
+	fp, err := pgmcp.QueryFingerprint(sql)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, fp)
+	return nil
+}
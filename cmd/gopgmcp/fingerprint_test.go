@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintFingerprint(t *testing.T) {
+	t.Parallel()
+	var fromArgs, fromStdin bytes.Buffer
+	if err := printFingerprint([]string{"SELECT * FROM users", "WHERE id = 1"}, strings.NewReader(""), &fromArgs); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := printFingerprint(nil, strings.NewReader("select * from users where id = $1\n"), &fromStdin); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(strings.TrimSpace(fromArgs.String())) != 16 || fromArgs.String() != fromStdin.String() {
+		t.Fatalf("expected the same 16-digit fingerprint, got %q and %q", fromArgs.String(), fromStdin.String())
+	}
+
+	if err := printFingerprint(nil, strings.NewReader("  \n"), &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "no query given") {
+		t.Fatalf("expected missing query error, got %v", err)
+	}
+	if err := printFingerprint([]string{"SELEC 1"}, strings.NewReader(""), &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "SQL parse error") {
+		t.Fatalf("expected parse error, got %v", err)
+	}
+}
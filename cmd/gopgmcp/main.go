@@ -29,6 +29,11 @@ func main() {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+	case "fingerprint":
+		if err := runFingerprint(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 	case "--version", "-v", "version":
 		fmt.Printf("gopgmcp %s\n", meta.Version)
 	case "--help", "-h", "help":
@@ -47,6 +52,7 @@ func printUsage() {
 	fmt.Println("  gopgmcp serve       Start the MCP server")
 	fmt.Println("  gopgmcp configure   Run interactive configuration wizard")
 	fmt.Println("  gopgmcp doctor      Validate config and show agent connection snippets")
+	fmt.Println("  gopgmcp fingerprint Print a query's fingerprint for protection.allowed_query_fingerprints")
 	fmt.Println("  gopgmcp --version   Show version")
 	fmt.Println("  gopgmcp --help      Show this help message")
 }
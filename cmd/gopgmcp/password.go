@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// passwordCommandTimeout bounds how long a "command:" password or token source may run.
+const passwordCommandTimeout = 30 * time.Second
+
+// resolvePassword fetches the database password from a connection.password_source:
+//
+//	env:VAR_NAME      value of the environment variable
+//	file:/path        file contents (trailing newline stripped)
+//	command:tool args stdout of the command (trailing newline stripped)
+//
+// Commands are executed directly with whitespace-separated arguments — no shell.
+func resolvePassword(ctx context.Context, source string) (string, error) {
+	return resolveSecret(ctx, "connection.password_source", "password", source)
+}
+
+// resolveSecret fetches a secret from a source in the resolvePassword format. setting (the
+// config field) and what (the kind of secret) are used in error messages.
+func resolveSecret(ctx context.Context, setting, what, source string) (string, error) {
+	kind, value, ok := strings.Cut(source, ":")
+	if !ok || value == "" {
+		return "", fmt.Errorf("invalid %s %q: expected env:VAR_NAME, file:/path, or command:tool", setting, source)
+	}
+
+	switch kind {
+	case "env":
+		secret, ok := os.LookupEnv(value)
+		if !ok {
+			return "", fmt.Errorf("%s: environment variable %s is not set", setting, value)
+		}
+		return secret, nil
+
+	case "file":
+		data, err := os.ReadFile(value)
+		if err != nil {
+			return "", fmt.Errorf("%s: failed to read %s file: %w", setting, what, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+
+	case "command":
+		args := strings.Fields(value)
+		if len(args) == 0 {
+			return "", fmt.Errorf("%s: command is empty", setting)
+		}
+		ctx, cancel := context.WithTimeout(ctx, passwordCommandTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return "", fmt.Errorf("%s: command timed out after %s: %s", setting, passwordCommandTimeout, args[0])
+			}
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return "", fmt.Errorf("%s: command failed (%s): %w: %s", setting, args[0], err, msg)
+			}
+			return "", fmt.Errorf("%s: command failed (%s): %w", setting, args[0], err)
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+
+	default:
+		return "", fmt.Errorf("invalid %s %q: unknown source %q (expected env, file, or command)", setting, source, kind)
+	}
+}
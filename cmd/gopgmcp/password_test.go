@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolvePassword_Env(t *testing.T) {
+	t.Setenv("GOPGMCP_TEST_DB_PASSWORD", "s3cret")
+	got, err := resolvePassword(context.Background(), "env:GOPGMCP_TEST_DB_PASSWORD")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "s3cret" {
+		t.Fatalf("expected %q, got %q", "s3cret", got)
+	}
+}
+
+func TestResolvePassword_EnvUnset(t *testing.T) {
+	t.Parallel()
+	_, err := resolvePassword(context.Background(), "env:GOPGMCP_TEST_DEFINITELY_UNSET")
+	expected := "connection.password_source: environment variable GOPGMCP_TEST_DEFINITELY_UNSET is not set"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestResolvePassword_File(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "pgpass")
+	if err := os.WriteFile(path, []byte("from file\n"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	got, err := resolvePassword(context.Background(), "file:"+path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "from file" {
+		t.Fatalf("expected %q, got %q", "from file", got)
+	}
+}
+
+func TestResolvePassword_FileMissing(t *testing.T) {
+	t.Parallel()
+	_, err := resolvePassword(context.Background(), "file:"+filepath.Join(t.TempDir(), "missing"))
+	if err == nil || !strings.HasPrefix(err.Error(), "connection.password_source: failed to read password file:") {
+		t.Fatalf("expected file read error, got %v", err)
+	}
+}
+
+func TestResolvePassword_Command(t *testing.T) {
+	t.Parallel()
+	got, err := resolvePassword(context.Background(), "command:echo from-command")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "from-command" {
+		t.Fatalf("expected %q, got %q", "from-command", got)
+	}
+}
+
+func TestResolvePassword_CommandFails(t *testing.T) {
+	t.Parallel()
+	_, err := resolvePassword(context.Background(), "command:sh -c exit_7_not_a_command")
+	if err == nil || !strings.HasPrefix(err.Error(), "connection.password_source: command failed (sh):") {
+		t.Fatalf("expected command failure error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "exit_7_not_a_command") {
+		t.Fatalf("expected stderr in error, got %v", err)
+	}
+}
+
+func TestResolvePassword_CommandNotFound(t *testing.T) {
+	t.Parallel()
+	_, err := resolvePassword(context.Background(), "command:/nonexistent/secret-tool get db")
+	if err == nil || !strings.HasPrefix(err.Error(), "connection.password_source: command failed (/nonexistent/secret-tool):") {
+		t.Fatalf("expected command failure error, got %v", err)
+	}
+}
+
+func TestResolvePassword_CommandEmpty(t *testing.T) {
+	t.Parallel()
+	_, err := resolvePassword(context.Background(), "command:   ")
+	expected := "connection.password_source: command is empty"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestResolvePassword_InvalidSource(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"hunter2", `invalid connection.password_source "hunter2": expected env:VAR_NAME, file:/path, or command:tool`},
+		{"env:", `invalid connection.password_source "env:": expected env:VAR_NAME, file:/path, or command:tool`},
+		{"vault:db/pass", `invalid connection.password_source "vault:db/pass": unknown source "vault" (expected env, file, or command)`},
+	}
+	for _, tt := range tests {
+		_, err := resolvePassword(context.Background(), tt.source)
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("source %q: expected %q, got %v", tt.source, tt.expected, err)
+		}
+	}
+}
+
+func TestResolveSecret_NamesSetting(t *testing.T) {
+	t.Parallel()
+	_, err := resolveSecret(context.Background(), "server.admin_token_source", "token", "file:"+filepath.Join(t.TempDir(), "missing"))
+	if err == nil || !strings.HasPrefix(err.Error(), "server.admin_token_source: failed to read token file:") {
+		t.Fatalf("expected file read error, got %v", err)
+	}
+}
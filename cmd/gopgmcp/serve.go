@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	pgmcp "github.com/rickchristie/postgres-mcp"
 
@@ -29,13 +33,47 @@ func runServe() error {
 	if serverConfig.Server.Port <= 0 {
 		panic("gopgmcp: server.port must be > 0")
 	}
+	if serverConfig.Connection.Host != "" && serverConfig.Connection.Socket != "" {
+		panic("gopgmcp: connection.host and connection.socket are mutually exclusive")
+	}
+	if serverConfig.Server.ShutdownTimeout != "" {
+		if _, err := time.ParseDuration(serverConfig.Server.ShutdownTimeout); err != nil {
+			panic(fmt.Sprintf("gopgmcp: invalid server.shutdown_timeout %q: %v", serverConfig.Server.ShutdownTimeout, err))
+		}
+	}
 
 	// 2. Resolve connection string
 	connString := os.Getenv("GOPGMCP_PG_CONNSTRING")
 	if connString == "" {
+		if err := validateTLSFiles(serverConfig.Connection); err != nil {
+			return fmt.Errorf("invalid connection TLS config: %w", err)
+		}
+		// password_source supplies only the password; the username is always prompted.
 		username := promptInput("Username: ")
-		password := promptPassword("Password: ")
-		connString = buildConnString(serverConfig.Connection, username, password)
+		var password string
+		if serverConfig.Connection.PasswordSource != "" {
+			password, err = resolvePassword(ctx, serverConfig.Connection.PasswordSource)
+			if err != nil {
+				return fmt.Errorf("failed to resolve database password: %w", err)
+			}
+		} else {
+			password = promptPassword("Password: ")
+		}
+		connString, err = serverConfig.Connection.DSNWithCredentials(username, password)
+		if err != nil {
+			return fmt.Errorf("invalid connection config: %w", err)
+		}
+	}
+
+	var adminToken string
+	if serverConfig.Server.AdminTokenSource != "" {
+		adminToken, err = resolveSecret(ctx, "server.admin_token_source", "token", serverConfig.Server.AdminTokenSource)
+		if err != nil {
+			return fmt.Errorf("failed to resolve admin token: %w", err)
+		}
+		if adminToken == "" {
+			return errors.New("failed to resolve admin token: server.admin_token_source resolved to an empty token")
+		}
 	}
 
 	// 3. Setup logger
@@ -52,11 +90,16 @@ func runServe() error {
 	}
 	defer pgMcp.Close(ctx)
 
-	// 5. Test database connection
-	logger.Info().Msg("testing database connection")
-	if err := pgMcp.Ping(ctx); err != nil {
-		logger.Error().Err(err).Msg("database connection test failed")
-		return fmt.Errorf("database connection test failed: %w", err)
+	// 5. Wait for the database to accept connections (retries until startup probe timeout),
+	// then run the startup checks (timezone, startup_assertions, pool.max_connections_check)
+	probeTimeout := startupProbeTimeout(serverConfig.Pool)
+	logger.Info().Dur("timeout", probeTimeout).Msg("testing database connection")
+	probeCtx, cancelProbe := context.WithTimeout(ctx, probeTimeout)
+	err = pgMcp.WaitReady(probeCtx)
+	cancelProbe()
+	if err != nil {
+		logger.Error().Err(err).Msg("database startup check failed")
+		return fmt.Errorf("database startup check failed: %w", err)
 	}
 	logger.Info().Msg("database connection test successful")
 
@@ -93,6 +136,11 @@ func runServe() error {
 		})
 	}
 
+	// Admin endpoint to cancel every in-flight query, only with a token configured
+	if adminToken != "" {
+		mux.Handle(adminCancelAllPath, cancelAllHandler(pgMcp, adminToken, logger))
+	}
+
 	httpSrv := &http.Server{
 		Addr:    addr,
 		Handler: mux,
@@ -110,7 +158,62 @@ func runServe() error {
 	mux.Handle("/mcp", streamableServer)
 
 	logger.Info().Int("port", serverConfig.Server.Port).Msg("starting gopgmcp server")
-	return streamableServer.Start(addr)
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- streamableServer.Start(addr) }()
+
+	// 7. On SIGINT/SIGTERM, drain in-flight queries, then stop the HTTP server.
+	sigCtx, stopSignals := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	select {
+	case err := <-serveErr:
+		return err
+	case <-sigCtx.Done():
+	}
+	stopSignals()
+	drainTimeout := shutdownTimeout(serverConfig.Server)
+	logger.Info().Dur("timeout", drainTimeout).Msg("shutdown signal received")
+	drainCtx, cancelDrain := context.WithTimeout(ctx, drainTimeout)
+	defer cancelDrain()
+	if err := pgMcp.Shutdown(drainCtx); err != nil {
+		logger.Warn().Err(err).Msg("in-flight queries were cancelled")
+	}
+	httpCtx, cancelHTTP := context.WithTimeout(ctx, 5*time.Second)
+	defer cancelHTTP()
+	if err := streamableServer.Shutdown(httpCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to shut down HTTP server: %w", err)
+	}
+	return nil
+}
+
+// adminCancelAllPath is the admin endpoint enabled by server.admin_token_source.
+const adminCancelAllPath = "/admin/cancel-all"
+
+// canceller is the part of *pgmcp.PostgresMcp the admin endpoint uses.
+type canceller interface {
+	CancelAll() int
+}
+
+// cancelAllHandler serves POST /admin/cancel-all: it cancels every in-flight query,
+// leaving the server running, and responds with the number cancelled. Requests must carry
+// "Authorization: Bearer <token>".
+func cancelAllHandler(c canceller, token string, logger zerolog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			logger.Warn().Str("remote_addr", r.RemoteAddr).Msg("rejected admin request: invalid token")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		cancelled := c.CancelAll()
+		logger.Warn().Str("remote_addr", r.RemoteAddr).Int("cancelled", cancelled).Msg("admin request: cancelled all in-flight queries")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"cancelled":%d}`, cancelled)
+	})
 }
 
 func loadServerConfig() (*pgmcp.ServerConfig, error) {
@@ -124,35 +227,44 @@ func loadServerConfig() (*pgmcp.ServerConfig, error) {
 		return nil, fmt.Errorf("failed to read config file %s: %w", configPath, err)
 	}
 
-	var config pgmcp.ServerConfig
-	if err := json.Unmarshal(data, &config); err != nil {
+	config, err := pgmcp.ParseServerConfig(data)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
-	return &config, nil
+	return config, nil
 }
 
-func buildConnString(conn pgmcp.ConnectionConfig, username, password string) string {
-	parts := []string{}
-	if conn.Host != "" {
-		parts = append(parts, fmt.Sprintf("host=%s", conn.Host))
-	}
-	if conn.Port > 0 {
-		parts = append(parts, fmt.Sprintf("port=%d", conn.Port))
-	}
-	if conn.DBName != "" {
-		parts = append(parts, fmt.Sprintf("dbname=%s", conn.DBName))
+// defaultStartupProbeTimeout is used when pool.startup_probe_timeout is not set.
+const defaultStartupProbeTimeout = 30 * time.Second
+
+// defaultShutdownTimeout is used when server.shutdown_timeout is not set.
+const defaultShutdownTimeout = 30 * time.Second
+
+// shutdownTimeout returns the configured shutdown drain timeout, or the default.
+// The value has already been validated by runServe.
+func shutdownTimeout(server pgmcp.ServerSettings) time.Duration {
+	if server.ShutdownTimeout == "" {
+		return defaultShutdownTimeout
 	}
-	if username != "" {
-		parts = append(parts, fmt.Sprintf("user=%s", username))
+	d, err := time.ParseDuration(server.ShutdownTimeout)
+	if err != nil {
+		return defaultShutdownTimeout
 	}
-	if password != "" {
-		parts = append(parts, fmt.Sprintf("password=%s", password))
+	return d
+}
+
+// startupProbeTimeout returns the configured startup probe timeout, or the default.
+// The value has already been validated by pgmcp.New().
+func startupProbeTimeout(pool pgmcp.PoolConfig) time.Duration {
+	if pool.StartupProbeTimeout == "" {
+		return defaultStartupProbeTimeout
 	}
-	if conn.SSLMode != "" {
-		parts = append(parts, fmt.Sprintf("sslmode=%s", conn.SSLMode))
+	d, err := time.ParseDuration(pool.StartupProbeTimeout)
+	if err != nil {
+		return defaultStartupProbeTimeout
 	}
-	return strings.Join(parts, " ")
+	return d
 }
 
 func setupLogger(config pgmcp.LoggingConfig) zerolog.Logger {
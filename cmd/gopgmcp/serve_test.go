@@ -2,12 +2,16 @@ package main
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	pgmcp "github.com/rickchristie/postgres-mcp"
+	"github.com/rs/zerolog"
 )
 
 // validServerConfig returns a minimal valid ServerConfig for testing.
@@ -239,3 +243,100 @@ func TestLoadConfigValidation_HealthCheckPathNotRequiredWhenDisabled(t *testing.
 		t.Fatal("expected health_check_enabled to be false")
 	}
 }
+
+func TestStartupProbeTimeout_Default(t *testing.T) {
+	t.Parallel()
+	got := startupProbeTimeout(pgmcp.PoolConfig{})
+	if got != 30*time.Second {
+		t.Fatalf("expected 30s, got %s", got)
+	}
+}
+
+func TestStartupProbeTimeout_Configured(t *testing.T) {
+	t.Parallel()
+	got := startupProbeTimeout(pgmcp.PoolConfig{StartupProbeTimeout: "2m30s"})
+	if got != 150*time.Second {
+		t.Fatalf("expected 2m30s, got %s", got)
+	}
+}
+
+func TestShutdownTimeout_Default(t *testing.T) {
+	t.Parallel()
+	got := shutdownTimeout(pgmcp.ServerSettings{})
+	if got != 30*time.Second {
+		t.Fatalf("expected 30s, got %s", got)
+	}
+}
+
+func TestShutdownTimeout_Configured(t *testing.T) {
+	t.Parallel()
+	got := shutdownTimeout(pgmcp.ServerSettings{ShutdownTimeout: "45s"})
+	if got != 45*time.Second {
+		t.Fatalf("expected 45s, got %s", got)
+	}
+}
+
+func TestRunServe_PanicsOnHostAndSocket(t *testing.T) {
+	dir := t.TempDir()
+	cfg := validServerConfig()
+	cfg.Connection.Socket = "/var/run/postgresql"
+	path := writeConfigFile(t, dir, cfg)
+
+	t.Setenv("GOPGMCP_CONFIG_PATH", path)
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("expected panic for host and socket both set, but no panic occurred")
+		}
+		msg, ok := r.(string)
+		if !ok {
+			t.Fatalf("expected string panic, got %T: %v", r, r)
+		}
+		if !strings.Contains(msg, "connection.host and connection.socket are mutually exclusive") {
+			t.Fatalf("expected panic about host/socket, got %q", msg)
+		}
+	}()
+
+	_ = runServe()
+}
+
+type fakeCanceller struct{ calls int }
+
+func (f *fakeCanceller) CancelAll() int {
+	f.calls++
+	return 2
+}
+
+func TestCancelAllHandler(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		method     string
+		auth       string
+		wantStatus int
+		wantBody   string
+		wantCalls  int
+	}{
+		{"valid token", http.MethodPost, "Bearer s3cret", http.StatusOK, `{"cancelled":2}`, 1},
+		{"missing token", http.MethodPost, "", http.StatusUnauthorized, "unauthorized\n", 0},
+		{"wrong token", http.MethodPost, "Bearer wrong", http.StatusUnauthorized, "unauthorized\n", 0},
+		{"not bearer", http.MethodPost, "s3cret", http.StatusUnauthorized, "unauthorized\n", 0},
+		{"wrong method", http.MethodGet, "Bearer s3cret", http.StatusMethodNotAllowed, "method not allowed\n", 0},
+	}
+	for _, tt := range tests {
+		c := &fakeCanceller{}
+		req := httptest.NewRequest(tt.method, adminCancelAllPath, nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rec := httptest.NewRecorder()
+		cancelAllHandler(c, "s3cret", zerolog.Nop()).ServeHTTP(rec, req)
+		if rec.Code != tt.wantStatus || rec.Body.String() != tt.wantBody {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.name, tt.wantStatus, tt.wantBody, rec.Code, rec.Body.String())
+		}
+		if c.calls != tt.wantCalls {
+			t.Errorf("%s: expected %d CancelAll calls, got %d", tt.name, tt.wantCalls, c.calls)
+		}
+	}
+}
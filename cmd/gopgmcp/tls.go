@@ -0,0 +1,38 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	pgmcp "github.com/rickchristie/postgres-mcp"
+)
+
+// validateTLSFiles checks that the configured client certificate, key, and root CA
+// files exist and parse, so misconfiguration fails before prompting for credentials.
+func validateTLSFiles(conn pgmcp.ConnectionConfig) error {
+	if (conn.SSLCert == "") != (conn.SSLKey == "") {
+		return fmt.Errorf("connection.sslcert and connection.sslkey must be set together")
+	}
+	if conn.SSLCert != "" {
+		if _, err := tls.LoadX509KeyPair(conn.SSLCert, conn.SSLKey); err != nil {
+			return fmt.Errorf("failed to load client certificate (connection.sslcert/sslkey): %w", err)
+		}
+	}
+	if conn.SSLRootCert != "" {
+		data, err := os.ReadFile(conn.SSLRootCert)
+		if err != nil {
+			return fmt.Errorf("failed to read connection.sslrootcert: %w", err)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(data) {
+			return fmt.Errorf("connection.sslrootcert %s contains no valid PEM certificates", conn.SSLRootCert)
+		}
+	}
+	return nil
+}
+
+// isVerifySSLMode reports whether sslmode verifies the server certificate against a root CA.
+func isVerifySSLMode(sslMode string) bool {
+	return sslMode == "verify-ca" || sslMode == "verify-full"
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	pgmcp "github.com/rickchristie/postgres-mcp"
+)
+
+// writeTestCerts writes a self-signed CA and a client cert/key signed by it to dir.
+func writeTestCerts(t *testing.T, dir string) (caPath, certPath, keyPath string) {
+	t.Helper()
+	writePEM := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create CA cert: %v", err)
+	}
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate client key: %v", err)
+	}
+	clientTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "agent"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTmpl, caTmpl, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create client cert: %v", err)
+	}
+	clientKeyDER, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatalf("failed to marshal client key: %v", err)
+	}
+
+	caPath = writePEM("root.crt", "CERTIFICATE", caDER)
+	certPath = writePEM("client.crt", "CERTIFICATE", clientDER)
+	keyPath = writePEM("client.key", "EC PRIVATE KEY", clientKeyDER)
+	return caPath, certPath, keyPath
+}
+
+func TestConnectionDSN_TLSClientCert(t *testing.T) {
+	t.Parallel()
+	caPath, certPath, keyPath := writeTestCerts(t, t.TempDir())
+	conn := pgmcp.ConnectionConfig{
+		Host:        "db.example.com",
+		Port:        5432,
+		DBName:      "mydb",
+		SSLMode:     "verify-full",
+		SSLCert:     certPath,
+		SSLKey:      keyPath,
+		SSLRootCert: caPath,
+	}
+
+	connString, err := conn.DSNWithCredentials("agent", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg, err := pgconn.ParseConfig(connString)
+	if err != nil {
+		t.Fatalf("failed to parse conn string %q: %v", connString, err)
+	}
+	if cfg.TLSConfig == nil {
+		t.Fatal("expected TLS config to be set")
+	}
+	if len(cfg.TLSConfig.Certificates) != 1 {
+		t.Fatalf("expected 1 client certificate, got %d", len(cfg.TLSConfig.Certificates))
+	}
+	if cfg.TLSConfig.RootCAs == nil {
+		t.Fatal("expected RootCAs from sslrootcert")
+	}
+	if cfg.TLSConfig.ServerName != "db.example.com" {
+		t.Fatalf("expected ServerName 'db.example.com' for verify-full, got %q", cfg.TLSConfig.ServerName)
+	}
+	if len(cfg.Fallbacks) != 0 {
+		t.Fatalf("expected no plaintext fallback for verify-full, got %d", len(cfg.Fallbacks))
+	}
+}
+
+func TestValidateTLSFiles_Valid(t *testing.T) {
+	t.Parallel()
+	caPath, certPath, keyPath := writeTestCerts(t, t.TempDir())
+	conn := pgmcp.ConnectionConfig{SSLCert: certPath, SSLKey: keyPath, SSLRootCert: caPath}
+	if err := validateTLSFiles(conn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateTLSFiles_NoneSet(t *testing.T) {
+	t.Parallel()
+	if err := validateTLSFiles(pgmcp.ConnectionConfig{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateTLSFiles_CertWithoutKey(t *testing.T) {
+	t.Parallel()
+	_, certPath, _ := writeTestCerts(t, t.TempDir())
+	err := validateTLSFiles(pgmcp.ConnectionConfig{SSLCert: certPath})
+	expected := "connection.sslcert and connection.sslkey must be set together"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestValidateTLSFiles_MissingCertFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	_, _, keyPath := writeTestCerts(t, dir)
+	err := validateTLSFiles(pgmcp.ConnectionConfig{SSLCert: filepath.Join(dir, "missing.crt"), SSLKey: keyPath})
+	if err == nil || !strings.Contains(err.Error(), "failed to load client certificate") {
+		t.Fatalf("expected client certificate load error, got %v", err)
+	}
+}
+
+func TestValidateTLSFiles_MismatchedKey(t *testing.T) {
+	t.Parallel()
+	_, certPath, _ := writeTestCerts(t, t.TempDir())
+	_, _, otherKeyPath := writeTestCerts(t, t.TempDir())
+	err := validateTLSFiles(pgmcp.ConnectionConfig{SSLCert: certPath, SSLKey: otherKeyPath})
+	if err == nil || !strings.Contains(err.Error(), "failed to load client certificate") {
+		t.Fatalf("expected client certificate load error, got %v", err)
+	}
+}
+
+func TestValidateTLSFiles_RootCertNotPEM(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "root.crt")
+	if err := os.WriteFile(path, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	err := validateTLSFiles(pgmcp.ConnectionConfig{SSLRootCert: path})
+	expected := "connection.sslrootcert " + path + " contains no valid PEM certificates"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestValidateTLSFiles_RootCertMissing(t *testing.T) {
+	t.Parallel()
+	err := validateTLSFiles(pgmcp.ConnectionConfig{SSLRootCert: filepath.Join(t.TempDir(), "missing.crt")})
+	if err == nil || !strings.Contains(err.Error(), "failed to read connection.sslrootcert") {
+		t.Fatalf("expected read error, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package pgmcp
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// maxArrayDims is Postgres's MAXDIM; larger header values are not array headers.
+const maxArrayDims = 6
+
+// pgTypeInfo is a pg_type row resolved for a result column.
+type pgTypeInfo struct {
+	name    string // typname, e.g. "int4", "_int4"
+	isArray bool   // typcategory 'A'
+	elem    string // element typname for arrays
+}
+
+// setColumnTypes resolves the type OIDs of fields via pg_type and sets result.ColumnTypes
+// (and result.ColumnTypeDetails when query.column_type_details is set). Array types are
+// named by element, e.g. "_int4" becomes "int4[]". dims holds the dimensions found in the
+// column values by collectRows; 0 means no non-empty array value was seen.
+func (p *PostgresMcp) setColumnTypes(ctx context.Context, tx pgx.Tx, result *QueryOutput, fields []pgconn.FieldDescription, dims []int) error {
+	oids := make([]uint32, len(fields))
+	for i, fd := range fields {
+		oids[i] = fd.DataTypeOID
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT t.oid, t.typname, t.typcategory = 'A', COALESCE(e.typname, '')
+		FROM pg_catalog.pg_type t
+		LEFT JOIN pg_catalog.pg_type e ON e.oid = t.typelem
+		WHERE t.oid = ANY($1)`, oids)
+	if err != nil {
+		return fmt.Errorf("failed to resolve column types: %w", err)
+	}
+	defer rows.Close()
+	types := make(map[uint32]pgTypeInfo, len(oids))
+	for rows.Next() {
+		var oid uint32
+		var info pgTypeInfo
+		if err := rows.Scan(&oid, &info.name, &info.isArray, &info.elem); err != nil {
+			return fmt.Errorf("failed to resolve column types: %w", err)
+		}
+		types[oid] = info
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to resolve column types: %w", err)
+	}
+
+	result.ColumnTypes = make([]string, len(fields))
+	if p.config.Query.ColumnTypeDetails {
+		result.ColumnTypeDetails = make([]ColumnType, len(fields))
+	}
+	for i, fd := range fields {
+		info, ok := types[fd.DataTypeOID]
+		if !ok {
+			info.name = fmt.Sprintf("oid:%d", fd.DataTypeOID)
+		}
+		ct := ColumnType{Name: info.name, Base: info.name}
+		if info.isArray {
+			ct = ColumnType{Name: info.elem + "[]", Base: info.elem, Dims: 1}
+			if i < len(dims) && dims[i] > 0 {
+				ct.Dims = dims[i]
+			}
+		}
+		result.ColumnTypes[i] = ct.Name
+		if result.ColumnTypeDetails != nil {
+			result.ColumnTypeDetails[i] = ct
+		}
+	}
+	return nil
+}
+
+// rawArrayDims returns the number of dimensions of a raw array value, or 0 for NULL, an
+// empty array, or a value that is not an array. pgx decodes multi-dimensional arrays
+// into flat slices, so dimensions are read from the wire format instead.
+func rawArrayDims(format int16, raw []byte) int {
+	if len(raw) == 0 {
+		return 0
+	}
+	if format == pgx.BinaryFormatCode {
+		if len(raw) < 4 {
+			return 0
+		}
+		ndim := int32(binary.BigEndian.Uint32(raw))
+		if ndim < 0 || ndim > maxArrayDims {
+			return 0
+		}
+		return int(ndim)
+	}
+	// Text format: optional "[1:2][1:3]=" bounds decoration, then one '{' per dimension.
+	i := 0
+	if raw[0] == '[' {
+		for i < len(raw) && raw[i] != '=' {
+			i++
+		}
+		i++
+	}
+	ndim := 0
+	for ; i < len(raw) && raw[i] == '{'; i++ {
+		ndim++
+	}
+	if ndim == 1 && i < len(raw) && raw[i] == '}' {
+		return 0 // "{}"
+	}
+	return ndim
+}
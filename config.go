@@ -7,6 +7,9 @@ import (
 
 // Config is the base configuration used by library mode via New().
 type Config struct {
+	// Preset names a bundle of protection flags applied before the rest of the config:
+	// PresetReadOnlyAnalytics, PresetReadWriteApp, or PresetAdmin (see ApplyPreset).
+	Preset                    string             `json:"preset"`
 	Pool                      PoolConfig         `json:"pool"`
 	Protection                ProtectionConfig   `json:"protection"`
 	Query                     QueryConfig        `json:"query"`
@@ -14,14 +17,103 @@ type Config struct {
 	Sanitization              []SanitizationRule `json:"sanitization"`
 	ReadOnly                  bool               `json:"read_only"`
 	Timezone                  string             `json:"timezone"`
+	SessionRole               string             `json:"session_role"`
 	DefaultHookTimeoutSeconds int                `json:"default_hook_timeout_seconds"`
+	// TimezoneFallback is what WaitReady (or, without it, the first tool call) does when the
+	// server does not recognize Timezone: TimezoneFallbackError (the default when empty)
+	// fails, TimezoneFallbackUTC uses UTC with a warning.
+	TimezoneFallback string `json:"timezone_fallback"`
+	// ErrorDetailMode is ErrorDetailFull (the default when empty) or ErrorDetailMinimal,
+	// which hides Postgres error messages from untrusted agents. Error prompts still apply.
+	ErrorDetailMode string `json:"error_detail_mode"`
+	// ValidateHookOutput verifies after AfterQuery hooks run that every row key
+	// is listed in Columns, rejecting the result (and rolling back writes) if not.
+	ValidateHookOutput bool `json:"validate_hook_output"`
+	// MaskColumns lists result columns whose values are replaced with "***" in every
+	// result (SELECT and RETURNING): "column" matches any column with that name,
+	// "table.column" only that table's column. Table columns match by source name,
+	// whatever their alias.
+	MaskColumns []string `json:"mask_columns"`
+	// MaxTotalHookSeconds caps the combined wall-clock time of all before and after
+	// hooks for a single query. Per-hook timeouts are clamped to the remaining budget.
+	// 0 means no aggregate limit.
+	MaxTotalHookSeconds int `json:"max_total_hook_seconds"`
+	// HookIdleInTransactionTimeoutSeconds is the idle_in_transaction_session_timeout set
+	// (via SET LOCAL) while AfterQuery hooks hold a write transaction open, so a hung hook
+	// cannot hold row locks indefinitely. 0 means use the query's resolved timeout.
+	HookIdleInTransactionTimeoutSeconds int `json:"hook_idle_in_transaction_timeout_seconds"`
+	// HookProtectionOrder is HookProtectionOrderHooksFirst (the default when empty), which
+	// checks protection only on the SQL returned by before-hooks, or
+	// HookProtectionOrderProtectionFirst, which also checks the original SQL before hooks run.
+	HookProtectionOrder string `json:"hook_protection_order"`
+	// StartupAssertions run once WaitReady reaches the database; WaitReady fails if any
+	// returns a value other than its Expect. Tool calls made without WaitReady run them
+	// first and fail while they fail. Use them to guard against connecting to the wrong
+	// database or role.
+	StartupAssertions []StartupAssertion `json:"startup_assertions"`
+	// StatementComment is a text/template rendered with the call's CallInfo and prepended
+	// to every executed statement as a /* ... */ comment, after protection checks, for
+	// attribution in pg_stat_activity and pg_stat_statements. E.g. "pgmcp agent={{.Agent}} req={{.RequestID}}".
+	StatementComment string `json:"statement_comment"`
+	// AuditExplain adds the EXPLAIN (FORMAT JSON) plan of each explainable statement to
+	// its AuditRecord. Costs one extra planning round trip per query; needs AuditSink.
+	AuditExplain bool `json:"audit_explain"`
+	// MaxConcurrentPerTenant caps concurrent Query calls per tenant (CallInfo.Tenant, or
+	// Agent when unset); calls over the cap fail immediately with a retryable error.
+	// Unattributed calls are only subject to pool.max_conns. 0 means no per-tenant limit.
+	MaxConcurrentPerTenant int `json:"max_concurrent_per_tenant"`
+	// SanitizationMaxScannedCells caps how many result cells (rows × columns) sanitization
+	// scans per query; larger results are handled per SanitizationOverLimit. 0 means no cap.
+	SanitizationMaxScannedCells int `json:"sanitization_max_scanned_cells"`
+	// SanitizationOverLimit is SanitizationOverLimitReject (the default when empty) or
+	// SanitizationOverLimitSkip.
+	SanitizationOverLimit string `json:"sanitization_over_limit"`
+	// SanitizationPresets enables built-in rule sets for common PII, appended after the
+	// Sanitization rules: SanitizationPresetCreditCard, SanitizationPresetSSN,
+	// SanitizationPresetUSPhone, and SanitizationPresetEmail. They are applied in that
+	// order whatever order they are listed in.
+	SanitizationPresets []string `json:"sanitization_presets"`
+	// CaseInsensitiveTableLookup makes DescribeTable retry a schema and table that do not
+	// exist as given ignoring case, using the match (and setting ResolvedName) when exactly
+	// one relation matches.
+	CaseInsensitiveTableLookup bool `json:"case_insensitive_table_lookup"`
+	// ExposeCapabilities registers the capabilities MCP tool, which reports what the
+	// protection rules allow and the query limits (see PostgresMcp.Capabilities).
+	ExposeCapabilities bool `json:"expose_capabilities"`
 
 	// Library mode: Go function hooks (not serializable).
 	// Mutually exclusive with ServerConfig.ServerHooks.
 	BeforeQueryHooks []BeforeQueryHookEntry `json:"-"`
 	AfterQueryHooks  []AfterQueryHookEntry  `json:"-"`
+
+	// AuditSink receives an AuditRecord after every Query call (library mode; see
+	// NewJSONLinesAuditSink for a file sink).
+	AuditSink AuditSink `json:"-"`
+
+	// TypeCodecs decode custom Postgres types in query results (library mode).
+	TypeCodecs []TypeCodec `json:"-"`
+
+	// ResultConverter replaces DefaultResultConverter for every top-level value in query
+	// results and DescribeTable sample rows (library mode). It receives values as decoded
+	// by pgx and must return JSON-marshalable ones. EXPLAIN output is never converted.
+	ResultConverter func(value interface{}) interface{} `json:"-"`
+
+	// presetApplied records that ApplyPreset has run, so New does not apply it again over
+	// flags the config file turned off.
+	presetApplied bool
 }
 
+// Config.Preset values.
+const (
+	// PresetReadOnlyAnalytics sets ReadOnly and leaves every Allow* rule blocked.
+	PresetReadOnlyAnalytics = "read-only-analytics"
+	// PresetReadWriteApp allows DML including MERGE, SET LOCAL, and temp tables, but not
+	// DDL, DROP/TRUNCATE, roles, grants, extensions, or functions.
+	PresetReadWriteApp = "read-write-app"
+	// PresetAdmin allows everything except ALTER SYSTEM and UPDATE/DELETE without WHERE.
+	PresetAdmin = "admin"
+)
+
 // ServerConfig embeds Config and adds server-only fields for CLI mode.
 type ServerConfig struct {
 	Config
@@ -37,6 +129,23 @@ type ConnectionConfig struct {
 	Port    int    `json:"port"`
 	DBName  string `json:"dbname"`
 	SSLMode string `json:"sslmode"`
+	// Socket connects over a Unix domain socket instead of TCP. Either the socket
+	// directory (e.g. "/var/run/postgresql") or the full socket file path
+	// (e.g. "/var/run/postgresql/.s.PGSQL.5432"). Mutually exclusive with Host.
+	Socket string `json:"socket"`
+	// SSLCert and SSLKey are paths to the PEM client certificate and private key
+	// for TLS client-certificate (mutual TLS) authentication. Set both or neither.
+	SSLCert string `json:"sslcert"`
+	SSLKey  string `json:"sslkey"`
+	// SSLRootCert is the path to the PEM CA certificate(s) used to verify the
+	// server certificate. Needed for sslmode verify-ca / verify-full.
+	SSLRootCert string `json:"sslrootcert"`
+	// PasswordSource fetches the password at startup instead of prompting for it:
+	// "env:VAR_NAME", "file:/path", or "command:tool args". Empty means prompt.
+	PasswordSource string `json:"password_source"`
+	// RuntimeParams are extra connection parameters passed to the server at startup,
+	// e.g. {"application_name": "gopgmcp", "options": "-c statement_timeout=5000"}.
+	RuntimeParams map[string]string `json:"runtime_params"`
 }
 
 // PoolConfig holds connection pool settings.
@@ -46,6 +155,14 @@ type PoolConfig struct {
 	MaxConnLifetime   string `json:"max_conn_lifetime"`
 	MaxConnIdleTime   string `json:"max_conn_idle_time"`
 	HealthCheckPeriod string `json:"health_check_period"`
+	// StartupProbeTimeout bounds how long serve waits for the database to accept
+	// connections at startup (Go duration string, default "30s").
+	StartupProbeTimeout string `json:"startup_probe_timeout"`
+	// MaxConnectionsCheck is what WaitReady does when MaxConns could exhaust the server's
+	// max_connections, given the slots other clients already use: MaxConnectionsCheckWarn
+	// (the default when empty) logs a warning, MaxConnectionsCheckError fails, and
+	// MaxConnectionsCheckOff skips the check.
+	MaxConnectionsCheck string `json:"max_connections_check"`
 }
 
 // ServerSettings holds HTTP server settings for CLI mode.
@@ -53,6 +170,13 @@ type ServerSettings struct {
 	Port               int    `json:"port"`
 	HealthCheckEnabled bool   `json:"health_check_enabled"`
 	HealthCheckPath    string `json:"health_check_path"`
+	// ShutdownTimeout is how long serve waits for in-flight queries on SIGINT/SIGTERM
+	// before cancelling them (Go duration string, default "30s").
+	ShutdownTimeout string `json:"shutdown_timeout"`
+	// AdminTokenSource enables POST /admin/cancel-all, which cancels every in-flight
+	// query, and is where its bearer token is read from (env:VAR_NAME, file:/path, or
+	// command:tool, as for connection.password_source). Empty disables the endpoint.
+	AdminTokenSource string `json:"admin_token_source"`
 }
 
 // LoggingConfig holds logging settings for CLI mode.
@@ -63,9 +187,10 @@ type LoggingConfig struct {
 }
 
 // ProtectionConfig controls which SQL operations are allowed.
-// All fields default to false (blocked). Set to true to allow.
+// All Allow* fields default to false (blocked), except AllowUpsert. Set to true to allow.
 type ProtectionConfig struct {
 	AllowSet                bool `json:"allow_set"`
+	AllowSetLocal           bool `json:"allow_set_local"` // SET LOCAL only, without AllowSet; timeouts, read-only and role stay blocked
 	AllowDrop               bool `json:"allow_drop"`
 	AllowTruncate           bool `json:"allow_truncate"`
 	AllowDo                 bool `json:"allow_do"`
@@ -77,6 +202,7 @@ type ProtectionConfig struct {
 	AllowUpdateWithoutWhere bool `json:"allow_update_without_where"`
 	AllowAlterSystem        bool `json:"allow_alter_system"`
 	AllowMerge              bool `json:"allow_merge"`
+	AllowMergeDelete        bool `json:"allow_merge_delete"` // MERGE ... THEN DELETE; requires AllowMerge
 	AllowGrantRevoke        bool `json:"allow_grant_revoke"`
 	AllowManageRoles        bool `json:"allow_manage_roles"`
 	AllowCreateExtension    bool `json:"allow_create_extension"`
@@ -88,8 +214,133 @@ type ProtectionConfig struct {
 	AllowComment            bool `json:"allow_comment"`
 	AllowCreateTrigger      bool `json:"allow_create_trigger"`
 	AllowCreateRule         bool `json:"allow_create_rule"`
+	// AllowTempTables permits CREATE TEMP TABLE (and CREATE TABLE pg_temp.name) without
+	// AllowDDL, including in read-only mode, where Postgres allows writes to temp tables but
+	// not to permanent ones; CREATE TEMP TABLE AS is blocked there. A session that creates
+	// one (CallInfo.SessionID, required) runs its later queries on a connection of its own,
+	// outside the pool, so they see the table. At most 4 sessions hold temp tables at once;
+	// a session's connection, and its temp tables, are closed 10 minutes after its last call.
+	AllowTempTables bool `json:"allow_temp_tables"`
+	// BlockSecurityDefinerCalls rejects statements that call a SECURITY DEFINER function
+	// (pg_proc.prosecdef), which runs with its owner's privileges. Checked against the
+	// catalog inside the query's transaction; lookups are cached for a minute.
+	BlockSecurityDefinerCalls bool `json:"block_security_definer_calls"`
+	// AllowUpsert permits INSERT ... ON CONFLICT (DO UPDATE and DO NOTHING). Unlike the
+	// other Allow* fields it defaults to true (nil); set false for strictly append-only inserts.
+	AllowUpsert *bool `json:"allow_upsert,omitempty"`
+	// MaxInListItems rejects `x IN (...)` lists longer than this. 0 means unlimited.
+	MaxInListItems int `json:"max_in_list_items"`
+	// MaxValuesRows rejects VALUES lists with more rows than this. 0 means unlimited.
+	MaxValuesRows int `json:"max_values_rows"`
+	// MaxIdentifierLength rejects identifiers longer than this many bytes before parsing.
+	// 0 means 63, Postgres's NAMEDATALEN limit (longer names are silently truncated).
+	MaxIdentifierLength int `json:"max_identifier_length"`
+	// MaxStatementCandidates rejects SQL containing more than this many semicolons (in
+	// literals and comments too) before it is parsed, as a raw input size limit. 0 means
+	// no limit; the parser still rejects multiple statements.
+	MaxStatementCandidates int `json:"max_statement_candidates"`
+	// OnParseFailure decides what happens when the SQL parser rejects a statement that
+	// Postgres may still accept: OnParseFailureBlock ("block", the default when empty) or
+	// OnParseFailureAllowReads, which runs it in a server-enforced read-only transaction.
+	OnParseFailure string `json:"on_parse_failure"`
+	// AllowedExtensions, when non-empty, limits CREATE EXTENSION to these extension names.
+	// Only applies when AllowCreateExtension is true.
+	AllowedExtensions []string `json:"allowed_extensions"`
+	// BlockRegexOperators rejects ~, ~*, !~, !~* (also with ANY/ALL), regexp_* functions,
+	// the functions behind the operators (textregexeq, ...) and substring(x FROM pattern),
+	// which cannot use ordinary indexes and can scan whole tables at high CPU cost.
+	BlockRegexOperators bool `json:"block_regex_operators"`
+	// BlockSimilarity rejects SIMILAR TO, substring(x SIMILAR pattern ESCAPE e), and pg_trgm
+	// similarity functions and operators.
+	BlockSimilarity bool `json:"block_similarity"`
+	// BlockComments rejects SQL containing -- or /* */ comments, a common way to hide
+	// intent from reviewers and log readers. Comment markers inside literals are fine.
+	BlockComments bool `json:"block_comments"`
+	// AllowedQueryFingerprints, when non-empty, only lets through statements whose
+	// fingerprint (see QueryFingerprint) is listed, turning the server into a gateway for a
+	// fixed catalog of queries. Literal values may differ; the structure may not. The
+	// other protection rules still apply.
+	AllowedQueryFingerprints []string `json:"allowed_query_fingerprints"`
+	// TreatAsWriteFunctions lists functions (name or schema.name) that modify data even
+	// though calling them from a SELECT parses as a read. A statement calling one runs and
+	// commits as a write, and is rejected in read-only mode.
+	TreatAsWriteFunctions []string `json:"treat_as_write_functions"`
+	// BlockVolatileInReadOnly rejects read-only queries (read_only mode or
+	// QueryInput.ForceReadOnly) that call a VOLATILE function (pg_proc.provolatile), which
+	// can have side effects. Checked like BlockSecurityDefinerCalls.
+	BlockVolatileInReadOnly bool `json:"block_volatile_in_read_only"`
 }
 
+// ProtectionConfig.OnParseFailure policies.
+const (
+	// OnParseFailureBlock rejects statements the parser cannot parse.
+	OnParseFailureBlock = "block"
+	// OnParseFailureAllowReads sends unparseable statements to Postgres in a READ ONLY
+	// transaction with statement_timeout set, logging a warning. No protection rule is
+	// evaluated for them; writes fail on the server.
+	OnParseFailureAllowReads = "deny-writes-allow-reads-with-warning"
+)
+
+// Config.HookProtectionOrder values.
+const (
+	// HookProtectionOrderHooksFirst runs before-hooks, then protection on their output.
+	HookProtectionOrderHooksFirst = "hooks-then-protection"
+	// HookProtectionOrderProtectionFirst runs protection on the original SQL, then
+	// before-hooks, then protection again on their output.
+	HookProtectionOrderProtectionFirst = "protection-then-hooks"
+)
+
+// Config.SanitizationOverLimit policies.
+const (
+	// SanitizationOverLimitReject fails the query (rolling back writes), so unsanitized
+	// data never leaves the server.
+	SanitizationOverLimitReject = "reject"
+	// SanitizationOverLimitSkip logs a warning and returns the result unsanitized.
+	SanitizationOverLimitSkip = "skip"
+)
+
+// Config.SanitizationPresets names.
+const (
+	// SanitizationPresetCreditCard masks runs of 13 to 19 digits, optionally grouped by
+	// spaces or dashes, keeping the last four: "4111 1111 1111 1234" → "****1234".
+	SanitizationPresetCreditCard = "credit_card"
+	// SanitizationPresetSSN masks US Social Security numbers written NNN-NN-NNNN, keeping
+	// the last four: "123-45-6789" → "***-**-6789".
+	SanitizationPresetSSN = "ssn"
+	// SanitizationPresetUSPhone masks US phone numbers, keeping the last four:
+	// "(212) 555-0123" → "***-***-0123".
+	SanitizationPresetUSPhone = "us_phone"
+	// SanitizationPresetEmail masks the local part of email addresses:
+	// "alice@example.com" → "***@example.com".
+	SanitizationPresetEmail = "email"
+)
+
+// QueryConfig.SpecialFloatMode values.
+const (
+	// SpecialFloatString returns the strings "NaN", "Infinity" and "-Infinity".
+	SpecialFloatString = "string"
+	// SpecialFloatNull returns JSON null, keeping float columns numeric-or-null.
+	SpecialFloatNull = "null"
+	// SpecialFloatError fails the query (rolling back writes) when a result has any.
+	SpecialFloatError = "error"
+)
+
+// QueryConfig.BooleanFormat values.
+const (
+	// BooleanFormatBool returns JSON true and false.
+	BooleanFormatBool = "bool"
+	// BooleanFormatInt returns 1 and 0.
+	BooleanFormatInt = "int"
+	// BooleanFormatTF returns "t" and "f", as psql does.
+	BooleanFormatTF = "tf"
+)
+
+// QueryConfig.MaxSQLLengthUnit values.
+const (
+	MaxSQLLengthBytes = "bytes"
+	MaxSQLLengthRunes = "runes"
+)
+
 // QueryConfig holds query execution settings.
 type QueryConfig struct {
 	DefaultTimeoutSeconds       int           `json:"default_timeout_seconds"`
@@ -98,25 +349,175 @@ type QueryConfig struct {
 	MaxSQLLength                int           `json:"max_sql_length"`
 	MaxResultLength             int           `json:"max_result_length"`
 	TimeoutRules                []TimeoutRule `json:"timeout_rules"`
+	// MaxSQLLengthUnit is what MaxSQLLength counts: MaxSQLLengthBytes (the default when
+	// empty) or MaxSQLLengthRunes, so multibyte identifiers and strings count as one each.
+	MaxSQLLengthUnit string `json:"max_sql_length_unit"`
+	// NullString is how NULL renders in CSV output (empty field by default).
+	// Also applied to result rows when NullStringInRows is true.
+	NullString       string `json:"null_string"`
+	NullStringInRows bool   `json:"null_string_in_rows"`
+	// SummarizeOversizeResults returns a summary (columns, total row count, first and
+	// last few rows) instead of a truncation error when MaxResultLength is exceeded.
+	SummarizeOversizeResults bool `json:"summarize_oversize_results"`
+	// DefaultSampleRows is the number of preview rows returned when none are requested:
+	// DescribeTable sample rows (0 means none) and the oversize-result summary (0 means 6).
+	DefaultSampleRows int `json:"default_sample_rows"`
+	// MaxSampleRows caps every preview: DescribeTableInput.SampleRows (larger requests are
+	// clamped with a note), DefaultSampleRows, and the summary sample. 0 means 100.
+	MaxSampleRows int `json:"max_sample_rows"`
+	// AutoLimit caps top-level SELECTs at this many rows by adding a LIMIT (or reducing a
+	// larger constant one). Aggregate-only SELECTs are left alone. 0 disables.
+	AutoLimit int `json:"auto_limit"`
+	// IncludeColumnTypes adds QueryOutput.ColumnTypes: each result column's type name, with
+	// arrays named by element type (e.g. "int4[]" rather than "_int4").
+	IncludeColumnTypes bool `json:"include_column_types"`
+	// ColumnTypeDetails also adds QueryOutput.ColumnTypeDetails (base type and array
+	// dimensions per column). Requires IncludeColumnTypes.
+	ColumnTypeDetails bool `json:"column_type_details"`
+	// CaptureNotices returns NOTICE/WARNING messages raised while the query ran
+	// (RAISE NOTICE, IF NOT EXISTS skips, etc.) in QueryOutput.Notices.
+	CaptureNotices bool `json:"capture_notices"`
+	// IncludeQuotedNames adds QuotedName to ListTables entries and DescribeTable output:
+	// the schema-qualified name, double-quoted only where Postgres requires it.
+	IncludeQuotedNames bool `json:"include_quoted_names"`
+	// BlockExplainAnalyze rejects EXPLAIN ANALYZE, which runs the statement for real,
+	// even for SELECTs. Plain EXPLAIN is still allowed.
+	BlockExplainAnalyze bool `json:"block_explain_analyze"`
+	// LockTimeoutMillis sets lock_timeout (via SET LOCAL) in every query transaction, so
+	// queries blocked on another transaction's lock fail fast. 0 means no lock timeout.
+	LockTimeoutMillis int `json:"lock_timeout_millis"`
+	// RetryOnDeadlock re-runs a statement's transaction up to this many times, with a short
+	// backoff, when Postgres aborts it as a deadlock victim (SQLSTATE 40P01). The whole
+	// transaction was rolled back, so writes are not applied twice. 0 means no retries.
+	RetryOnDeadlock int `json:"retry_on_deadlock"`
+	// ReturnCommitInfo adds QueryOutput.TxID and CommitLSN to committed writes, for
+	// correlating them with change data capture. Writes that changed nothing get neither.
+	ReturnCommitInfo bool `json:"return_commit_info"`
+	// MaxRowsAffected caps how many rows a single write may affect. UPDATE/DELETE whose
+	// planner estimate is far above the cap are rejected before running, and any write
+	// whose actual row count exceeds it is rolled back. 0 means no cap.
+	MaxRowsAffected int `json:"max_rows_affected"`
+	// DuplicateColumnMode handles result columns sharing a name (e.g. SELECT * over a
+	// join): DuplicateColumnsSuffix (the default when empty), DuplicateColumnsQualify, or
+	// DuplicateColumnsError.
+	DuplicateColumnMode string `json:"duplicate_column_mode"`
+	// MaxTablesListed caps the relations ListTables returns; past it the output is marked
+	// truncated with the total count. 0 means 10000.
+	MaxTablesListed int `json:"max_tables_listed"`
+	// SortDiscoveryResults sorts ListTables entries by schema, then name, in Go (byte
+	// order) rather than relying on the database collation.
+	SortDiscoveryResults bool `json:"sort_discovery_results"`
+	// SortDiscoveryCaseInsensitive makes that sort ignore case. Requires SortDiscoveryResults.
+	SortDiscoveryCaseInsensitive bool `json:"sort_discovery_case_insensitive"`
+	// GeometryAsObject returns geometric values (point, line, lseg, box, path, polygon,
+	// circle) as JSON objects, e.g. {"x":1.5,"y":2.5}, instead of Postgres text syntax.
+	GeometryAsObject bool `json:"geometry_as_object"`
+	// DateAsTimestamp returns date values in RFC3339Nano with a zero time, as timestamps
+	// are, instead of the default date-only "2006-01-02".
+	DateAsTimestamp bool `json:"date_as_timestamp"`
+	// BooleanFormat is how boolean and boolean[] columns are returned: BooleanFormatBool
+	// (the default when empty), BooleanFormatInt, or BooleanFormatTF. Booleans inside
+	// json/jsonb values are unaffected.
+	BooleanFormat string `json:"boolean_format"`
+	// SpecialFloatMode is how NaN, Infinity and -Infinity in real and double precision
+	// columns (and their arrays) are returned: SpecialFloatString (the default when empty),
+	// SpecialFloatNull, or SpecialFloatError.
+	SpecialFloatMode string `json:"special_float_mode"`
+	// MaxJSONDepth caps how deeply nested JSON objects and arrays in results may be;
+	// anything nested deeper is replaced with "<max depth exceeded>" before conversion
+	// and sanitization. 0 means 100.
+	MaxJSONDepth int `json:"max_json_depth"`
+	// IncludeResultHash adds QueryOutput.ResultHash, a fingerprint of the result rows, so
+	// polling agents can tell whether anything changed without diffing results.
+	IncludeResultHash bool `json:"include_result_hash"`
+	// ReturnAffectedKeys adds RETURNING <primary key columns> to UPDATE and DELETE
+	// statements without RETURNING, reporting the keys in QueryOutput.AffectedKeys.
+	ReturnAffectedKeys bool `json:"return_affected_keys"`
+	// AutoReturnGeneratedKeys adds RETURNING <primary key columns with a default or identity>
+	// to INSERT statements without RETURNING, reporting them in QueryOutput.GeneratedKeys.
+	AutoReturnGeneratedKeys bool `json:"auto_return_generated_keys"`
+	// ExplainSlowQueriesMillis logs the estimated plan (EXPLAIN without ANALYZE) of reads
+	// that take at least this long, at warn level with the SQL. 0 disables.
+	ExplainSlowQueriesMillis int `json:"explain_slow_queries_millis"`
+	// PlanAdvisories attaches advisories derived from the estimated plan to
+	// QueryOutput.Advisories whenever a plan is captured (QueryInput.IncludePlan or
+	// explain_slow_queries_millis), e.g. sequential scans of large tables.
+	PlanAdvisories bool `json:"plan_advisories"`
+	// ExplainOptionPolicy bounds the overhead of EXPLAIN options the agent requests.
+	ExplainOptionPolicy ExplainOptionPolicy `json:"explain_option_policy"`
+	// TimeoutByEstimatedRows sets the timeout of SELECTs no timeout_rule matched from the
+	// planner's row estimate (the largest of any plan node): the tier with the highest
+	// MinRows at or below the estimate wins, falling back to DefaultTimeoutSeconds.
+	// Costs one extra EXPLAIN per SELECT.
+	TimeoutByEstimatedRows []EstimatedRowsTimeout `json:"timeout_by_estimated_rows"`
+	// RowSecurityNotes adds QueryOutput.RowSecurityNote to successful statements that read
+	// or write tables whose row-level security policies apply to the current role.
+	RowSecurityNotes bool `json:"row_security_notes"`
+	// ResolveRegTypes returns OIDs read from well-known system catalog columns (pg_class.oid,
+	// pg_attribute.atttypid, pg_proc.prorettype, ...) as the names of the objects they
+	// identify, as the matching reg* cast would. regclass, regtype, and other reg* columns
+	// are always returned as names.
+	ResolveRegTypes bool `json:"resolve_reg_types"`
+	// SingleFlightReads coalesces identical read-only queries in flight: one runs, and
+	// callers sending the same SQL meanwhile share its result (each gets its own copy).
+	// Writes and reads calling volatile functions are never coalesced. A coalesced read
+	// runs with only the first caller's statement_comment, so pg_stat_activity and server
+	// logs attribute it to that caller.
+	SingleFlightReads bool `json:"single_flight_reads"`
+	// WarnOnAlwaysFalsePredicate adds QueryOutput.PredicateNote when the top-level WHERE
+	// clause can never be true (e.g. WHERE 1=0, WHERE false, WHERE col = NULL), so agents
+	// notice a broken filter instead of concluding the table is empty.
+	WarnOnAlwaysFalsePredicate bool `json:"warn_on_always_false_predicate"`
+}
+
+// EstimatedRowsTimeout is a timeout tier for SELECTs the planner estimates will process at
+// least MinRows rows (query.timeout_by_estimated_rows).
+type EstimatedRowsTimeout struct {
+	MinRows        int64 `json:"min_rows"`
+	TimeoutSeconds int   `json:"timeout_seconds"`
+}
+
+// ExplainOptionPolicy strips or rejects EXPLAIN options, e.g. to allow EXPLAIN ANALYZE
+// without the cost of per-node timing or WAL accounting.
+type ExplainOptionPolicy struct {
+	// Disallowed lists option names (case-insensitive), e.g. "wal", "buffers", "serialize".
+	Disallowed []string `json:"disallowed"`
+	// Action is ExplainOptionStrip (the default when empty) or ExplainOptionReject.
+	Action string `json:"action"`
+	// ForceTimingOff runs EXPLAIN ANALYZE with TIMING OFF, replacing any TIMING option.
+	ForceTimingOff bool `json:"force_timing_off"`
 }
 
 // TimeoutRule maps a SQL pattern to a specific timeout duration.
+// Rules are evaluated by descending Priority, then slice order; the first match wins.
 type TimeoutRule struct {
 	Pattern        string `json:"pattern"`
 	TimeoutSeconds int    `json:"timeout_seconds"`
+	Priority       int    `json:"priority"`
+}
+
+// StartupAssertion is a query checked once the database is ready (WaitReady). SQL must return
+// a single value, which is compared (in Postgres's text form, e.g. "t" for true) with Expect.
+type StartupAssertion struct {
+	SQL    string `json:"sql"`
+	Expect string `json:"expect"`
 }
 
 // ErrorPromptRule maps an error message pattern to a guidance message.
+// All matching rules apply; messages are emitted by descending Priority, then slice order.
 type ErrorPromptRule struct {
-	Pattern string `json:"pattern"`
-	Message string `json:"message"`
+	Pattern  string `json:"pattern"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
 }
 
 // SanitizationRule defines a regex-based field sanitization rule.
+// Rules are applied in sequence by descending Priority, then slice order.
 type SanitizationRule struct {
 	Pattern     string `json:"pattern"`
 	Replacement string `json:"replacement"`
 	Description string `json:"description"`
+	Priority    int    `json:"priority"`
 }
 
 // ServerHooksConfig holds command-based hook configuration for CLI mode.
@@ -104,6 +104,20 @@ func TestLoadConfigInvalidRegex_ErrorPrompts(t *testing.T) {
 	}
 }
 
+func TestLoadConfigInvalidStatementComment(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.StatementComment = "pgmcp agent={{.Agent"
+
+	_, err := pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	if err == nil {
+		t.Fatal("expected error for invalid statement_comment template")
+	}
+	if !strings.Contains(err.Error(), "invalid statement_comment") {
+		t.Fatalf("expected error to contain 'invalid statement_comment', got: %s", err)
+	}
+}
+
 func TestLoadConfigInvalidRegex_TimeoutRules(t *testing.T) {
 	t.Parallel()
 	config := validConfig()
@@ -578,6 +592,36 @@ func TestLoadConfigValidation_InvalidHealthCheckPeriod(t *testing.T) {
 	})
 }
 
+func TestLoadConfigValidation_InvalidStartupProbeTimeout(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Pool.StartupProbeTimeout = "soon"
+
+	expectPanic(t, "pool.startup_probe_timeout", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_SessionRoleTooLong(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.SessionRole = strings.Repeat("r", 64)
+
+	expectPanic(t, "session_role", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_SessionRoleNulByte(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.SessionRole = "agent\x00role"
+
+	expectPanic(t, "session_role must not contain NUL bytes", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
 func TestLoadConfig_ValidPoolDurations(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
@@ -645,3 +689,382 @@ type passthroughAfterHookConfig struct{}
 func (h *passthroughAfterHookConfig) Run(_ context.Context, result *pgmcp.QueryOutput) (*pgmcp.QueryOutput, error) {
 	return result, nil
 }
+
+func TestLoadConfigValidation_NegativeMaxTotalHookSeconds(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.MaxTotalHookSeconds = -1
+
+	expectPanic(t, "max_total_hook_seconds must be >= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_NegativeHookIdleInTransactionTimeout(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.HookIdleInTransactionTimeoutSeconds = -1
+
+	expectPanic(t, "hook_idle_in_transaction_timeout_seconds must be >= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_NegativeMaxIdentifierLength(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Protection.MaxIdentifierLength = -1
+
+	expectPanic(t, "protection.max_identifier_length must be >= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_ColumnTypeDetailsWithoutColumnTypes(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Query.ColumnTypeDetails = true
+
+	expectPanic(t, "query.column_type_details requires query.include_column_types", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_InvalidBooleanFormat(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Query.BooleanFormat = "yesno"
+	expectPanic(t, `query.boolean_format must be "bool", "int", or "tf", got "yesno"`, func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_InvalidMaxSQLLengthUnit(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Query.MaxSQLLengthUnit = "chars"
+
+	expectPanic(t, `query.max_sql_length_unit must be "bytes" or "runes", got "chars"`, func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_InvalidOnParseFailure(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Protection.OnParseFailure = "allow"
+
+	expectPanic(t, `protection.on_parse_failure must be "block" or "deny-writes-allow-reads-with-warning", got "allow"`, func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_EmptyStartupAssertionSQL(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.StartupAssertions = []pgmcp.StartupAssertion{{SQL: "", Expect: "t"}}
+
+	expectPanic(t, "startup_assertions[0].sql must be non-empty", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_TypeCodecWithoutNameOrOID(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.TypeCodecs = []pgmcp.TypeCodec{{Decode: func(raw []byte) (interface{}, error) { return string(raw), nil }}}
+
+	expectPanic(t, "type_codecs[0] must set Name or OID", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_TypeCodecWithoutDecode(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.TypeCodecs = []pgmcp.TypeCodec{{Name: "vector"}}
+
+	expectPanic(t, "type_codecs[0] (vector) has nil Decode", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_NegativeMaxConcurrentPerTenant(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.MaxConcurrentPerTenant = -1
+
+	expectPanic(t, "max_concurrent_per_tenant must be >= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_InvalidSanitizationOverLimit(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.SanitizationOverLimit = "allow"
+	expectPanic(t, "sanitization_over_limit must be", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+
+	config = validConfig()
+	config.SanitizationMaxScannedCells = -1
+	expectPanic(t, "sanitization_max_scanned_cells must be >= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_NegativeRetryOnDeadlock(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Query.RetryOnDeadlock = -1
+	expectPanic(t, "query.retry_on_deadlock must be >= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_InvalidQueryFingerprint(t *testing.T) {
+	t.Parallel()
+	for _, fp := range []string{"SELECT 1", "a0ead580058af58", "a0ead580058af585f", "+0ead580058af585"} {
+		config := validConfig()
+		config.Protection.AllowedQueryFingerprints = []string{fp}
+		expectPanic(t, "is not a fingerprint: expected 16 hex digits", func() {
+			pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+		})
+	}
+}
+
+func TestLoadConfigValidation_InvalidErrorDetailMode(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.ErrorDetailMode = "none"
+	expectPanic(t, `error_detail_mode must be "full" or "minimal", got "none"`, func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_UnknownSanitizationPreset(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.SanitizationPresets = []string{pgmcp.SanitizationPresetEmail, "passport"}
+	expectPanic(t, "sanitization_presets must only contain credit_card, ssn, us_phone, email", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_NegativeExplainSlowQueriesMillis(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Query.ExplainSlowQueriesMillis = -1
+	expectPanic(t, "query.explain_slow_queries_millis must be >= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_NegativeMaxJSONDepth(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Query.MaxJSONDepth = -1
+	expectPanic(t, "query.max_json_depth must be > 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_InvalidExplainOptionPolicy(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Query.ExplainOptionPolicy.Action = "drop"
+	expectPanic(t, "query.explain_option_policy.action must be", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+
+	config = validConfig()
+	config.Query.ExplainOptionPolicy.Disallowed = []string{"wall"}
+	expectPanic(t, "unknown EXPLAIN option \"wall\"", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_InvalidDuplicateColumnMode(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Query.DuplicateColumnMode = "overwrite"
+
+	expectPanic(t, "query.duplicate_column_mode must be", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_InvalidTreatAsWriteFunction(t *testing.T) {
+	t.Parallel()
+	for _, entry := range []string{"", "a.b.c", ".fn", "fn."} {
+		config := validConfig()
+		config.Protection.TreatAsWriteFunctions = []string{entry}
+		expectPanic(t, "invalid protection.treat_as_write_functions entry", func() {
+			pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+		})
+	}
+}
+
+func TestLoadConfigValidation_InvalidTimezoneFallback(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.TimezoneFallback = "local"
+
+	expectPanic(t, "timezone_fallback must be", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_InvalidMaxConnectionsCheck(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Pool.MaxConnectionsCheck = "fail"
+
+	expectPanic(t, "pool.max_connections_check must be", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_SortCaseInsensitiveWithoutSort(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Query.SortDiscoveryCaseInsensitive = true
+
+	expectPanic(t, "query.sort_discovery_case_insensitive requires query.sort_discovery_results", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_NegativeMaxRowsAffected(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Query.MaxRowsAffected = -1
+
+	expectPanic(t, "query.max_rows_affected must be >= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_NegativeLockTimeoutMillis(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Query.LockTimeoutMillis = -1
+
+	expectPanic(t, "query.lock_timeout_millis must be >= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_NegativeAutoLimit(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Query.AutoLimit = -1
+
+	expectPanic(t, "query.auto_limit must be >= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_InvalidMaskColumns(t *testing.T) {
+	t.Parallel()
+	for _, entry := range []string{"", "public.users.ssn", ".ssn", "users."} {
+		config := validConfig()
+		config.MaskColumns = []string{entry}
+
+		expectPanic(t, "invalid mask_columns entry", func() {
+			pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+		})
+	}
+}
+
+func TestLoadConfigValidation_NegativeMaxInListItems(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Protection.MaxInListItems = -1
+
+	expectPanic(t, "protection.max_in_list_items must be >= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_NegativeMaxValuesRows(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Protection.MaxValuesRows = -1
+
+	expectPanic(t, "protection.max_values_rows must be >= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_InvalidHookProtectionOrder(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.HookProtectionOrder = "protection-only"
+
+	expectPanic(t, "hook_protection_order must be", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_NegativeMaxStatementCandidates(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Protection.MaxStatementCandidates = -1
+
+	expectPanic(t, "protection.max_statement_candidates must be >= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_InvalidSpecialFloatMode(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Query.SpecialFloatMode = "zero"
+	expectPanic(t, `query.special_float_mode must be "string", "null", or "error", got "zero"`, func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_SampleRows(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Query.DefaultSampleRows = -1
+	expectPanic(t, "query.default_sample_rows must be >= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+
+	config = validConfig()
+	config.Query.MaxSampleRows = -1
+	expectPanic(t, "query.max_sample_rows must be >= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+
+	config = validConfig()
+	config.Query.MaxTablesListed = -1
+	expectPanic(t, "query.max_tables_listed must be >= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+
+	config = validConfig()
+	config.Query.DefaultSampleRows = 101
+	expectPanic(t, "query.default_sample_rows (101) must not exceed query.max_sample_rows (100)", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
+
+func TestLoadConfigValidation_TimeoutByEstimatedRows(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Query.TimeoutByEstimatedRows = []pgmcp.EstimatedRowsTimeout{{MinRows: -1, TimeoutSeconds: 10}}
+	expectPanic(t, "query.timeout_by_estimated_rows tier has min_rows -1, must be >= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+
+	config = validConfig()
+	config.Query.TimeoutByEstimatedRows = []pgmcp.EstimatedRowsTimeout{{MinRows: 1000, TimeoutSeconds: 0}}
+	expectPanic(t, "query.timeout_by_estimated_rows tier with min_rows 1000 has timeout_seconds <= 0", func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
@@ -0,0 +1,75 @@
+package pgmcp
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+)
+
+// FormatCSV renders a QueryOutput as CSV: a header row from Columns followed by
+// one record per row, in column order. NULL values render as Query.NullString
+// (empty field by default). JSONB/array values are rendered as their JSON encoding.
+func (p *PostgresMcp) FormatCSV(output *QueryOutput) (string, error) {
+	return formatCSV(output, p.config.Query.NullString)
+}
+
+// formatCSV is the config-independent implementation of FormatCSV.
+func formatCSV(output *QueryOutput, nullString string) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	if err := w.Write(output.Columns); err != nil {
+		return "", fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	record := make([]string, len(output.Columns))
+	for _, row := range output.Rows {
+		for i, col := range output.Columns {
+			field, err := csvField(row[col], nullString)
+			if err != nil {
+				return "", fmt.Errorf("failed to format CSV field %q: %w", col, err)
+			}
+			record[i] = field
+		}
+		if err := w.Write(record); err != nil {
+			return "", fmt.Errorf("failed to write CSV record: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// csvField converts a single converted row value to its CSV field representation.
+func csvField(v interface{}, nullString string) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return nullString, nil
+	case string:
+		return val, nil
+	case json.Number:
+		return val.String(), nil
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	default:
+		return fmt.Sprintf("%v", val), nil
+	}
+}
+
+// replaceNulls replaces nil top-level row values with the given sentinel string.
+// Nested JSONB/array nulls are left untouched — they are part of the value, not a NULL column.
+func replaceNulls(rows []map[string]interface{}, sentinel string) {
+	for _, row := range rows {
+		for k, v := range row {
+			if v == nil {
+				row[k] = sentinel
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package pgmcp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFormatCSV_DefaultNullIsEmptyField(t *testing.T) {
+	t.Parallel()
+	p := &PostgresMcp{}
+	output := &QueryOutput{
+		Columns: []string{"id", "name", "email"},
+		Rows: []map[string]interface{}{
+			{"id": int32(1), "name": "Alice", "email": nil},
+			{"id": int32(2), "name": nil, "email": "bob@example.com"},
+		},
+	}
+
+	got, err := p.FormatCSV(output)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "id,name,email\n1,Alice,\n2,,bob@example.com\n"
+	if got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestFormatCSV_ConfiguredNullString(t *testing.T) {
+	t.Parallel()
+	p := &PostgresMcp{config: Config{Query: QueryConfig{NullString: `\N`}}}
+	output := &QueryOutput{
+		Columns: []string{"id", "name"},
+		Rows: []map[string]interface{}{
+			{"id": int64(1), "name": nil},
+		},
+	}
+
+	got, err := p.FormatCSV(output)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "id,name\n1,\\N\n"
+	if got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestFormatCSV_QuotingAndNestedValues(t *testing.T) {
+	t.Parallel()
+	p := &PostgresMcp{}
+	output := &QueryOutput{
+		Columns: []string{"note", "tags", "meta", "active", "amount"},
+		Rows: []map[string]interface{}{
+			{
+				"note":   "hello, \"world\"",
+				"tags":   []interface{}{"a", nil},
+				"meta":   map[string]interface{}{"k": "v"},
+				"active": true,
+				"amount": json.Number("12.50"),
+			},
+		},
+	}
+
+	got, err := p.FormatCSV(output)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "note,tags,meta,active,amount\n" +
+		`"hello, ""world""","[""a"",null]","{""k"":""v""}",true,12.50` + "\n"
+	if got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestFormatCSV_EmptyResult(t *testing.T) {
+	t.Parallel()
+	p := &PostgresMcp{}
+	output := &QueryOutput{Columns: []string{"id"}, Rows: []map[string]interface{}{}}
+
+	got, err := p.FormatCSV(output)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "id\n" {
+		t.Fatalf("expected %q, got %q", "id\n", got)
+	}
+}
+
+func TestReplaceNulls_TopLevelOnly(t *testing.T) {
+	t.Parallel()
+	rows := []map[string]interface{}{
+		{"a": nil, "b": "x", "c": []interface{}{nil, "y"}},
+	}
+
+	replaceNulls(rows, "NULL")
+
+	if rows[0]["a"] != "NULL" {
+		t.Fatalf("expected a='NULL', got %v", rows[0]["a"])
+	}
+	if rows[0]["b"] != "x" {
+		t.Fatalf("expected b='x', got %v", rows[0]["b"])
+	}
+	arr := rows[0]["c"].([]interface{})
+	if len(arr) != 2 || arr[0] != nil || arr[1] != "y" {
+		t.Fatalf("expected c=[nil y] untouched, got %v", arr)
+	}
+}
@@ -0,0 +1,99 @@
+package pgmcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DescribeQuery returns the result columns and types of a statement without executing it:
+// the statement is only prepared (parsed and planned by Postgres), so no rows are read
+// and writes have no effect. Protection rules are checked first, as for Query; hooks do
+// not run. Returns Go errors, like DescribeTable.
+func (p *PostgresMcp) DescribeQuery(ctx context.Context, input DescribeQueryInput) (*DescribeQueryOutput, error) {
+	startTime := time.Now()
+	sql := input.SQL
+	if strings.TrimSpace(sql) == "" {
+		return nil, errors.New("DescribeQuery: " + emptySQLMessage)
+	}
+
+	ctx, done, err := p.inflight.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("DescribeQuery: %w", err)
+	}
+	defer done()
+
+	// 1. Acquire semaphore
+	select {
+	case p.semaphore <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("DescribeQuery: failed to acquire query slot: all %d connection slots are in use, context cancelled while waiting: %w", cap(p.semaphore), ctx.Err())
+	}
+	defer func() { <-p.semaphore }()
+	if err := p.ensureStarted(ctx); err != nil {
+		return nil, fmt.Errorf("DescribeQuery: %w", err)
+	}
+
+	// 2. Same length and protection checks as Query
+	length, unit := len(sql), "bytes"
+	if p.config.Query.MaxSQLLengthUnit == MaxSQLLengthRunes {
+		length, unit = utf8.RuneCountInString(sql), "characters"
+	}
+	if length > p.config.Query.MaxSQLLength {
+		return nil, fmt.Errorf("SQL query too long: %d %s exceeds maximum of %d %s", length, unit, p.config.Query.MaxSQLLength, unit)
+	}
+	if err := p.protection.Check(sql); err != nil {
+		return nil, err
+	}
+
+	// 3. Prepare in a transaction that is always rolled back
+	queryCtx, cancel := context.WithTimeout(ctx, time.Duration(p.config.Query.DescribeTableTimeoutSeconds)*time.Second)
+	defer cancel()
+	conn, err := p.pool.Acquire(queryCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+	tx, err := conn.Begin(queryCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sd, err := tx.Prepare(queryCtx, "", sql)
+	if err != nil {
+		return nil, err
+	}
+	fields := append([]pgconn.FieldDescription(nil), sd.Fields...)
+
+	// 4. Name and type the columns the way Query would
+	output := &DescribeQueryOutput{ReturnsRows: len(fields) > 0, Columns: []string{}, ColumnTypes: []string{}}
+	if len(fields) > 0 {
+		columns, err := p.resultColumnNames(fields)
+		if err != nil {
+			return nil, err
+		}
+		result := &QueryOutput{Columns: columns}
+		if p.config.Query.DuplicateColumnMode == DuplicateColumnsQualify {
+			if err := p.qualifyDuplicateColumns(queryCtx, tx, result, fields); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.setColumnTypes(queryCtx, tx, result, fields, nil); err != nil {
+			return nil, err
+		}
+		output.Columns, output.ColumnTypes = result.Columns, result.ColumnTypes
+	}
+
+	p.logger.Info().
+		Str("sql", truncateForLog(sql, 200)).
+		Int("columns", len(output.Columns)).
+		Dur("duration", time.Since(startTime)).
+		Msg("query described")
+	return output, nil
+}
@@ -7,12 +7,26 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 // SQL queries for DescribeTable
 
+// caseInsensitiveLookupSQL finds relations whose schema and name match $1 and $2 ignoring
+// case (config case_insensitive_table_lookup). Only consulted when $3 does not exist.
+const caseInsensitiveLookupSQL = `
+SELECT n.nspname, c.relname
+FROM pg_catalog.pg_class c
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE to_regclass($3) IS NULL
+  AND lower(n.nspname) = lower($1)
+  AND lower(c.relname) = lower($2)
+  AND c.relkind IN ('r', 'v', 'm', 'f', 'p')
+ORDER BY n.nspname, c.relname;
+`
+
 const detectTypeSQL = `
-SELECT c.relkind
+SELECT c.relkind, quote_ident(n.nspname) || '.' || quote_ident(c.relname) AS quoted_name
 FROM pg_catalog.pg_class c
 JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
 WHERE c.oid = $1::regclass;
@@ -23,8 +37,11 @@ SELECT
     c.column_name AS name,
     c.data_type AS type,
     CASE c.is_nullable WHEN 'YES' THEN true ELSE false END AS nullable,
-    COALESCE(c.column_default, '') AS default_val,
-    CASE WHEN pk.column_name IS NOT NULL THEN true ELSE false END AS is_primary_key
+    COALESCE(c.column_default, c.generation_expression, '') AS default_val,
+    CASE WHEN pk.column_name IS NOT NULL THEN true ELSE false END AS is_primary_key,
+    c.is_generated = 'ALWAYS' AS is_generated,
+    c.is_identity = 'YES' AS is_identity,
+    COALESCE(c.identity_generation, '') AS identity_generation
 FROM information_schema.columns c
 LEFT JOIN (
     SELECT kcu.column_name
@@ -158,7 +175,7 @@ WHERE i.inhrelid = $1::regclass;
 `
 
 // DescribeTable returns detailed schema information about a table, view, or materialized view.
-// Does NOT go through the hook/protection/sanitization pipeline.
+// Does NOT go through the hook/protection pipeline; optional sample rows are masked and sanitized.
 func (p *PostgresMcp) DescribeTable(ctx context.Context, input DescribeTableInput) (*DescribeTableOutput, error) {
 	startTime := time.Now()
 
@@ -167,6 +184,12 @@ func (p *PostgresMcp) DescribeTable(ctx context.Context, input DescribeTableInpu
 		schema = "public"
 	}
 
+	ctx, done, err := p.inflight.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("DescribeTable: %w", err)
+	}
+	defer done()
+
 	// 1. Acquire semaphore
 	select {
 	case p.semaphore <- struct{}{}:
@@ -174,6 +197,9 @@ func (p *PostgresMcp) DescribeTable(ctx context.Context, input DescribeTableInpu
 		return nil, fmt.Errorf("DescribeTable: failed to acquire query slot: all %d connection slots are in use, context cancelled while waiting: %w", cap(p.semaphore), ctx.Err())
 	}
 	defer func() { <-p.semaphore }()
+	if err := p.ensureStarted(ctx); err != nil {
+		return nil, fmt.Errorf("DescribeTable: %w", err)
+	}
 
 	// 2. Apply configurable timeout
 	queryCtx, cancel := context.WithTimeout(ctx, time.Duration(p.config.Query.DescribeTableTimeoutSeconds)*time.Second)
@@ -186,25 +212,42 @@ func (p *PostgresMcp) DescribeTable(ctx context.Context, input DescribeTableInpu
 	}
 	defer conn.Release()
 
-	tx, err := conn.Begin(queryCtx)
+	tx, err := conn.BeginTx(queryCtx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx) // always rollback — read-only metadata queries
 
 	// Construct properly-quoted identifier for $1::regclass parameters
-	qualName := quoteIdent(schema) + "." + quoteIdent(input.Table)
+	table := input.Table
+	qualName := quoteIdent(schema) + "." + quoteIdent(table)
 
 	output := &DescribeTableOutput{
 		Schema: schema,
-		Name:   input.Table,
+		Name:   table,
+	}
+
+	// 3a. Fall back to a case-insensitive match when the exact name does not exist
+	if p.config.CaseInsensitiveTableLookup {
+		resolvedSchema, resolvedTable, err := resolveTableCase(queryCtx, tx, schema, table, qualName)
+		if err != nil {
+			return nil, err
+		}
+		if resolvedTable != "" {
+			schema, table = resolvedSchema, resolvedTable
+			qualName = quoteIdent(schema) + "." + quoteIdent(table)
+			output.Schema, output.Name, output.ResolvedName = schema, table, table
+		}
 	}
 
 	// 4. Detect object type
-	var relkind string
-	err = tx.QueryRow(queryCtx, detectTypeSQL, qualName).Scan(&relkind)
+	var relkind, quotedName string
+	err = tx.QueryRow(queryCtx, detectTypeSQL, qualName).Scan(&relkind, &quotedName)
 	if err != nil {
-		return nil, fmt.Errorf("table not found: %s.%s: %w", schema, input.Table, err)
+		return nil, fmt.Errorf("table not found: %s.%s: %w", schema, table, err)
+	}
+	if p.config.Query.IncludeQuotedNames {
+		output.QuotedName = quotedName
 	}
 
 	switch relkind {
@@ -229,7 +272,7 @@ func (p *PostgresMcp) DescribeTable(ctx context.Context, input DescribeTableInpu
 			return nil, err
 		}
 	} else {
-		if err := p.fetchColumns(queryCtx, tx, schema, input.Table, output); err != nil {
+		if err := p.fetchColumns(queryCtx, tx, schema, table, output); err != nil {
 			return nil, err
 		}
 	}
@@ -246,21 +289,21 @@ func (p *PostgresMcp) DescribeTable(ctx context.Context, input DescribeTableInpu
 
 	// 7. Fetch indexes (tables, partitioned tables, materialized views — views don't have indexes)
 	if relkind == "r" || relkind == "p" || relkind == "m" {
-		if err := p.fetchIndexes(queryCtx, tx, schema, input.Table, output); err != nil {
+		if err := p.fetchIndexes(queryCtx, tx, schema, table, output); err != nil {
 			return nil, err
 		}
 	}
 
 	// 8. Fetch constraints (tables and partitioned tables)
 	if relkind == "r" || relkind == "p" {
-		if err := p.fetchConstraints(queryCtx, tx, schema, input.Table, output); err != nil {
+		if err := p.fetchConstraints(queryCtx, tx, schema, table, output); err != nil {
 			return nil, err
 		}
 	}
 
 	// 9. Fetch foreign keys (tables and partitioned tables)
 	if relkind == "r" || relkind == "p" {
-		if err := p.fetchForeignKeys(queryCtx, tx, schema, input.Table, output); err != nil {
+		if err := p.fetchForeignKeys(queryCtx, tx, schema, table, output); err != nil {
 			return nil, err
 		}
 	}
@@ -279,6 +322,16 @@ func (p *PostgresMcp) DescribeTable(ctx context.Context, input DescribeTableInpu
 		}
 	}
 
+	// 12. Fetch sample rows (optional; query.default_sample_rows when not requested)
+	if limit, clamped := p.sampleSize(input.SampleRows, 0); limit > 0 {
+		if err := p.fetchSampleRows(queryCtx, tx, qualName, limit, output); err != nil {
+			return nil, err
+		}
+		if clamped {
+			output.SampleRowsNote = fmt.Sprintf("sample_rows %d exceeds the maximum of %d (query.max_sample_rows): returned at most %d rows", input.SampleRows, limit, limit)
+		}
+	}
+
 	// Ensure non-nil slices for JSON serialization
 	if output.Columns == nil {
 		output.Columns = []ColumnInfo{}
@@ -295,7 +348,7 @@ func (p *PostgresMcp) DescribeTable(ctx context.Context, input DescribeTableInpu
 
 	p.logger.Info().
 		Str("schema", schema).
-		Str("table", input.Table).
+		Str("table", table).
 		Dur("duration", time.Since(startTime)).
 		Str("type", output.Type).
 		Int("column_count", len(output.Columns)).
@@ -304,6 +357,40 @@ func (p *PostgresMcp) DescribeTable(ctx context.Context, input DescribeTableInpu
 	return output, nil
 }
 
+// resolveTableCase returns the schema and name of the single relation matching schema.table
+// ignoring case, or empty strings when qualName exists as given or nothing matches (the
+// caller then reports it as not found). Several matches are an error listing them.
+func resolveTableCase(ctx context.Context, tx pgx.Tx, schema, table, qualName string) (string, string, error) {
+	rows, err := tx.Query(ctx, caseInsensitiveLookupSQL, schema, table, qualName)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to look up table: %w", err)
+	}
+	var matches [][2]string
+	for rows.Next() {
+		var m [2]string
+		if err := rows.Scan(&m[0], &m[1]); err != nil {
+			rows.Close()
+			return "", "", fmt.Errorf("failed to look up table: %w", err)
+		}
+		matches = append(matches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return "", "", fmt.Errorf("failed to look up table: %w", err)
+	}
+	switch len(matches) {
+	case 0:
+		return "", "", nil
+	case 1:
+		return matches[0][0], matches[0][1], nil
+	}
+	candidates := make([]string, len(matches))
+	for i, m := range matches {
+		candidates[i] = quoteIdent(m[0]) + "." + quoteIdent(m[1])
+	}
+	return "", "", fmt.Errorf("table not found: %s.%s; it matches several tables ignoring case: %s. Pass the exact name of one of them",
+		schema, table, strings.Join(candidates, ", "))
+}
+
 func (p *PostgresMcp) fetchColumns(ctx context.Context, tx pgx.Tx, schema, table string, output *DescribeTableOutput) error {
 	rows, err := tx.Query(ctx, columnsSQL, schema, table)
 	if err != nil {
@@ -313,7 +400,7 @@ func (p *PostgresMcp) fetchColumns(ctx context.Context, tx pgx.Tx, schema, table
 
 	for rows.Next() {
 		var col ColumnInfo
-		if err := rows.Scan(&col.Name, &col.Type, &col.Nullable, &col.Default, &col.IsPrimaryKey); err != nil {
+		if err := rows.Scan(&col.Name, &col.Type, &col.Nullable, &col.Default, &col.IsPrimaryKey, &col.IsGenerated, &col.IsIdentity, &col.IdentityGeneration); err != nil {
 			return fmt.Errorf("failed to scan column: %w", err)
 		}
 		output.Columns = append(output.Columns, col)
@@ -321,6 +408,32 @@ func (p *PostgresMcp) fetchColumns(ctx context.Context, tx pgx.Tx, schema, table
 	return rows.Err()
 }
 
+// fetchSampleRows reads up to limit rows of the described relation and applies the same
+// result processing as Query: type conversion, column masking, sanitization, and NULL rendering.
+func (p *PostgresMcp) fetchSampleRows(ctx context.Context, tx pgx.Tx, qualName string, limit int, output *DescribeTableOutput) error {
+	rows, err := tx.Query(ctx, fmt.Sprintf("SELECT * FROM %s LIMIT %d", qualName, limit))
+	if err != nil {
+		return fmt.Errorf("failed to fetch sample rows: %w", err)
+	}
+	// Copy: pgconn reuses the field description buffer for the connection's next query.
+	fields := append([]pgconn.FieldDescription(nil), rows.FieldDescriptions()...)
+	sample, _, err := p.collectRows(rows, nil, p.resultConverter())
+	if err != nil {
+		return fmt.Errorf("failed to fetch sample rows: %w", err)
+	}
+	if p.masker.hasRules() {
+		if err := p.masker.mask(ctx, tx, sample, fields); err != nil {
+			return err
+		}
+	}
+	sample.Rows = p.sanitizer.SanitizeRows(sample.Rows)
+	if p.config.Query.NullStringInRows {
+		replaceNulls(sample.Rows, p.config.Query.NullString)
+	}
+	output.SampleRows = sample.Rows
+	return nil
+}
+
 func (p *PostgresMcp) fetchMatviewColumns(ctx context.Context, tx pgx.Tx, qualName string, output *DescribeTableOutput) error {
 	rows, err := tx.Query(ctx, matviewColumnsSQL, qualName)
 	if err != nil {
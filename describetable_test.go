@@ -62,6 +62,223 @@ func TestDescribeTable_Columns(t *testing.T) {
 	}
 }
 
+func TestDescribeTable_QuotedName(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Query.IncludeQuotedNames = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, `CREATE TABLE "MixedCase" (id int)`)
+	setupTable(t, p, `CREATE TABLE "order items" (id int)`)
+
+	tests := []struct {
+		table    string
+		expected string
+	}{
+		{"MixedCase", `public."MixedCase"`},
+		{"order items", `public."order items"`},
+	}
+	for _, tt := range tests {
+		output, err := p.DescribeTable(context.Background(), pgmcp.DescribeTableInput{Table: tt.table})
+		if err != nil {
+			t.Fatalf("unexpected error describing %q: %v", tt.table, err)
+		}
+		if output.QuotedName != tt.expected {
+			t.Fatalf("expected quoted_name %s for %q, got %q", tt.expected, tt.table, output.QuotedName)
+		}
+		if output.Name != tt.table {
+			t.Fatalf("expected name %q to stay unquoted, got %q", tt.table, output.Name)
+		}
+	}
+}
+
+func TestDescribeTable_CaseInsensitiveLookup(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.CaseInsensitiveTableLookup = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE accounts (id int, balance numeric)")
+
+	// Exact name: described as-is, no resolved_name.
+	output, err := p.DescribeTable(context.Background(), pgmcp.DescribeTableInput{Table: "accounts"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output.ResolvedName != "" {
+		t.Fatalf("expected no resolved_name for an exact match, got %q", output.ResolvedName)
+	}
+
+	// Wrong case (table and schema): the unique match is described.
+	output, err = p.DescribeTable(context.Background(), pgmcp.DescribeTableInput{Schema: "PUBLIC", Table: "Accounts"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output.ResolvedName != "accounts" || output.Name != "accounts" || output.Schema != "public" {
+		t.Fatalf("expected public.accounts resolved, got schema=%q name=%q resolved_name=%q", output.Schema, output.Name, output.ResolvedName)
+	}
+	if len(output.Columns) != 2 {
+		t.Fatalf("expected 2 columns, got %d", len(output.Columns))
+	}
+
+	// No match at all is still "not found".
+	_, err = p.DescribeTable(context.Background(), pgmcp.DescribeTableInput{Table: "Missing"})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestDescribeTable_CaseInsensitiveLookupAmbiguous(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.CaseInsensitiveTableLookup = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, `CREATE TABLE orders (id int)`)
+	setupTable(t, p, `CREATE TABLE "Orders" (id int)`)
+
+	// Each exact name still resolves to its own table.
+	output, err := p.DescribeTable(context.Background(), pgmcp.DescribeTableInput{Table: "Orders"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output.Name != "Orders" || output.ResolvedName != "" {
+		t.Fatalf("expected exact match on \"Orders\", got name=%q resolved_name=%q", output.Name, output.ResolvedName)
+	}
+
+	_, err = p.DescribeTable(context.Background(), pgmcp.DescribeTableInput{Table: "ORDERS"})
+	if err == nil {
+		t.Fatal("expected error for ambiguous match")
+	}
+	if !strings.Contains(err.Error(), `"public"."Orders"`) || !strings.Contains(err.Error(), `"public"."orders"`) {
+		t.Fatalf("expected both candidates listed, got %q", err.Error())
+	}
+}
+
+func TestDescribeTable_CaseInsensitiveLookupDisabled(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE invoices (id int)")
+
+	_, err := p.DescribeTable(context.Background(), pgmcp.DescribeTableInput{Table: "Invoices"})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected not found error without case_insensitive_table_lookup, got %v", err)
+	}
+}
+
+func TestDescribeTable_SampleRowsMaskedAndSanitized(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.MaskColumns = []string{"ssn"}
+	config.Sanitization = []pgmcp.SanitizationRule{
+		{Pattern: `\d{3}-\d{3}-\d{4}`, Replacement: "***-***-****"},
+	}
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE contacts (id int, phone text, ssn text)")
+	setupTable(t, p, "INSERT INTO contacts VALUES (1, '555-123-4567', '123-45-6789'), (2, '555-987-6543', '987-65-4321'), (3, NULL, NULL)")
+
+	output, err := p.DescribeTable(context.Background(), pgmcp.DescribeTableInput{Table: "contacts", SampleRows: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(output.SampleRows) != 2 {
+		t.Fatalf("expected 2 sample rows, got %d", len(output.SampleRows))
+	}
+	for _, row := range output.SampleRows {
+		if row["phone"] != "***-***-****" {
+			t.Fatalf("expected phone sanitized, got %v", row["phone"])
+		}
+		if row["ssn"] != "***" {
+			t.Fatalf("expected ssn masked, got %v", row["ssn"])
+		}
+		if _, ok := row["id"].(int32); !ok {
+			t.Fatalf("expected id converted to int32, got %T", row["id"])
+		}
+	}
+	if len(output.Columns) != 3 {
+		t.Fatalf("expected structure alongside samples, got %d columns", len(output.Columns))
+	}
+}
+
+func TestDescribeTable_SampleRowsCapped(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE numbers (n int)")
+	setupTable(t, p, "INSERT INTO numbers SELECT g FROM generate_series(1, 150) AS g")
+
+	output, err := p.DescribeTable(context.Background(), pgmcp.DescribeTableInput{Table: "numbers", SampleRows: 1000})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(output.SampleRows) != 100 {
+		t.Fatalf("expected sample capped at 100 rows, got %d", len(output.SampleRows))
+	}
+	if expected := "sample_rows 1000 exceeds the maximum of 100 (query.max_sample_rows): returned at most 100 rows"; output.SampleRowsNote != expected {
+		t.Fatalf("expected note %q, got %q", expected, output.SampleRowsNote)
+	}
+
+	output, err = p.DescribeTable(context.Background(), pgmcp.DescribeTableInput{Table: "numbers"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output.SampleRows != nil {
+		t.Fatalf("expected no sample rows by default, got %d", len(output.SampleRows))
+	}
+}
+
+func TestDescribeTable_SampleRowsConfiguredDefaultAndMax(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Query.DefaultSampleRows = 3
+	config.Query.MaxSampleRows = 5
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE numbers (n int)")
+	setupTable(t, p, "INSERT INTO numbers SELECT g FROM generate_series(1, 20) AS g")
+
+	// Not requested: query.default_sample_rows.
+	output, err := p.DescribeTable(context.Background(), pgmcp.DescribeTableInput{Table: "numbers"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(output.SampleRows) != 3 || output.SampleRowsNote != "" {
+		t.Fatalf("expected 3 default sample rows and no note, got %d rows, note %q", len(output.SampleRows), output.SampleRowsNote)
+	}
+
+	// Within the max: returned as requested.
+	output, err = p.DescribeTable(context.Background(), pgmcp.DescribeTableInput{Table: "numbers", SampleRows: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(output.SampleRows) != 5 || output.SampleRowsNote != "" {
+		t.Fatalf("expected 5 sample rows and no note, got %d rows, note %q", len(output.SampleRows), output.SampleRowsNote)
+	}
+
+	// Over the max: clamped with a note.
+	output, err = p.DescribeTable(context.Background(), pgmcp.DescribeTableInput{Table: "numbers", SampleRows: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(output.SampleRows) != 5 {
+		t.Fatalf("expected sample clamped to 5 rows, got %d", len(output.SampleRows))
+	}
+	if expected := "sample_rows 10 exceeds the maximum of 5 (query.max_sample_rows): returned at most 5 rows"; output.SampleRowsNote != expected {
+		t.Fatalf("expected note %q, got %q", expected, output.SampleRowsNote)
+	}
+}
+
 func TestDescribeTable_PrimaryKey(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
@@ -262,6 +479,70 @@ func TestDescribeTable_DefaultValues(t *testing.T) {
 	}
 }
 
+func TestDescribeTable_GeneratedIdentityAndDefaults(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, `CREATE TABLE gen_table (
+		id serial PRIMARY KEY,
+		ident bigint GENERATED ALWAYS AS IDENTITY,
+		ident_default bigint GENERATED BY DEFAULT AS IDENTITY,
+		price numeric NOT NULL,
+		qty integer NOT NULL DEFAULT 1,
+		total numeric GENERATED ALWAYS AS (price * qty) STORED,
+		note text
+	)`)
+
+	output, err := p.DescribeTable(context.Background(), pgmcp.DescribeTableInput{Table: "gen_table"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cols := map[string]pgmcp.ColumnInfo{}
+	for _, col := range output.Columns {
+		cols[col.Name] = col
+	}
+
+	tests := []struct {
+		name               string
+		isGenerated        bool
+		isIdentity         bool
+		identityGeneration string
+		defaultContains    string
+	}{
+		{name: "id", defaultContains: "nextval('gen_table_id_seq'::regclass)"},
+		{name: "ident", isIdentity: true, identityGeneration: "ALWAYS"},
+		{name: "ident_default", isIdentity: true, identityGeneration: "BY DEFAULT"},
+		{name: "price"},
+		{name: "qty", defaultContains: "1"},
+		{name: "total", isGenerated: true, defaultContains: "price * "},
+		{name: "note"},
+	}
+	for _, tt := range tests {
+		col, ok := cols[tt.name]
+		if !ok {
+			t.Errorf("column %s missing", tt.name)
+			continue
+		}
+		if col.IsGenerated != tt.isGenerated {
+			t.Errorf("%s: IsGenerated = %v, want %v", tt.name, col.IsGenerated, tt.isGenerated)
+		}
+		if col.IsIdentity != tt.isIdentity {
+			t.Errorf("%s: IsIdentity = %v, want %v", tt.name, col.IsIdentity, tt.isIdentity)
+		}
+		if col.IdentityGeneration != tt.identityGeneration {
+			t.Errorf("%s: IdentityGeneration = %q, want %q", tt.name, col.IdentityGeneration, tt.identityGeneration)
+		}
+		if tt.defaultContains == "" && col.Default != "" {
+			t.Errorf("%s: Default = %q, want none", tt.name, col.Default)
+		}
+		if !strings.Contains(col.Default, tt.defaultContains) {
+			t.Errorf("%s: Default = %q, want it to contain %q", tt.name, col.Default, tt.defaultContains)
+		}
+	}
+}
+
 func TestDescribeTable_NotFound(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
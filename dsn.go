@@ -0,0 +1,111 @@
+package pgmcp
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DSN builds a keyword/value connection string (accepted by pgx and libpq) from the
+// structured connection fields, without credentials. Values are quoted and escaped as
+// needed, and runtime params are sorted, so the same config always gives the same string.
+// Returns an error for contradictory or malformed fields.
+func (c ConnectionConfig) DSN() (string, error) {
+	return c.DSNWithCredentials("", "")
+}
+
+// DSNWithCredentials is DSN with user and password added; empty values are left out.
+// gopgmcp serve uses it with the prompted (or password_source) credentials.
+func (c ConnectionConfig) DSNWithCredentials(user, password string) (string, error) {
+	if c.Host != "" && c.Socket != "" {
+		return "", errors.New("connection.host and connection.socket are mutually exclusive")
+	}
+	if c.Port < 0 || c.Port > 65535 {
+		return "", fmt.Errorf("connection.port must be between 1 and 65535, got %d", c.Port)
+	}
+	if (c.SSLCert == "") != (c.SSLKey == "") {
+		return "", errors.New("connection.sslcert and connection.sslkey must be set together")
+	}
+
+	var parts []string
+	add := func(key, value string) {
+		parts = append(parts, key+"="+quoteConnValue(value))
+	}
+	port := c.Port
+	if c.Socket != "" {
+		// libpq/pgx treat a host starting with "/" as a Unix socket directory.
+		dir, socketPort := socketDirAndPort(c.Socket)
+		add("host", dir)
+		if port == 0 {
+			port = socketPort
+		}
+	} else if c.Host != "" {
+		add("host", c.Host)
+	}
+	if port > 0 {
+		add("port", strconv.Itoa(port))
+	}
+	if c.DBName != "" {
+		add("dbname", c.DBName)
+	}
+	if user != "" {
+		add("user", user)
+	}
+	if password != "" {
+		add("password", password)
+	}
+	if c.SSLMode != "" {
+		add("sslmode", c.SSLMode)
+	}
+	// pgx loads these files into the tls.Config when the pool config is parsed.
+	if c.SSLCert != "" {
+		add("sslcert", c.SSLCert)
+	}
+	if c.SSLKey != "" {
+		add("sslkey", c.SSLKey)
+	}
+	if c.SSLRootCert != "" {
+		add("sslrootcert", c.SSLRootCert)
+	}
+	// Unrecognized keywords are sent to the server as runtime params. Sorted for a stable string.
+	keys := make([]string, 0, len(c.RuntimeParams))
+	for k := range c.RuntimeParams {
+		if k == "" || strings.ContainsAny(k, " \t\n='\\") {
+			return "", fmt.Errorf("connection.runtime_params: invalid parameter name %q", k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		add(k, c.RuntimeParams[k])
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// socketDirAndPort splits a connection.socket value into the socket directory and,
+// when a full ".s.PGSQL.<port>" file path is given, its port (0 otherwise).
+func socketDirAndPort(socket string) (string, int) {
+	base := filepath.Base(socket)
+	if !strings.HasPrefix(base, ".s.PGSQL.") {
+		return socket, 0
+	}
+	port, err := strconv.Atoi(strings.TrimPrefix(base, ".s.PGSQL."))
+	if err != nil {
+		return socket, 0
+	}
+	return filepath.Dir(socket), port
+}
+
+// quoteConnValue quotes a keyword/value connection string value if it is empty or
+// contains spaces, quotes, or backslashes.
+func quoteConnValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t\n'\\") {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
@@ -0,0 +1,194 @@
+package pgmcp_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	pgmcp "github.com/rickchristie/postgres-mcp"
+)
+
+func TestConnectionDSN_TCP(t *testing.T) {
+	t.Parallel()
+	conn := pgmcp.ConnectionConfig{Host: "localhost", Port: 5432, DBName: "mydb", SSLMode: "require"}
+	got, err := conn.DSNWithCredentials("alice", "secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "host=localhost port=5432 dbname=mydb user=alice password=secret sslmode=require"
+	if got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestConnectionDSN_SocketDirectory(t *testing.T) {
+	t.Parallel()
+	conn := pgmcp.ConnectionConfig{Socket: "/var/run/postgresql", DBName: "mydb"}
+	got, err := conn.DSNWithCredentials("alice", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "host=/var/run/postgresql dbname=mydb user=alice"
+	if got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	cfg, err := pgconn.ParseConfig(got)
+	if err != nil {
+		t.Fatalf("failed to parse conn string: %v", err)
+	}
+	if cfg.Host != "/var/run/postgresql" {
+		t.Fatalf("expected host to be the socket dir, got %q", cfg.Host)
+	}
+}
+
+func TestConnectionDSN_SocketFilePath(t *testing.T) {
+	t.Parallel()
+	conn := pgmcp.ConnectionConfig{Socket: "/tmp/pg sockets/.s.PGSQL.6543", DBName: "mydb"}
+	got, err := conn.DSN()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "host='/tmp/pg sockets' port=6543 dbname=mydb"
+	if got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	cfg, err := pgconn.ParseConfig(got)
+	if err != nil {
+		t.Fatalf("failed to parse conn string: %v", err)
+	}
+	if cfg.Host != "/tmp/pg sockets" || cfg.Port != 6543 {
+		t.Fatalf("expected host '/tmp/pg sockets' port 6543, got %q %d", cfg.Host, cfg.Port)
+	}
+}
+
+func TestConnectionDSN_SocketExplicitPortWins(t *testing.T) {
+	t.Parallel()
+	conn := pgmcp.ConnectionConfig{Socket: "/var/run/postgresql/.s.PGSQL.6543", Port: 5433}
+	got, err := conn.DSN()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "host=/var/run/postgresql port=5433"
+	if got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestConnectionDSN_RuntimeParams(t *testing.T) {
+	t.Parallel()
+	conn := pgmcp.ConnectionConfig{
+		Host:   "localhost",
+		DBName: "mydb",
+		RuntimeParams: map[string]string{
+			"options":          "-c statement_timeout=5000",
+			"application_name": "gopgmcp",
+			"search_path":      `it's\here`,
+		},
+	}
+	got, err := conn.DSN()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `host=localhost dbname=mydb application_name=gopgmcp options='-c statement_timeout=5000' search_path='it\'s\\here'`
+	if got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	cfg, err := pgconn.ParseConfig(got)
+	if err != nil {
+		t.Fatalf("failed to parse conn string: %v", err)
+	}
+	expectedParams := map[string]string{
+		"options":          "-c statement_timeout=5000",
+		"application_name": "gopgmcp",
+		"search_path":      `it's\here`,
+	}
+	for k, v := range expectedParams {
+		if cfg.RuntimeParams[k] != v {
+			t.Fatalf("expected runtime param %s=%q, got %q", k, v, cfg.RuntimeParams[k])
+		}
+	}
+}
+
+func TestConnectionDSN_EscapesSpecialCharacters(t *testing.T) {
+	t.Parallel()
+	conn := pgmcp.ConnectionConfig{Host: "localhost", DBName: `my db's`}
+	got, err := conn.DSNWithCredentials("alice", `p@ss word'\x`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `host=localhost dbname='my db\'s' user=alice password='p@ss word\'\\x'`
+	if got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	cfg, err := pgconn.ParseConfig(got)
+	if err != nil {
+		t.Fatalf("failed to parse conn string: %v", err)
+	}
+	if cfg.Database != `my db's` || cfg.Password != `p@ss word'\x` {
+		t.Fatalf("expected dbname and password to round-trip, got %q %q", cfg.Database, cfg.Password)
+	}
+}
+
+func TestConnectionDSN_NoCredentials(t *testing.T) {
+	t.Parallel()
+	conn := pgmcp.ConnectionConfig{Host: "localhost", DBName: "mydb"}
+	got, err := conn.DSN()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "host=localhost dbname=mydb" {
+		t.Fatalf("expected no credentials, got %q", got)
+	}
+}
+
+func TestConnectionDSN_SSLModeAndCerts(t *testing.T) {
+	t.Parallel()
+	conn := pgmcp.ConnectionConfig{
+		Host:        "db.example.com",
+		DBName:      "mydb",
+		SSLMode:     "verify-full",
+		SSLCert:     "/etc/certs/client.crt",
+		SSLKey:      "/etc/certs/client key.pem",
+		SSLRootCert: "/etc/certs/root.crt",
+	}
+	got, err := conn.DSN()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "host=db.example.com dbname=mydb sslmode=verify-full sslcert=/etc/certs/client.crt sslkey='/etc/certs/client key.pem' sslrootcert=/etc/certs/root.crt"
+	if got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	got, err = pgmcp.ConnectionConfig{Host: "localhost", SSLMode: "disable"}.DSN()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "host=localhost sslmode=disable" {
+		t.Fatalf("expected only host and sslmode, got %q", got)
+	}
+}
+
+func TestConnectionDSN_Invalid(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		conn   pgmcp.ConnectionConfig
+		errMsg string
+	}{
+		{"host and socket", pgmcp.ConnectionConfig{Host: "localhost", Socket: "/tmp"}, "mutually exclusive"},
+		{"port out of range", pgmcp.ConnectionConfig{Host: "localhost", Port: 70000}, "connection.port must be between 1 and 65535"},
+		{"cert without key", pgmcp.ConnectionConfig{Host: "localhost", SSLCert: "/c.crt"}, "sslcert and connection.sslkey must be set together"},
+		{"bad runtime param", pgmcp.ConnectionConfig{Host: "localhost", RuntimeParams: map[string]string{"a b": "x"}}, `invalid parameter name "a b"`},
+	}
+	for _, tt := range tests {
+		_, err := tt.conn.DSN()
+		if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.errMsg, err)
+		}
+	}
+}
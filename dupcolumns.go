@@ -0,0 +1,136 @@
+package pgmcp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// QueryConfig.DuplicateColumnMode values.
+const (
+	// DuplicateColumnsSuffix (the default) numbers every column sharing a name: id_1, id_2.
+	DuplicateColumnsSuffix = "suffix"
+	// DuplicateColumnsQualify prefixes duplicated columns with their source table name
+	// (users.id, orders.id), numbering those it cannot tell apart (computed columns,
+	// self-joins) as DuplicateColumnsSuffix does.
+	DuplicateColumnsQualify = "qualify"
+	// DuplicateColumnsError fails the query, asking for column aliases.
+	DuplicateColumnsError = "error"
+)
+
+// duplicateColumnNames returns the field names that occur more than once.
+func duplicateColumnNames(fields []pgconn.FieldDescription) map[string]bool {
+	seen := make(map[string]bool, len(fields))
+	var dups map[string]bool
+	for _, fd := range fields {
+		if seen[fd.Name] {
+			if dups == nil {
+				dups = map[string]bool{}
+			}
+			dups[fd.Name] = true
+		}
+		seen[fd.Name] = true
+	}
+	return dups
+}
+
+// resultColumnNames returns unique row keys for fields. Without duplicates these are the
+// field names; otherwise duplicates are numbered (query.duplicate_column_mode "suffix",
+// and the starting point for "qualify"), or an error is returned for "error".
+func (p *PostgresMcp) resultColumnNames(fields []pgconn.FieldDescription) ([]string, error) {
+	columns := make([]string, len(fields))
+	for i, fd := range fields {
+		columns[i] = fd.Name
+	}
+	dups := duplicateColumnNames(fields)
+	if dups == nil {
+		return columns, nil
+	}
+	if p.config.Query.DuplicateColumnMode == DuplicateColumnsError {
+		for _, fd := range fields {
+			if dups[fd.Name] {
+				return nil, fmt.Errorf("result has more than one column named %q: give the columns distinct aliases, e.g. SELECT a.%s AS a_%s, b.%s AS b_%s", fd.Name, fd.Name, fd.Name, fd.Name, fd.Name)
+			}
+		}
+	}
+
+	taken := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		taken[col] = true
+	}
+	next := make(map[string]int, len(dups))
+	for i, col := range columns {
+		if !dups[col] {
+			continue
+		}
+		for {
+			next[col]++
+			name := col + "_" + strconv.Itoa(next[col])
+			if !taken[name] {
+				taken[name] = true
+				columns[i] = name
+				break
+			}
+		}
+	}
+	return columns, nil
+}
+
+// qualifyDuplicateColumns renames numbered duplicate columns to "table.column" for
+// query.duplicate_column_mode "qualify". Columns without a source table, or whose
+// qualified names would still collide, keep their numbered names.
+func (p *PostgresMcp) qualifyDuplicateColumns(ctx context.Context, tx pgx.Tx, result *QueryOutput, fields []pgconn.FieldDescription) error {
+	dups := duplicateColumnNames(fields)
+	if dups == nil {
+		return nil
+	}
+	var oids []uint32
+	for _, fd := range fields {
+		if dups[fd.Name] && fd.TableOID != 0 {
+			oids = append(oids, fd.TableOID)
+		}
+	}
+	if len(oids) == 0 {
+		return nil
+	}
+	rows, err := tx.Query(ctx, "SELECT oid, relname FROM pg_catalog.pg_class WHERE oid = ANY($1)", oids)
+	if err != nil {
+		return fmt.Errorf("failed to resolve tables for duplicate columns: %w", err)
+	}
+	defer rows.Close()
+	tableNames := make(map[uint32]string, len(oids))
+	for rows.Next() {
+		var oid uint32
+		var name string
+		if err := rows.Scan(&oid, &name); err != nil {
+			return fmt.Errorf("failed to resolve tables for duplicate columns: %w", err)
+		}
+		tableNames[oid] = name
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to resolve tables for duplicate columns: %w", err)
+	}
+
+	qualified := make([]string, len(fields))
+	count := map[string]int{}
+	for i, fd := range fields {
+		if table, ok := tableNames[fd.TableOID]; ok && dups[fd.Name] {
+			qualified[i] = table + "." + fd.Name
+			count[qualified[i]]++
+		}
+	}
+	taken := make(map[string]bool, len(result.Columns))
+	for _, col := range result.Columns {
+		taken[col] = true
+	}
+	mapping := map[string]string{}
+	for i, name := range qualified {
+		if name != "" && count[name] == 1 && !taken[name] {
+			mapping[result.Columns[i]] = name
+		}
+	}
+	return RenameColumns(result, mapping)
+}
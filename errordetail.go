@@ -0,0 +1,57 @@
+package pgmcp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Config.ErrorDetailMode values.
+const (
+	// ErrorDetailFull (the default) returns Postgres error messages as reported.
+	ErrorDetailFull = "full"
+	// ErrorDetailMinimal replaces Postgres error messages with a generic description of
+	// their SQLSTATE class, so names of constraints, columns, and values never reach the
+	// agent. Errors raised by postgres-mcp itself are unchanged.
+	ErrorDetailMinimal = "minimal"
+)
+
+// sqlstateClassMessages describes each SQLSTATE class for ErrorDetailMinimal.
+var sqlstateClassMessages = map[string]string{
+	"08": "a connection error occurred",
+	"0A": "the statement uses a feature that is not supported",
+	"21": "a subquery or row returned more values than expected",
+	"22": "a value was invalid or out of range for its type",
+	"23": "a constraint was violated",
+	"25": "the statement is not allowed in the current transaction state",
+	"28": "authorization failed",
+	"2B": "the object has dependent privileges",
+	"3D": "the database does not exist",
+	"3F": "the schema does not exist",
+	"40": "the transaction was rolled back because of a serialization failure or deadlock: retry it",
+	"42": "the statement is invalid, or refers to an object that does not exist or that you may not access",
+	"44": "a row violated a view's WITH CHECK OPTION",
+	"53": "the server ran out of resources",
+	"54": "the statement exceeds a server limit",
+	"55": "an object is not in the required state, e.g. it is locked by another transaction",
+	"57": "the statement was cancelled, e.g. by a timeout",
+	"58": "a system error occurred on the server",
+	"P0": "a function raised an error",
+	"XX": "an internal server error occurred",
+}
+
+// minimalErrorMessage returns the ErrorDetailMinimal message for err: a generic
+// description of its SQLSTATE class, keeping the SQLSTATE so the agent can still tell
+// errors apart. ok is false when err carries no Postgres error.
+func minimalErrorMessage(err error) (msg string, ok bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return "", false
+	}
+	description, known := sqlstateClassMessages[pgErr.Code[:min(2, len(pgErr.Code))]]
+	if !known {
+		description = "the database reported an error"
+	}
+	return fmt.Sprintf("database error (SQLSTATE %s): %s", pgErr.Code, description), true
+}
@@ -0,0 +1,80 @@
+package pgmcp
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rickchristie/postgres-mcp/internal/protection"
+)
+
+// ErrorKind values for QueryOutput.ErrorKind. They are stable: agents and embedders may
+// branch on them instead of matching error text.
+const (
+	ErrorKindProtection = "protection" // rejected by a protection rule or query limit before or instead of running
+	ErrorKindHook       = "hook"       // a BeforeQuery or AfterQuery hook rejected, failed, or timed out
+	ErrorKindTimeout    = "timeout"    // statement_timeout, lock_timeout, or another deadline expired
+	ErrorKindTruncation = "truncation" // the result was too large to return
+	ErrorKindSemaphore  = "semaphore"  // no query slot was available (concurrency limits, shutdown)
+	ErrorKindDatabase   = "database"   // any other error reported by Postgres or the connection
+	ErrorKindReadOnly   = "readonly"   // a write was attempted in a read-only transaction
+	ErrorKindParse      = "parse"      // the SQL could not be parsed
+)
+
+// kindError tags err with the ErrorKind reported for it.
+type kindError struct {
+	kind string
+	err  error
+}
+
+func (e *kindError) Error() string { return e.err.Error() }
+func (e *kindError) Unwrap() error { return e.err }
+
+// withKind tags err with kind. Returns nil if err is nil.
+func withKind(kind string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// errorKind classifies err for QueryOutput.ErrorKind. An explicit kind from withKind wins,
+// except that parse failures are always reported as "parse"; untagged errors are
+// classified by SQLSTATE, defaulting to "database".
+func errorKind(err error) string {
+	var parseErr *protection.ParseError
+	if errors.As(err, &parseErr) {
+		return ErrorKindParse
+	}
+	var kindErr *kindError
+	if errors.As(err, &kindErr) {
+		return kindErr.kind
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "25006": // read_only_sql_transaction
+			return ErrorKindReadOnly
+		case "57014", "25P03": // query_canceled, idle_in_transaction_session_timeout
+			return ErrorKindTimeout
+		case "55P03": // lock_not_available: lock_timeout, or NOWAIT finding the lock taken
+			if isLockTimeout(pgErr) {
+				return ErrorKindTimeout
+			}
+		case "42601": // syntax_error
+			return ErrorKindParse
+		}
+		return ErrorKindDatabase
+	}
+	if errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err) {
+		return ErrorKindTimeout
+	}
+	return ErrorKindDatabase
+}
+
+// isLockTimeout reports whether pgErr is lock_timeout cancelling a statement. NOWAIT
+// (SELECT ... FOR UPDATE NOWAIT, LOCK ... NOWAIT) raises the same SQLSTATE 55P03 without
+// waiting, so only the server's message tells them apart (with English lc_messages).
+func isLockTimeout(pgErr *pgconn.PgError) bool {
+	return pgErr.Code == "55P03" && pgErr.Message == "canceling statement due to lock timeout"
+}
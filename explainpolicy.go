@@ -0,0 +1,91 @@
+package pgmcp
+
+import (
+	"fmt"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+	"github.com/rickchristie/postgres-mcp/internal/protection"
+)
+
+// ExplainOptionPolicy.Action values.
+const (
+	// ExplainOptionStrip (the default) removes disallowed options and runs the rest.
+	ExplainOptionStrip = "strip"
+	// ExplainOptionReject fails the query when a disallowed option is turned on.
+	ExplainOptionReject = "reject"
+)
+
+// explainOptions are the options Postgres accepts in EXPLAIN (...).
+var explainOptions = map[string]bool{
+	"analyze": true, "verbose": true, "costs": true, "settings": true, "generic_plan": true,
+	"buffers": true, "serialize": true, "wal": true, "timing": true, "summary": true,
+	"memory": true, "format": true,
+}
+
+// enabled reports whether the policy changes anything.
+func (e ExplainOptionPolicy) enabled() bool {
+	return len(e.Disallowed) > 0 || e.ForceTimingOff
+}
+
+// applyExplainOptionPolicy rewrites the options of a top-level EXPLAIN according to
+// policy, or returns an error naming the first disallowed option under
+// ExplainOptionReject. Other statements are returned unchanged. Returns the SQL to
+// execute; it is only deparsed when an option was actually changed.
+func applyExplainOptionPolicy(sql string, policy ExplainOptionPolicy) (string, error) {
+	tree, err := pg_query.Parse(sql)
+	if err != nil || len(tree.Stmts) != 1 {
+		return sql, nil
+	}
+	explain := tree.Stmts[0].Stmt.GetExplainStmt()
+	if explain == nil {
+		return sql, nil
+	}
+
+	disallowed := make(map[string]bool, len(policy.Disallowed))
+	for _, name := range policy.Disallowed {
+		disallowed[strings.ToLower(name)] = true
+	}
+	changed := false
+	analyze := false
+	options := make([]*pg_query.Node, 0, len(explain.Options)+1)
+	for _, opt := range explain.Options {
+		def := opt.GetDefElem()
+		if def == nil {
+			options = append(options, opt)
+			continue
+		}
+		name := strings.ToLower(def.Defname)
+		if disallowed[name] {
+			if policy.Action != ExplainOptionReject {
+				changed = true
+				continue
+			}
+			if protection.ExplainOptionEnabled(def) {
+				return "", fmt.Errorf("EXPLAIN option %s is not allowed (query.explain_option_policy): remove it and retry", strings.ToUpper(name))
+			}
+		}
+		if name == "analyze" && protection.ExplainOptionEnabled(def) {
+			analyze = true
+		}
+		if name == "timing" && policy.ForceTimingOff {
+			changed = true
+			continue
+		}
+		options = append(options, opt)
+	}
+	// TIMING is only valid together with ANALYZE.
+	if analyze && policy.ForceTimingOff {
+		options = append(options, pg_query.MakeSimpleDefElemNode("timing", pg_query.MakeStrNode("off"), -1))
+		changed = true
+	}
+	if !changed {
+		return sql, nil
+	}
+	explain.Options = options
+	rewritten, err := pg_query.Deparse(tree)
+	if err != nil {
+		return "", fmt.Errorf("failed to apply query.explain_option_policy: %w", err)
+	}
+	return rewritten, nil
+}
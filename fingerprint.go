@@ -0,0 +1,11 @@
+package pgmcp
+
+import "github.com/rickchristie/postgres-mcp/internal/protection"
+
+// QueryFingerprint returns the fingerprint of sql for protection.allowed_query_fingerprints:
+// 16 hex digits identifying the statement's structure. Queries that differ only in literal
+// values, parameters ($1), the number of items in an IN list, comments, whitespace, or
+// keyword case share a fingerprint. Returns an error if sql does not parse.
+func QueryFingerprint(sql string) (string, error) {
+	return protection.Fingerprint(sql)
+}
@@ -0,0 +1,192 @@
+package pgmcp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+	"github.com/rickchristie/postgres-mcp/internal/protection"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// funcCatalogCacheTTL is how long a function's catalog lookup is cached.
+const funcCatalogCacheTTL = time.Minute
+
+// funcName is a function name as written in SQL; schema is empty when unqualified.
+type funcName struct {
+	schema string
+	name   string
+}
+
+func (f funcName) String() string {
+	if f.schema == "" {
+		return f.name
+	}
+	return f.schema + "." + f.name
+}
+
+// funcCatalogChecker rejects statements that call functions matching a pg_proc condition,
+// caching lookups per function name: SECURITY DEFINER functions
+// (protection.block_security_definer_calls) and volatile functions in read-only
+// transactions (protection.block_volatile_in_read_only). query.single_flight_reads also
+// uses one to keep reads calling volatile functions from being coalesced.
+type funcCatalogChecker struct {
+	condition string                 // SQL condition on pg_proc p, e.g. "p.prosecdef"
+	what      string                 // the functions matched, for lookup errors
+	blocked   func(f funcName) error // the error for a call to a matching function
+
+	mu    sync.Mutex
+	cache map[funcName]funcCatalogEntry
+}
+
+type funcCatalogEntry struct {
+	matches bool
+	expires time.Time
+}
+
+func newSecurityDefinerChecker() *funcCatalogChecker {
+	return &funcCatalogChecker{
+		condition: "p.prosecdef",
+		what:      "SECURITY DEFINER functions",
+		blocked: func(f funcName) error {
+			return withKind(ErrorKindProtection, fmt.Errorf("call to SECURITY DEFINER function %s is not allowed: it runs with its owner's privileges", f))
+		},
+		cache: map[funcName]funcCatalogEntry{},
+	}
+}
+
+func newVolatileChecker() *funcCatalogChecker {
+	return &funcCatalogChecker{
+		condition: "p.provolatile = 'v'",
+		what:      "volatile functions",
+		blocked: func(f funcName) error {
+			return withKind(ErrorKindProtection, fmt.Errorf("volatile function %q not allowed in read-only mode: volatile functions can have side effects; use a STABLE or IMMUTABLE alternative", f.String()))
+		},
+		cache: map[funcName]funcCatalogEntry{},
+	}
+}
+
+// catalogQuerier runs the pg_proc lookup: a transaction, or the pool outside one.
+type catalogQuerier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// check returns an error if sql calls a matching function. Unqualified names match any
+// visible function with that name (any overload), qualified names any in that schema.
+// Only direct calls are seen: functions reached through views, operators, or triggers are not.
+func (c *funcCatalogChecker) check(ctx context.Context, q catalogQuerier, sql string) error {
+	f, err := c.find(ctx, q, sql)
+	if err != nil {
+		return err
+	}
+	if f != nil {
+		return c.blocked(*f)
+	}
+	return nil
+}
+
+// find returns the first matching function sql calls, or nil if it calls none, matching
+// names as check does.
+func (c *funcCatalogChecker) find(ctx context.Context, q catalogQuerier, sql string) (*funcName, error) {
+	names := referencedFunctions(sql)
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	var uncached []funcName
+	c.mu.Lock()
+	for _, f := range names {
+		entry, ok := c.cache[f]
+		if !ok || now.After(entry.expires) {
+			uncached = append(uncached, f)
+		} else if entry.matches {
+			c.mu.Unlock()
+			return &f, nil
+		}
+	}
+	c.mu.Unlock()
+	if len(uncached) == 0 {
+		return nil, nil
+	}
+
+	schemas := make([]string, len(uncached))
+	funcs := make([]string, len(uncached))
+	for i, f := range uncached {
+		schemas[i], funcs[i] = f.schema, f.name
+	}
+	rows, err := q.Query(ctx, fmt.Sprintf(`
+		SELECT r.schema_name, r.func_name, EXISTS (
+			SELECT 1
+			FROM pg_catalog.pg_proc p
+			JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+			WHERE p.proname = r.func_name AND %s
+			  AND CASE WHEN r.schema_name = '' THEN pg_catalog.pg_function_is_visible(p.oid)
+			           ELSE n.nspname = r.schema_name END
+		)
+		FROM unnest($1::text[], $2::text[]) AS r(schema_name, func_name)`, c.condition), schemas, funcs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %w", c.what, err)
+	}
+	defer rows.Close()
+	var found *funcName
+	expires := now.Add(funcCatalogCacheTTL)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for rows.Next() {
+		var f funcName
+		var matches bool
+		if err := rows.Scan(&f.schema, &f.name, &matches); err != nil {
+			return nil, fmt.Errorf("failed to look up %s: %w", c.what, err)
+		}
+		c.cache[f] = funcCatalogEntry{matches: matches, expires: expires}
+		if matches && found == nil {
+			found = &f
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %w", c.what, err)
+	}
+	return found, nil
+}
+
+// referencedFunctions returns the distinct functions called anywhere in sql (including
+// CALL and subqueries), in order of appearance. Returns nil if sql does not parse.
+func referencedFunctions(sql string) []funcName {
+	tree, err := pg_query.Parse(sql)
+	if err != nil {
+		return nil
+	}
+	seen := map[funcName]bool{}
+	var names []funcName
+	for _, stmt := range tree.Stmts {
+		protection.Walk(stmt.ProtoReflect(), func(m protoreflect.Message) error {
+			if call, ok := m.Interface().(*pg_query.FuncCall); ok {
+				if f, ok := funcNameOf(call.Funcname); ok && !seen[f] {
+					seen[f] = true
+					names = append(names, f)
+				}
+			}
+			return nil
+		})
+	}
+	return names
+}
+
+// funcNameOf converts a FuncCall name list ("name", "schema.name", or "db.schema.name").
+func funcNameOf(parts []*pg_query.Node) (funcName, bool) {
+	strs := make([]string, len(parts))
+	for i, part := range parts {
+		strs[i] = part.GetString_().GetSval()
+	}
+	switch len(strs) {
+	case 1:
+		return funcName{name: strs[0]}, true
+	case 2, 3:
+		return funcName{schema: strs[len(strs)-2], name: strs[len(strs)-1]}, true
+	}
+	return funcName{}, false
+}
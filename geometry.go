@@ -0,0 +1,79 @@
+package pgmcp
+
+import "github.com/jackc/pgx/v5/pgtype"
+
+// geometryObjectConverter wraps convert so that geometric values (including inside
+// arrays) become JSON objects instead of Postgres text syntax (query.geometry_as_object):
+//
+//	point   {"x":1.5,"y":2.5}
+//	line    {"a":1,"b":2,"c":3}            (Ax + By + C = 0)
+//	lseg    {"start":point,"end":point}
+//	box     {"high":point,"low":point}     (upper-right and lower-left corners)
+//	path    {"points":[point...],"closed":bool}
+//	polygon {"points":[point...]}
+//	circle  {"center":point,"radius":5}
+//
+// Other values are passed to convert.
+func geometryObjectConverter(convert func(interface{}) interface{}) func(interface{}) interface{} {
+	var wrapped func(interface{}) interface{}
+	wrapped = func(v interface{}) interface{} {
+		switch val := v.(type) {
+		case pgtype.Point:
+			if !val.Valid {
+				return nil
+			}
+			return pointObject(val.P)
+		case pgtype.Line:
+			if !val.Valid {
+				return nil
+			}
+			return map[string]interface{}{"a": val.A, "b": val.B, "c": val.C}
+		case pgtype.Lseg:
+			if !val.Valid {
+				return nil
+			}
+			return map[string]interface{}{"start": pointObject(val.P[0]), "end": pointObject(val.P[1])}
+		case pgtype.Box:
+			if !val.Valid {
+				return nil
+			}
+			return map[string]interface{}{"high": pointObject(val.P[0]), "low": pointObject(val.P[1])}
+		case pgtype.Path:
+			if !val.Valid {
+				return nil
+			}
+			return map[string]interface{}{"points": pointObjects(val.P), "closed": val.Closed}
+		case pgtype.Polygon:
+			if !val.Valid {
+				return nil
+			}
+			return map[string]interface{}{"points": pointObjects(val.P)}
+		case pgtype.Circle:
+			if !val.Valid {
+				return nil
+			}
+			return map[string]interface{}{"center": pointObject(val.P), "radius": val.R}
+		case []interface{}:
+			result := make([]interface{}, len(val))
+			for i, v := range val {
+				result[i] = wrapped(v)
+			}
+			return result
+		default:
+			return convert(v)
+		}
+	}
+	return wrapped
+}
+
+func pointObject(p pgtype.Vec2) map[string]interface{} {
+	return map[string]interface{}{"x": p.X, "y": p.Y}
+}
+
+func pointObjects(points []pgtype.Vec2) []interface{} {
+	result := make([]interface{}, len(points))
+	for i, p := range points {
+		result[i] = pointObject(p)
+	}
+	return result
+}
@@ -9,6 +9,7 @@ require (
 	github.com/rickchristie/govner/pgflock v1.2.5
 	github.com/rs/zerolog v1.34.0
 	golang.org/x/term v0.40.0
+	google.golang.org/protobuf v1.31.0
 )
 
 require (
@@ -28,6 +29,5 @@ require (
 	golang.org/x/sync v0.17.0 // indirect
 	golang.org/x/sys v0.41.0 // indirect
 	golang.org/x/text v0.29.0 // indirect
-	google.golang.org/protobuf v1.31.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -5,11 +5,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 	"unicode/utf8"
 
+	"github.com/jackc/pgx/v5"
 	pgmcp "github.com/rickchristie/postgres-mcp"
 	"github.com/rs/zerolog"
 )
@@ -43,6 +51,42 @@ func TestQuery_SelectBasic(t *testing.T) {
 	}
 }
 
+func TestQuery_ColumnOrderPreserved(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE letters (a int, b int, c int)")
+	setupTable(t, p, "INSERT INTO letters VALUES (1, 2, 3)")
+
+	// Repeat to catch any map-iteration nondeterminism.
+	for i := 0; i < 20; i++ {
+		output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT c, a, b FROM letters"})
+		if output.Error != "" {
+			t.Fatalf("unexpected error: %s", output.Error)
+		}
+		if fmt.Sprint(output.Columns) != "[c a b]" {
+			t.Fatalf("expected columns [c a b], got %v", output.Columns)
+		}
+		csv, err := p.FormatCSV(output)
+		if err != nil {
+			t.Fatalf("unexpected CSV error: %v", err)
+		}
+		if csv != "c,a,b\n3,1,2\n" {
+			t.Fatalf("expected CSV in column order, got %q", csv)
+		}
+	}
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "INSERT INTO letters VALUES (4, 5, 6) RETURNING b, c, a"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if fmt.Sprint(output.Columns) != "[b c a]" {
+		t.Fatalf("expected RETURNING columns [b c a], got %v", output.Columns)
+	}
+}
+
 func TestQuery_Insert(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
@@ -145,6 +189,52 @@ func TestQuery_NullValues(t *testing.T) {
 	}
 }
 
+func TestQuery_NullStringNotAppliedToRowsByDefault(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Query.NullString = "NULL"
+	p, _ := newTestInstance(t, config)
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT NULL::text AS name, 'a'::text AS other"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if output.Rows[0]["name"] != nil {
+		t.Fatalf("expected nil for name, got %v", output.Rows[0]["name"])
+	}
+
+	csvOut, err := p.FormatCSV(output)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if csvOut != "name,other\nNULL,a\n" {
+		t.Fatalf("expected %q, got %q", "name,other\nNULL,a\n", csvOut)
+	}
+}
+
+func TestQuery_NullStringInRows(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Query.NullString = "<null>"
+	config.Query.NullStringInRows = true
+	p, _ := newTestInstance(t, config)
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT NULL::int AS n, 1 AS one, ARRAY[NULL, 'x']::text[] AS arr"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if output.Rows[0]["n"] != "<null>" {
+		t.Fatalf("expected n='<null>', got %v", output.Rows[0]["n"])
+	}
+	if output.Rows[0]["one"] != int32(1) {
+		t.Fatalf("expected one=int32(1), got %T(%v)", output.Rows[0]["one"], output.Rows[0]["one"])
+	}
+	arr, ok := output.Rows[0]["arr"].([]interface{})
+	if !ok || len(arr) != 2 || arr[0] != nil || arr[1] != "x" {
+		t.Fatalf("expected arr=[nil x] (nested NULLs untouched), got %v", output.Rows[0]["arr"])
+	}
+}
+
 func TestQuery_UUIDColumn(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
@@ -332,6 +422,9 @@ func TestQuery_Timeout(t *testing.T) {
 	if !strings.Contains(output.Error, "context deadline exceeded") && !strings.Contains(output.Error, "canceling statement") {
 		t.Fatalf("expected timeout error, got %q", output.Error)
 	}
+	if output.ErrorKind != pgmcp.ErrorKindTimeout {
+		t.Fatalf("expected error kind %q, got %q", pgmcp.ErrorKindTimeout, output.ErrorKind)
+	}
 }
 
 func TestQuery_ProtectionEndToEnd(t *testing.T) {
@@ -346,6 +439,14 @@ func TestQuery_ProtectionEndToEnd(t *testing.T) {
 	if !strings.Contains(output.Error, "DROP statements are not allowed") {
 		t.Fatalf("expected drop error, got %q", output.Error)
 	}
+	if output.ErrorKind != pgmcp.ErrorKindProtection {
+		t.Fatalf("expected error kind %q, got %q", pgmcp.ErrorKindProtection, output.ErrorKind)
+	}
+
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELEC 1"})
+	if output.ErrorKind != pgmcp.ErrorKindParse {
+		t.Fatalf("expected error kind %q for unparseable SQL, got %q (%s)", pgmcp.ErrorKindParse, output.ErrorKind, output.Error)
+	}
 }
 
 func TestQuery_SanitizationEndToEnd(t *testing.T) {
@@ -370,6 +471,194 @@ func TestQuery_SanitizationEndToEnd(t *testing.T) {
 	}
 }
 
+func TestQuery_SanitizationPresets(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Sanitization = []pgmcp.SanitizationRule{
+		{Pattern: `secret-\w+`, Replacement: "secret-***"},
+	}
+	config.SanitizationPresets = []string{pgmcp.SanitizationPresetEmail, pgmcp.SanitizationPresetCreditCard}
+	p, _ := newTestInstance(t, config)
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 'alice@example.com' AS email, '4111 1111 1111 1234' AS card, 'secret-abc' AS token, '212-555-0123' AS phone"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	expected := map[string]interface{}{
+		"email": "***@example.com",
+		"card":  "****1234",
+		"token": "secret-***",
+		// us_phone is not enabled.
+		"phone": "212-555-0123",
+	}
+	if !reflect.DeepEqual(output.Rows[0], expected) {
+		t.Fatalf("expected %v, got %v", expected, output.Rows[0])
+	}
+}
+
+func TestQuery_SanitizationMaxScannedCells(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Sanitization = []pgmcp.SanitizationRule{
+		{Pattern: `\d{3}-\d{3}-\d{4}`, Replacement: "***-***-****"},
+	}
+	config.SanitizationMaxScannedCells = 4
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE contacts_capped (id int, phone text)")
+
+	// 2 rows × 2 columns fits.
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "INSERT INTO contacts_capped VALUES (1, '555-123-4567'), (2, '555-987-6543') RETURNING *"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if phone := output.Rows[0]["phone"]; phone != "***-***-****" {
+		t.Fatalf("expected sanitized phone, got %v", phone)
+	}
+
+	// 3 rows × 2 columns is over the cap: fail closed, rolling the write back.
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "INSERT INTO contacts_capped VALUES (3, '555-000-0001'), (4, '555-000-0002'), (5, '555-000-0003') RETURNING *"})
+	if !strings.Contains(output.Error, "result has 6 cells, more than sanitization can scan") {
+		t.Fatalf("expected sanitization cap error, got rows=%v err=%q", output.Rows, output.Error)
+	}
+	if strings.Contains(output.Error, "555-000") {
+		t.Fatalf("error leaked unsanitized data: %q", output.Error)
+	}
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT count(*) AS n FROM contacts_capped"})
+	if output.Error != "" || output.Rows[0]["n"] != int64(2) {
+		t.Fatalf("expected the over-cap write to be rolled back, got rows=%v err=%q", output.Rows, output.Error)
+	}
+}
+
+func TestQuery_SanitizationMaxScannedCellsSkip(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Sanitization = []pgmcp.SanitizationRule{
+		{Pattern: `\d{3}-\d{3}-\d{4}`, Replacement: "***-***-****"},
+	}
+	config.SanitizationMaxScannedCells = 1
+	config.SanitizationOverLimit = pgmcp.SanitizationOverLimitSkip
+	p, _ := newTestInstance(t, config)
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT '555-123-4567' AS phone FROM generate_series(1, 2)"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if phone := output.Rows[0]["phone"]; phone != "555-123-4567" {
+		t.Fatalf("expected the result unsanitized, got %v", phone)
+	}
+}
+
+func TestQuery_MaskColumns(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.MaskColumns = []string{"ssn"}
+	p, connStr := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE people (id int, name text, ssn text)")
+	setupTable(t, p, "INSERT INTO people VALUES (1, 'alice', '123-45-6789'), (2, 'bob', NULL)")
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT id, name, ssn FROM people ORDER BY id"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	for _, row := range output.Rows {
+		if row["ssn"] != "***" {
+			t.Fatalf("expected ssn masked, got %v", row["ssn"])
+		}
+	}
+	if output.Rows[0]["id"] != int32(1) || output.Rows[0]["name"] != "alice" {
+		t.Fatalf("expected other columns intact, got %v", output.Rows[0])
+	}
+
+	output = p.Query(context.Background(), pgmcp.QueryInput{
+		SQL: "INSERT INTO people VALUES (3, 'carol', '987-65-4321') RETURNING name, ssn",
+	})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if output.Rows[0]["ssn"] != "***" || output.Rows[0]["name"] != "carol" {
+		t.Fatalf("expected RETURNING ssn masked and name intact, got %v", output.Rows[0])
+	}
+
+	// Masking only affects results; the committed value is unchanged.
+	conn, err := pgx.Connect(context.Background(), connStr)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close(context.Background())
+	var ssn string
+	if err := conn.QueryRow(context.Background(), "SELECT ssn FROM people WHERE id = 3").Scan(&ssn); err != nil {
+		t.Fatalf("failed to read ssn: %v", err)
+	}
+	if ssn != "987-65-4321" {
+		t.Fatalf("expected stored ssn unchanged, got %q", ssn)
+	}
+}
+
+func TestQuery_MaskColumnsTableQualified(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.MaskColumns = []string{"employees.ssn"}
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE employees (ssn text)")
+	setupTable(t, p, "CREATE TABLE vendors (ssn text)")
+	setupTable(t, p, "INSERT INTO employees VALUES ('111-11-1111')")
+	setupTable(t, p, "INSERT INTO vendors VALUES ('222-22-2222')")
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{
+		SQL: "SELECT e.ssn, v.ssn AS vendor_ssn FROM employees e, vendors v",
+	})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if output.Rows[0]["ssn"] != "***" {
+		t.Fatalf("expected employees.ssn masked, got %v", output.Rows[0]["ssn"])
+	}
+	if output.Rows[0]["vendor_ssn"] != "222-22-2222" {
+		t.Fatalf("expected vendors.ssn intact, got %v", output.Rows[0]["vendor_ssn"])
+	}
+}
+
+func TestQuery_MaskColumnsAliased(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.MaskColumns = []string{"ssn", "accounts.password_hash"}
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE accounts (id int, ssn text, password_hash text)")
+	setupTable(t, p, "INSERT INTO accounts VALUES (1, '123-45-6789', 'hash')")
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{
+		SQL: `SELECT a.ssn AS x, a.password_hash AS "SSN", a.id AS ssn, upper(a.ssn) AS y FROM accounts a`,
+	})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	row := output.Rows[0]
+	if row["x"] != "***" || row["SSN"] != "***" {
+		t.Fatalf("expected aliased source columns masked, got %v", row)
+	}
+	// A table column is matched by its source name, not its alias; a computed
+	// expression has no source column and is matched by its output name only.
+	if row["ssn"] != int32(1) || row["y"] != "123-45-6789" {
+		t.Fatalf("expected id and the computed column intact, got %v", row)
+	}
+
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT upper(ssn) AS ssn FROM accounts"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if output.Rows[0]["ssn"] != "***" {
+		t.Fatalf("expected computed column named ssn masked, got %v", output.Rows[0]["ssn"])
+	}
+}
+
 func TestQuery_ErrorPromptEndToEnd(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
@@ -459,6 +748,99 @@ func TestQuery_ReadOnlyMode(t *testing.T) {
 	}
 }
 
+func TestQuery_TreatAsWriteFunctions(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Protection.AllowCreateFunction = true
+	p, connStr := newTestInstance(t, config)
+	setupTable(t, p, "CREATE TABLE fn_target (id int)")
+	setupTable(t, p, "CREATE FUNCTION dangerous_fn() RETURNS int LANGUAGE sql AS 'INSERT INTO fn_target VALUES (1) RETURNING id'")
+	ctx := context.Background()
+
+	// Read-only: blocked by protection instead of failing in the server.
+	roConfig := defaultConfig()
+	roConfig.ReadOnly = true
+	roConfig.Protection.TreatAsWriteFunctions = []string{"dangerous_fn"}
+	ro, err := pgmcp.New(ctx, connStr, roConfig, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
+	}
+	defer ro.Close(ctx)
+	output := ro.Query(ctx, pgmcp.QueryInput{SQL: "SELECT dangerous_fn()"})
+	if !strings.Contains(output.Error, "function dangerous_fn() is not allowed in read-only mode") || output.ErrorKind != pgmcp.ErrorKindProtection {
+		t.Fatalf("expected a protection error, got %q (kind %q)", output.Error, output.ErrorKind)
+	}
+
+	// Unlisted, the SELECT is a read and its write is rolled back with the transaction.
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT dangerous_fn()"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS n FROM fn_target"})
+	if output.Rows[0]["n"] != int64(0) {
+		t.Fatalf("expected the unlisted call's insert to be rolled back, got %v rows", output.Rows[0]["n"])
+	}
+
+	// Listed, it runs and commits as a write.
+	rwConfig := defaultConfig()
+	rwConfig.Protection.TreatAsWriteFunctions = []string{"public.dangerous_fn"}
+	rw, err := pgmcp.New(ctx, connStr, rwConfig, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
+	}
+	defer rw.Close(ctx)
+	output = rw.Query(ctx, pgmcp.QueryInput{SQL: "SELECT dangerous_fn()"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS n FROM fn_target"})
+	if output.Rows[0]["n"] != int64(1) {
+		t.Fatalf("expected the listed call's insert to be committed, got %v rows", output.Rows[0]["n"])
+	}
+}
+
+func TestQuery_ReadOnlyModeServerEnforced(t *testing.T) {
+	t.Parallel()
+	connStr := acquireTestDB(t)
+	ctx := context.Background()
+
+	// A SELECT calling a function that writes passes the statement checks; only the
+	// server-side read-only transaction stops the write.
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setupConfig.Protection.AllowCreateFunction = true
+	setup, err := pgmcp.New(ctx, connStr, setupConfig, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create setup instance: %v", err)
+	}
+	setupTable(t, setup, "CREATE TABLE ro_target (id int)")
+	setupTable(t, setup, "CREATE FUNCTION sneaky_write() RETURNS int LANGUAGE sql AS 'INSERT INTO ro_target VALUES (1) RETURNING id'")
+	setup.Close(ctx)
+
+	config := defaultConfig()
+	config.ReadOnly = true
+	p, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create read-only instance: %v", err)
+	}
+	defer p.Close(ctx)
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT current_setting('transaction_read_only') AS ro"})
+	if output.Error != "" || len(output.Rows) != 1 || output.Rows[0]["ro"] != "on" {
+		t.Fatalf("expected a read-only transaction, got rows=%v err=%q", output.Rows, output.Error)
+	}
+
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT sneaky_write()"})
+	if !strings.Contains(output.Error, "read-only transaction") {
+		t.Fatalf("expected the write to fail in the read-only transaction, got rows=%v err=%q", output.Rows, output.Error)
+	}
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS n FROM ro_target"})
+	if output.Error != "" || fmt.Sprint(output.Rows[0]["n"]) != "0" {
+		t.Fatalf("expected no rows written, got rows=%v err=%q", output.Rows, output.Error)
+	}
+}
+
 func TestQuery_ReadOnlyModeBlocksSetBypass(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
@@ -553,6 +935,9 @@ func TestQuery_ReadOnlyBlocksInsert(t *testing.T) {
 	if !strings.Contains(output.Error, "read-only transaction") {
 		t.Fatalf("expected read-only transaction error, got: %s", output.Error)
 	}
+	if output.ErrorKind != pgmcp.ErrorKindReadOnly {
+		t.Fatalf("expected error kind %q, got %q", pgmcp.ErrorKindReadOnly, output.ErrorKind)
+	}
 }
 
 func TestQuery_ReadOnlyBlocksUpdate(t *testing.T) {
@@ -902,796 +1287,1122 @@ func TestQuery_TimezoneUTC(t *testing.T) {
 	}
 }
 
-func TestQuery_MaxSQLLength(t *testing.T) {
+func TestQuery_TimezoneUnknownToServer(t *testing.T) {
 	t.Parallel()
-	config := defaultConfig()
-	config.Query.MaxSQLLength = 100
-	p, _ := newTestInstance(t, config)
+	connStr := acquireTestDB(t)
+	ctx := context.Background()
 
-	longSQL := "SELECT " + strings.Repeat("1,", 100) + "1"
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: longSQL})
-	if output.Error == "" {
-		t.Fatal("expected SQL length error")
-	}
-	if !strings.Contains(output.Error, "SQL query too long") {
-		t.Fatalf("expected SQL length error, got %q", output.Error)
+	// Go's time.LoadLocation accepts "Local", but it is not in Postgres's time zone database.
+	const zone = "Local"
+	if _, err := time.LoadLocation(zone); err != nil {
+		t.Fatalf("expected Go to accept %q: %v", zone, err)
 	}
-}
 
-func TestQuery_DDLBlocked(t *testing.T) {
-	t.Parallel()
 	config := defaultConfig()
-	p, _ := newTestInstance(t, config)
-
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "CREATE TABLE test (id int)"})
-	if output.Error == "" {
-		t.Fatal("expected DDL blocked error")
+	config.Timezone = zone
+	p, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create PostgresMcp: %v", err)
 	}
-	if !strings.Contains(output.Error, "CREATE TABLE is not allowed") {
-		t.Fatalf("expected DDL error, got %q", output.Error)
+	defer p.Close(ctx)
+	if err := p.WaitReady(ctx); err == nil {
+		t.Fatal("expected WaitReady to fail for a timezone the server rejects")
+	} else if !strings.Contains(err.Error(), `timezone "Local" is not recognized by the Postgres server`) {
+		t.Fatalf("unexpected error: %v", err)
 	}
-}
-
-func TestQuery_DDLAllowed(t *testing.T) {
-	t.Parallel()
-	config := defaultConfig()
-	config.Protection.AllowDDL = true
-	p, _ := newTestInstance(t, config)
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "CREATE TABLE test (id int)"})
+	var buf bytes.Buffer
+	config.TimezoneFallback = pgmcp.TimezoneFallbackUTC
+	p, err = pgmcp.New(ctx, connStr, config, zerolog.New(&buf))
+	if err != nil {
+		t.Fatalf("failed to create PostgresMcp: %v", err)
+	}
+	defer p.Close(ctx)
+	if err := p.WaitReady(ctx); err != nil {
+		t.Fatalf("expected WaitReady to fall back to UTC, got: %v", err)
+	}
+	if !strings.Contains(buf.String(), "using UTC instead") {
+		t.Fatalf("expected a fallback warning, got logs: %s", buf.String())
+	}
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT current_setting('timezone') AS tz"})
 	if output.Error != "" {
-		t.Fatalf("expected DDL to succeed when allowed: %s", output.Error)
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if output.Rows[0]["tz"] != "UTC" {
+		t.Fatalf("expected UTC, got %v", output.Rows[0]["tz"])
 	}
 }
 
-func TestQuery_TransactionControlBlocked(t *testing.T) {
+func TestQuery_TimezoneVerifiedWithoutWaitReady(t *testing.T) {
 	t.Parallel()
-	config := defaultConfig()
-	p, _ := newTestInstance(t, config)
+	connStr := acquireTestDB(t)
+	ctx := context.Background()
 
-	for _, sql := range []string{"BEGIN", "COMMIT", "ROLLBACK"} {
-		output := p.Query(context.Background(), pgmcp.QueryInput{SQL: sql})
-		if output.Error == "" {
-			t.Fatalf("expected error for %s", sql)
-		}
-		if !strings.Contains(output.Error, "transaction control statements are not allowed") {
-			t.Fatalf("expected transaction control error for %s, got %q", sql, output.Error)
-		}
-	}
-}
-
-func TestQuery_RowsAffected_Insert(t *testing.T) {
-	t.Parallel()
+	// Without WaitReady, the first tool call verifies the timezone.
 	config := defaultConfig()
-	config.Protection.AllowDDL = true
-	p, _ := newTestInstance(t, config)
-
-	setupTable(t, p, "CREATE TABLE users (id serial PRIMARY KEY, name text)")
-
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "INSERT INTO users (name) VALUES ('a'), ('b'), ('c')"})
-	if output.Error != "" {
-		t.Fatalf("unexpected error: %s", output.Error)
+	config.Timezone = "Local"
+	p, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create PostgresMcp: %v", err)
 	}
-	if output.RowsAffected != 3 {
-		t.Fatalf("expected RowsAffected=3, got %d", output.RowsAffected)
+	defer p.Close(ctx)
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 1"})
+	if !strings.Contains(output.Error, `database startup check failed`) || !strings.Contains(output.Error, `timezone "Local" is not recognized by the Postgres server`) {
+		t.Fatalf("expected the timezone check to refuse the query, got %q", output.Error)
 	}
-}
-
-func TestQuery_RowsAffected_Update(t *testing.T) {
-	t.Parallel()
-	config := defaultConfig()
-	config.Protection.AllowDDL = true
-	p, _ := newTestInstance(t, config)
-
-	setupTable(t, p, "CREATE TABLE users (id serial PRIMARY KEY, active boolean DEFAULT false)")
-	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
-	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
-	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
-	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
-	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "UPDATE users SET active = true WHERE id <= 3"})
-	if output.Error != "" {
-		t.Fatalf("unexpected error: %s", output.Error)
-	}
-	if output.RowsAffected != 3 {
-		t.Fatalf("expected RowsAffected=3, got %d", output.RowsAffected)
+	config.TimezoneFallback = pgmcp.TimezoneFallbackUTC
+	p, err = pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create PostgresMcp: %v", err)
 	}
-}
-
-func TestQuery_RowsAffected_Delete(t *testing.T) {
-	t.Parallel()
-	config := defaultConfig()
-	config.Protection.AllowDDL = true
-	p, _ := newTestInstance(t, config)
-
-	setupTable(t, p, "CREATE TABLE users (id serial PRIMARY KEY)")
-	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
-	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
-	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
-	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
-	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
-
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "DELETE FROM users WHERE id <= 2"})
+	defer p.Close(ctx)
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT current_setting('timezone') AS tz"})
 	if output.Error != "" {
 		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if output.RowsAffected != 2 {
-		t.Fatalf("expected RowsAffected=2, got %d", output.RowsAffected)
-	}
-}
-
-func TestQuery_SemaphoreContention(t *testing.T) {
-	t.Parallel()
-	config := defaultConfig()
-	config.Pool.MaxConns = 1
-	config.Query.DefaultTimeoutSeconds = 30
-	p, _ := newTestInstance(t, config)
-
-	// Start a slow query in a goroutine to hold the semaphore
-	done := make(chan struct{})
-	go func() {
-		defer close(done)
-		p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT pg_sleep(5)"})
-	}()
-
-	// Give the goroutine time to acquire the semaphore
-	time.Sleep(100 * time.Millisecond)
-
-	// Try to run another query with a short context timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
-	defer cancel()
-
-	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 1"})
-	if output.Error == "" {
-		t.Fatal("expected semaphore contention error")
-	}
-	if !strings.Contains(output.Error, "failed to acquire query slot") {
-		t.Fatalf("expected semaphore error, got %q", output.Error)
+	if output.Rows[0]["tz"] != "UTC" {
+		t.Fatalf("expected UTC, got %v", output.Rows[0]["tz"])
 	}
-
-	<-done
 }
 
-func TestQuery_AfterHookRejectRollbacksWrite(t *testing.T) {
+func TestQuery_SessionRole(t *testing.T) {
 	t.Parallel()
-	// Create table with a non-hooked instance first
 	setupConfig := defaultConfig()
 	setupConfig.Protection.AllowDDL = true
+	setupConfig.Protection.AllowManageRoles = true
+	setupConfig.Protection.AllowGrantRevoke = true
 	setupP, connStr := newTestInstance(t, setupConfig)
-	setupTable(t, setupP, "CREATE TABLE users (id serial PRIMARY KEY, name text)")
-	setupP.Close(context.Background())
 
-	// Now create a hooked instance using the same connection string
+	// Roles are cluster-wide — use a unique name and drop it afterwards.
+	role := fmt.Sprintf("pgmcp_session_role_%d", time.Now().UnixNano())
+	setupTable(t, setupP, fmt.Sprintf("CREATE ROLE %s NOLOGIN", role))
+	t.Cleanup(func() {
+		setupP.Query(context.Background(), pgmcp.QueryInput{SQL: fmt.Sprintf("DROP ROLE %s", role)})
+	})
+	setupTable(t, setupP, fmt.Sprintf("GRANT %s TO CURRENT_USER", role))
+	setupTable(t, setupP, "CREATE TABLE session_role_secret (id int)")
+
 	config := defaultConfig()
-	config.DefaultHookTimeoutSeconds = 5
+	config.SessionRole = role
+	config.Protection.AllowSet = true
 	ctx := context.Background()
-	p, err := pgmcp.New(ctx, connStr, config, testLogger(), pgmcp.WithServerHooks(pgmcp.ServerHooksConfig{
-		AfterQuery: []pgmcp.HookEntry{
-			{Pattern: ".*", Command: hookScript("reject.sh")},
-		},
-	}))
+	p, err := pgmcp.New(ctx, connStr, config, testLogger())
 	if err != nil {
-		t.Fatalf("Failed to create PostgresMcp: %v", err)
+		t.Fatalf("failed to create PostgresMcp: %v", err)
 	}
-	defer p.Close(ctx)
+	t.Cleanup(func() { p.Close(ctx) })
 
-	output := p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO users (name) VALUES ('rejected_row') RETURNING *"})
-	if output.Error == "" {
-		t.Fatal("expected hook rejection error")
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT current_user::text AS cu, current_role::text AS cr"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if !strings.Contains(output.Error, "rejected by test hook") {
-		t.Fatalf("expected rejection message, got %q", output.Error)
+	if output.Rows[0]["cu"] != role {
+		t.Fatalf("expected current_user=%q, got %v", role, output.Rows[0]["cu"])
 	}
-
-	// Verify the row was NOT inserted (rollback happened)
-	// Use a non-hooked instance to verify
-	verifyConfig := defaultConfig()
-	verifyP, err := pgmcp.New(ctx, connStr, verifyConfig, testLogger())
-	if err != nil {
-		t.Fatalf("Failed to create verify instance: %v", err)
+	if output.Rows[0]["cr"] != role {
+		t.Fatalf("expected current_role=%q, got %v", role, output.Rows[0]["cr"])
 	}
-	defer verifyP.Close(ctx)
 
-	verifyOutput := verifyP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS cnt FROM users WHERE name = 'rejected_row'"})
-	if verifyOutput.Error != "" {
-		t.Fatalf("verification query failed: %s", verifyOutput.Error)
+	// Restricted role has no privileges on the table
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT * FROM session_role_secret"})
+	if !strings.Contains(output.Error, "permission denied") {
+		t.Fatalf("expected permission denied, got %q", output.Error)
 	}
-	cnt := verifyOutput.Rows[0]["cnt"]
-	if cnt != int64(0) {
-		t.Fatalf("expected 0 rows (rollback), got %v (%T)", cnt, cnt)
+
+	// Agent cannot escape the session role, even with AllowSet
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "RESET ROLE"})
+	expected := "RESET ROLE is not allowed: session role is enforced by server configuration"
+	if output.Error != expected {
+		t.Fatalf("expected %q, got %q", expected, output.Error)
 	}
 }
 
-func TestQuery_AfterHookAcceptCommitsWrite(t *testing.T) {
+func TestQuery_RowSecurity(t *testing.T) {
 	t.Parallel()
-	// Create table with a non-hooked instance first
 	setupConfig := defaultConfig()
 	setupConfig.Protection.AllowDDL = true
+	setupConfig.Protection.AllowManageRoles = true
+	setupConfig.Protection.AllowGrantRevoke = true
+	setupConfig.Protection.AllowDrop = true
 	setupP, connStr := newTestInstance(t, setupConfig)
-	setupTable(t, setupP, "CREATE TABLE users (id serial PRIMARY KEY, name text)")
-	setupP.Close(context.Background())
 
-	// Create hooked instance
+	// Policies do not apply to superusers, so query through an unprivileged session role.
+	role := fmt.Sprintf("pgmcp_rls_role_%d", time.Now().UnixNano())
+	setupTable(t, setupP, fmt.Sprintf("CREATE ROLE %s NOLOGIN", role))
+	t.Cleanup(func() {
+		// The role's grants and policy go with the tables.
+		setupP.Query(context.Background(), pgmcp.QueryInput{SQL: "DROP TABLE rls_orders, rls_plain"})
+		setupP.Query(context.Background(), pgmcp.QueryInput{SQL: fmt.Sprintf("DROP ROLE %s", role)})
+	})
+	setupTable(t, setupP, fmt.Sprintf("GRANT %s TO CURRENT_USER", role))
+	setupTable(t, setupP, "CREATE TABLE rls_orders (id int, region text)")
+	setupTable(t, setupP, "INSERT INTO rls_orders VALUES (1, 'eu'), (2, 'us'), (3, 'us')")
+	setupTable(t, setupP, "CREATE TABLE rls_plain (id int)")
+	setupTable(t, setupP, fmt.Sprintf("GRANT SELECT, INSERT ON rls_orders, rls_plain TO %s", role))
+	setupTable(t, setupP, "ALTER TABLE rls_orders ENABLE ROW LEVEL SECURITY")
+	setupTable(t, setupP, fmt.Sprintf("CREATE POLICY eu_only ON rls_orders TO %s USING (region = 'eu') WITH CHECK (region = 'eu')", role))
+
 	config := defaultConfig()
-	config.DefaultHookTimeoutSeconds = 5
+	config.SessionRole = role
+	config.Query.RowSecurityNotes = true
 	ctx := context.Background()
-	p, err := pgmcp.New(ctx, connStr, config, testLogger(), pgmcp.WithServerHooks(pgmcp.ServerHooksConfig{
-		AfterQuery: []pgmcp.HookEntry{
-			{Pattern: ".*", Command: hookScript("accept.sh")},
-		},
-	}))
+	p, err := pgmcp.New(ctx, connStr, config, testLogger())
 	if err != nil {
-		t.Fatalf("Failed to create PostgresMcp: %v", err)
+		t.Fatalf("failed to create PostgresMcp: %v", err)
 	}
-	defer p.Close(ctx)
+	t.Cleanup(func() { p.Close(ctx) })
 
-	output := p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO users (name) VALUES ('accepted_row') RETURNING *"})
+	// Filtered rows come back with a note naming the table.
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT id FROM rls_orders ORDER BY id"})
 	if output.Error != "" {
 		t.Fatalf("unexpected error: %s", output.Error)
 	}
+	if len(output.Rows) != 1 {
+		t.Fatalf("expected the policy to hide all but 1 row, got %v", output.Rows)
+	}
+	if !strings.Contains(output.RowSecurityNote, "public.rls_orders") {
+		t.Fatalf("expected a note naming public.rls_orders, got %q", output.RowSecurityNote)
+	}
 
-	// Verify the row WAS inserted (commit happened) using non-hooked instance
-	verifyConfig := defaultConfig()
-	verifyP, err := pgmcp.New(ctx, connStr, verifyConfig, testLogger())
-	if err != nil {
-		t.Fatalf("Failed to create verify instance: %v", err)
+	// Tables without policies get no note.
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT id FROM rls_plain"})
+	if output.Error != "" || output.RowSecurityNote != "" {
+		t.Fatalf("expected no note, got err=%q note=%q", output.Error, output.RowSecurityNote)
 	}
-	defer verifyP.Close(ctx)
 
-	verifyOutput := verifyP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS cnt FROM users WHERE name = 'accepted_row'"})
-	if verifyOutput.Error != "" {
-		t.Fatalf("verification query failed: %s", verifyOutput.Error)
+	// A write the policy rejects explains why.
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO rls_orders VALUES (4, 'us')"})
+	if !strings.Contains(output.Error, "row-level security policy") {
+		t.Fatalf("expected a policy violation, got %q", output.Error)
 	}
-	cnt := verifyOutput.Rows[0]["cnt"]
-	if cnt != int64(1) {
-		t.Fatalf("expected 1 row (committed), got %v (%T)", cnt, cnt)
+	if !strings.HasPrefix(output.RowSecurityNote, "a row-level security policy rejected this statement") {
+		t.Fatalf("expected a policy violation note, got %q", output.RowSecurityNote)
 	}
 }
 
-func TestQuery_HookCrashStopsPipeline(t *testing.T) {
+func TestQuery_SessionRoleMissingRole(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
-	config.DefaultHookTimeoutSeconds = 5
-
-	p := newTestInstanceWithHooks(t, config, pgmcp.ServerHooksConfig{
-		BeforeQuery: []pgmcp.HookEntry{
-			{Pattern: ".*", Command: hookScript("crash.sh")},
-		},
-	})
+	config.SessionRole = "pgmcp_role_that_does_not_exist"
+	p, _ := newTestInstance(t, config)
 
 	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1"})
-	if output.Error == "" {
-		t.Fatal("expected hook crash error")
-	}
-	if !strings.Contains(output.Error, "hook failed") {
-		t.Fatalf("expected hook failed error, got %q", output.Error)
+	if !strings.Contains(output.Error, "failed to SET ROLE pgmcp_role_that_does_not_exist") {
+		t.Fatalf("expected SET ROLE failure, got %q", output.Error)
 	}
 }
 
-func TestQuery_HookBadJsonStopsPipeline(t *testing.T) {
+func TestQuery_MaxSQLLength(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
-	config.DefaultHookTimeoutSeconds = 5
-
-	p := newTestInstanceWithHooks(t, config, pgmcp.ServerHooksConfig{
-		BeforeQuery: []pgmcp.HookEntry{
-			{Pattern: ".*", Command: hookScript("bad_json.sh")},
-		},
-	})
+	config.Query.MaxSQLLength = 100
+	p, _ := newTestInstance(t, config)
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1"})
+	longSQL := "SELECT " + strings.Repeat("1,", 100) + "1"
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: longSQL})
 	if output.Error == "" {
-		t.Fatal("expected unparseable response error")
+		t.Fatal("expected SQL length error")
 	}
-	if !strings.Contains(output.Error, "unparseable response") {
-		t.Fatalf("expected unparseable response error, got %q", output.Error)
+	if !strings.Contains(output.Error, "SQL query too long") {
+		t.Fatalf("expected SQL length error, got %q", output.Error)
 	}
 }
 
-func TestQuery_ExplainAnalyzeProtection(t *testing.T) {
+func TestQuery_MaxSQLLengthUnit(t *testing.T) {
+	t.Parallel()
+	// 11 CJK characters (3 bytes each): the query is 25 runes but 47 bytes.
+	sql := "SELECT '東京都渋谷区神宮前一丁' AS v"
+
+	bytesConfig := defaultConfig()
+	bytesConfig.Query.MaxSQLLength = 30
+	p, _ := newTestInstance(t, bytesConfig)
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: sql})
+	if output.Error != "SQL query too long: 47 bytes exceeds maximum of 30 bytes" {
+		t.Fatalf("expected byte length error, got %q", output.Error)
+	}
+
+	runesConfig := defaultConfig()
+	runesConfig.Query.MaxSQLLength = 30
+	runesConfig.Query.MaxSQLLengthUnit = pgmcp.MaxSQLLengthRunes
+	p, _ = newTestInstance(t, runesConfig)
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: sql})
+	if output.Error != "" {
+		t.Fatalf("expected query to pass under rune counting, got %q", output.Error)
+	}
+	if output.Rows[0]["v"] != "東京都渋谷区神宮前一丁" {
+		t.Fatalf("unexpected value: %v", output.Rows[0]["v"])
+	}
+
+	runesConfig.Query.MaxSQLLength = 20
+	p, _ = newTestInstance(t, runesConfig)
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: sql})
+	if output.Error != "SQL query too long: 25 characters exceeds maximum of 20 characters" {
+		t.Fatalf("expected rune length error, got %q", output.Error)
+	}
+}
+
+func TestQuery_ColumnTypes(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
 	config.Protection.AllowDDL = true
+	config.Query.IncludeColumnTypes = true
 	p, _ := newTestInstance(t, config)
+	setupTable(t, p, "CREATE TYPE mood AS ENUM ('happy', 'sad')")
+	setupTable(t, p, "CREATE TABLE t (id int, tags text[], grid int[][], moods mood[])")
+	setupTable(t, p, "INSERT INTO t VALUES (1, '{a,b}', '{{1,2},{3,4}}', '{happy}')")
 
-	setupTable(t, p, "CREATE TABLE users (id serial PRIMARY KEY, name text)")
-
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "EXPLAIN ANALYZE DELETE FROM users"})
-	if output.Error == "" {
-		t.Fatal("expected protection error for EXPLAIN ANALYZE DELETE")
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT id, tags, grid, moods, now() AS ts FROM t"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if !strings.Contains(output.Error, "DELETE without WHERE") {
-		t.Fatalf("expected delete protection error, got %q", output.Error)
+	expected := []string{"int4", "text[]", "int4[]", "mood[]", "timestamptz"}
+	if !reflect.DeepEqual(output.ColumnTypes, expected) {
+		t.Fatalf("expected column types %v, got %v", expected, output.ColumnTypes)
+	}
+	if output.ColumnTypeDetails != nil {
+		t.Fatalf("expected no column type details, got %v", output.ColumnTypeDetails)
 	}
 }
 
-func TestQuery_UTF8Truncation(t *testing.T) {
+func TestQuery_ColumnTypeDetails(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
 	config.Protection.AllowDDL = true
-	config.Query.MaxResultLength = 50 // very small
+	config.Query.IncludeColumnTypes = true
+	config.Query.ColumnTypeDetails = true
 	p, _ := newTestInstance(t, config)
+	setupTable(t, p, "CREATE TABLE t (id int, tags text[], grid int[][], cube numeric[], empty bool[])")
+	setupTable(t, p, "INSERT INTO t VALUES (1, NULL, '{{1,2},{3,4}}', '{{{1.5}},{{2.5}}}', '{}')")
+	setupTable(t, p, "INSERT INTO t VALUES (2, '{a,b}', NULL, NULL, NULL)")
 
-	setupTable(t, p, "CREATE TABLE emoji_table (data text)")
-	setupTable(t, p, "INSERT INTO emoji_table VALUES ('Hello world! Here are some special chars: cafe\u0301 naïve résumé')")
-	setupTable(t, p, "INSERT INTO emoji_table VALUES ('More text to ensure we exceed the limit easily here')")
-
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT * FROM emoji_table"})
-	if output.Error == "" {
-		t.Fatal("expected truncation")
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT id, tags, grid, cube, empty FROM t ORDER BY id"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	// Verify the truncated output is valid UTF-8
-	idx := strings.Index(output.Error, "...[truncated]")
-	if idx == -1 {
-		t.Fatalf("expected truncation marker, got %q", output.Error)
+	expectedTypes := []string{"int4", "text[]", "int4[]", "numeric[]", "bool[]"}
+	if !reflect.DeepEqual(output.ColumnTypes, expectedTypes) {
+		t.Fatalf("expected column types %v, got %v", expectedTypes, output.ColumnTypes)
 	}
-	truncatedPart := output.Error[:idx]
-	if !utf8.ValidString(truncatedPart) {
-		t.Fatalf("truncated output is not valid UTF-8: %q", truncatedPart)
+	// Dimensions come from the first non-empty value; an array column with none reports 1.
+	expectedDetails := []pgmcp.ColumnType{
+		{Name: "int4", Base: "int4", Dims: 0},
+		{Name: "text[]", Base: "text", Dims: 1},
+		{Name: "int4[]", Base: "int4", Dims: 2},
+		{Name: "numeric[]", Base: "numeric", Dims: 3},
+		{Name: "bool[]", Base: "bool", Dims: 1},
+	}
+	if !reflect.DeepEqual(output.ColumnTypeDetails, expectedDetails) {
+		t.Fatalf("expected column type details %+v, got %+v", expectedDetails, output.ColumnTypeDetails)
+	}
+
+	// Types are reported even when no rows are returned.
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT grid FROM t WHERE false"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if !reflect.DeepEqual(output.ColumnTypeDetails, []pgmcp.ColumnType{{Name: "int4[]", Base: "int4", Dims: 1}}) {
+		t.Fatalf("unexpected details for empty result: %+v", output.ColumnTypeDetails)
 	}
 }
 
-func TestQuery_TimeoutRuleMatch(t *testing.T) {
+func TestQuery_StatementComment(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
-	config.Query.DefaultTimeoutSeconds = 30
-	config.Query.TimeoutRules = []pgmcp.TimeoutRule{
-		{Pattern: "pg_sleep", TimeoutSeconds: 1},
-	}
+	config.StatementComment = "pgmcp agent={{.Agent}} req={{.RequestID}}"
 	p, _ := newTestInstance(t, config)
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT pg_sleep(10)"})
-	if output.Error == "" {
-		t.Fatal("expected timeout from rule")
+	ctx := pgmcp.WithCallInfo(context.Background(), pgmcp.CallInfo{Agent: "analyst", RequestID: "42"})
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT current_query() AS q"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if q := output.Rows[0]["q"]; q != "/* pgmcp agent=analyst req=42 */ SELECT current_query() AS q" {
+		t.Fatalf("unexpected executed SQL: %v", q)
+	}
+
+	// Comments in the agent's SQL do not hide a second statement from protection.
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 1 /* */; DROP TABLE x"})
+	if output.Error != "multi-statement queries are not allowed: found 2 statements" {
+		t.Fatalf("expected multi-statement rejection, got %q", output.Error)
 	}
-	// Should have timed out after ~1s, not 30s
 }
 
-func TestQuery_TimeoutFallbackToDefault(t *testing.T) {
+func TestQuery_BlockSecurityDefinerCalls(t *testing.T) {
 	t.Parallel()
-	config := defaultConfig()
-	config.Query.DefaultTimeoutSeconds = 1 // short default
-	config.Query.TimeoutRules = []pgmcp.TimeoutRule{
-		{Pattern: "NEVER_MATCH_THIS_PATTERN", TimeoutSeconds: 30},
+	connStr := acquireTestDB(t)
+	ctx := context.Background()
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setupConfig.Protection.AllowCreateFunction = true
+	setup, err := pgmcp.New(ctx, connStr, setupConfig, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create setup instance: %v", err)
 	}
-	p, _ := newTestInstance(t, config)
-
-	// This query won't match the rule, so it should use default (1s) and timeout
-	start := time.Now()
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT pg_sleep(10)"})
-	elapsed := time.Since(start)
+	setupTable(t, setup, "CREATE SCHEMA admin")
+	setupTable(t, setup, "CREATE FUNCTION add_one(i int) RETURNS int LANGUAGE sql AS 'SELECT i + 1'")
+	setupTable(t, setup, "CREATE FUNCTION admin.elevate() RETURNS int LANGUAGE sql SECURITY DEFINER AS 'SELECT 1'")
+	setupTable(t, setup, "CREATE FUNCTION elevate_public() RETURNS int LANGUAGE sql SECURITY DEFINER AS 'SELECT 1'")
+	setup.Close(ctx)
 
-	if output.Error == "" {
-		t.Fatal("expected timeout from default timeout")
+	config := defaultConfig()
+	config.Protection.BlockSecurityDefinerCalls = true
+	p, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
 	}
-	// Should have timed out after ~1s (default), not 30s (rule)
-	if elapsed > 5*time.Second {
-		t.Fatalf("expected timeout near 1s (default), but took %v", elapsed)
+	defer p.Close(ctx)
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT add_one(1) AS v, now() IS NOT NULL AS ok"})
+	if output.Error != "" {
+		t.Fatalf("expected normal function call to be allowed, got %q", output.Error)
+	}
+	if output.Rows[0]["v"] != int32(2) {
+		t.Fatalf("unexpected result: %v", output.Rows)
+	}
+
+	tests := []struct {
+		sql      string
+		function string
+	}{
+		{"SELECT admin.elevate()", "admin.elevate"},
+		{"SELECT elevate_public()", "elevate_public"},
+		{"SELECT 1 WHERE EXISTS (SELECT elevate_public())", "elevate_public"},
+	}
+	for _, tt := range tests {
+		// Twice: the second call is answered from the cache.
+		for i := 0; i < 2; i++ {
+			output = p.Query(ctx, pgmcp.QueryInput{SQL: tt.sql})
+			expected := "call to SECURITY DEFINER function " + tt.function + " is not allowed: it runs with its owner's privileges"
+			if output.Error != expected {
+				t.Fatalf("%s: expected %q, got %q", tt.sql, expected, output.Error)
+			}
+		}
 	}
 }
 
-func TestQuery_InetColumn(t *testing.T) {
+func TestQuery_BlockVolatileInReadOnly(t *testing.T) {
 	t.Parallel()
-	config := defaultConfig()
-	config.Protection.AllowDDL = true
-	p, _ := newTestInstance(t, config)
+	connStr := acquireTestDB(t)
+	ctx := context.Background()
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setupConfig.Protection.AllowCreateFunction = true
+	setup, err := pgmcp.New(ctx, connStr, setupConfig, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create setup instance: %v", err)
+	}
+	setupTable(t, setup, "CREATE FUNCTION double_it(i int) RETURNS int LANGUAGE sql IMMUTABLE AS 'SELECT i * 2'")
+	setupTable(t, setup, "CREATE FUNCTION bump() RETURNS int LANGUAGE sql VOLATILE AS 'SELECT 1'")
+	setup.Close(ctx)
 
-	setupTable(t, p, "CREATE TABLE servers (ip inet)")
-	setupTable(t, p, "INSERT INTO servers VALUES ('192.168.1.1/24')")
+	config := defaultConfig()
+	config.ReadOnly = true
+	config.Protection.BlockVolatileInReadOnly = true
+	p, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	defer p.Close(ctx)
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT ip FROM servers"})
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT double_it(21) AS v, now() IS NOT NULL AS ok"})
 	if output.Error != "" {
-		t.Fatalf("unexpected error: %s", output.Error)
+		t.Fatalf("expected immutable and stable function calls to be allowed, got %q", output.Error)
+	}
+	if output.Rows[0]["v"] != int32(42) {
+		t.Fatalf("unexpected result: %v", output.Rows)
+	}
+
+	for _, tt := range []struct{ sql, function string }{
+		{"SELECT bump()", "bump"},
+		{"SELECT public.bump()", "public.bump"},
+		{"SELECT 1 WHERE EXISTS (SELECT random())", "random"},
+	} {
+		// Twice: the second call is answered from the cache.
+		for i := 0; i < 2; i++ {
+			output = p.Query(ctx, pgmcp.QueryInput{SQL: tt.sql})
+			expected := `volatile function "` + tt.function + `" not allowed in read-only mode`
+			if !strings.HasPrefix(output.Error, expected) || output.ErrorKind != pgmcp.ErrorKindProtection {
+				t.Fatalf("%s: expected %q, got %q (kind %q)", tt.sql, expected, output.Error, output.ErrorKind)
+			}
+		}
 	}
-	ipStr, ok := output.Rows[0]["ip"].(string)
-	if !ok {
-		t.Fatalf("expected string for inet, got %T", output.Rows[0]["ip"])
+
+	// Outside read-only mode the option does nothing.
+	config.ReadOnly = false
+	rw, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
 	}
-	if !strings.Contains(ipStr, "192.168.1.1") {
-		t.Fatalf("expected 192.168.1.1 in inet, got %s", ipStr)
+	defer rw.Close(ctx)
+	if output := rw.Query(ctx, pgmcp.QueryInput{SQL: "SELECT bump()"}); output.Error != "" {
+		t.Fatalf("expected volatile call to be allowed outside read-only mode, got %q", output.Error)
+	}
+	if output := rw.Query(ctx, pgmcp.QueryInput{SQL: "SELECT bump()", ForceReadOnly: true}); !strings.HasPrefix(output.Error, `volatile function "bump"`) {
+		t.Fatalf("expected volatile call to be blocked with ForceReadOnly, got %q", output.Error)
 	}
 }
 
-// --- Section 4: Missing Query Integration Tests ---
+// memoryAuditSink collects audit records in memory.
+type memoryAuditSink struct {
+	mu      sync.Mutex
+	records []pgmcp.AuditRecord
+}
 
-func TestQuery_JSONBReturnType(t *testing.T) {
+func (s *memoryAuditSink) Record(_ context.Context, record pgmcp.AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *memoryAuditSink) last() pgmcp.AuditRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[len(s.records)-1]
+}
+
+func TestQuery_AuditSink(t *testing.T) {
 	t.Parallel()
+	sink := &memoryAuditSink{}
 	config := defaultConfig()
+	config.AuditSink = sink
+	config.AuditExplain = true
+	config.Query.AutoLimit = 10
 	config.Protection.AllowDDL = true
 	p, _ := newTestInstance(t, config)
+	ctx := context.Background()
+	setupTable(t, p, "CREATE TABLE audit_items (id int PRIMARY KEY)")
 
-	setupTable(t, p, "CREATE TABLE items (data jsonb)")
-	setupTable(t, p, `INSERT INTO items VALUES ('{"name":"test","nested":{"arr":[1,2,3]},"flag":true,"nothing":null,"count":42}')`)
-
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT data FROM items"})
+	// Allowed: final SQL includes auto_limit, plan is captured.
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT id FROM audit_items"})
 	if output.Error != "" {
 		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if len(output.Rows) != 1 {
-		t.Fatalf("expected 1 row, got %d", len(output.Rows))
+	record := sink.last()
+	if record.Outcome != pgmcp.AuditOutcomeSuccess || record.Error != "" {
+		t.Fatalf("unexpected outcome: %+v", record)
 	}
-
-	// Verify JSONB is returned as parsed Go map, not string
-	dataMap, ok := output.Rows[0]["data"].(map[string]interface{})
-	if !ok {
-		t.Fatalf("expected map[string]interface{} for JSONB, got %T: %v", output.Rows[0]["data"], output.Rows[0]["data"])
+	if record.OriginalSQL != "SELECT id FROM audit_items" || !strings.Contains(record.FinalSQL, "LIMIT 10") {
+		t.Fatalf("unexpected SQL: original %q, final %q", record.OriginalSQL, record.FinalSQL)
+	}
+	if !strings.Contains(string(record.Plan), `"Node Type"`) {
+		t.Fatalf("expected plan, got %s", record.Plan)
+	}
+	if record.Time.IsZero() || record.Duration <= 0 {
+		t.Fatalf("expected time and duration, got %+v", record)
 	}
 
-	// Verify nested object
-	nested, ok := dataMap["nested"].(map[string]interface{})
-	if !ok {
-		t.Fatalf("expected nested map, got %T", dataMap["nested"])
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO audit_items VALUES (1), (2)"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if record = sink.last(); record.RowsAffected != 2 || record.Plan == nil {
+		t.Fatalf("unexpected insert record: %+v", record)
 	}
 
-	// Verify nested array
-	arr, ok := nested["arr"].([]interface{})
-	if !ok {
-		t.Fatalf("expected nested array, got %T", nested["arr"])
+	// Blocked by protection: never executed.
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "DROP TABLE audit_items"})
+	if output.Error == "" {
+		t.Fatal("expected DROP to be blocked")
 	}
-	if len(arr) != 3 {
-		t.Fatalf("expected 3 elements in array, got %d", len(arr))
+	record = sink.last()
+	if record.Outcome != pgmcp.AuditOutcomeBlocked || record.Error != output.Error || record.FinalSQL != "" || record.Plan != nil {
+		t.Fatalf("unexpected blocked record: %+v", record)
 	}
 
-	// Verify boolean
-	flag, ok := dataMap["flag"].(bool)
-	if !ok {
-		t.Fatalf("expected bool for flag, got %T", dataMap["flag"])
+	// Postgres error: plan capture fails quietly and the statement's own error is recorded.
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT missing FROM audit_items"})
+	if output.Error == "" {
+		t.Fatal("expected error")
 	}
-	if flag != true {
-		t.Fatalf("expected true, got %v", flag)
+	record = sink.last()
+	if record.Outcome != pgmcp.AuditOutcomeError || !strings.Contains(record.Error, "missing") || record.Plan != nil {
+		t.Fatalf("unexpected error record: %+v", record)
 	}
+}
 
-	// Verify null
-	if dataMap["nothing"] != nil {
-		t.Fatalf("expected nil for nothing, got %v (%T)", dataMap["nothing"], dataMap["nothing"])
+func TestQuery_TypeCodecs(t *testing.T) {
+	t.Parallel()
+	connStr := acquireTestDB(t)
+	ctx := context.Background()
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setup, err := pgmcp.New(ctx, connStr, setupConfig, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create setup instance: %v", err)
 	}
+	setupTable(t, setup, "CREATE TYPE mood AS ENUM ('happy', 'sad')")
+	setup.Close(ctx)
 
-	// Verify string
-	if dataMap["name"] != "test" {
-		t.Fatalf("expected name=test, got %v", dataMap["name"])
+	config := defaultConfig()
+	config.TypeCodecs = []pgmcp.TypeCodec{{
+		Name: "mood",
+		Decode: func(raw []byte) (interface{}, error) {
+			return map[string]interface{}{"mood": strings.ToUpper(string(raw))}, nil
+		},
+	}}
+	p, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	defer p.Close(ctx)
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 'happy'::mood AS m, NULL::mood AS n, 'sad'::text AS t"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	row := output.Rows[0]
+	if !reflect.DeepEqual(row["m"], map[string]interface{}{"mood": "HAPPY"}) {
+		t.Fatalf("expected codec to decode m, got %#v", row["m"])
+	}
+	if row["n"] != nil || row["t"] != "sad" {
+		t.Fatalf("unexpected row: %v", row)
 	}
 }
 
-func TestQuery_JSONBNumericPrecision(t *testing.T) {
+func TestQuery_ForceReadOnly(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
 	config.Protection.AllowDDL = true
+	config.Protection.AllowSet = true
 	p, _ := newTestInstance(t, config)
+	ctx := context.Background()
+	setupTable(t, p, "CREATE TABLE preview_items (id int PRIMARY KEY)")
+
+	for _, sql := range []string{
+		"INSERT INTO preview_items VALUES (1)",
+		"WITH ins AS (INSERT INTO preview_items VALUES (2) RETURNING id) SELECT id FROM ins",
+	} {
+		output := p.Query(ctx, pgmcp.QueryInput{SQL: sql, ForceReadOnly: true})
+		if !strings.Contains(output.Error, "read-only transaction") {
+			t.Fatalf("%s: expected read-only transaction error, got %q", sql, output.Error)
+		}
+	}
 
-	setupTable(t, p, "CREATE TABLE items (data jsonb)")
-	setupTable(t, p, `INSERT INTO items VALUES ('{"id": 9007199254740993}')`) // 2^53+1
+	// Read-only protection rules apply even though the instance allows SET.
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SET transaction_read_only = off", ForceReadOnly: true})
+	if !strings.Contains(output.Error, "blocked in read-only mode") {
+		t.Fatalf("expected read-only protection error, got %q", output.Error)
+	}
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT data FROM items"})
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS n FROM preview_items", ForceReadOnly: true})
 	if output.Error != "" {
 		t.Fatalf("unexpected error: %s", output.Error)
 	}
+	if output.Rows[0]["n"] != int64(0) {
+		t.Fatalf("expected no rows written, got %v", output.Rows[0]["n"])
+	}
 
-	// Known limitation: large integer inside JSONB loses precision to float64
-	// because pgx parses JSONB internally and numbers become float64.
-	dataMap, ok := output.Rows[0]["data"].(map[string]interface{})
-	if !ok {
-		t.Fatalf("expected map for JSONB, got %T", output.Rows[0]["data"])
+	// The same instance still writes without the override.
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO preview_items VALUES (1)"})
+	if output.Error != "" || output.RowsAffected != 1 {
+		t.Fatalf("expected write to succeed, got %+v", output)
 	}
+}
 
-	// The value should be a float64 (precision lost — pgx limitation)
-	val, ok := dataMap["id"].(float64)
-	if !ok {
-		t.Fatalf("expected float64 for JSONB large int (pgx limitation), got %T: %v", dataMap["id"], dataMap["id"])
+func TestQuery_DuplicateColumnMode(t *testing.T) {
+	t.Parallel()
+	connStr := acquireTestDB(t)
+	ctx := context.Background()
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setup, err := pgmcp.New(ctx, connStr, setupConfig, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create setup instance: %v", err)
+	}
+	setupTable(t, setup, "CREATE TABLE dup_users (id int PRIMARY KEY, name text)")
+	setupTable(t, setup, "CREATE TABLE dup_orders (id int PRIMARY KEY, user_id int)")
+	setupTable(t, setup, "INSERT INTO dup_users VALUES (1, 'alice')")
+	setupTable(t, setup, "INSERT INTO dup_orders VALUES (10, 1)")
+	setup.Close(ctx)
+
+	const joinSQL = "SELECT * FROM dup_users u JOIN dup_orders o ON o.user_id = u.id"
+	tests := []struct {
+		mode     string
+		expected map[string]interface{}
+	}{
+		{"", map[string]interface{}{"id_1": int32(1), "name": "alice", "id_2": int32(10), "user_id": int32(1)}},
+		{pgmcp.DuplicateColumnsQualify, map[string]interface{}{"dup_users.id": int32(1), "name": "alice", "dup_orders.id": int32(10), "user_id": int32(1)}},
+	}
+	for _, tt := range tests {
+		config := defaultConfig()
+		config.Query.DuplicateColumnMode = tt.mode
+		p, err := pgmcp.New(ctx, connStr, config, testLogger())
+		if err != nil {
+			t.Fatalf("failed to create instance: %v", err)
+		}
+		output := p.Query(ctx, pgmcp.QueryInput{SQL: joinSQL})
+		p.Close(ctx)
+		if output.Error != "" {
+			t.Fatalf("mode %q: unexpected error: %s", tt.mode, output.Error)
+		}
+		if len(output.Columns) != 4 || !reflect.DeepEqual(output.Rows[0], tt.expected) {
+			t.Fatalf("mode %q: expected %v, got columns %v, row %v", tt.mode, tt.expected, output.Columns, output.Rows[0])
+		}
 	}
-	// 9007199254740993 → 9007199254740992 (precision lost)
-	if val != 9.007199254740992e+15 {
-		t.Fatalf("expected 9.007199254740992e+15 (precision loss), got %v", val)
+
+	config := defaultConfig()
+	config.Query.DuplicateColumnMode = pgmcp.DuplicateColumnsError
+	p, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	defer p.Close(ctx)
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: joinSQL})
+	if !strings.Contains(output.Error, `result has more than one column named "id"`) {
+		t.Fatalf("expected duplicate column error, got %q", output.Error)
 	}
 }
 
-func TestQuery_SelectNestedSubquery(t *testing.T) {
+func TestWaitForNotification(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
-	config.Protection.AllowDDL = true
+	config.Protection.AllowListenNotify = true
 	p, _ := newTestInstance(t, config)
+	ctx := context.Background()
 
-	setupTable(t, p, "CREATE TABLE departments (id serial PRIMARY KEY, name text)")
-	setupTable(t, p, "CREATE TABLE employees (id serial PRIMARY KEY, name text, dept_id int)")
-	setupTable(t, p, "INSERT INTO departments (name) VALUES ('Engineering'), ('Sales')")
-	setupTable(t, p, "INSERT INTO employees (name, dept_id) VALUES ('Alice', 1), ('Bob', 1), ('Charlie', 2)")
+	type result struct {
+		output *pgmcp.NotificationOutput
+		err    error
+	}
+	received := make(chan result, 1)
+	go func() {
+		output, err := p.WaitForNotification(ctx, "job events", 10)
+		received <- result{output, err}
+	}()
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: `
-		SELECT name FROM employees
-		WHERE dept_id IN (SELECT id FROM departments WHERE name = 'Engineering')
-		ORDER BY name
-	`})
-	if output.Error != "" {
-		t.Fatalf("unexpected error: %s", output.Error)
+	// Keep notifying until the waiter's LISTEN is in place and it returns.
+	var got result
+	for done := false; !done; {
+		output := p.Query(ctx, pgmcp.QueryInput{SQL: `NOTIFY "job events", 'job 42 finished'`})
+		if output.Error != "" {
+			t.Fatalf("NOTIFY failed: %s", output.Error)
+		}
+		select {
+		case got = <-received:
+			done = true
+		case <-time.After(50 * time.Millisecond):
+		}
 	}
-	if len(output.Rows) != 2 {
-		t.Fatalf("expected 2 rows, got %d", len(output.Rows))
+	if got.err != nil {
+		t.Fatalf("unexpected error: %v", got.err)
 	}
-	if output.Rows[0]["name"] != "Alice" {
-		t.Fatalf("expected Alice, got %v", output.Rows[0]["name"])
+	if got.output.Channel != "job events" || got.output.Payload != "job 42 finished" || got.output.TimedOut || got.output.SenderPID == 0 {
+		t.Fatalf("unexpected notification: %+v", got.output)
 	}
-	if output.Rows[1]["name"] != "Bob" {
-		t.Fatalf("expected Bob, got %v", output.Rows[1]["name"])
+
+	output, err := p.WaitForNotification(ctx, "quiet_channel", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !output.TimedOut || output.Payload != "" {
+		t.Fatalf("expected timeout, got %+v", output)
 	}
 }
 
-func TestQuery_Transaction(t *testing.T) {
+func TestWaitForNotification_DoesNotHoldQuerySlots(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
-	config.Protection.AllowDDL = true
-	p, connStr := newTestInstance(t, config)
+	config.Protection.AllowListenNotify = true
+	config.Pool.MaxConns = 2
+	p, _ := newTestInstance(t, config)
+	ctx := context.Background()
 
-	setupTable(t, p, "CREATE TABLE tx_test (id serial PRIMARY KEY, val int)")
-	setupTable(t, p, "INSERT INTO tx_test (val) VALUES (1), (2), (3)")
+	// As many waiters as pool.max_conns.
+	waited := make(chan error, config.Pool.MaxConns)
+	for i := 0; i < config.Pool.MaxConns; i++ {
+		go func() {
+			_, err := p.WaitForNotification(ctx, "quiet_channel", 3)
+			waited <- err
+		}()
+	}
+
+	// Queries still run while every waiter is listening.
+	listening := false
+	for deadline := time.Now().Add(2 * time.Second); !listening && time.Now().Before(deadline); {
+		queryCtx, cancel := context.WithTimeout(ctx, time.Second)
+		output := p.Query(queryCtx, pgmcp.QueryInput{SQL: `SELECT count(*) AS n FROM pg_stat_activity WHERE query = 'LISTEN "quiet_channel"' AND datname = current_database()`})
+		cancel()
+		if output.Error != "" {
+			t.Fatalf("expected the query to run while waiters hold %d connections, got %q", config.Pool.MaxConns, output.Error)
+		}
+		listening = output.Rows[0]["n"] == int64(config.Pool.MaxConns)
+	}
+	if !listening {
+		t.Fatalf("expected %d waiters listening", config.Pool.MaxConns)
+	}
+	for i := 0; i < config.Pool.MaxConns; i++ {
+		if err := <-waited; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
 
-	// INSERT with RETURNING should commit (data persisted)
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "INSERT INTO tx_test (val) VALUES (42) RETURNING val"})
+func TestQuery_ResultConverter(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.ResultConverter = func(v interface{}) interface{} {
+		if s, ok := v.(string); ok {
+			return strings.ToUpper(s)
+		}
+		return pgmcp.DefaultResultConverter(v)
+	}
+	p, _ := newTestInstance(t, config)
+	ctx := context.Background()
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 'hello' AS greeting, 7 AS n, '2024-01-02 03:04:05+00'::timestamptz AS ts, NULL::text AS missing"})
 	if output.Error != "" {
 		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if output.Rows[0]["val"] != int32(42) {
-		t.Fatalf("expected 42, got %v", output.Rows[0]["val"])
+	row := output.Rows[0]
+	if row["greeting"] != "HELLO" || row["n"] != int32(7) || row["missing"] != nil {
+		t.Fatalf("unexpected row: %v", row)
 	}
-
-	// Verify data persisted by creating a fresh instance and querying
-	ctx := context.Background()
-	verifyP, err := pgmcp.New(ctx, connStr, defaultConfig(), testLogger())
-	if err != nil {
-		t.Fatalf("Failed to create verify instance: %v", err)
+	if ts, ok := row["ts"].(string); !ok || !strings.HasPrefix(ts, "2024-01-02T03:04:05") {
+		t.Fatalf("expected default conversion for timestamptz, got %#v", row["ts"])
 	}
-	defer verifyP.Close(ctx)
 
-	verifyOutput := verifyP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS cnt FROM tx_test WHERE val = 42"})
-	if verifyOutput.Error != "" {
-		t.Fatalf("verify query failed: %s", verifyOutput.Error)
+	// EXPLAIN output bypasses conversion.
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "EXPLAIN SELECT 1"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if verifyOutput.Rows[0]["cnt"] != int64(1) {
-		t.Fatalf("expected 1 row persisted, got %v", verifyOutput.Rows[0]["cnt"])
+	if plan, _ := output.Rows[0]["QUERY PLAN"].(string); !strings.Contains(plan, "cost=") {
+		t.Fatalf("expected unconverted plan text, got %v", output.Rows[0])
 	}
 }
 
-func TestQuery_HooksEndToEnd(t *testing.T) {
+func TestDescribeQuery(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
 	config.Protection.AllowDDL = true
-	config.DefaultHookTimeoutSeconds = 5
+	p, _ := newTestInstance(t, config)
+	ctx := context.Background()
+	setupTable(t, p, "CREATE TABLE dq_items (id serial PRIMARY KEY, name text NOT NULL, tags text[])")
 
-	p := newTestInstanceWithHooks(t, config, pgmcp.ServerHooksConfig{
-		BeforeQuery: []pgmcp.HookEntry{
-			{Pattern: ".*", Command: hookScript("modify_query.sh")},
-		},
-	})
+	output, err := p.DescribeQuery(ctx, pgmcp.DescribeQueryInput{SQL: "SELECT id, name, tags, count(*) OVER () AS total FROM dq_items"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !output.ReturnsRows ||
+		!reflect.DeepEqual(output.Columns, []string{"id", "name", "tags", "total"}) ||
+		!reflect.DeepEqual(output.ColumnTypes, []string{"int4", "text", "text[]", "int8"}) {
+		t.Fatalf("unexpected SELECT description: %+v", output)
+	}
 
-	// modify_query.sh changes any query to "SELECT 1 AS modified"
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 999"})
-	if output.Error != "" {
-		t.Fatalf("unexpected error: %s", output.Error)
+	output, err = p.DescribeQuery(ctx, pgmcp.DescribeQueryInput{SQL: "INSERT INTO dq_items (name) VALUES ('widget') RETURNING id, name"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
-	if len(output.Rows) != 1 {
-		t.Fatalf("expected 1 row, got %d", len(output.Rows))
+	if !output.ReturnsRows || !reflect.DeepEqual(output.Columns, []string{"id", "name"}) || !reflect.DeepEqual(output.ColumnTypes, []string{"int4", "text"}) {
+		t.Fatalf("unexpected INSERT ... RETURNING description: %+v", output)
 	}
-	if len(output.Columns) != 1 || output.Columns[0] != "modified" {
-		t.Fatalf("expected column 'modified', got %v", output.Columns)
+
+	output, err = p.DescribeQuery(ctx, pgmcp.DescribeQueryInput{SQL: "INSERT INTO dq_items (name) VALUES ('gadget')"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
-	val, ok := output.Rows[0]["modified"].(int32)
-	if !ok {
-		t.Fatalf("expected int32, got %T: %v", output.Rows[0]["modified"], output.Rows[0]["modified"])
+	if output.ReturnsRows || len(output.Columns) != 0 {
+		t.Fatalf("expected no result columns, got %+v", output)
 	}
-	if val != 1 {
-		t.Fatalf("expected 1, got %d", val)
+
+	// Nothing was executed.
+	count := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS n FROM dq_items"})
+	if count.Error != "" || count.Rows[0]["n"] != int64(0) {
+		t.Fatalf("expected no rows inserted, got %+v", count)
+	}
+
+	// Protection runs first; Postgres errors are returned as-is.
+	if _, err := p.DescribeQuery(ctx, pgmcp.DescribeQueryInput{SQL: "DROP TABLE dq_items"}); err == nil || !strings.Contains(err.Error(), "DROP") {
+		t.Fatalf("expected protection error, got %v", err)
+	}
+	if _, err := p.DescribeQuery(ctx, pgmcp.DescribeQueryInput{SQL: "SELECT missing FROM dq_items"}); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("expected unknown column error, got %v", err)
 	}
 }
 
-func TestQuery_HookTimeoutStopsPipeline(t *testing.T) {
+func TestQuery_ReadOnlyTempTables(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
-	config.DefaultHookTimeoutSeconds = 1
-
-	p := newTestInstanceWithHooks(t, config, pgmcp.ServerHooksConfig{
-		BeforeQuery: []pgmcp.HookEntry{
-			{Pattern: ".*", Command: hookScript("slow.sh")},
-		},
+	config.Protection.AllowTempTables = true
+	p := newReadOnlyTestInstance(t, config, func(t *testing.T, p *pgmcp.PostgresMcp) {
+		setupTable(t, p, "CREATE TABLE orders (id int, total int)")
+		setupTable(t, p, "INSERT INTO orders VALUES (1, 10), (2, 20), (3, 30)")
 	})
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1"})
-	if output.Error == "" {
-		t.Fatal("expected hook timeout error")
+	ctx := pgmcp.WithCallInfo(context.Background(), pgmcp.CallInfo{SessionID: "session-a"})
+	run := func(sql string) *pgmcp.QueryOutput {
+		return p.Query(ctx, pgmcp.QueryInput{SQL: sql})
 	}
-	if !strings.Contains(output.Error, "hook timed out") {
-		t.Fatalf("expected 'hook timed out' in error, got %q", output.Error)
+	if output := run("CREATE TEMP TABLE scratch (id int, total int)"); output.Error != "" {
+		t.Fatalf("expected CREATE TEMP TABLE to succeed in read-only mode, got %q", output.Error)
+	}
+	// Later calls in the session run on the connection holding the temp table.
+	if output := run("INSERT INTO scratch SELECT id, total FROM orders WHERE total > 10"); output.Error != "" || output.RowsAffected != 2 {
+		t.Fatalf("expected insert into temp table to affect 2 rows, got %d rows, error %q", output.RowsAffected, output.Error)
+	}
+	if output := run("UPDATE scratch SET total = total * 2 WHERE id > 0"); output.Error != "" {
+		t.Fatalf("expected update of temp table to succeed, got %q", output.Error)
+	}
+	output := run("SELECT id, total FROM scratch ORDER BY id")
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	expected := []map[string]interface{}{{"id": int32(2), "total": int32(40)}, {"id": int32(3), "total": int32(60)}}
+	if !reflect.DeepEqual(output.Rows, expected) {
+		t.Fatalf("expected %v, got %v", expected, output.Rows)
+	}
+
+	// Permanent tables stay read-only, in the session and outside it.
+	if output := run("INSERT INTO orders VALUES (4, 40)"); !strings.Contains(output.Error, "cannot execute INSERT in a read-only transaction") {
+		t.Fatalf("expected insert into permanent table to fail, got %q", output.Error)
+	}
+	if output := run("CREATE TABLE permanent (id int)"); !strings.HasPrefix(output.Error, "CREATE TABLE is not allowed: DDL operations are blocked") {
+		t.Fatalf("expected permanent CREATE TABLE to be blocked, got %q", output.Error)
+	}
+	if output := run("CREATE TEMP TABLE copy AS SELECT * FROM orders"); !strings.HasPrefix(output.Error, "CREATE TEMP TABLE AS is not allowed in read-only mode") {
+		t.Fatalf("expected CREATE TEMP TABLE AS to be blocked, got %q", output.Error)
 	}
 }
 
-func TestQuery_TimezoneEmpty(t *testing.T) {
+func TestQuery_TempTablesStayInTheirSession(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
-	config.Timezone = "" // empty = server default
+	config.Protection.AllowTempTables = true
 	p, _ := newTestInstance(t, config)
+	setupTable(t, p, "CREATE TABLE users (id int)")
+	setupTable(t, p, "INSERT INTO users VALUES (1), (2), (3)")
+
+	sessionA := pgmcp.WithCallInfo(context.Background(), pgmcp.CallInfo{SessionID: "session-a"})
+	sessionB := pgmcp.WithCallInfo(context.Background(), pgmcp.CallInfo{SessionID: "session-b"})
+	count := func(ctx context.Context) interface{} {
+		t.Helper()
+		output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS n FROM users"})
+		if output.Error != "" {
+			t.Fatalf("unexpected error: %s", output.Error)
+		}
+		return output.Rows[0]["n"]
+	}
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT current_setting('timezone') AS tz"})
-	if output.Error != "" {
-		t.Fatalf("unexpected error: %s", output.Error)
+	if output := p.Query(sessionA, pgmcp.QueryInput{SQL: "CREATE TEMP TABLE users (id int)"}); output.Error != "" {
+		t.Fatalf("expected CREATE TEMP TABLE to succeed, got %q", output.Error)
 	}
-	tz, ok := output.Rows[0]["tz"].(string)
-	if !ok {
-		t.Fatalf("expected string for timezone, got %T", output.Rows[0]["tz"])
+	// pg_temp comes first in search_path: the temp table shadows users in its own session only.
+	if n := count(sessionA); n != int64(0) {
+		t.Fatalf("expected session A to read its empty temp table, got %v rows", n)
 	}
-	// Server default timezone should be non-empty (not overridden)
-	if tz == "" {
-		t.Fatal("expected non-empty timezone from server default")
+	if n := count(sessionB); n != int64(3) {
+		t.Fatalf("expected session B to read the permanent table's 3 rows, got %v", n)
+	}
+	if n := count(context.Background()); n != int64(3) {
+		t.Fatalf("expected a call without a session to read the permanent table's 3 rows, got %v", n)
+	}
+
+	// Without a session there is nowhere to keep the table.
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "CREATE TEMP TABLE scratch (id int)"})
+	if !strings.HasPrefix(output.Error, "CREATE TEMP TABLE needs a client session") {
+		t.Fatalf("expected CREATE TEMP TABLE without a session to be refused, got %q", output.Error)
 	}
 }
 
-func TestQuery_TimezoneWithReadOnly(t *testing.T) {
+func TestQuery_DDLBlocked(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
-	config.Timezone = "Asia/Jakarta"
-	config.ReadOnly = true
 	p, _ := newTestInstance(t, config)
 
-	// Verify timezone is applied
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT current_setting('timezone') AS tz"})
-	if output.Error != "" {
-		t.Fatalf("unexpected error: %s", output.Error)
-	}
-	tz := output.Rows[0]["tz"].(string)
-	if tz != "Asia/Jakarta" {
-		t.Fatalf("expected Asia/Jakarta, got %s", tz)
-	}
-
-	// Verify read_only is also applied (CREATE should fail)
-	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "CREATE TABLE should_fail (id int)"})
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "CREATE TABLE test (id int)"})
 	if output.Error == "" {
-		t.Fatal("expected error for CREATE in read-only mode")
+		t.Fatal("expected DDL blocked error")
+	}
+	if !strings.Contains(output.Error, "CREATE TABLE is not allowed") {
+		t.Fatalf("expected DDL error, got %q", output.Error)
 	}
 }
 
-func TestQuery_NumericPrecisionWithHooks(t *testing.T) {
+func TestQuery_DDLAllowed(t *testing.T) {
 	t.Parallel()
-	// Setup: create table with bigint 2^53+1
-	setupConfig := defaultConfig()
-	setupConfig.Protection.AllowDDL = true
-	setupP, connStr := newTestInstance(t, setupConfig)
-	setupTable(t, setupP, "CREATE TABLE bigint_cmd_hook (big_id bigint)")
-	setupTable(t, setupP, "INSERT INTO bigint_cmd_hook VALUES (9007199254740993)") // 2^53+1
-	setupP.Close(context.Background())
-
-	// Create instance with accept.sh AfterQuery hook (triggers JSON round-trip)
 	config := defaultConfig()
-	config.DefaultHookTimeoutSeconds = 5
-	ctx := context.Background()
-	p, err := pgmcp.New(ctx, connStr, config, testLogger(), pgmcp.WithServerHooks(pgmcp.ServerHooksConfig{
-		AfterQuery: []pgmcp.HookEntry{
-			{Pattern: ".*", Command: hookScript("accept.sh")},
-		},
-	}))
-	if err != nil {
-		t.Fatalf("Failed to create PostgresMcp: %v", err)
-	}
-	defer p.Close(ctx)
+	config.Protection.AllowDDL = true
+	p, _ := newTestInstance(t, config)
 
-	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT big_id FROM bigint_cmd_hook"})
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "CREATE TABLE test (id int)"})
 	if output.Error != "" {
-		t.Fatalf("unexpected error: %s", output.Error)
+		t.Fatalf("expected DDL to succeed when allowed: %s", output.Error)
 	}
+}
 
-	// After JSON round-trip with UseNumber(), the value survives as json.Number
-	val := output.Rows[0]["big_id"]
-	numVal, ok := val.(json.Number)
-	if !ok {
-		t.Fatalf("expected json.Number after cmd hook round-trip, got %T: %v", val, val)
-	}
-	// Verify exact integer preserved via UseNumber()
-	int64Val, err := numVal.Int64()
-	if err != nil {
-		t.Fatalf("failed to convert json.Number to int64: %v", err)
-	}
-	if int64Val != 9007199254740993 {
-		t.Fatalf("expected 9007199254740993, got %d", int64Val)
+func TestQuery_TransactionControlBlocked(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	p, _ := newTestInstance(t, config)
+
+	for _, sql := range []string{"BEGIN", "COMMIT", "ROLLBACK"} {
+		output := p.Query(context.Background(), pgmcp.QueryInput{SQL: sql})
+		if output.Error == "" {
+			t.Fatalf("expected error for %s", sql)
+		}
+		if !strings.Contains(output.Error, "transaction control statements are not allowed") {
+			t.Fatalf("expected transaction control error for %s, got %q", sql, output.Error)
+		}
 	}
 }
 
-func TestQuery_NumericPrecisionWithoutHooks(t *testing.T) {
+func TestQuery_RowsAffected_Insert(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
 	config.Protection.AllowDDL = true
 	p, _ := newTestInstance(t, config)
 
-	setupTable(t, p, "CREATE TABLE bigint_nohook (big_id bigint)")
-	setupTable(t, p, "INSERT INTO bigint_nohook VALUES (9007199254740993)") // 2^53+1
+	setupTable(t, p, "CREATE TABLE users (id serial PRIMARY KEY, name text)")
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT big_id FROM bigint_nohook"})
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "INSERT INTO users (name) VALUES ('a'), ('b'), ('c')"})
 	if output.Error != "" {
 		t.Fatalf("unexpected error: %s", output.Error)
 	}
-
-	// Without hooks, no JSON round-trip, value stays as int64
-	val := output.Rows[0]["big_id"]
-	int64Val, ok := val.(int64)
-	if !ok {
-		t.Fatalf("expected int64 without hooks, got %T: %v", val, val)
-	}
-	if int64Val != 9007199254740993 {
-		t.Fatalf("expected 9007199254740993, got %d", int64Val)
+	if output.RowsAffected != 3 {
+		t.Fatalf("expected RowsAffected=3, got %d", output.RowsAffected)
 	}
 }
 
-func TestQuery_RowsAffected_Select(t *testing.T) {
+func TestQuery_CommandTag(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
 	config.Protection.AllowDDL = true
+	config.Protection.AllowMerge = true
 	p, _ := newTestInstance(t, config)
 
-	setupTable(t, p, "CREATE TABLE users_ra (id serial PRIMARY KEY, name text)")
-	setupTable(t, p, "INSERT INTO users_ra (name) VALUES ('a'), ('b'), ('c'), ('d'), ('e')")
+	setupTable(t, p, "CREATE TABLE cmd_tag (id serial PRIMARY KEY, name text)")
+
+	tests := []struct {
+		sql     string
+		command string
+	}{
+		{"INSERT INTO cmd_tag (name) VALUES ('a'), ('b'), ('c')", "INSERT 0 3"},
+		{"UPDATE cmd_tag SET name = 'z' WHERE id <= 2", "UPDATE 2"},
+		{"DELETE FROM cmd_tag WHERE id = 3", "DELETE 1"},
+		{"MERGE INTO cmd_tag t USING (SELECT 1 AS id) s ON t.id = s.id WHEN MATCHED THEN UPDATE SET name = 'm'", "MERGE 1"},
+		{"INSERT INTO cmd_tag (name) VALUES ('r') RETURNING id", "INSERT 0 1"},
+		{"SELECT * FROM cmd_tag", ""},
+	}
+	for _, tt := range tests {
+		output := p.Query(context.Background(), pgmcp.QueryInput{SQL: tt.sql})
+		if output.Error != "" {
+			t.Fatalf("unexpected error for %q: %s", tt.sql, output.Error)
+		}
+		if output.Command != tt.command {
+			t.Fatalf("expected Command=%q for %q, got %q", tt.command, tt.sql, output.Command)
+		}
+		if output.LastInsertOID != 0 {
+			t.Fatalf("expected LastInsertOID=0 for %q, got %d", tt.sql, output.LastInsertOID)
+		}
+	}
+}
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT * FROM users_ra"})
+func TestQuery_CommandTagOmittedForSelect(t *testing.T) {
+	t.Parallel()
+	p, _ := newTestInstance(t, defaultConfig())
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1 AS one"})
 	if output.Error != "" {
 		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if len(output.Rows) != 5 {
-		t.Fatalf("expected 5 rows, got %d", len(output.Rows))
+	b, err := json.Marshal(output)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
 	}
-	if output.RowsAffected != 5 {
-		t.Fatalf("expected RowsAffected=5, got %d", output.RowsAffected)
+	expected := `{"columns":["one"],"rows":[{"one":1}],"rows_affected":1}`
+	if string(b) != expected {
+		t.Fatalf("expected %s, got %s", expected, string(b))
 	}
 }
 
-func TestQuery_RowsAffected_InsertReturning(t *testing.T) {
+func TestQuery_RowsAffected_Update(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
 	config.Protection.AllowDDL = true
 	p, _ := newTestInstance(t, config)
 
-	setupTable(t, p, "CREATE TABLE users_ra2 (id serial PRIMARY KEY, name text)")
+	setupTable(t, p, "CREATE TABLE users (id serial PRIMARY KEY, active boolean DEFAULT false)")
+	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
+	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
+	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
+	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
+	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "INSERT INTO users_ra2 (name) VALUES ('a') RETURNING *"})
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "UPDATE users SET active = true WHERE id <= 3"})
 	if output.Error != "" {
 		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if output.RowsAffected != 1 {
-		t.Fatalf("expected RowsAffected=1, got %d", output.RowsAffected)
-	}
-	if len(output.Rows) != 1 {
-		t.Fatalf("expected 1 row, got %d", len(output.Rows))
-	}
-	if output.Rows[0]["name"] != "a" {
-		t.Fatalf("expected name='a', got %v", output.Rows[0]["name"])
+	if output.RowsAffected != 3 {
+		t.Fatalf("expected RowsAffected=3, got %d", output.RowsAffected)
 	}
 }
 
-func TestQuery_CidrColumn(t *testing.T) {
+func TestQuery_RowsAffected_Delete(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
 	config.Protection.AllowDDL = true
 	p, _ := newTestInstance(t, config)
 
-	setupTable(t, p, "CREATE TABLE subnets (network cidr)")
-	setupTable(t, p, "INSERT INTO subnets VALUES ('10.0.0.0/8')")
+	setupTable(t, p, "CREATE TABLE users (id serial PRIMARY KEY)")
+	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
+	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
+	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
+	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
+	setupTable(t, p, "INSERT INTO users DEFAULT VALUES")
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT network FROM subnets"})
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "DELETE FROM users WHERE id <= 2"})
 	if output.Error != "" {
 		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	netStr, ok := output.Rows[0]["network"].(string)
-	if !ok {
-		t.Fatalf("expected string for cidr, got %T", output.Rows[0]["network"])
+	if output.RowsAffected != 2 {
+		t.Fatalf("expected RowsAffected=2, got %d", output.RowsAffected)
 	}
-	if netStr != "10.0.0.0/8" {
-		t.Fatalf("expected 10.0.0.0/8, got %s", netStr)
+}
+
+func TestQuery_SemaphoreContention(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Pool.MaxConns = 1
+	config.Query.DefaultTimeoutSeconds = 30
+	p, _ := newTestInstance(t, config)
+
+	// Start a slow query in a goroutine to hold the semaphore
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT pg_sleep(5)"})
+	}()
+
+	// Give the goroutine time to acquire the semaphore
+	time.Sleep(100 * time.Millisecond)
+
+	// Try to run another query with a short context timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 1"})
+	if output.Error == "" {
+		t.Fatal("expected semaphore contention error")
+	}
+	if !strings.Contains(output.Error, "failed to acquire query slot") {
+		t.Fatalf("expected semaphore error, got %q", output.Error)
 	}
+
+	<-done
 }
 
-func TestQuery_AfterHookRejectSelectNoSideEffect(t *testing.T) {
+func TestQuery_AfterHookRejectRollbacksWrite(t *testing.T) {
 	t.Parallel()
+	// Create table with a non-hooked instance first
 	setupConfig := defaultConfig()
 	setupConfig.Protection.AllowDDL = true
 	setupP, connStr := newTestInstance(t, setupConfig)
-	setupTable(t, setupP, "CREATE TABLE users_select_reject (id serial PRIMARY KEY, name text)")
-	setupTable(t, setupP, "INSERT INTO users_select_reject (name) VALUES ('existing')")
+	setupTable(t, setupP, "CREATE TABLE users (id serial PRIMARY KEY, name text)")
 	setupP.Close(context.Background())
 
-	// Create instance with rejecting AfterQuery hook
+	// Now create a hooked instance using the same connection string
 	config := defaultConfig()
 	config.DefaultHookTimeoutSeconds = 5
 	ctx := context.Background()
@@ -1705,16 +2416,16 @@ func TestQuery_AfterHookRejectSelectNoSideEffect(t *testing.T) {
 	}
 	defer p.Close(ctx)
 
-	// SELECT is read-only — hook rejects but no side effect
-	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT * FROM users_select_reject"})
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO users (name) VALUES ('rejected_row') RETURNING *"})
 	if output.Error == "" {
-		t.Fatal("expected hook rejection error for SELECT")
+		t.Fatal("expected hook rejection error")
 	}
 	if !strings.Contains(output.Error, "rejected by test hook") {
 		t.Fatalf("expected rejection message, got %q", output.Error)
 	}
 
-	// Verify data unchanged — use non-hooked instance
+	// Verify the row was NOT inserted (rollback happened)
+	// Use a non-hooked instance to verify
 	verifyConfig := defaultConfig()
 	verifyP, err := pgmcp.New(ctx, connStr, verifyConfig, testLogger())
 	if err != nil {
@@ -1722,1098 +2433,3343 @@ func TestQuery_AfterHookRejectSelectNoSideEffect(t *testing.T) {
 	}
 	defer verifyP.Close(ctx)
 
-	verifyOutput := verifyP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS cnt FROM users_select_reject"})
+	verifyOutput := verifyP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS cnt FROM users WHERE name = 'rejected_row'"})
 	if verifyOutput.Error != "" {
 		t.Fatalf("verification query failed: %s", verifyOutput.Error)
 	}
-	if verifyOutput.Rows[0]["cnt"] != int64(1) {
-		t.Fatalf("expected 1 row unchanged, got %v", verifyOutput.Rows[0]["cnt"])
+	cnt := verifyOutput.Rows[0]["cnt"]
+	if cnt != int64(0) {
+		t.Fatalf("expected 0 rows (rollback), got %v (%T)", cnt, cnt)
 	}
 }
 
-func TestQuery_ReadOnlyStatementRollbacksBeforeHooks(t *testing.T) {
+func TestQuery_AfterHookAcceptCommitsWrite(t *testing.T) {
 	t.Parallel()
-	// This test verifies that SELECT causes rollback before AfterQuery hooks run.
-	// The hook still runs, but the transaction is already rolled back.
+	// Create table with a non-hooked instance first
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setupP, connStr := newTestInstance(t, setupConfig)
+	setupTable(t, setupP, "CREATE TABLE users (id serial PRIMARY KEY, name text)")
+	setupP.Close(context.Background())
+
+	// Create hooked instance
 	config := defaultConfig()
-	config.Protection.AllowDDL = true
 	config.DefaultHookTimeoutSeconds = 5
-
-	// Use a capture hook to verify it receives data (hook is called even though tx is rolled back)
-	captureHook := &captureAfterHook{}
-	config.AfterQueryHooks = []pgmcp.AfterQueryHookEntry{
-		{Name: "capture", Hook: captureHook},
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, connStr, config, testLogger(), pgmcp.WithServerHooks(pgmcp.ServerHooksConfig{
+		AfterQuery: []pgmcp.HookEntry{
+			{Pattern: ".*", Command: hookScript("accept.sh")},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
 	}
-	p, _ := newTestInstance(t, config)
-
-	setupTable(t, p, "CREATE TABLE readonly_hook_test (id serial PRIMARY KEY, name text)")
-	setupTable(t, p, "INSERT INTO readonly_hook_test (name) VALUES ('Alice')")
+	defer p.Close(ctx)
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT * FROM readonly_hook_test"})
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO users (name) VALUES ('accepted_row') RETURNING *"})
 	if output.Error != "" {
 		t.Fatalf("unexpected error: %s", output.Error)
 	}
 
-	// Hook was called (it captured the result)
-	if captureHook.captured == nil {
-		t.Fatal("expected AfterQuery hook to be called for SELECT")
+	// Verify the row WAS inserted (commit happened) using non-hooked instance
+	verifyConfig := defaultConfig()
+	verifyP, err := pgmcp.New(ctx, connStr, verifyConfig, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create verify instance: %v", err)
 	}
-	if len(captureHook.captured.Rows) != 1 {
-		t.Fatalf("expected hook to receive 1 row, got %d", len(captureHook.captured.Rows))
+	defer verifyP.Close(ctx)
+
+	verifyOutput := verifyP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS cnt FROM users WHERE name = 'accepted_row'"})
+	if verifyOutput.Error != "" {
+		t.Fatalf("verification query failed: %s", verifyOutput.Error)
 	}
-	if captureHook.captured.Rows[0]["name"] != "Alice" {
-		t.Fatalf("expected hook to receive 'Alice', got %v", captureHook.captured.Rows[0]["name"])
+	cnt := verifyOutput.Rows[0]["cnt"]
+	if cnt != int64(1) {
+		t.Fatalf("expected 1 row (committed), got %v (%T)", cnt, cnt)
 	}
 }
 
-func TestQuery_MaxSQLLength_ExactLimit(t *testing.T) {
+func TestQuery_HookCrashStopsPipeline(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
-	config.Query.MaxSQLLength = 20
-	p, _ := newTestInstance(t, config)
+	config.DefaultHookTimeoutSeconds = 5
+
+	p := newTestInstanceWithHooks(t, config, pgmcp.ServerHooksConfig{
+		BeforeQuery: []pgmcp.HookEntry{
+			{Pattern: ".*", Command: hookScript("crash.sh")},
+		},
+	})
 
-	// "SELECT 1" is 8 bytes, well under 20
 	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1"})
-	if output.Error != "" {
-		t.Fatalf("expected query under limit to succeed, got error: %s", output.Error)
+	if output.Error == "" {
+		t.Fatal("expected hook crash error")
+	}
+	if !strings.Contains(output.Error, "hook failed") {
+		t.Fatalf("expected hook failed error, got %q", output.Error)
 	}
 }
 
-func TestQuery_CreateExtensionBlocked(t *testing.T) {
+func TestQuery_HookBadJsonStopsPipeline(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
-	// AllowCreateExtension defaults to false
-	p, _ := newTestInstance(t, config)
+	config.DefaultHookTimeoutSeconds = 5
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "CREATE EXTENSION IF NOT EXISTS pg_trgm"})
+	p := newTestInstanceWithHooks(t, config, pgmcp.ServerHooksConfig{
+		BeforeQuery: []pgmcp.HookEntry{
+			{Pattern: ".*", Command: hookScript("bad_json.sh")},
+		},
+	})
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1"})
 	if output.Error == "" {
-		t.Fatal("expected CREATE EXTENSION to be blocked")
+		t.Fatal("expected unparseable response error")
 	}
-	if !strings.Contains(output.Error, "CREATE EXTENSION is not allowed") {
-		t.Fatalf("expected CREATE EXTENSION error, got %q", output.Error)
+	if !strings.Contains(output.Error, "unparseable response") {
+		t.Fatalf("expected unparseable response error, got %q", output.Error)
 	}
 }
 
-func TestQuery_MaintenanceBlocked(t *testing.T) {
+func TestQuery_ExplainAnalyzeProtection(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
 	config.Protection.AllowDDL = true
 	p, _ := newTestInstance(t, config)
 
-	setupTable(t, p, "CREATE TABLE maint_test (id serial PRIMARY KEY)")
+	setupTable(t, p, "CREATE TABLE users (id serial PRIMARY KEY, name text)")
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "ANALYZE maint_test"})
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "EXPLAIN ANALYZE DELETE FROM users"})
 	if output.Error == "" {
-		t.Fatal("expected ANALYZE to be blocked")
+		t.Fatal("expected protection error for EXPLAIN ANALYZE DELETE")
 	}
-	if !strings.Contains(output.Error, "VACUUM/ANALYZE is not allowed") {
-		t.Fatalf("expected maintenance error, got %q", output.Error)
+	if !strings.Contains(output.Error, "DELETE without WHERE") {
+		t.Fatalf("expected delete protection error, got %q", output.Error)
 	}
 }
 
-func TestQuery_CreateTriggerBlocked(t *testing.T) {
+func TestQuery_SummarizeOversizeResults(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
-	config.Protection.AllowDDL = true
-	config.Protection.AllowCreateFunction = true
+	config.Query.MaxResultLength = 1000
+	config.Query.SummarizeOversizeResults = true
 	p, _ := newTestInstance(t, config)
 
-	setupTable(t, p, "CREATE TABLE trigger_test (id serial PRIMARY KEY)")
-	setupTable(t, p, "CREATE FUNCTION audit_func() RETURNS TRIGGER AS $$ BEGIN RETURN NEW; END; $$ LANGUAGE plpgsql")
-
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "CREATE TRIGGER trg AFTER INSERT ON trigger_test FOR EACH ROW EXECUTE FUNCTION audit_func()"})
-	if output.Error == "" {
-		t.Fatal("expected CREATE TRIGGER to be blocked")
+	output := p.Query(context.Background(), pgmcp.QueryInput{
+		SQL: "SELECT g AS id, 'padding text for row ' || g AS label FROM generate_series(1, 200) AS g",
+	})
+	if output.Error != "" {
+		t.Fatalf("expected no error, got %q", output.Error)
 	}
-	if !strings.Contains(output.Error, "CREATE TRIGGER is not allowed") {
-		t.Fatalf("expected trigger error, got %q", output.Error)
+	if output.Summary == nil {
+		t.Fatal("expected summary to be set")
+	}
+	if output.Summary.TotalRows != 200 {
+		t.Fatalf("expected total_rows 200, got %d", output.Summary.TotalRows)
+	}
+	if len(output.Summary.Columns) != 2 || output.Summary.Columns[0] != "id" || output.Summary.Columns[1] != "label" {
+		t.Fatalf("expected summary columns [id label], got %v", output.Summary.Columns)
+	}
+	var ids []int32
+	for _, row := range output.Summary.SampleRows {
+		ids = append(ids, row["id"].(int32))
+	}
+	if fmt.Sprint(ids) != "[1 2 3 198 199 200]" {
+		t.Fatalf("expected sample_rows to be the first/last 3 rows, got ids %v", ids)
+	}
+	if output.Rows != nil {
+		t.Fatalf("expected Rows to be nil, got %d rows", len(output.Rows))
 	}
 }
 
-func TestQuery_CreateRuleBlocked(t *testing.T) {
+func TestQuery_AutoLimit(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
-	config.Protection.AllowDDL = true
+	config.Query.AutoLimit = 10
 	p, _ := newTestInstance(t, config)
 
-	setupTable(t, p, "CREATE TABLE rule_test (id serial PRIMARY KEY)")
-
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "CREATE RULE r AS ON INSERT TO rule_test DO ALSO NOTIFY rule_test_changed"})
-	if output.Error == "" {
-		t.Fatal("expected CREATE RULE to be blocked")
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT g FROM generate_series(1, 50) AS g"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if !strings.Contains(output.Error, "CREATE RULE is not allowed") {
-		t.Fatalf("expected rule error, got %q", output.Error)
+	if len(output.Rows) != 10 || !output.LimitApplied {
+		t.Fatalf("expected 10 rows with limit_applied, got %d rows, limit_applied=%v", len(output.Rows), output.LimitApplied)
 	}
-}
 
-func TestQuery_CommitBlocked(t *testing.T) {
-	t.Parallel()
-	config := defaultConfig()
-	p, _ := newTestInstance(t, config)
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT g FROM generate_series(1, 50) AS g LIMIT 3"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if len(output.Rows) != 3 || output.LimitApplied {
+		t.Fatalf("expected smaller limit kept, got %d rows, limit_applied=%v", len(output.Rows), output.LimitApplied)
+	}
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "COMMIT"})
-	if output.Error == "" {
-		t.Fatal("expected COMMIT to be blocked")
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT count(*) AS n FROM generate_series(1, 50) AS g"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if !strings.Contains(output.Error, "transaction control statements are not allowed") {
-		t.Fatalf("expected transaction control error, got %q", output.Error)
+	if output.LimitApplied || output.Rows[0]["n"] != int64(50) {
+		t.Fatalf("expected aggregate query untouched, got %v, limit_applied=%v", output.Rows, output.LimitApplied)
 	}
 }
 
-func TestQuery_AlterExtensionBlocked(t *testing.T) {
+func TestQuery_IncludePlan(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
 	p, _ := newTestInstance(t, config)
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "ALTER EXTENSION pg_trgm UPDATE"})
-	if output.Error == "" {
-		t.Fatal("expected ALTER EXTENSION to be blocked")
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1 AS one", IncludePlan: true})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if !strings.Contains(output.Error, "ALTER EXTENSION is not allowed") {
-		t.Fatalf("expected ALTER EXTENSION error, got %q", output.Error)
+	plan := output.PlanSummary
+	if plan == nil {
+		t.Fatal("expected plan_summary to be set")
+	}
+	if plan.NodeType != "Result" || plan.EstimatedRows != 1 {
+		t.Fatalf("expected Result node estimating 1 row, got %+v", plan)
+	}
+	if plan.LargeSeqScan || len(plan.SeqScanTables) != 0 {
+		t.Fatalf("expected no seq scans, got %+v", plan)
+	}
+	if len(output.Rows) != 1 || output.Rows[0]["one"] != int32(1) {
+		t.Fatalf("expected query results alongside the plan, got %v", output.Rows)
 	}
-}
 
-// --- Gap 5: Command-mode AfterQuery timeout triggers rollback for writes ---
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1 AS one"})
+	if output.PlanSummary != nil {
+		t.Fatalf("expected no plan_summary without include_plan, got %+v", output.PlanSummary)
+	}
+}
 
-func TestQuery_CmdAfterHookTimeoutRollbacksInsert(t *testing.T) {
+func TestQuery_IncludePlan_LargeSeqScan(t *testing.T) {
 	t.Parallel()
-	setupConfig := defaultConfig()
-	setupConfig.Protection.AllowDDL = true
-	setupP, connStr := newTestInstance(t, setupConfig)
-	setupTable(t, setupP, "CREATE TABLE cmd_timeout_insert (id serial PRIMARY KEY, name text)")
-	setupP.Close(context.Background())
-
 	config := defaultConfig()
-	config.DefaultHookTimeoutSeconds = 1
-	ctx := context.Background()
-	p, err := pgmcp.New(ctx, connStr, config, testLogger(), pgmcp.WithServerHooks(pgmcp.ServerHooksConfig{
-		AfterQuery: []pgmcp.HookEntry{
-			{Pattern: ".*", Command: hookScript("slow.sh")},
-		},
-	}))
+	config.Protection.AllowDDL = true
+	p, connStr := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE events (id int, kind text)")
+	setupTable(t, p, "INSERT INTO events SELECT g, 'kind ' || (g % 10) FROM generate_series(1, 20000) AS g")
+	// ANALYZE is blocked by protection rules; run it directly so reltuples is populated.
+	conn, err := pgx.Connect(context.Background(), connStr)
 	if err != nil {
-		t.Fatalf("Failed to create PostgresMcp: %v", err)
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(context.Background(), "ANALYZE events"); err != nil {
+		t.Fatalf("failed to analyze: %v", err)
 	}
-	defer p.Close(ctx)
 
-	output := p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO cmd_timeout_insert (name) VALUES ('should_rollback') RETURNING *"})
-	if output.Error == "" {
-		t.Fatal("expected hook timeout error")
+	output := p.Query(context.Background(), pgmcp.QueryInput{
+		SQL:         "SELECT kind, count(*) FROM events WHERE kind <> 'kind 3' GROUP BY kind",
+		IncludePlan: true,
+	})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if !strings.Contains(output.Error, "hook timed out") {
-		t.Fatalf("expected 'hook timed out' in error, got %q", output.Error)
+	plan := output.PlanSummary
+	if plan == nil {
+		t.Fatal("expected plan_summary to be set")
 	}
-
-	// Verify the row was NOT inserted (rollback happened)
-	verifyConfig := defaultConfig()
-	verifyP, err := pgmcp.New(ctx, connStr, verifyConfig, testLogger())
-	if err != nil {
-		t.Fatalf("Failed to create verify instance: %v", err)
+	if plan.NodeType == "" || plan.TotalCost <= 0 || plan.EstimatedRows <= 0 {
+		t.Fatalf("expected node type, cost, and row estimate, got %+v", plan)
+	}
+	if len(plan.SeqScanTables) != 1 || plan.SeqScanTables[0] != "public.events" {
+		t.Fatalf("expected seq scan on public.events, got %v", plan.SeqScanTables)
+	}
+	if !plan.LargeSeqScan {
+		t.Fatalf("expected large_seq_scan for a 20000-row table, got %+v", plan)
+	}
+	if len(output.Rows) != 9 {
+		t.Fatalf("expected 9 result rows, got %d", len(output.Rows))
+	}
+	pgmcp.SortRows(output, []string{"kind"})
+	if output.Rows[0]["kind"] != "kind 0" || output.Rows[8]["kind"] != "kind 9" {
+		t.Fatalf("expected kinds 0..9 without 3, got %v", output.Rows)
 	}
-	defer verifyP.Close(ctx)
 
-	verifyOutput := verifyP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS cnt FROM cmd_timeout_insert"})
-	if verifyOutput.Error != "" {
-		t.Fatalf("verification query failed: %s", verifyOutput.Error)
+	// Writes are never explained.
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "DELETE FROM events WHERE id = 1", IncludePlan: true})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if verifyOutput.Rows[0]["cnt"] != int64(0) {
-		t.Fatalf("expected 0 rows (rollback), got %v", verifyOutput.Rows[0]["cnt"])
+	if output.PlanSummary != nil {
+		t.Fatalf("expected no plan_summary for a write, got %+v", output.PlanSummary)
 	}
 }
 
-// --- Gap 6: Command-mode AfterQuery reject rollback for UPDATE and DELETE ---
-
-func TestQuery_CmdAfterHookRejectRollbacksUpdate(t *testing.T) {
+func TestQuery_CaptureNotices(t *testing.T) {
 	t.Parallel()
-	setupConfig := defaultConfig()
-	setupConfig.Protection.AllowDDL = true
-	setupP, connStr := newTestInstance(t, setupConfig)
-	setupTable(t, setupP, "CREATE TABLE cmd_reject_update (id serial PRIMARY KEY, name text)")
-	setupTable(t, setupP, "INSERT INTO cmd_reject_update (name) VALUES ('original')")
-	setupP.Close(context.Background())
-
 	config := defaultConfig()
-	config.DefaultHookTimeoutSeconds = 5
-	config.Protection.AllowUpdateWithoutWhere = true
-	ctx := context.Background()
-	p, err := pgmcp.New(ctx, connStr, config, testLogger(), pgmcp.WithServerHooks(pgmcp.ServerHooksConfig{
-		AfterQuery: []pgmcp.HookEntry{
-			{Pattern: ".*", Command: hookScript("reject.sh")},
-		},
-	}))
-	if err != nil {
-		t.Fatalf("Failed to create PostgresMcp: %v", err)
-	}
-	defer p.Close(ctx)
+	config.Protection.AllowDo = true
+	config.Protection.AllowDDL = true
+	config.Query.CaptureNotices = true
+	p, _ := newTestInstance(t, config)
 
-	output := p.Query(ctx, pgmcp.QueryInput{SQL: "UPDATE cmd_reject_update SET name = 'changed' WHERE id = 1"})
-	if output.Error == "" {
-		t.Fatal("expected hook rejection error")
+	output := p.Query(context.Background(), pgmcp.QueryInput{
+		SQL: "DO $$ BEGIN RAISE NOTICE 'processed % rows', 42; RAISE WARNING 'almost out of space'; END $$",
+	})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if !strings.Contains(output.Error, "rejected by test hook") {
-		t.Fatalf("expected rejection message, got %q", output.Error)
+	expected := []string{"NOTICE: processed 42 rows", "WARNING: almost out of space"}
+	if fmt.Sprint(output.Notices) != fmt.Sprint(expected) {
+		t.Fatalf("expected notices %q, got %q", expected, output.Notices)
 	}
 
-	// Verify row was NOT updated (rollback happened)
-	verifyConfig := defaultConfig()
-	verifyP, err := pgmcp.New(ctx, connStr, verifyConfig, testLogger())
-	if err != nil {
-		t.Fatalf("Failed to create verify instance: %v", err)
+	setupTable(t, p, "CREATE TABLE IF NOT EXISTS widgets (id int)")
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "CREATE TABLE IF NOT EXISTS widgets (id int)"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	defer verifyP.Close(ctx)
-
-	verifyOutput := verifyP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT name FROM cmd_reject_update WHERE id = 1"})
-	if verifyOutput.Error != "" {
-		t.Fatalf("verification query failed: %s", verifyOutput.Error)
+	if len(output.Notices) != 1 || !strings.Contains(output.Notices[0], `relation "widgets" already exists, skipping`) {
+		t.Fatalf("expected IF NOT EXISTS notice, got %q", output.Notices)
 	}
-	if verifyOutput.Rows[0]["name"] != "original" {
-		t.Fatalf("expected 'original' (rollback), got %v", verifyOutput.Rows[0]["name"])
+
+	// Notices belong to the query that raised them.
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1"})
+	if len(output.Notices) != 0 {
+		t.Fatalf("expected no notices, got %q", output.Notices)
 	}
 }
 
-func TestQuery_CmdAfterHookRejectRollbacksDelete(t *testing.T) {
+func TestQuery_NoticesNotCapturedByDefault(t *testing.T) {
 	t.Parallel()
-	setupConfig := defaultConfig()
-	setupConfig.Protection.AllowDDL = true
-	setupP, connStr := newTestInstance(t, setupConfig)
-	setupTable(t, setupP, "CREATE TABLE cmd_reject_delete (id serial PRIMARY KEY, name text)")
-	setupTable(t, setupP, "INSERT INTO cmd_reject_delete (name) VALUES ('keep_me')")
-	setupP.Close(context.Background())
-
 	config := defaultConfig()
-	config.DefaultHookTimeoutSeconds = 5
-	ctx := context.Background()
-	p, err := pgmcp.New(ctx, connStr, config, testLogger(), pgmcp.WithServerHooks(pgmcp.ServerHooksConfig{
-		AfterQuery: []pgmcp.HookEntry{
-			{Pattern: ".*", Command: hookScript("reject.sh")},
-		},
-	}))
-	if err != nil {
-		t.Fatalf("Failed to create PostgresMcp: %v", err)
-	}
-	defer p.Close(ctx)
+	config.Protection.AllowDo = true
+	p, _ := newTestInstance(t, config)
 
-	output := p.Query(ctx, pgmcp.QueryInput{SQL: "DELETE FROM cmd_reject_delete WHERE id = 1"})
-	if output.Error == "" {
-		t.Fatal("expected hook rejection error")
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "DO $$ BEGIN RAISE NOTICE 'hello'; END $$"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if !strings.Contains(output.Error, "rejected by test hook") {
-		t.Fatalf("expected rejection message, got %q", output.Error)
+	if output.Notices != nil {
+		t.Fatalf("expected notices to be omitted, got %q", output.Notices)
 	}
+}
 
-	// Verify row was NOT deleted (rollback happened)
-	verifyConfig := defaultConfig()
-	verifyP, err := pgmcp.New(ctx, connStr, verifyConfig, testLogger())
-	if err != nil {
-		t.Fatalf("Failed to create verify instance: %v", err)
-	}
-	defer verifyP.Close(ctx)
+func TestQuery_BlockExplainAnalyze(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Query.BlockExplainAnalyze = true
+	p, _ := newTestInstance(t, config)
 
-	verifyOutput := verifyP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS cnt FROM cmd_reject_delete"})
-	if verifyOutput.Error != "" {
-		t.Fatalf("verification query failed: %s", verifyOutput.Error)
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "EXPLAIN SELECT 1"})
+	if output.Error != "" {
+		t.Fatalf("expected plain EXPLAIN to pass, got %q", output.Error)
 	}
-	if verifyOutput.Rows[0]["cnt"] != int64(1) {
-		t.Fatalf("expected 1 row (rollback), got %v", verifyOutput.Rows[0]["cnt"])
+
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "EXPLAIN ANALYZE SELECT 1"})
+	if !strings.Contains(output.Error, "EXPLAIN ANALYZE is not allowed") {
+		t.Fatalf("expected EXPLAIN ANALYZE to be blocked, got %q", output.Error)
 	}
 }
 
-// --- Gap 7: Command-mode BeforeQuery multi-hook chaining (integration) ---
-
-func TestQuery_CmdBeforeHookChaining(t *testing.T) {
+func TestQuery_ExplainOptionPolicy(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
-	config.Protection.AllowDDL = true
-	config.DefaultHookTimeoutSeconds = 5
-
-	setupConfig := defaultConfig()
-	setupConfig.Protection.AllowDDL = true
-	setupP, connStr := newTestInstance(t, setupConfig)
-	setupTable(t, setupP, "CREATE TABLE chain_test (id serial PRIMARY KEY, name text)")
-	setupTable(t, setupP, "INSERT INTO chain_test (name) VALUES ('Alice'), ('Bob'), ('Charlie')")
-	setupP.Close(context.Background())
-
-	// Chain: modify_query.sh rewrites to "SELECT 1 AS modified",
-	// then add_limit.sh appends "LIMIT 1" since it contains SELECT.
-	// Final query: "SELECT 1 AS modified LIMIT 1"
-	ctx := context.Background()
-	p, err := pgmcp.New(ctx, connStr, config, testLogger(), pgmcp.WithServerHooks(pgmcp.ServerHooksConfig{
-		BeforeQuery: []pgmcp.HookEntry{
-			{Pattern: ".*", Command: hookScript("modify_query.sh")},
-			{Pattern: "(?i)SELECT", Command: hookScript("add_limit.sh")},
-		},
-	}))
-	if err != nil {
-		t.Fatalf("Failed to create PostgresMcp: %v", err)
-	}
-	defer p.Close(ctx)
+	config.Query.ExplainOptionPolicy = pgmcp.ExplainOptionPolicy{Disallowed: []string{"wal"}, ForceTimingOff: true}
+	p, _ := newTestInstance(t, config)
 
-	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT * FROM chain_test"})
+	// WAL is stripped and TIMING OFF added; the EXPLAIN ANALYZE itself still runs.
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "EXPLAIN (ANALYZE, WAL, TIMING, FORMAT TEXT) SELECT 1"})
 	if output.Error != "" {
-		t.Fatalf("unexpected error: %s", output.Error)
+		t.Fatalf("expected stripped EXPLAIN to run, got %q", output.Error)
 	}
-	// First hook rewrites to "SELECT 1 AS modified"
-	// Second hook appends " LIMIT 1"
-	// Result should be single row with column "modified"
-	if len(output.Rows) != 1 {
-		t.Fatalf("expected 1 row (LIMIT 1 from chained hook), got %d", len(output.Rows))
+	if len(output.Rows) == 0 {
+		t.Fatal("expected plan rows")
 	}
-	if len(output.Columns) != 1 || output.Columns[0] != "modified" {
-		t.Fatalf("expected column 'modified' from first hook rewrite, got %v", output.Columns)
+	plan := fmt.Sprint(output.Rows)
+	if !strings.Contains(plan, "actual rows=") || strings.Contains(plan, "actual time=") {
+		t.Fatalf("expected an ANALYZE plan without timing, got %s", plan)
 	}
 }
 
-// --- Gap 8: Command-mode AfterQuery multi-hook chaining (integration) ---
-
-func TestQuery_CmdAfterHookChaining(t *testing.T) {
+func TestQuery_ExplainOptionPolicyReject(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
-	config.Protection.AllowDDL = true
-	config.DefaultHookTimeoutSeconds = 5
-
-	setupConfig := defaultConfig()
-	setupConfig.Protection.AllowDDL = true
-	setupP, connStr := newTestInstance(t, setupConfig)
-	setupTable(t, setupP, "CREATE TABLE after_chain_test (id serial PRIMARY KEY, name text)")
-	setupTable(t, setupP, "INSERT INTO after_chain_test (name) VALUES ('Alice')")
-	setupP.Close(context.Background())
+	config.Query.ExplainOptionPolicy = pgmcp.ExplainOptionPolicy{Disallowed: []string{"wal"}, Action: pgmcp.ExplainOptionReject}
+	p, _ := newTestInstance(t, config)
 
-	// Chain two AfterQuery hooks: first modifies result, second accepts.
-	// modify_result.sh replaces with {"columns":["modified"],"rows":[{"modified":true}]}
-	// accept.sh just accepts (passes through).
-	ctx := context.Background()
-	p, err := pgmcp.New(ctx, connStr, config, testLogger(), pgmcp.WithServerHooks(pgmcp.ServerHooksConfig{
-		AfterQuery: []pgmcp.HookEntry{
-			{Pattern: ".*", Command: hookScript("modify_result.sh")},
-			{Pattern: ".*", Command: hookScript("accept.sh")},
-		},
-	}))
-	if err != nil {
-		t.Fatalf("Failed to create PostgresMcp: %v", err)
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "EXPLAIN (ANALYZE, WAL) SELECT 1"})
+	if !strings.Contains(output.Error, "EXPLAIN option WAL is not allowed") {
+		t.Fatalf("expected WAL to be rejected, got %q", output.Error)
 	}
-	defer p.Close(ctx)
-
-	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT * FROM after_chain_test"})
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "EXPLAIN ANALYZE SELECT 1"})
 	if output.Error != "" {
-		t.Fatalf("unexpected error: %s", output.Error)
-	}
-	// First hook modified result to {"columns":["modified"],"rows":[{"modified":true}]}
-	// Second hook accepted the modified result
-	if len(output.Columns) != 1 || output.Columns[0] != "modified" {
-		t.Fatalf("expected column 'modified' from first AfterQuery hook, got %v", output.Columns)
-	}
-	if len(output.Rows) != 1 {
-		t.Fatalf("expected 1 row from modified result, got %d", len(output.Rows))
+		t.Fatalf("expected EXPLAIN ANALYZE without WAL to run, got %q", output.Error)
 	}
 }
 
-// --- Gap 9: Read-only mode blocks SET transaction_read_only (integration) ---
-
-func TestQuery_ReadOnlyBlocksSetTransactionReadOnly(t *testing.T) {
+func TestQuery_IncludeResultHash(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
-	config.ReadOnly = true
-	config.Protection.AllowSet = true
+	config.Protection.AllowDDL = true
+	config.Query.IncludeResultHash = true
 	p, _ := newTestInstance(t, config)
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SET transaction_read_only = false"})
-	if output.Error == "" {
-		t.Fatal("expected error for SET transaction_read_only in read-only mode")
+	setupTable(t, p, "CREATE TABLE hashed (id int, name text)")
+	setupTable(t, p, "INSERT INTO hashed VALUES (1, 'a'), (2, 'b')")
+
+	query := pgmcp.QueryInput{SQL: "SELECT * FROM hashed ORDER BY id"}
+	first := p.Query(context.Background(), query)
+	second := p.Query(context.Background(), query)
+	if first.Error != "" || second.Error != "" {
+		t.Fatalf("unexpected errors: %q, %q", first.Error, second.Error)
 	}
-	if !strings.Contains(output.Error, "transaction_read_only") {
-		t.Fatalf("expected error about transaction_read_only, got %q", output.Error)
+	if first.ResultHash == "" || first.ResultHash != second.ResultHash {
+		t.Fatalf("expected identical results to hash equal, got %q and %q", first.ResultHash, second.ResultHash)
 	}
-}
 
-// --- Gap 10: JSONB sanitization integration test with real DB ---
+	setupTable(t, p, "UPDATE hashed SET name = 'c' WHERE id = 2")
+	changed := p.Query(context.Background(), query)
+	if changed.Error != "" {
+		t.Fatalf("unexpected error: %q", changed.Error)
+	}
+	if changed.ResultHash == first.ResultHash {
+		t.Fatal("expected changed result to hash differently")
+	}
+}
 
-func TestQuery_SanitizationJSONBIntegration(t *testing.T) {
+func TestQuery_ReturnAffectedKeys(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
 	config.Protection.AllowDDL = true
-	config.Sanitization = []pgmcp.SanitizationRule{
-		{
-			Pattern:     `(\+62)\d{6,}(\d{3})`,
-			Replacement: "${1}xxx${2}",
-			Description: "Mask Indonesian phone numbers",
-		},
-		{
-			Pattern:     `(\d{4})\d{8}(\d{4})`,
-			Replacement: "${1}********${2}",
-			Description: "Mask 16-digit numbers",
-		},
-	}
+	config.Query.ReturnAffectedKeys = true
 	p, _ := newTestInstance(t, config)
 
-	// Create table with JSONB, text, and array columns containing PII data
-	setupTable(t, p, `CREATE TABLE sanitize_jsonb_test (
-		id serial PRIMARY KEY,
-		profile jsonb,
-		tags text[],
-		phone text
-	)`)
-	setupTable(t, p, `INSERT INTO sanitize_jsonb_test (profile, tags, phone) VALUES
-		('{"name": "Alice", "phone": "+6282123344789", "card": "1234567890121234"}', ARRAY['+6282123344789', 'no-pii'], '+6282123344789'),
-		('{"contacts": [{"phone": "+6289999999123"}, {"phone": "+6281111111456"}], "nested": {"deep": "+6282222222789"}}', ARRAY['clean'], '+6289999999123'),
-		('{"simple": "no pii here"}', ARRAY['also clean'], 'not-a-phone')
-	`)
+	setupTable(t, p, "CREATE TABLE order_lines (order_id int, line_no int, qty int, PRIMARY KEY (order_id, line_no))")
+	setupTable(t, p, "INSERT INTO order_lines VALUES (1, 1, 5), (1, 2, 3), (2, 1, 1)")
 
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT id, profile, tags, phone FROM sanitize_jsonb_test ORDER BY id"})
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "UPDATE order_lines ol SET qty = qty + 1 WHERE ol.order_id = 1;"})
 	if output.Error != "" {
 		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if len(output.Rows) != 3 {
-		t.Fatalf("expected 3 rows, got %d", len(output.Rows))
+	if output.RowsAffected != 2 || len(output.Rows) != 0 || len(output.Columns) != 0 {
+		t.Fatalf("expected a clean write result, got %+v", output)
 	}
-
-	// Row 1: JSONB object with phone and card number
-	profile1, ok := output.Rows[0]["profile"].(map[string]interface{})
-	if !ok {
-		t.Fatalf("expected map for profile, got %T: %v", output.Rows[0]["profile"], output.Rows[0]["profile"])
+	sortRows := func(rows []map[string]interface{}) string {
+		parts := make([]string, len(rows))
+		for i, row := range rows {
+			parts[i] = fmt.Sprintf("%v/%v", row["order_id"], row["line_no"])
+		}
+		sort.Strings(parts)
+		return strings.Join(parts, ",")
 	}
-	if profile1["phone"] != "+62xxx789" {
-		t.Fatalf("expected JSONB phone masked to '+62xxx789', got %v", profile1["phone"])
+	if got := sortRows(output.AffectedKeys); got != "1/1,1/2" {
+		t.Fatalf("expected keys 1/1,1/2, got %v", output.AffectedKeys)
 	}
-	if profile1["card"] != "1234********1234" {
-		t.Fatalf("expected JSONB card masked to '1234********1234', got %v", profile1["card"])
+
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "DELETE FROM order_lines WHERE order_id = 2 -- cleanup"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if profile1["name"] != "Alice" {
-		t.Fatalf("expected name 'Alice' unchanged, got %v", profile1["name"])
+	if got := sortRows(output.AffectedKeys); got != "2/1" {
+		t.Fatalf("expected keys 2/1, got %v", output.AffectedKeys)
 	}
 
-	// Row 1: text column masked
-	if output.Rows[0]["phone"] != "+62xxx789" {
-		t.Fatalf("expected phone column masked to '+62xxx789', got %v", output.Rows[0]["phone"])
+	// An explicit RETURNING is left alone.
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "UPDATE order_lines SET qty = 0 WHERE order_id = 1 AND line_no = 1 RETURNING qty"})
+	if output.Error != "" || len(output.Rows) != 1 || output.AffectedKeys != nil {
+		t.Fatalf("expected RETURNING rows and no affected keys, got %+v", output)
 	}
 
-	// Row 1: array column values masked
-	tags1, ok := output.Rows[0]["tags"].([]interface{})
-	if !ok {
-		t.Fatalf("expected []interface{} for tags, got %T", output.Rows[0]["tags"])
-	}
-	if len(tags1) != 2 {
-		t.Fatalf("expected 2 tags, got %d", len(tags1))
-	}
-	if tags1[0] != "+62xxx789" {
-		t.Fatalf("expected first tag masked to '+62xxx789', got %v", tags1[0])
-	}
-	if tags1[1] != "no-pii" {
-		t.Fatalf("expected second tag unchanged 'no-pii', got %v", tags1[1])
+	// Tables without a primary key report nothing.
+	setupTable(t, p, "CREATE TABLE no_pk (v int)")
+	setupTable(t, p, "INSERT INTO no_pk VALUES (1)")
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "DELETE FROM no_pk WHERE v = 1"})
+	if output.Error != "" || output.RowsAffected != 1 || output.AffectedKeys != nil {
+		t.Fatalf("expected no affected keys without a primary key, got %+v", output)
 	}
+}
 
-	// Row 2: nested JSONB with array of objects
-	profile2, ok := output.Rows[1]["profile"].(map[string]interface{})
-	if !ok {
-		t.Fatalf("expected map for profile, got %T: %v", output.Rows[1]["profile"], output.Rows[1]["profile"])
-	}
-	contacts, ok := profile2["contacts"].([]interface{})
-	if !ok {
-		t.Fatalf("expected contacts array, got %T", profile2["contacts"])
+func TestQuery_AutoReturnGeneratedKeys(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Query.AutoReturnGeneratedKeys = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE gen_serial (id serial PRIMARY KEY, name text)")
+	setupTable(t, p, "CREATE TABLE gen_bigserial (id bigserial PRIMARY KEY, name text)")
+	setupTable(t, p, "CREATE TABLE gen_identity (id int GENERATED ALWAYS AS IDENTITY PRIMARY KEY, name text)")
+
+	cases := []struct {
+		table string
+		want  []map[string]interface{}
+	}{
+		{"gen_serial", []map[string]interface{}{{"id": int32(1)}, {"id": int32(2)}}},
+		{"gen_bigserial", []map[string]interface{}{{"id": int64(1)}, {"id": int64(2)}}},
+		{"gen_identity", []map[string]interface{}{{"id": int32(1)}, {"id": int32(2)}}},
+	}
+	for _, tc := range cases {
+		output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "INSERT INTO " + tc.table + " (name) VALUES ('a'), ('b');"})
+		if output.Error != "" {
+			t.Fatalf("%s: unexpected error: %s", tc.table, output.Error)
+		}
+		if output.RowsAffected != 2 || len(output.Rows) != 0 || len(output.Columns) != 0 {
+			t.Fatalf("%s: expected a clean write result, got %+v", tc.table, output)
+		}
+		if !reflect.DeepEqual(output.GeneratedKeys, tc.want) {
+			t.Fatalf("%s: expected generated keys %v, got %v", tc.table, tc.want, output.GeneratedKeys)
+		}
 	}
-	if len(contacts) != 2 {
-		t.Fatalf("expected 2 contacts, got %d", len(contacts))
+
+	// An explicit RETURNING is left alone.
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "INSERT INTO gen_serial (name) VALUES ('c') RETURNING name"})
+	if output.Error != "" || len(output.Rows) != 1 || output.GeneratedKeys != nil {
+		t.Fatalf("expected RETURNING rows and no generated keys, got %+v", output)
 	}
-	contact0, ok := contacts[0].(map[string]interface{})
-	if !ok {
-		t.Fatalf("expected map for contact, got %T", contacts[0])
+
+	// Keys without a default or identity are not generated, so nothing is reported.
+	setupTable(t, p, "CREATE TABLE natural_key (code text PRIMARY KEY)")
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "INSERT INTO natural_key VALUES ('x')"})
+	if output.Error != "" || output.RowsAffected != 1 || output.GeneratedKeys != nil {
+		t.Fatalf("expected no generated keys for a natural key, got %+v", output)
 	}
-	if contact0["phone"] != "+62xxx123" {
-		t.Fatalf("expected nested contact phone masked to '+62xxx123', got %v", contact0["phone"])
+}
+
+func TestQuery_MultipleResultSetsFunction(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Protection.AllowCreateFunction = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, `CREATE FUNCTION two_sets() RETURNS SETOF refcursor LANGUAGE plpgsql AS $$
+		DECLARE
+			a refcursor := 'first_set';
+			b refcursor := 'second_set';
+		BEGIN
+			OPEN a FOR SELECT 1 AS x;
+			RETURN NEXT a;
+			OPEN b FOR SELECT 'two' AS y;
+			RETURN NEXT b;
+		END $$`)
+
+	// The function's "result sets" are cursors: the one result is their names, not
+	// the first cursor's rows.
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT * FROM two_sets()"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	contact1, ok := contacts[1].(map[string]interface{})
-	if !ok {
-		t.Fatalf("expected map for contact, got %T", contacts[1])
+	if !reflect.DeepEqual(output.Columns, []string{"two_sets"}) {
+		t.Fatalf("expected a single two_sets column, got %v", output.Columns)
 	}
-	if contact1["phone"] != "+62xxx456" {
-		t.Fatalf("expected nested contact phone masked to '+62xxx456', got %v", contact1["phone"])
+	expected := []map[string]interface{}{{"two_sets": "first_set"}, {"two_sets": "second_set"}}
+	if !reflect.DeepEqual(output.Rows, expected) {
+		t.Fatalf("expected cursor names %v, got %v", expected, output.Rows)
 	}
 
-	// Row 2: deeply nested value
-	nested, ok := profile2["nested"].(map[string]interface{})
-	if !ok {
-		t.Fatalf("expected map for nested, got %T", profile2["nested"])
-	}
-	if nested["deep"] != "+62xxx789" {
-		t.Fatalf("expected deeply nested phone masked to '+62xxx789', got %v", nested["deep"])
+	// Two statements would be two result sets; they are rejected rather than truncated.
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1; SELECT 2"})
+	if output.Error == "" || len(output.Rows) != 0 {
+		t.Fatalf("expected multi-statement query to be rejected, got %+v", output)
+	}
+}
+
+func TestQuery_ZeroColumnResult(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Sanitization = []pgmcp.SanitizationRule{{Pattern: "x", Replacement: "y"}}
+	config.MaskColumns = []string{"secret"}
+	config.Query.NullStringInRows = true
+	config.Query.IncludeColumnTypes = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE no_cols (id int)")
+
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		// One row, zero columns.
+		{"SELECT", `"columns":[],"rows":[{}]`},
+		// Zero rows, zero columns.
+		{"SELECT FROM no_cols", `"columns":[],"rows":[]`},
+	}
+	for _, tt := range tests {
+		output := p.Query(context.Background(), pgmcp.QueryInput{SQL: tt.sql})
+		if output.Error != "" {
+			t.Fatalf("%s: unexpected error: %s", tt.sql, output.Error)
+		}
+		b, err := json.Marshal(output)
+		if err != nil {
+			t.Fatalf("%s: failed to marshal: %v", tt.sql, err)
+		}
+		if !strings.Contains(string(b), tt.expected) {
+			t.Fatalf("%s: expected JSON containing %s, got %s", tt.sql, tt.expected, b)
+		}
+	}
+}
+
+func TestQuery_UTF8Truncation(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Query.MaxResultLength = 50 // very small
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE emoji_table (data text)")
+	setupTable(t, p, "INSERT INTO emoji_table VALUES ('Hello world! Here are some special chars: cafe\u0301 naïve résumé')")
+	setupTable(t, p, "INSERT INTO emoji_table VALUES ('More text to ensure we exceed the limit easily here')")
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT * FROM emoji_table"})
+	if output.Error == "" {
+		t.Fatal("expected truncation")
+	}
+	// Verify the truncated output is valid UTF-8
+	idx := strings.Index(output.Error, "...[truncated]")
+	if idx == -1 {
+		t.Fatalf("expected truncation marker, got %q", output.Error)
+	}
+	truncatedPart := output.Error[:idx]
+	if !utf8.ValidString(truncatedPart) {
+		t.Fatalf("truncated output is not valid UTF-8: %q", truncatedPart)
+	}
+}
+
+func TestQuery_TimeoutRuleMatch(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Query.DefaultTimeoutSeconds = 30
+	config.Query.TimeoutRules = []pgmcp.TimeoutRule{
+		{Pattern: "pg_sleep", TimeoutSeconds: 1},
+	}
+	p, _ := newTestInstance(t, config)
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT pg_sleep(10)"})
+	if output.Error == "" {
+		t.Fatal("expected timeout from rule")
+	}
+	// Should have timed out after ~1s, not 30s
+}
+
+func TestQuery_TimeoutFallbackToDefault(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Query.DefaultTimeoutSeconds = 1 // short default
+	config.Query.TimeoutRules = []pgmcp.TimeoutRule{
+		{Pattern: "NEVER_MATCH_THIS_PATTERN", TimeoutSeconds: 30},
+	}
+	p, _ := newTestInstance(t, config)
+
+	// This query won't match the rule, so it should use default (1s) and timeout
+	start := time.Now()
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT pg_sleep(10)"})
+	elapsed := time.Since(start)
+
+	if output.Error == "" {
+		t.Fatal("expected timeout from default timeout")
+	}
+	// Should have timed out after ~1s (default), not 30s (rule)
+	if elapsed > 5*time.Second {
+		t.Fatalf("expected timeout near 1s (default), but took %v", elapsed)
+	}
+}
+
+func TestQuery_TimeoutByEstimatedRows(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Query.DefaultTimeoutSeconds = 1
+	config.Query.TimeoutByEstimatedRows = []pgmcp.EstimatedRowsTimeout{
+		{MinRows: 50000, TimeoutSeconds: 30},
+	}
+	p, _ := newTestInstance(t, config)
+	ctx := context.Background()
+
+	// Estimated at one row: stays on the 1s default and times out.
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT pg_sleep(2)::text AS slept"})
+	if output.ErrorKind != pgmcp.ErrorKindTimeout {
+		t.Fatalf("expected the small query to time out on the default, got rows=%v err=%q", output.Rows, output.Error)
+	}
+
+	// The scan is estimated at 100,000 rows, selecting the 30s tier: the same sleep finishes.
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(CASE WHEN g = 1 THEN pg_sleep(2)::text END) AS n FROM generate_series(1, 100000) g"})
+	if output.Error != "" {
+		t.Fatalf("expected the large query to run on the 30s tier, got %q", output.Error)
+	}
+	if fmt.Sprint(output.Rows[0]["n"]) != "1" {
+		t.Fatalf("expected n = 1, got %v", output.Rows[0]["n"])
+	}
+}
+
+func TestQuery_SingleFlightReads(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Query.SingleFlightReads = true
+	p, _ := newTestInstance(t, config)
+	// STABLE, so reads calling it can be coalesced; it keeps the first execution in flight
+	// while the others arrive.
+	setupTable(t, p, "CREATE FUNCTION slow_stable(s float8) RETURNS text STABLE LANGUAGE plpgsql AS $$ BEGIN PERFORM pg_sleep(s); RETURN 'slept'; END $$")
+	ctx := context.Background()
+
+	// Each execution has its own backend and statement timestamp, so callers sharing one
+	// execution see the same values.
+	const callers = 5
+	sql := "SELECT pg_backend_pid() || ' ' || statement_timestamp() AS run, slow_stable(1) AS slept"
+	outputs := make(chan *pgmcp.QueryOutput, callers)
+	for i := 0; i < callers; i++ {
+		go func() { outputs <- p.Query(ctx, pgmcp.QueryInput{SQL: sql}) }()
+	}
+	var first *pgmcp.QueryOutput
+	for i := 0; i < callers; i++ {
+		output := <-outputs
+		if output.Error != "" {
+			t.Fatalf("unexpected error: %s", output.Error)
+		}
+		if first == nil {
+			first = output
+			continue
+		}
+		if fmt.Sprint(output.Rows[0]["run"]) != fmt.Sprint(first.Rows[0]["run"]) {
+			t.Fatalf("expected every caller to share one execution, got runs %v and %v", first.Rows[0]["run"], output.Rows[0]["run"])
+		}
+		// Each caller owns its copy.
+		output.Rows[0]["run"] = "mutated"
+	}
+	if fmt.Sprint(first.Rows[0]["run"]) == "mutated" {
+		t.Fatal("expected callers not to share result rows")
+	}
+
+	// Reads calling volatile functions are never coalesced: each caller gets its own value.
+	setupTable(t, p, "CREATE SEQUENCE single_flight_runs")
+	sql = "SELECT nextval('single_flight_runs') AS run, pg_sleep(0.5)::text AS slept"
+	for i := 0; i < callers; i++ {
+		go func() { outputs <- p.Query(ctx, pgmcp.QueryInput{SQL: sql}) }()
+	}
+	runs := map[string]bool{}
+	for i := 0; i < callers; i++ {
+		output := <-outputs
+		if output.Error != "" {
+			t.Fatalf("unexpected error: %s", output.Error)
+		}
+		runs[fmt.Sprint(output.Rows[0]["run"])] = true
+	}
+	if len(runs) != callers {
+		t.Fatalf("expected %d distinct nextval results, got %v", callers, runs)
+	}
+
+	// Writes are never coalesced.
+	setupTable(t, p, "CREATE TABLE single_flight_writes (id int)")
+	for i := 0; i < callers; i++ {
+		go func() {
+			outputs <- p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO single_flight_writes SELECT 1 FROM pg_sleep(0.5)"})
+		}()
+	}
+	for i := 0; i < callers; i++ {
+		if output := <-outputs; output.Error != "" {
+			t.Fatalf("unexpected error: %s", output.Error)
+		}
+	}
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS n FROM single_flight_writes"})
+	if output.Error != "" || fmt.Sprint(output.Rows[0]["n"]) != fmt.Sprint(callers) {
+		t.Fatalf("expected %d inserted rows, got %v err=%q", callers, output.Rows, output.Error)
+	}
+}
+
+func TestQuery_WarnOnAlwaysFalsePredicate(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Query.WarnOnAlwaysFalsePredicate = true
+	p, _ := newTestInstance(t, config)
+	setupTable(t, p, "CREATE TABLE predicate_items (id int)")
+	setupTable(t, p, "INSERT INTO predicate_items VALUES (1), (2)")
+	ctx := context.Background()
+
+	for _, sql := range []string{"SELECT * FROM predicate_items WHERE 1=0", "SELECT * FROM predicate_items WHERE false"} {
+		output := p.Query(ctx, pgmcp.QueryInput{SQL: sql})
+		if output.Error != "" {
+			t.Fatalf("%s: unexpected error: %s", sql, output.Error)
+		}
+		if len(output.Rows) != 0 {
+			t.Fatalf("%s: expected no rows, got %v", sql, output.Rows)
+		}
+		if output.PredicateNote != "query has an always-false predicate and will return no rows" {
+			t.Fatalf("%s: expected the always-false note, got %q", sql, output.PredicateNote)
+		}
+	}
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT * FROM predicate_items WHERE id = 1"})
+	if output.Error != "" || len(output.Rows) != 1 {
+		t.Fatalf("expected 1 row, got %v err=%q", output.Rows, output.Error)
+	}
+	if output.PredicateNote != "" {
+		t.Fatalf("expected no note for a normal predicate, got %q", output.PredicateNote)
+	}
+}
+
+func TestQuery_InetColumn(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE servers (ip inet)")
+	setupTable(t, p, "INSERT INTO servers VALUES ('192.168.1.1/24')")
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT ip FROM servers"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	ipStr, ok := output.Rows[0]["ip"].(string)
+	if !ok {
+		t.Fatalf("expected string for inet, got %T", output.Rows[0]["ip"])
+	}
+	if !strings.Contains(ipStr, "192.168.1.1") {
+		t.Fatalf("expected 192.168.1.1 in inet, got %s", ipStr)
+	}
+}
+
+// --- Section 4: Missing Query Integration Tests ---
+
+func TestQuery_JSONBReturnType(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE items (data jsonb)")
+	setupTable(t, p, `INSERT INTO items VALUES ('{"name":"test","nested":{"arr":[1,2,3]},"flag":true,"nothing":null,"count":42}')`)
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT data FROM items"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if len(output.Rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(output.Rows))
+	}
+
+	// Verify JSONB is returned as parsed Go map, not string
+	dataMap, ok := output.Rows[0]["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected map[string]interface{} for JSONB, got %T: %v", output.Rows[0]["data"], output.Rows[0]["data"])
+	}
+
+	// Verify nested object
+	nested, ok := dataMap["nested"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected nested map, got %T", dataMap["nested"])
+	}
+
+	// Verify nested array
+	arr, ok := nested["arr"].([]interface{})
+	if !ok {
+		t.Fatalf("expected nested array, got %T", nested["arr"])
+	}
+	if len(arr) != 3 {
+		t.Fatalf("expected 3 elements in array, got %d", len(arr))
+	}
+
+	// Verify boolean
+	flag, ok := dataMap["flag"].(bool)
+	if !ok {
+		t.Fatalf("expected bool for flag, got %T", dataMap["flag"])
+	}
+	if flag != true {
+		t.Fatalf("expected true, got %v", flag)
+	}
+
+	// Verify null
+	if dataMap["nothing"] != nil {
+		t.Fatalf("expected nil for nothing, got %v (%T)", dataMap["nothing"], dataMap["nothing"])
+	}
+
+	// Verify string
+	if dataMap["name"] != "test" {
+		t.Fatalf("expected name=test, got %v", dataMap["name"])
+	}
+}
+
+func TestQuery_JSONBNumericPrecision(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE items (data jsonb)")
+	setupTable(t, p, `INSERT INTO items VALUES ('{"id": 9007199254740993}')`) // 2^53+1
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT data FROM items"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+
+	// Known limitation: large integer inside JSONB loses precision to float64
+	// because pgx parses JSONB internally and numbers become float64.
+	dataMap, ok := output.Rows[0]["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected map for JSONB, got %T", output.Rows[0]["data"])
+	}
+
+	// The value should be a float64 (precision lost — pgx limitation)
+	val, ok := dataMap["id"].(float64)
+	if !ok {
+		t.Fatalf("expected float64 for JSONB large int (pgx limitation), got %T: %v", dataMap["id"], dataMap["id"])
+	}
+	// 9007199254740993 → 9007199254740992 (precision lost)
+	if val != 9.007199254740992e+15 {
+		t.Fatalf("expected 9.007199254740992e+15 (precision loss), got %v", val)
+	}
+}
+
+func TestQuery_SelectNestedSubquery(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE departments (id serial PRIMARY KEY, name text)")
+	setupTable(t, p, "CREATE TABLE employees (id serial PRIMARY KEY, name text, dept_id int)")
+	setupTable(t, p, "INSERT INTO departments (name) VALUES ('Engineering'), ('Sales')")
+	setupTable(t, p, "INSERT INTO employees (name, dept_id) VALUES ('Alice', 1), ('Bob', 1), ('Charlie', 2)")
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: `
+		SELECT name FROM employees
+		WHERE dept_id IN (SELECT id FROM departments WHERE name = 'Engineering')
+		ORDER BY name
+	`})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if len(output.Rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(output.Rows))
+	}
+	if output.Rows[0]["name"] != "Alice" {
+		t.Fatalf("expected Alice, got %v", output.Rows[0]["name"])
+	}
+	if output.Rows[1]["name"] != "Bob" {
+		t.Fatalf("expected Bob, got %v", output.Rows[1]["name"])
+	}
+}
+
+func TestQuery_Transaction(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, connStr := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE tx_test (id serial PRIMARY KEY, val int)")
+	setupTable(t, p, "INSERT INTO tx_test (val) VALUES (1), (2), (3)")
+
+	// INSERT with RETURNING should commit (data persisted)
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "INSERT INTO tx_test (val) VALUES (42) RETURNING val"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if output.Rows[0]["val"] != int32(42) {
+		t.Fatalf("expected 42, got %v", output.Rows[0]["val"])
+	}
+
+	// Verify data persisted by creating a fresh instance and querying
+	ctx := context.Background()
+	verifyP, err := pgmcp.New(ctx, connStr, defaultConfig(), testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create verify instance: %v", err)
+	}
+	defer verifyP.Close(ctx)
+
+	verifyOutput := verifyP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS cnt FROM tx_test WHERE val = 42"})
+	if verifyOutput.Error != "" {
+		t.Fatalf("verify query failed: %s", verifyOutput.Error)
+	}
+	if verifyOutput.Rows[0]["cnt"] != int64(1) {
+		t.Fatalf("expected 1 row persisted, got %v", verifyOutput.Rows[0]["cnt"])
+	}
+}
+
+func TestQuery_HooksEndToEnd(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.DefaultHookTimeoutSeconds = 5
+
+	p := newTestInstanceWithHooks(t, config, pgmcp.ServerHooksConfig{
+		BeforeQuery: []pgmcp.HookEntry{
+			{Pattern: ".*", Command: hookScript("modify_query.sh")},
+		},
+	})
+
+	// modify_query.sh changes any query to "SELECT 1 AS modified"
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 999"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if len(output.Rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(output.Rows))
+	}
+	if len(output.Columns) != 1 || output.Columns[0] != "modified" {
+		t.Fatalf("expected column 'modified', got %v", output.Columns)
+	}
+	val, ok := output.Rows[0]["modified"].(int32)
+	if !ok {
+		t.Fatalf("expected int32, got %T: %v", output.Rows[0]["modified"], output.Rows[0]["modified"])
+	}
+	if val != 1 {
+		t.Fatalf("expected 1, got %d", val)
+	}
+}
+
+func TestQuery_HookTimeoutStopsPipeline(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 1
+
+	p := newTestInstanceWithHooks(t, config, pgmcp.ServerHooksConfig{
+		BeforeQuery: []pgmcp.HookEntry{
+			{Pattern: ".*", Command: hookScript("slow.sh")},
+		},
+	})
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1"})
+	if output.Error == "" {
+		t.Fatal("expected hook timeout error")
+	}
+	if !strings.Contains(output.Error, "hook timed out") {
+		t.Fatalf("expected 'hook timed out' in error, got %q", output.Error)
+	}
+}
+
+func TestQuery_TimezoneEmpty(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Timezone = "" // empty = server default
+	p, _ := newTestInstance(t, config)
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT current_setting('timezone') AS tz"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	tz, ok := output.Rows[0]["tz"].(string)
+	if !ok {
+		t.Fatalf("expected string for timezone, got %T", output.Rows[0]["tz"])
+	}
+	// Server default timezone should be non-empty (not overridden)
+	if tz == "" {
+		t.Fatal("expected non-empty timezone from server default")
+	}
+}
+
+func TestQuery_TimezoneWithReadOnly(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Timezone = "Asia/Jakarta"
+	config.ReadOnly = true
+	p, _ := newTestInstance(t, config)
+
+	// Verify timezone is applied
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT current_setting('timezone') AS tz"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	tz := output.Rows[0]["tz"].(string)
+	if tz != "Asia/Jakarta" {
+		t.Fatalf("expected Asia/Jakarta, got %s", tz)
+	}
+
+	// Verify read_only is also applied (CREATE should fail)
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "CREATE TABLE should_fail (id int)"})
+	if output.Error == "" {
+		t.Fatal("expected error for CREATE in read-only mode")
+	}
+}
+
+func TestQuery_NumericPrecisionWithHooks(t *testing.T) {
+	t.Parallel()
+	// Setup: create table with bigint 2^53+1
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setupP, connStr := newTestInstance(t, setupConfig)
+	setupTable(t, setupP, "CREATE TABLE bigint_cmd_hook (big_id bigint)")
+	setupTable(t, setupP, "INSERT INTO bigint_cmd_hook VALUES (9007199254740993)") // 2^53+1
+	setupP.Close(context.Background())
+
+	// Create instance with accept.sh AfterQuery hook (triggers JSON round-trip)
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 5
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, connStr, config, testLogger(), pgmcp.WithServerHooks(pgmcp.ServerHooksConfig{
+		AfterQuery: []pgmcp.HookEntry{
+			{Pattern: ".*", Command: hookScript("accept.sh")},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
+	}
+	defer p.Close(ctx)
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT big_id FROM bigint_cmd_hook"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+
+	// After JSON round-trip with UseNumber(), the value survives as json.Number
+	val := output.Rows[0]["big_id"]
+	numVal, ok := val.(json.Number)
+	if !ok {
+		t.Fatalf("expected json.Number after cmd hook round-trip, got %T: %v", val, val)
+	}
+	// Verify exact integer preserved via UseNumber()
+	int64Val, err := numVal.Int64()
+	if err != nil {
+		t.Fatalf("failed to convert json.Number to int64: %v", err)
+	}
+	if int64Val != 9007199254740993 {
+		t.Fatalf("expected 9007199254740993, got %d", int64Val)
+	}
+}
+
+func TestQuery_NumericPrecisionWithoutHooks(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE bigint_nohook (big_id bigint)")
+	setupTable(t, p, "INSERT INTO bigint_nohook VALUES (9007199254740993)") // 2^53+1
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT big_id FROM bigint_nohook"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+
+	// Without hooks, no JSON round-trip, value stays as int64
+	val := output.Rows[0]["big_id"]
+	int64Val, ok := val.(int64)
+	if !ok {
+		t.Fatalf("expected int64 without hooks, got %T: %v", val, val)
+	}
+	if int64Val != 9007199254740993 {
+		t.Fatalf("expected 9007199254740993, got %d", int64Val)
+	}
+}
+
+func TestQuery_RowsAffected_Select(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE users_ra (id serial PRIMARY KEY, name text)")
+	setupTable(t, p, "INSERT INTO users_ra (name) VALUES ('a'), ('b'), ('c'), ('d'), ('e')")
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT * FROM users_ra"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if len(output.Rows) != 5 {
+		t.Fatalf("expected 5 rows, got %d", len(output.Rows))
+	}
+	if output.RowsAffected != 5 {
+		t.Fatalf("expected RowsAffected=5, got %d", output.RowsAffected)
+	}
+}
+
+func TestQuery_RowsAffected_InsertReturning(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE users_ra2 (id serial PRIMARY KEY, name text)")
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "INSERT INTO users_ra2 (name) VALUES ('a') RETURNING *"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if output.RowsAffected != 1 {
+		t.Fatalf("expected RowsAffected=1, got %d", output.RowsAffected)
+	}
+	if len(output.Rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(output.Rows))
+	}
+	if output.Rows[0]["name"] != "a" {
+		t.Fatalf("expected name='a', got %v", output.Rows[0]["name"])
+	}
+}
+
+func TestQuery_CidrColumn(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE subnets (network cidr)")
+	setupTable(t, p, "INSERT INTO subnets VALUES ('10.0.0.0/8')")
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT network FROM subnets"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	netStr, ok := output.Rows[0]["network"].(string)
+	if !ok {
+		t.Fatalf("expected string for cidr, got %T", output.Rows[0]["network"])
+	}
+	if netStr != "10.0.0.0/8" {
+		t.Fatalf("expected 10.0.0.0/8, got %s", netStr)
+	}
+}
+
+func TestQuery_AfterHookRejectSelectNoSideEffect(t *testing.T) {
+	t.Parallel()
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setupP, connStr := newTestInstance(t, setupConfig)
+	setupTable(t, setupP, "CREATE TABLE users_select_reject (id serial PRIMARY KEY, name text)")
+	setupTable(t, setupP, "INSERT INTO users_select_reject (name) VALUES ('existing')")
+	setupP.Close(context.Background())
+
+	// Create instance with rejecting AfterQuery hook
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 5
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, connStr, config, testLogger(), pgmcp.WithServerHooks(pgmcp.ServerHooksConfig{
+		AfterQuery: []pgmcp.HookEntry{
+			{Pattern: ".*", Command: hookScript("reject.sh")},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
+	}
+	defer p.Close(ctx)
+
+	// SELECT is read-only — hook rejects but no side effect
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT * FROM users_select_reject"})
+	if output.Error == "" {
+		t.Fatal("expected hook rejection error for SELECT")
+	}
+	if !strings.Contains(output.Error, "rejected by test hook") {
+		t.Fatalf("expected rejection message, got %q", output.Error)
+	}
+
+	// Verify data unchanged — use non-hooked instance
+	verifyConfig := defaultConfig()
+	verifyP, err := pgmcp.New(ctx, connStr, verifyConfig, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create verify instance: %v", err)
+	}
+	defer verifyP.Close(ctx)
+
+	verifyOutput := verifyP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS cnt FROM users_select_reject"})
+	if verifyOutput.Error != "" {
+		t.Fatalf("verification query failed: %s", verifyOutput.Error)
+	}
+	if verifyOutput.Rows[0]["cnt"] != int64(1) {
+		t.Fatalf("expected 1 row unchanged, got %v", verifyOutput.Rows[0]["cnt"])
+	}
+}
+
+func TestQuery_ReadOnlyStatementRollbacksBeforeHooks(t *testing.T) {
+	t.Parallel()
+	// This test verifies that SELECT causes rollback before AfterQuery hooks run.
+	// The hook still runs, but the transaction is already rolled back.
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.DefaultHookTimeoutSeconds = 5
+
+	// Use a capture hook to verify it receives data (hook is called even though tx is rolled back)
+	captureHook := &captureAfterHook{}
+	config.AfterQueryHooks = []pgmcp.AfterQueryHookEntry{
+		{Name: "capture", Hook: captureHook},
+	}
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE readonly_hook_test (id serial PRIMARY KEY, name text)")
+	setupTable(t, p, "INSERT INTO readonly_hook_test (name) VALUES ('Alice')")
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT * FROM readonly_hook_test"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+
+	// Hook was called (it captured the result)
+	if captureHook.captured == nil {
+		t.Fatal("expected AfterQuery hook to be called for SELECT")
+	}
+	if len(captureHook.captured.Rows) != 1 {
+		t.Fatalf("expected hook to receive 1 row, got %d", len(captureHook.captured.Rows))
+	}
+	if captureHook.captured.Rows[0]["name"] != "Alice" {
+		t.Fatalf("expected hook to receive 'Alice', got %v", captureHook.captured.Rows[0]["name"])
+	}
+}
+
+func TestQuery_MaxSQLLength_ExactLimit(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Query.MaxSQLLength = 20
+	p, _ := newTestInstance(t, config)
+
+	// "SELECT 1" is 8 bytes, well under 20
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1"})
+	if output.Error != "" {
+		t.Fatalf("expected query under limit to succeed, got error: %s", output.Error)
+	}
+}
+
+func TestQuery_CreateExtensionBlocked(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	// AllowCreateExtension defaults to false
+	p, _ := newTestInstance(t, config)
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "CREATE EXTENSION IF NOT EXISTS pg_trgm"})
+	if output.Error == "" {
+		t.Fatal("expected CREATE EXTENSION to be blocked")
+	}
+	if !strings.Contains(output.Error, "CREATE EXTENSION is not allowed") {
+		t.Fatalf("expected CREATE EXTENSION error, got %q", output.Error)
+	}
+}
+
+func TestQuery_MaintenanceBlocked(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE maint_test (id serial PRIMARY KEY)")
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "ANALYZE maint_test"})
+	if output.Error == "" {
+		t.Fatal("expected ANALYZE to be blocked")
+	}
+	if !strings.Contains(output.Error, "VACUUM/ANALYZE is not allowed") {
+		t.Fatalf("expected maintenance error, got %q", output.Error)
+	}
+}
+
+func TestQuery_CreateTriggerBlocked(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Protection.AllowCreateFunction = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE trigger_test (id serial PRIMARY KEY)")
+	setupTable(t, p, "CREATE FUNCTION audit_func() RETURNS TRIGGER AS $$ BEGIN RETURN NEW; END; $$ LANGUAGE plpgsql")
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "CREATE TRIGGER trg AFTER INSERT ON trigger_test FOR EACH ROW EXECUTE FUNCTION audit_func()"})
+	if output.Error == "" {
+		t.Fatal("expected CREATE TRIGGER to be blocked")
+	}
+	if !strings.Contains(output.Error, "CREATE TRIGGER is not allowed") {
+		t.Fatalf("expected trigger error, got %q", output.Error)
+	}
+}
+
+func TestQuery_CreateRuleBlocked(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE rule_test (id serial PRIMARY KEY)")
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "CREATE RULE r AS ON INSERT TO rule_test DO ALSO NOTIFY rule_test_changed"})
+	if output.Error == "" {
+		t.Fatal("expected CREATE RULE to be blocked")
+	}
+	if !strings.Contains(output.Error, "CREATE RULE is not allowed") {
+		t.Fatalf("expected rule error, got %q", output.Error)
+	}
+}
+
+func TestQuery_CommitBlocked(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	p, _ := newTestInstance(t, config)
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "COMMIT"})
+	if output.Error == "" {
+		t.Fatal("expected COMMIT to be blocked")
+	}
+	if !strings.Contains(output.Error, "transaction control statements are not allowed") {
+		t.Fatalf("expected transaction control error, got %q", output.Error)
+	}
+}
+
+func TestQuery_AlterExtensionBlocked(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	p, _ := newTestInstance(t, config)
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "ALTER EXTENSION pg_trgm UPDATE"})
+	if output.Error == "" {
+		t.Fatal("expected ALTER EXTENSION to be blocked")
+	}
+	if !strings.Contains(output.Error, "ALTER EXTENSION is not allowed") {
+		t.Fatalf("expected ALTER EXTENSION error, got %q", output.Error)
+	}
+}
+
+// --- Gap 5: Command-mode AfterQuery timeout triggers rollback for writes ---
+
+func TestQuery_CmdAfterHookTimeoutRollbacksInsert(t *testing.T) {
+	t.Parallel()
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setupP, connStr := newTestInstance(t, setupConfig)
+	setupTable(t, setupP, "CREATE TABLE cmd_timeout_insert (id serial PRIMARY KEY, name text)")
+	setupP.Close(context.Background())
+
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 1
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, connStr, config, testLogger(), pgmcp.WithServerHooks(pgmcp.ServerHooksConfig{
+		AfterQuery: []pgmcp.HookEntry{
+			{Pattern: ".*", Command: hookScript("slow.sh")},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
+	}
+	defer p.Close(ctx)
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO cmd_timeout_insert (name) VALUES ('should_rollback') RETURNING *"})
+	if output.Error == "" {
+		t.Fatal("expected hook timeout error")
+	}
+	if !strings.Contains(output.Error, "hook timed out") {
+		t.Fatalf("expected 'hook timed out' in error, got %q", output.Error)
+	}
+
+	// Verify the row was NOT inserted (rollback happened)
+	verifyConfig := defaultConfig()
+	verifyP, err := pgmcp.New(ctx, connStr, verifyConfig, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create verify instance: %v", err)
+	}
+	defer verifyP.Close(ctx)
+
+	verifyOutput := verifyP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS cnt FROM cmd_timeout_insert"})
+	if verifyOutput.Error != "" {
+		t.Fatalf("verification query failed: %s", verifyOutput.Error)
+	}
+	if verifyOutput.Rows[0]["cnt"] != int64(0) {
+		t.Fatalf("expected 0 rows (rollback), got %v", verifyOutput.Rows[0]["cnt"])
+	}
+}
+
+// --- Gap 6: Command-mode AfterQuery reject rollback for UPDATE and DELETE ---
+
+func TestQuery_CmdAfterHookRejectRollbacksUpdate(t *testing.T) {
+	t.Parallel()
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setupP, connStr := newTestInstance(t, setupConfig)
+	setupTable(t, setupP, "CREATE TABLE cmd_reject_update (id serial PRIMARY KEY, name text)")
+	setupTable(t, setupP, "INSERT INTO cmd_reject_update (name) VALUES ('original')")
+	setupP.Close(context.Background())
+
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 5
+	config.Protection.AllowUpdateWithoutWhere = true
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, connStr, config, testLogger(), pgmcp.WithServerHooks(pgmcp.ServerHooksConfig{
+		AfterQuery: []pgmcp.HookEntry{
+			{Pattern: ".*", Command: hookScript("reject.sh")},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
+	}
+	defer p.Close(ctx)
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "UPDATE cmd_reject_update SET name = 'changed' WHERE id = 1"})
+	if output.Error == "" {
+		t.Fatal("expected hook rejection error")
+	}
+	if !strings.Contains(output.Error, "rejected by test hook") {
+		t.Fatalf("expected rejection message, got %q", output.Error)
+	}
+
+	// Verify row was NOT updated (rollback happened)
+	verifyConfig := defaultConfig()
+	verifyP, err := pgmcp.New(ctx, connStr, verifyConfig, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create verify instance: %v", err)
+	}
+	defer verifyP.Close(ctx)
+
+	verifyOutput := verifyP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT name FROM cmd_reject_update WHERE id = 1"})
+	if verifyOutput.Error != "" {
+		t.Fatalf("verification query failed: %s", verifyOutput.Error)
+	}
+	if verifyOutput.Rows[0]["name"] != "original" {
+		t.Fatalf("expected 'original' (rollback), got %v", verifyOutput.Rows[0]["name"])
+	}
+}
+
+func TestQuery_CmdAfterHookRejectRollbacksDelete(t *testing.T) {
+	t.Parallel()
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setupP, connStr := newTestInstance(t, setupConfig)
+	setupTable(t, setupP, "CREATE TABLE cmd_reject_delete (id serial PRIMARY KEY, name text)")
+	setupTable(t, setupP, "INSERT INTO cmd_reject_delete (name) VALUES ('keep_me')")
+	setupP.Close(context.Background())
+
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 5
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, connStr, config, testLogger(), pgmcp.WithServerHooks(pgmcp.ServerHooksConfig{
+		AfterQuery: []pgmcp.HookEntry{
+			{Pattern: ".*", Command: hookScript("reject.sh")},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
+	}
+	defer p.Close(ctx)
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "DELETE FROM cmd_reject_delete WHERE id = 1"})
+	if output.Error == "" {
+		t.Fatal("expected hook rejection error")
+	}
+	if !strings.Contains(output.Error, "rejected by test hook") {
+		t.Fatalf("expected rejection message, got %q", output.Error)
+	}
+
+	// Verify row was NOT deleted (rollback happened)
+	verifyConfig := defaultConfig()
+	verifyP, err := pgmcp.New(ctx, connStr, verifyConfig, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create verify instance: %v", err)
+	}
+	defer verifyP.Close(ctx)
+
+	verifyOutput := verifyP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS cnt FROM cmd_reject_delete"})
+	if verifyOutput.Error != "" {
+		t.Fatalf("verification query failed: %s", verifyOutput.Error)
+	}
+	if verifyOutput.Rows[0]["cnt"] != int64(1) {
+		t.Fatalf("expected 1 row (rollback), got %v", verifyOutput.Rows[0]["cnt"])
+	}
+}
+
+// --- Gap 7: Command-mode BeforeQuery multi-hook chaining (integration) ---
+
+func TestQuery_CmdBeforeHookChaining(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.DefaultHookTimeoutSeconds = 5
+
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setupP, connStr := newTestInstance(t, setupConfig)
+	setupTable(t, setupP, "CREATE TABLE chain_test (id serial PRIMARY KEY, name text)")
+	setupTable(t, setupP, "INSERT INTO chain_test (name) VALUES ('Alice'), ('Bob'), ('Charlie')")
+	setupP.Close(context.Background())
+
+	// Chain: modify_query.sh rewrites to "SELECT 1 AS modified",
+	// then add_limit.sh appends "LIMIT 1" since it contains SELECT.
+	// Final query: "SELECT 1 AS modified LIMIT 1"
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, connStr, config, testLogger(), pgmcp.WithServerHooks(pgmcp.ServerHooksConfig{
+		BeforeQuery: []pgmcp.HookEntry{
+			{Pattern: ".*", Command: hookScript("modify_query.sh")},
+			{Pattern: "(?i)SELECT", Command: hookScript("add_limit.sh")},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
+	}
+	defer p.Close(ctx)
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT * FROM chain_test"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	// First hook rewrites to "SELECT 1 AS modified"
+	// Second hook appends " LIMIT 1"
+	// Result should be single row with column "modified"
+	if len(output.Rows) != 1 {
+		t.Fatalf("expected 1 row (LIMIT 1 from chained hook), got %d", len(output.Rows))
+	}
+	if len(output.Columns) != 1 || output.Columns[0] != "modified" {
+		t.Fatalf("expected column 'modified' from first hook rewrite, got %v", output.Columns)
+	}
+}
+
+// --- Gap 8: Command-mode AfterQuery multi-hook chaining (integration) ---
+
+func TestQuery_CmdAfterHookChaining(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.DefaultHookTimeoutSeconds = 5
+
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setupP, connStr := newTestInstance(t, setupConfig)
+	setupTable(t, setupP, "CREATE TABLE after_chain_test (id serial PRIMARY KEY, name text)")
+	setupTable(t, setupP, "INSERT INTO after_chain_test (name) VALUES ('Alice')")
+	setupP.Close(context.Background())
+
+	// Chain two AfterQuery hooks: first modifies result, second accepts.
+	// modify_result.sh replaces with {"columns":["modified"],"rows":[{"modified":true}]}
+	// accept.sh just accepts (passes through).
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, connStr, config, testLogger(), pgmcp.WithServerHooks(pgmcp.ServerHooksConfig{
+		AfterQuery: []pgmcp.HookEntry{
+			{Pattern: ".*", Command: hookScript("modify_result.sh")},
+			{Pattern: ".*", Command: hookScript("accept.sh")},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
+	}
+	defer p.Close(ctx)
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT * FROM after_chain_test"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	// First hook modified result to {"columns":["modified"],"rows":[{"modified":true}]}
+	// Second hook accepted the modified result
+	if len(output.Columns) != 1 || output.Columns[0] != "modified" {
+		t.Fatalf("expected column 'modified' from first AfterQuery hook, got %v", output.Columns)
+	}
+	if len(output.Rows) != 1 {
+		t.Fatalf("expected 1 row from modified result, got %d", len(output.Rows))
+	}
+}
+
+func TestQuery_CmdAfterHookAnnotations(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 5
+	_, connStr := newTestInstance(t, defaultConfig())
+
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, connStr, config, testLogger(), pgmcp.WithServerHooks(pgmcp.ServerHooksConfig{
+		AfterQuery: []pgmcp.HookEntry{
+			{Pattern: ".*", Command: hookScript("annotate.sh")},
+			{Pattern: ".*", Command: hookScript("accept.sh")},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
+	}
+	defer p.Close(ctx)
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 1 AS val"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	// Decoded with UseNumber, like rows.
+	if output.Annotations["risk_score"] != json.Number("0.8") || output.Annotations["classification"] != "pii" {
+		t.Fatalf("expected annotations from the hook, got %v", output.Annotations)
+	}
+	if reasons, ok := output.Annotations["reasons"].([]interface{}); !ok || len(reasons) != 1 || reasons[0] != "email column" {
+		t.Fatalf("expected reasons [email column], got %v", output.Annotations["reasons"])
+	}
+}
+
+// --- Gap 9: Read-only mode blocks SET transaction_read_only (integration) ---
+
+func TestQuery_ReadOnlyBlocksSetTransactionReadOnly(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.ReadOnly = true
+	config.Protection.AllowSet = true
+	p, _ := newTestInstance(t, config)
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SET transaction_read_only = false"})
+	if output.Error == "" {
+		t.Fatal("expected error for SET transaction_read_only in read-only mode")
+	}
+	if !strings.Contains(output.Error, "transaction_read_only") {
+		t.Fatalf("expected error about transaction_read_only, got %q", output.Error)
+	}
+}
+
+// --- Gap 10: JSONB sanitization integration test with real DB ---
+
+func TestQuery_SanitizationJSONBIntegration(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Sanitization = []pgmcp.SanitizationRule{
+		{
+			Pattern:     `(\+62)\d{6,}(\d{3})`,
+			Replacement: "${1}xxx${2}",
+			Description: "Mask Indonesian phone numbers",
+		},
+		{
+			Pattern:     `(\d{4})\d{8}(\d{4})`,
+			Replacement: "${1}********${2}",
+			Description: "Mask 16-digit numbers",
+		},
+	}
+	p, _ := newTestInstance(t, config)
+
+	// Create table with JSONB, text, and array columns containing PII data
+	setupTable(t, p, `CREATE TABLE sanitize_jsonb_test (
+		id serial PRIMARY KEY,
+		profile jsonb,
+		tags text[],
+		phone text
+	)`)
+	setupTable(t, p, `INSERT INTO sanitize_jsonb_test (profile, tags, phone) VALUES
+		('{"name": "Alice", "phone": "+6282123344789", "card": "1234567890121234"}', ARRAY['+6282123344789', 'no-pii'], '+6282123344789'),
+		('{"contacts": [{"phone": "+6289999999123"}, {"phone": "+6281111111456"}], "nested": {"deep": "+6282222222789"}}', ARRAY['clean'], '+6289999999123'),
+		('{"simple": "no pii here"}', ARRAY['also clean'], 'not-a-phone')
+	`)
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT id, profile, tags, phone FROM sanitize_jsonb_test ORDER BY id"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if len(output.Rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(output.Rows))
+	}
+
+	// Row 1: JSONB object with phone and card number
+	profile1, ok := output.Rows[0]["profile"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected map for profile, got %T: %v", output.Rows[0]["profile"], output.Rows[0]["profile"])
+	}
+	if profile1["phone"] != "+62xxx789" {
+		t.Fatalf("expected JSONB phone masked to '+62xxx789', got %v", profile1["phone"])
+	}
+	if profile1["card"] != "1234********1234" {
+		t.Fatalf("expected JSONB card masked to '1234********1234', got %v", profile1["card"])
+	}
+	if profile1["name"] != "Alice" {
+		t.Fatalf("expected name 'Alice' unchanged, got %v", profile1["name"])
+	}
+
+	// Row 1: text column masked
+	if output.Rows[0]["phone"] != "+62xxx789" {
+		t.Fatalf("expected phone column masked to '+62xxx789', got %v", output.Rows[0]["phone"])
+	}
+
+	// Row 1: array column values masked
+	tags1, ok := output.Rows[0]["tags"].([]interface{})
+	if !ok {
+		t.Fatalf("expected []interface{} for tags, got %T", output.Rows[0]["tags"])
+	}
+	if len(tags1) != 2 {
+		t.Fatalf("expected 2 tags, got %d", len(tags1))
+	}
+	if tags1[0] != "+62xxx789" {
+		t.Fatalf("expected first tag masked to '+62xxx789', got %v", tags1[0])
+	}
+	if tags1[1] != "no-pii" {
+		t.Fatalf("expected second tag unchanged 'no-pii', got %v", tags1[1])
+	}
+
+	// Row 2: nested JSONB with array of objects
+	profile2, ok := output.Rows[1]["profile"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected map for profile, got %T: %v", output.Rows[1]["profile"], output.Rows[1]["profile"])
+	}
+	contacts, ok := profile2["contacts"].([]interface{})
+	if !ok {
+		t.Fatalf("expected contacts array, got %T", profile2["contacts"])
+	}
+	if len(contacts) != 2 {
+		t.Fatalf("expected 2 contacts, got %d", len(contacts))
+	}
+	contact0, ok := contacts[0].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected map for contact, got %T", contacts[0])
+	}
+	if contact0["phone"] != "+62xxx123" {
+		t.Fatalf("expected nested contact phone masked to '+62xxx123', got %v", contact0["phone"])
+	}
+	contact1, ok := contacts[1].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected map for contact, got %T", contacts[1])
+	}
+	if contact1["phone"] != "+62xxx456" {
+		t.Fatalf("expected nested contact phone masked to '+62xxx456', got %v", contact1["phone"])
+	}
+
+	// Row 2: deeply nested value
+	nested, ok := profile2["nested"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected map for nested, got %T", profile2["nested"])
+	}
+	if nested["deep"] != "+62xxx789" {
+		t.Fatalf("expected deeply nested phone masked to '+62xxx789', got %v", nested["deep"])
+	}
+
+	// Row 3: no PII — values unchanged
+	profile3, ok := output.Rows[2]["profile"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected map for profile, got %T", output.Rows[2]["profile"])
+	}
+	if profile3["simple"] != "no pii here" {
+		t.Fatalf("expected 'no pii here' unchanged, got %v", profile3["simple"])
+	}
+	if output.Rows[2]["phone"] != "not-a-phone" {
+		t.Fatalf("expected 'not-a-phone' unchanged, got %v", output.Rows[2]["phone"])
+	}
+}
+
+// --- Full Pipeline Test ---
+
+// pipelineBeforeHook rewrites any query to SELECT id, name, phone FROM pipeline_test ORDER BY id.
+type pipelineBeforeHook struct{}
+
+func (h *pipelineBeforeHook) Run(_ context.Context, _ string) (string, error) {
+	return "SELECT id, name, phone FROM pipeline_test ORDER BY id", nil
+}
+
+// pipelineAfterHook adds a "hook_stage" column to every row.
+type pipelineAfterHook struct{}
+
+func (h *pipelineAfterHook) Run(_ context.Context, result *pgmcp.QueryOutput) (*pgmcp.QueryOutput, error) {
+	result.Columns = append(result.Columns, "hook_stage")
+	for _, row := range result.Rows {
+		row["hook_stage"] = "after_hook_applied"
+	}
+	return result, nil
+}
+
+func TestFullPipeline(t *testing.T) {
+	t.Parallel()
+
+	// First, create a plain instance (no hooks) to set up the test table.
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setupP, connStr := newTestInstance(t, setupConfig)
+
+	setupTable(t, setupP, "CREATE TABLE pipeline_test (id serial PRIMARY KEY, name text, phone text)")
+	setupTable(t, setupP, "INSERT INTO pipeline_test (name, phone) VALUES ('Alice', '555-123-4567'), ('Bob', '555-987-6543')")
+
+	// Now create the full pipeline instance with hooks, sanitization, and error prompts
+	// on the same database.
+	pipelineConfig := defaultConfig()
+	pipelineConfig.DefaultHookTimeoutSeconds = 10
+	pipelineConfig.BeforeQueryHooks = []pgmcp.BeforeQueryHookEntry{
+		{Name: "rewrite", Hook: &pipelineBeforeHook{}},
+	}
+	pipelineConfig.AfterQueryHooks = []pgmcp.AfterQueryHookEntry{
+		{Name: "add_column", Hook: &pipelineAfterHook{}},
+	}
+	pipelineConfig.Sanitization = []pgmcp.SanitizationRule{
+		{
+			Pattern:     `\d{3}-\d{3}-\d{4}`,
+			Replacement: "***-***-****",
+			Description: "mask phone numbers",
+		},
+	}
+	pipelineConfig.ErrorPrompts = []pgmcp.ErrorPromptRule{
+		{
+			Pattern: "does not exist",
+			Message: "The table may not exist. Try running ListTables first.",
+		},
+	}
+
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, connStr, pipelineConfig, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create pipeline instance: %v", err)
+	}
+	defer p.Close(ctx)
+
+	// --- Test 1: Successful query through full pipeline ---
+	// Send a dummy query — BeforeQuery hook rewrites it to SELECT from pipeline_test.
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 1"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+
+	// Verify AfterQuery hook added the "hook_stage" column
+	if len(output.Columns) != 4 {
+		t.Fatalf("expected 4 columns (id, name, phone, hook_stage), got %d: %v", len(output.Columns), output.Columns)
+	}
+	foundHookStage := false
+	for _, col := range output.Columns {
+		if col == "hook_stage" {
+			foundHookStage = true
+			break
+		}
+	}
+	if !foundHookStage {
+		t.Fatalf("expected 'hook_stage' column from AfterQuery hook, got columns: %v", output.Columns)
+	}
+
+	// Verify we got 2 rows
+	if len(output.Rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(output.Rows))
+	}
+
+	// Verify BeforeQuery hook rewrote the query (we get pipeline_test data, not "SELECT 1")
+	if output.Rows[0]["name"] != "Alice" {
+		t.Fatalf("expected 'Alice' in first row, got %v", output.Rows[0]["name"])
+	}
+	if output.Rows[1]["name"] != "Bob" {
+		t.Fatalf("expected 'Bob' in second row, got %v", output.Rows[1]["name"])
+	}
+
+	// Verify sanitization masked phone numbers
+	phone0, ok := output.Rows[0]["phone"].(string)
+	if !ok {
+		t.Fatalf("expected phone to be string, got %T", output.Rows[0]["phone"])
+	}
+	if phone0 != "***-***-****" {
+		t.Fatalf("expected phone masked as '***-***-****', got %q", phone0)
+	}
+	phone1, ok := output.Rows[1]["phone"].(string)
+	if !ok {
+		t.Fatalf("expected phone to be string, got %T", output.Rows[1]["phone"])
+	}
+	if phone1 != "***-***-****" {
+		t.Fatalf("expected phone masked as '***-***-****', got %q", phone1)
+	}
+
+	// Verify AfterQuery hook applied to rows
+	for i, row := range output.Rows {
+		if row["hook_stage"] != "after_hook_applied" {
+			t.Fatalf("row %d: expected hook_stage='after_hook_applied', got %v", i, row["hook_stage"])
+		}
+	}
+
+	// --- Test 2: Error prompts applied on failure ---
+	// Use the setup instance (no hooks) with error prompts to test error prompt matching.
+	errPromptConfig := defaultConfig()
+	errPromptConfig.ErrorPrompts = []pgmcp.ErrorPromptRule{
+		{
+			Pattern: "does not exist",
+			Message: "The table may not exist. Try running ListTables first.",
+		},
+	}
+	pErrPrompt, err := pgmcp.New(ctx, connStr, errPromptConfig, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create error prompt instance: %v", err)
+	}
+	defer pErrPrompt.Close(ctx)
+
+	errOutput := pErrPrompt.Query(ctx, pgmcp.QueryInput{SQL: "SELECT * FROM nonexistent_table_xyz"})
+	if errOutput.Error == "" {
+		t.Fatal("expected error for nonexistent table")
+	}
+	if !strings.Contains(errOutput.Error, "does not exist") {
+		t.Fatalf("expected 'does not exist' in error, got %q", errOutput.Error)
+	}
+	if !strings.Contains(errOutput.Error, "The table may not exist. Try running ListTables first.") {
+		t.Fatalf("expected error prompt in error message, got %q", errOutput.Error)
+	}
+}
+
+// --- Config Defaults Tests ---
+
+func TestLoadConfigDefaults_MaxResultLength(t *testing.T) {
+	t.Parallel()
+	// Config with MaxResultLength omitted (0) — should default to 100000.
+	config := defaultConfig()
+	config.Query.MaxResultLength = 0
+	p, _ := newTestInstance(t, config)
+
+	// A simple query should succeed — the default (100000) is applied, not 0.
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 'hello' AS greeting"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error with default max_result_length: %s", output.Error)
+	}
+	if len(output.Rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(output.Rows))
+	}
+	if output.Rows[0]["greeting"] != "hello" {
+		t.Fatalf("expected 'hello', got %v", output.Rows[0]["greeting"])
+	}
+}
+
+func TestLoadConfigDefaults_MaxSQLLength(t *testing.T) {
+	t.Parallel()
+	// Config with MaxSQLLength omitted (0) — should default to 100000.
+	config := defaultConfig()
+	config.Query.MaxSQLLength = 0
+	p, _ := newTestInstance(t, config)
+
+	// A normal-length query should succeed — the default (100000) is applied, not 0.
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1 AS num"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error with default max_sql_length: %s", output.Error)
+	}
+	if len(output.Rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(output.Rows))
+	}
+}
+
+func TestClose_SubsequentOperationsFail(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	connStr := acquireTestDB(t)
+	config := defaultConfig()
+
+	p, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create pgmcp instance: %v", err)
+	}
+
+	// Verify the instance works before closing.
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 1 AS num"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error before close: %s", output.Error)
+	}
+	if len(output.Rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(output.Rows))
+	}
+
+	// Close the instance.
+	p.Close(ctx)
+
+	// Query should return an error in output.Error (pool is closed).
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 1 AS num"})
+	if output.Error == "" {
+		t.Fatalf("expected error after close, got none")
+	}
+
+	// ListTables should return a Go error.
+	_, err = p.ListTables(ctx, pgmcp.ListTablesInput{})
+	if err == nil {
+		t.Fatalf("expected error from ListTables after close, got nil")
+	}
+
+	// DescribeTable should return a Go error.
+	_, err = p.DescribeTable(ctx, pgmcp.DescribeTableInput{Table: "nonexistent"})
+	if err == nil {
+		t.Fatalf("expected error from DescribeTable after close, got nil")
+	}
+}
+
+// --- Logging Tests ---
+
+// parseLogLines splits a buffer into JSON log entries, returning only non-empty lines.
+func parseLogLines(buf *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func TestQuery_LogsExecutionDetails(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	connStr := acquireTestDB(t)
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, err := pgmcp.New(ctx, connStr, config, logger)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	t.Cleanup(func() { p.Close(ctx) })
+
+	// Setup table and insert data.
+	setupOutput := p.Query(ctx, pgmcp.QueryInput{SQL: "CREATE TABLE log_test (id serial PRIMARY KEY, name text)"})
+	if setupOutput.Error != "" {
+		t.Fatalf("setup failed: %s", setupOutput.Error)
+	}
+	setupOutput = p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO log_test (name) VALUES ('Alice'), ('Bob')"})
+	if setupOutput.Error != "" {
+		t.Fatalf("insert failed: %s", setupOutput.Error)
+	}
+
+	// Clear buffer to capture only the test query log.
+	buf.Reset()
+
+	// Execute a SELECT query.
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT id, name FROM log_test ORDER BY id"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+
+	entries := parseLogLines(&buf)
+	if len(entries) == 0 {
+		t.Fatal("expected at least one log entry, got none")
+	}
+
+	// Find the "query executed" log entry.
+	var queryLog map[string]interface{}
+	for _, entry := range entries {
+		if msg, ok := entry["message"].(string); ok && msg == "query executed" {
+			queryLog = entry
+			break
+		}
+	}
+	if queryLog == nil {
+		t.Fatalf("expected 'query executed' log entry, got entries: %v", entries)
+	}
+
+	// Verify required fields.
+	sql, ok := queryLog["sql"].(string)
+	if !ok || sql == "" {
+		t.Fatalf("expected non-empty 'sql' field in log, got %v", queryLog["sql"])
+	}
+	if !strings.Contains(sql, "SELECT id, name FROM log_test") {
+		t.Fatalf("expected sql to contain query text, got %q", sql)
+	}
+
+	if _, ok := queryLog["duration"]; !ok {
+		t.Fatalf("expected 'duration' field in log, got keys: %v", keys(queryLog))
+	}
+
+	rowCount, ok := queryLog["row_count"].(float64)
+	if !ok {
+		t.Fatalf("expected 'row_count' as number, got %T: %v", queryLog["row_count"], queryLog["row_count"])
+	}
+	if int(rowCount) != 2 {
+		t.Fatalf("expected row_count=2, got %v", rowCount)
+	}
+
+	if _, ok := queryLog["rows_affected"]; !ok {
+		t.Fatalf("expected 'rows_affected' field in log, got keys: %v", keys(queryLog))
+	}
+
+	// Verify log level is "info".
+	if level, ok := queryLog["level"].(string); ok && level != "info" {
+		t.Fatalf("expected log level 'info', got %q", level)
+	}
+}
+
+func TestQuery_LogsOptionalPipelineFields(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	connStr := acquireTestDB(t)
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+
+	// Configure hooks, sanitization, and timeout rules.
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.DefaultHookTimeoutSeconds = 5
+	config.BeforeQueryHooks = []pgmcp.BeforeQueryHookEntry{
+		{Name: "passthrough", Hook: &logPassthroughBeforeHook{}},
+	}
+	config.AfterQueryHooks = []pgmcp.AfterQueryHookEntry{
+		{Name: "accept_all", Hook: &logPassthroughAfterHook{}},
+	}
+	config.Sanitization = []pgmcp.SanitizationRule{
+		{Pattern: `\d{3}-\d{3}-\d{4}`, Replacement: "***-***-****"},
+	}
+	config.Query.TimeoutRules = []pgmcp.TimeoutRule{
+		{Pattern: "log_pipeline_test", TimeoutSeconds: 25},
+	}
+
+	p, err := pgmcp.New(ctx, connStr, config, logger)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	t.Cleanup(func() { p.Close(ctx) })
+
+	setupOutput := p.Query(ctx, pgmcp.QueryInput{SQL: "CREATE TABLE log_pipeline_test (id serial, phone text)"})
+	if setupOutput.Error != "" {
+		t.Fatalf("setup failed: %s", setupOutput.Error)
+	}
+	setupOutput = p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO log_pipeline_test (phone) VALUES ('555-123-4567')"})
+	if setupOutput.Error != "" {
+		t.Fatalf("insert failed: %s", setupOutput.Error)
+	}
+
+	buf.Reset()
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT * FROM log_pipeline_test"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+
+	entries := parseLogLines(&buf)
+	var queryLog map[string]interface{}
+	for _, entry := range entries {
+		if msg, ok := entry["message"].(string); ok && msg == "query executed" {
+			queryLog = entry
+			break
+		}
+	}
+	if queryLog == nil {
+		t.Fatalf("expected 'query executed' log entry, got entries: %v", entries)
+	}
+
+	// Verify optional before_hooks field.
+	beforeHooks, ok := queryLog["before_hooks"].([]interface{})
+	if !ok {
+		t.Fatalf("expected 'before_hooks' as array, got %T: %v", queryLog["before_hooks"], queryLog["before_hooks"])
+	}
+	if len(beforeHooks) != 1 || beforeHooks[0] != "passthrough" {
+		t.Fatalf("expected before_hooks=[passthrough], got %v", beforeHooks)
+	}
+
+	// Verify optional after_hooks field.
+	afterHooks, ok := queryLog["after_hooks"].([]interface{})
+	if !ok {
+		t.Fatalf("expected 'after_hooks' as array, got %T: %v", queryLog["after_hooks"], queryLog["after_hooks"])
+	}
+	if len(afterHooks) != 1 || afterHooks[0] != "accept_all" {
+		t.Fatalf("expected after_hooks=[accept_all], got %v", afterHooks)
+	}
+
+	// Verify optional timeout_rule field.
+	timeoutRule, ok := queryLog["timeout_rule"].(string)
+	if !ok || timeoutRule == "" {
+		t.Fatalf("expected non-empty 'timeout_rule' field, got %v", queryLog["timeout_rule"])
+	}
+	if timeoutRule != "log_pipeline_test" {
+		t.Fatalf("expected timeout_rule='log_pipeline_test', got %q", timeoutRule)
+	}
+
+	// Verify optional sanitized field.
+	sanitizedVal, ok := queryLog["sanitized"].(bool)
+	if !ok || !sanitizedVal {
+		t.Fatalf("expected sanitized=true, got %v", queryLog["sanitized"])
+	}
+}
+
+func TestListTables_LogsExecution(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	connStr := acquireTestDB(t)
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, err := pgmcp.New(ctx, connStr, config, logger)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	t.Cleanup(func() { p.Close(ctx) })
+
+	setupOutput := p.Query(ctx, pgmcp.QueryInput{SQL: "CREATE TABLE lt_log_test (id serial PRIMARY KEY)"})
+	if setupOutput.Error != "" {
+		t.Fatalf("setup failed: %s", setupOutput.Error)
+	}
+
+	buf.Reset()
+
+	_, err = p.ListTables(ctx, pgmcp.ListTablesInput{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entries := parseLogLines(&buf)
+	var ltLog map[string]interface{}
+	for _, entry := range entries {
+		if msg, ok := entry["message"].(string); ok && msg == "ListTables executed" {
+			ltLog = entry
+			break
+		}
+	}
+	if ltLog == nil {
+		t.Fatalf("expected 'ListTables executed' log entry, got entries: %v", entries)
+	}
+
+	if _, ok := ltLog["duration"]; !ok {
+		t.Fatalf("expected 'duration' field, got keys: %v", keys(ltLog))
+	}
+
+	tableCount, ok := ltLog["table_count"].(float64)
+	if !ok {
+		t.Fatalf("expected 'table_count' as number, got %T: %v", ltLog["table_count"], ltLog["table_count"])
+	}
+	if int(tableCount) < 1 {
+		t.Fatalf("expected table_count >= 1, got %v", tableCount)
+	}
+}
+
+func TestQuery_PlanAdvisories(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Query.PlanAdvisories = true
+	config.Query.ExplainSlowQueriesMillis = 200
+	p, connStr := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE TABLE orders (id int, status text)")
+	setupTable(t, p, "INSERT INTO orders SELECT g, CASE WHEN g % 100 = 0 THEN 'open' ELSE 'closed' END FROM generate_series(1, 20000) AS g")
+	analyzeTable(t, connStr, "orders")
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{
+		SQL:         "SELECT id FROM orders WHERE status = 'open'",
+		IncludePlan: true,
+	})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if len(output.Advisories) != 1 {
+		t.Fatalf("expected one advisory, got %q", output.Advisories)
+	}
+	advisory := output.Advisories[0]
+	if !strings.HasPrefix(advisory, "sequential scan on large table 'public.orders' (est 20K rows)") || !strings.Contains(advisory, "status") || !strings.Contains(advisory, "consider an index on the filter column") {
+		t.Fatalf("expected a seq scan advisory naming the filter, got %q", advisory)
+	}
+	if len(output.Rows) != 200 {
+		t.Fatalf("expected 200 rows, got %d", len(output.Rows))
 	}
 
-	// Row 3: no PII — values unchanged
-	profile3, ok := output.Rows[2]["profile"].(map[string]interface{})
-	if !ok {
-		t.Fatalf("expected map for profile, got %T", output.Rows[2]["profile"])
+	// A fast query without include_plan captures no plan, so it gets no advisories.
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT id FROM orders WHERE id = 1"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	if profile3["simple"] != "no pii here" {
-		t.Fatalf("expected 'no pii here' unchanged, got %v", profile3["simple"])
+	if output.Advisories != nil {
+		t.Fatalf("expected no advisories without a captured plan, got %q", output.Advisories)
 	}
-	if output.Rows[2]["phone"] != "not-a-phone" {
-		t.Fatalf("expected 'not-a-phone' unchanged, got %v", output.Rows[2]["phone"])
+
+	// A slow read's plan (explain_slow_queries_millis) is advised on too; it is not
+	// VERBOSE, so the table is not schema-qualified.
+	output = p.Query(context.Background(), pgmcp.QueryInput{
+		SQL: "SELECT o.id FROM orders o, (SELECT pg_sleep(0.3)) AS s WHERE o.status = 'open'",
+	})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if len(output.Advisories) != 1 || !strings.HasPrefix(output.Advisories[0], "sequential scan on large table 'orders' (est 20K rows)") {
+		t.Fatalf("expected a seq scan advisory for the slow query, got %q", output.Advisories)
 	}
 }
 
-// --- Full Pipeline Test ---
+func TestQuery_ExplainSlowQueries(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	connStr := acquireTestDB(t)
 
-// pipelineBeforeHook rewrites any query to SELECT id, name, phone FROM pipeline_test ORDER BY id.
-type pipelineBeforeHook struct{}
+	var buf bytes.Buffer
+	config := defaultConfig()
+	config.Query.ExplainSlowQueriesMillis = 200
+	p, err := pgmcp.New(ctx, connStr, config, zerolog.New(&buf))
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	t.Cleanup(func() { p.Close(ctx) })
 
-func (h *pipelineBeforeHook) Run(_ context.Context, _ string) (string, error) {
-	return "SELECT id, name, phone FROM pipeline_test ORDER BY id", nil
+	slowPlans := func() []map[string]interface{} {
+		var plans []map[string]interface{}
+		for _, entry := range parseLogLines(&buf) {
+			if entry["message"] == "slow query plan" {
+				plans = append(plans, entry)
+			}
+		}
+		return plans
+	}
+
+	if output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 1 AS fast"}); output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if plans := slowPlans(); len(plans) != 0 {
+		t.Fatalf("expected no plan for the fast query, got %v", plans)
+	}
+
+	buf.Reset()
+	if output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT pg_sleep(0.3) AS slow"}); output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	plans := slowPlans()
+	if len(plans) != 1 {
+		t.Fatalf("expected one plan for the slow query, got %v", parseLogLines(&buf))
+	}
+	if plans[0]["level"] != "warn" || !strings.Contains(fmt.Sprint(plans[0]["sql"]), "pg_sleep") {
+		t.Fatalf("expected a warn entry with the SQL, got %v", plans[0])
+	}
+	if _, ok := plans[0]["plan"].([]interface{}); !ok {
+		t.Fatalf("expected the JSON plan, got %v", plans[0]["plan"])
+	}
 }
 
-// pipelineAfterHook adds a "hook_stage" column to every row.
-type pipelineAfterHook struct{}
+func TestDescribeTable_LogsExecution(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	connStr := acquireTestDB(t)
 
-func (h *pipelineAfterHook) Run(_ context.Context, result *pgmcp.QueryOutput) (*pgmcp.QueryOutput, error) {
-	result.Columns = append(result.Columns, "hook_stage")
-	for _, row := range result.Rows {
-		row["hook_stage"] = "after_hook_applied"
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, err := pgmcp.New(ctx, connStr, config, logger)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	t.Cleanup(func() { p.Close(ctx) })
+
+	setupOutput := p.Query(ctx, pgmcp.QueryInput{SQL: "CREATE TABLE dt_log_test (id serial PRIMARY KEY, name text)"})
+	if setupOutput.Error != "" {
+		t.Fatalf("setup failed: %s", setupOutput.Error)
+	}
+
+	buf.Reset()
+
+	_, err = p.DescribeTable(ctx, pgmcp.DescribeTableInput{Table: "dt_log_test"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entries := parseLogLines(&buf)
+	var dtLog map[string]interface{}
+	for _, entry := range entries {
+		if msg, ok := entry["message"].(string); ok && msg == "DescribeTable executed" {
+			dtLog = entry
+			break
+		}
+	}
+	if dtLog == nil {
+		t.Fatalf("expected 'DescribeTable executed' log entry, got entries: %v", entries)
+	}
+
+	if _, ok := dtLog["duration"]; !ok {
+		t.Fatalf("expected 'duration' field, got keys: %v", keys(dtLog))
+	}
+
+	schema, ok := dtLog["schema"].(string)
+	if !ok || schema != "public" {
+		t.Fatalf("expected schema='public', got %v", dtLog["schema"])
+	}
+
+	table, ok := dtLog["table"].(string)
+	if !ok || table != "dt_log_test" {
+		t.Fatalf("expected table='dt_log_test', got %v", dtLog["table"])
+	}
+
+	tableType, ok := dtLog["type"].(string)
+	if !ok || tableType != "table" {
+		t.Fatalf("expected type='table', got %v", dtLog["type"])
+	}
+
+	columnCount, ok := dtLog["column_count"].(float64)
+	if !ok {
+		t.Fatalf("expected 'column_count' as number, got %T: %v", dtLog["column_count"], dtLog["column_count"])
+	}
+	if int(columnCount) != 2 {
+		t.Fatalf("expected column_count=2, got %v", columnCount)
 	}
-	return result, nil
 }
 
-func TestFullPipeline(t *testing.T) {
+// --- Connection Recovery Tests ---
+
+// terminateBackend terminates the given instance's pooled backend connection from a
+// separate instance, simulating a database restart as seen by existing pooled connections.
+func TestQuery_LockTimeoutFailsFast(t *testing.T) {
 	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Query.DefaultTimeoutSeconds = 30
+	config.Query.LockTimeoutMillis = 200
+	p, connStr := newTestInstance(t, config)
 
-	// First, create a plain instance (no hooks) to set up the test table.
-	setupConfig := defaultConfig()
-	setupConfig.Protection.AllowDDL = true
-	setupP, connStr := newTestInstance(t, setupConfig)
+	setupTable(t, p, "CREATE TABLE accounts (id int, balance int)")
+	setupTable(t, p, "INSERT INTO accounts VALUES (1, 100)")
 
-	setupTable(t, setupP, "CREATE TABLE pipeline_test (id serial PRIMARY KEY, name text, phone text)")
-	setupTable(t, setupP, "INSERT INTO pipeline_test (name, phone) VALUES ('Alice', '555-123-4567'), ('Bob', '555-987-6543')")
+	// Hold an exclusive lock from a separate connection.
+	ctx := context.Background()
+	holder, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer holder.Close(ctx)
+	lockTx, err := holder.Begin(ctx)
+	if err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	defer lockTx.Rollback(ctx)
+	if _, err := lockTx.Exec(ctx, "LOCK TABLE accounts IN ACCESS EXCLUSIVE MODE"); err != nil {
+		t.Fatalf("failed to lock table: %v", err)
+	}
 
-	// Now create the full pipeline instance with hooks, sanitization, and error prompts
-	// on the same database.
-	pipelineConfig := defaultConfig()
-	pipelineConfig.DefaultHookTimeoutSeconds = 10
-	pipelineConfig.BeforeQueryHooks = []pgmcp.BeforeQueryHookEntry{
-		{Name: "rewrite", Hook: &pipelineBeforeHook{}},
+	start := time.Now()
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT * FROM accounts"})
+	elapsed := time.Since(start)
+	if !strings.Contains(output.Error, "could not obtain lock within lock_timeout (200ms)") {
+		t.Fatalf("expected lock timeout error, got %q", output.Error)
 	}
-	pipelineConfig.AfterQueryHooks = []pgmcp.AfterQueryHookEntry{
-		{Name: "add_column", Hook: &pipelineAfterHook{}},
+	if elapsed > 5*time.Second {
+		t.Fatalf("expected query to fail fast, took %s", elapsed)
 	}
-	pipelineConfig.Sanitization = []pgmcp.SanitizationRule{
-		{
-			Pattern:     `\d{3}-\d{3}-\d{4}`,
-			Replacement: "***-***-****",
-			Description: "mask phone numbers",
-		},
+	if output.ErrorKind != pgmcp.ErrorKindTimeout {
+		t.Fatalf("expected error kind %q, got %q", pgmcp.ErrorKindTimeout, output.ErrorKind)
 	}
-	pipelineConfig.ErrorPrompts = []pgmcp.ErrorPromptRule{
-		{
-			Pattern: "does not exist",
-			Message: "The table may not exist. Try running ListTables first.",
-		},
+
+	// Once the lock is released, the same query succeeds.
+	lockTx.Rollback(ctx)
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT * FROM accounts"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error after lock released: %s", output.Error)
+	}
+
+	// NOWAIT fails with the same SQLSTATE without waiting; lock_timeout is not blamed.
+	rowTx, err := holder.Begin(ctx)
+	if err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	defer rowTx.Rollback(ctx)
+	if _, err := rowTx.Exec(ctx, "SELECT * FROM accounts WHERE id = 1 FOR UPDATE"); err != nil {
+		t.Fatalf("failed to lock row: %v", err)
+	}
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT * FROM accounts WHERE id = 1 FOR UPDATE NOWAIT"})
+	if !strings.Contains(output.Error, "(NOWAIT)") || strings.Contains(output.Error, "lock_timeout") {
+		t.Fatalf("expected a NOWAIT error, got %q", output.Error)
+	}
+	if output.ErrorKind != pgmcp.ErrorKindDatabase {
+		t.Fatalf("expected error kind %q, got %q", pgmcp.ErrorKindDatabase, output.ErrorKind)
+	}
+}
+
+// deepSumSQL nests a sum deeper than pg_query_go can decode (its protobuf recursion limit),
+// while Postgres itself parses and runs it: a known parser-vs-server discrepancy.
+func deepSumSQL(prefix string) string {
+	return prefix + "1" + strings.Repeat(" + 1", 6000)
+}
+
+func TestOnParseFailure_BlockByDefault(t *testing.T) {
+	t.Parallel()
+	p, _ := newTestInstance(t, defaultConfig())
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: deepSumSQL("SELECT ")})
+	if !strings.HasPrefix(output.Error, "SQL parse error: ") {
+		t.Fatalf("expected parse error, got %q", output.Error)
+	}
+}
+
+func TestOnParseFailure_AllowReads(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Protection.OnParseFailure = pgmcp.OnParseFailureAllowReads
+	p, _ := newTestInstance(t, config)
+	setupTable(t, p, "CREATE TABLE t (v int)")
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: deepSumSQL("SELECT ") + " AS total"})
+	if strings.Contains(output.Error, "stack depth limit exceeded") {
+		t.Skip("server rejects the expression as too deep; cannot exercise the fallback")
+	}
+	if output.Error != "" {
+		t.Fatalf("expected unparseable read to run, got %q", output.Error)
+	}
+	if len(output.Rows) != 1 || output.Rows[0]["total"] != int32(6001) {
+		t.Fatalf("unexpected rows: %v", output.Rows)
+	}
+
+	// Writes the parser cannot check are rejected by the server.
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: deepSumSQL("INSERT INTO t VALUES (") + ")"})
+	if !strings.Contains(output.Error, "cannot execute INSERT in a read-only transaction") {
+		t.Fatalf("expected read-only transaction error, got %q", output.Error)
+	}
+	count := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT count(*) AS n FROM t"})
+	if count.Rows[0]["n"] != int64(0) {
+		t.Fatalf("expected no rows inserted, got %v", count.Rows[0]["n"])
+	}
+
+	// Parseable statements still go through protection.
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "DROP TABLE t"})
+	if !strings.Contains(output.Error, "DROP statements are not allowed") {
+		t.Fatalf("expected DROP to be blocked, got %q", output.Error)
+	}
+}
+
+func TestStartupAssertions_Pass(t *testing.T) {
+	t.Parallel()
+	connStr := acquireTestDB(t)
+	pgxConfig, err := pgx.ParseConfig(connStr)
+	if err != nil {
+		t.Fatalf("failed to parse connection string: %v", err)
+	}
+	config := defaultConfig()
+	config.StartupAssertions = []pgmcp.StartupAssertion{
+		{SQL: "SELECT current_database()", Expect: pgxConfig.Database},
+		{SQL: "SELECT 1 + 1", Expect: "2"},
+		{SQL: "SELECT pg_is_in_recovery()", Expect: "f"},
+	}
+
+	p, err := pgmcp.New(context.Background(), connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create PostgresMcp: %v", err)
 	}
+	defer p.Close(context.Background())
+	if err := p.WaitReady(context.Background()); err != nil {
+		t.Fatalf("expected assertions to pass, got: %v", err)
+	}
+}
+
+func TestStartupAssertions_QueryBeforeWaitReady(t *testing.T) {
+	t.Parallel()
+	connStr := acquireTestDB(t)
+	config := defaultConfig()
+	config.StartupAssertions = []pgmcp.StartupAssertion{{SQL: "SELECT current_database()", Expect: "production"}}
 
 	ctx := context.Background()
-	p, err := pgmcp.New(ctx, connStr, pipelineConfig, testLogger())
+	p, err := pgmcp.New(ctx, connStr, config, testLogger())
 	if err != nil {
-		t.Fatalf("failed to create pipeline instance: %v", err)
+		t.Fatalf("failed to create PostgresMcp: %v", err)
 	}
 	defer p.Close(ctx)
 
-	// --- Test 1: Successful query through full pipeline ---
-	// Send a dummy query — BeforeQuery hook rewrites it to SELECT from pipeline_test.
+	// Tool calls run the assertions themselves and refuse to touch the database.
 	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 1"})
-	if output.Error != "" {
-		t.Fatalf("unexpected error: %s", output.Error)
+	if !strings.Contains(output.Error, "database startup check failed") || !strings.Contains(output.Error, `startup assertion "SELECT current_database()" failed`) {
+		t.Fatalf("expected the failing assertion to refuse the query, got %q", output.Error)
+	}
+	if _, err := p.ListTables(ctx, pgmcp.ListTablesInput{}); err == nil || !strings.Contains(err.Error(), "database startup check failed") {
+		t.Fatalf("expected ListTables to be refused, got %v", err)
 	}
+}
 
-	// Verify AfterQuery hook added the "hook_stage" column
-	if len(output.Columns) != 4 {
-		t.Fatalf("expected 4 columns (id, name, phone, hook_stage), got %d: %v", len(output.Columns), output.Columns)
+func TestStartupAssertions_Fail(t *testing.T) {
+	t.Parallel()
+	connStr := acquireTestDB(t)
+	config := defaultConfig()
+	config.StartupAssertions = []pgmcp.StartupAssertion{
+		{SQL: "SELECT 1 + 1", Expect: "2"},
+		{SQL: "SELECT current_database()", Expect: "production"},
 	}
-	foundHookStage := false
-	for _, col := range output.Columns {
-		if col == "hook_stage" {
-			foundHookStage = true
-			break
-		}
+
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create PostgresMcp: %v", err)
 	}
-	if !foundHookStage {
-		t.Fatalf("expected 'hook_stage' column from AfterQuery hook, got columns: %v", output.Columns)
+	defer p.Close(ctx)
+	err = p.WaitReady(ctx)
+	if err == nil {
+		t.Fatal("expected WaitReady to fail on a mismatched startup assertion")
 	}
-
-	// Verify we got 2 rows
-	if len(output.Rows) != 2 {
-		t.Fatalf("expected 2 rows, got %d", len(output.Rows))
+	if !strings.Contains(err.Error(), `startup assertion "SELECT current_database()" failed`) || !strings.Contains(err.Error(), `expected "production"`) {
+		t.Fatalf("unexpected error: %v", err)
 	}
 
-	// Verify BeforeQuery hook rewrote the query (we get pipeline_test data, not "SELECT 1")
-	if output.Rows[0]["name"] != "Alice" {
-		t.Fatalf("expected 'Alice' in first row, got %v", output.Rows[0]["name"])
+	// NULL never matches.
+	config.StartupAssertions = []pgmcp.StartupAssertion{{SQL: "SELECT NULL", Expect: ""}}
+	p2, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create PostgresMcp: %v", err)
 	}
-	if output.Rows[1]["name"] != "Bob" {
-		t.Fatalf("expected 'Bob' in second row, got %v", output.Rows[1]["name"])
+	defer p2.Close(ctx)
+	if err := p2.WaitReady(ctx); err == nil {
+		t.Fatal("expected WaitReady to fail when an assertion returns NULL")
 	}
+}
 
-	// Verify sanitization masked phone numbers
-	phone0, ok := output.Rows[0]["phone"].(string)
-	if !ok {
-		t.Fatalf("expected phone to be string, got %T", output.Rows[0]["phone"])
+func TestMaxConnectionsCheck(t *testing.T) {
+	t.Parallel()
+	connStr := acquireTestDB(t)
+	ctx := context.Background()
+
+	// start creates an instance and runs its startup checks.
+	start := func(config pgmcp.Config, logger zerolog.Logger) error {
+		p, err := pgmcp.New(ctx, connStr, config, logger)
+		if err != nil {
+			t.Fatalf("failed to create PostgresMcp: %v", err)
+		}
+		defer p.Close(ctx)
+		return p.WaitReady(ctx)
 	}
-	if phone0 != "***-***-****" {
-		t.Fatalf("expected phone masked as '***-***-****', got %q", phone0)
+
+	// Default (warn): an oversized pool starts, with a warning.
+	var buf bytes.Buffer
+	config := defaultConfig()
+	config.Pool.MaxConns = 100000
+	if err := start(config, zerolog.New(&buf)); err != nil {
+		t.Fatalf("expected an oversized pool to start in warn mode, got: %v", err)
 	}
-	phone1, ok := output.Rows[1]["phone"].(string)
-	if !ok {
-		t.Fatalf("expected phone to be string, got %T", output.Rows[1]["phone"])
+	if !strings.Contains(buf.String(), `"level":"warn"`) || !strings.Contains(buf.String(), "pool.max_conns (100000) could exhaust the server's connections") {
+		t.Fatalf("expected a max_connections warning, got logs: %s", buf.String())
 	}
-	if phone1 != "***-***-****" {
-		t.Fatalf("expected phone masked as '***-***-****', got %q", phone1)
+
+	config.Pool.MaxConnectionsCheck = pgmcp.MaxConnectionsCheckError
+	if err := start(config, testLogger()); err == nil {
+		t.Fatal("expected WaitReady to fail for an oversized pool in error mode")
+	} else if !strings.Contains(err.Error(), "pool.max_conns (100000) could exhaust") {
+		t.Fatalf("unexpected error: %v", err)
 	}
 
-	// Verify AfterQuery hook applied to rows
-	for i, row := range output.Rows {
-		if row["hook_stage"] != "after_hook_applied" {
-			t.Fatalf("row %d: expected hook_stage='after_hook_applied', got %v", i, row["hook_stage"])
-		}
+	// A small pool passes.
+	config.Pool.MaxConns = 2
+	if err := start(config, testLogger()); err != nil {
+		t.Fatalf("expected a small pool to pass the check, got: %v", err)
 	}
+}
 
-	// --- Test 2: Error prompts applied on failure ---
-	// Use the setup instance (no hooks) with error prompts to test error prompt matching.
-	errPromptConfig := defaultConfig()
-	errPromptConfig.ErrorPrompts = []pgmcp.ErrorPromptRule{
-		{
-			Pattern: "does not exist",
-			Message: "The table may not exist. Try running ListTables first.",
-		},
+func TestMaxConnectionsCheck_OnceReachable(t *testing.T) {
+	t.Parallel()
+	connStr := acquireTestDB(t)
+	u, err := url.Parse(connStr)
+	if err != nil {
+		t.Fatalf("failed to parse connStr: %v", err)
 	}
-	pErrPrompt, err := pgmcp.New(ctx, connStr, errPromptConfig, testLogger())
+	u.Host = startDelayedProxy(t, u.Host, 1500*time.Millisecond)
+
+	// New succeeds while the database is unreachable; the check waits for WaitReady.
+	ctx := context.Background()
+	config := defaultConfig()
+	config.Pool.MaxConns = 100000
+	config.Pool.MaxConnectionsCheck = pgmcp.MaxConnectionsCheckError
+	p, err := pgmcp.New(ctx, u.String(), config, testLogger())
 	if err != nil {
-		t.Fatalf("failed to create error prompt instance: %v", err)
+		t.Fatalf("expected New to succeed before the database is reachable, got: %v", err)
 	}
-	defer pErrPrompt.Close(ctx)
+	defer p.Close(ctx)
 
-	errOutput := pErrPrompt.Query(ctx, pgmcp.QueryInput{SQL: "SELECT * FROM nonexistent_table_xyz"})
-	if errOutput.Error == "" {
-		t.Fatal("expected error for nonexistent table")
+	probeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	err = p.WaitReady(probeCtx)
+	if err == nil || !strings.Contains(err.Error(), "pool.max_conns (100000) could exhaust") {
+		t.Fatalf("expected the check to run and fail once the database is reachable, got: %v", err)
 	}
-	if !strings.Contains(errOutput.Error, "does not exist") {
-		t.Fatalf("expected 'does not exist' in error, got %q", errOutput.Error)
+}
+
+// analyzeTable runs ANALYZE directly (it is blocked by protection rules by default) so
+// planner estimates reflect the table's contents.
+func analyzeTable(t *testing.T, connStr, table string) {
+	t.Helper()
+	conn, err := pgx.Connect(context.Background(), connStr)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
 	}
-	if !strings.Contains(errOutput.Error, "The table may not exist. Try running ListTables first.") {
-		t.Fatalf("expected error prompt in error message, got %q", errOutput.Error)
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(context.Background(), "ANALYZE "+table); err != nil {
+		t.Fatalf("failed to analyze %s: %v", table, err)
 	}
 }
 
-// --- Config Defaults Tests ---
-
-func TestLoadConfigDefaults_MaxResultLength(t *testing.T) {
+func TestQuery_MaxRowsAffected_EstimateRejectsUpFront(t *testing.T) {
 	t.Parallel()
-	// Config with MaxResultLength omitted (0) — should default to 100000.
 	config := defaultConfig()
-	config.Query.MaxResultLength = 0
-	p, _ := newTestInstance(t, config)
+	config.Protection.AllowDDL = true
+	config.Query.MaxRowsAffected = 1000
+	p, connStr := newTestInstance(t, config)
 
-	// A simple query should succeed — the default (100000) is applied, not 0.
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 'hello' AS greeting"})
-	if output.Error != "" {
-		t.Fatalf("unexpected error with default max_result_length: %s", output.Error)
-	}
-	if len(output.Rows) != 1 {
-		t.Fatalf("expected 1 row, got %d", len(output.Rows))
+	setupTable(t, p, "CREATE TABLE big (id int, val text)")
+	setupTable(t, p, "INSERT INTO big SELECT g, 'v' FROM generate_series(1, 50000) AS g")
+	analyzeTable(t, connStr, "big")
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "DELETE FROM big WHERE id > 0"})
+	if !strings.Contains(output.Error, "statement is estimated to affect about") || !strings.Contains(output.Error, "exceeding cap 1000") {
+		t.Fatalf("expected estimate rejection, got %q", output.Error)
 	}
-	if output.Rows[0]["greeting"] != "hello" {
-		t.Fatalf("expected 'hello', got %v", output.Rows[0]["greeting"])
+
+	count := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT count(*) AS n FROM big"})
+	if count.Rows[0]["n"] != int64(50000) {
+		t.Fatalf("expected no rows deleted, got count %v", count.Rows[0]["n"])
 	}
 }
 
-func TestLoadConfigDefaults_MaxSQLLength(t *testing.T) {
+func TestQuery_MaxRowsAffected_HardCapRollsBack(t *testing.T) {
 	t.Parallel()
-	// Config with MaxSQLLength omitted (0) — should default to 100000.
 	config := defaultConfig()
-	config.Query.MaxSQLLength = 0
-	p, _ := newTestInstance(t, config)
+	config.Protection.AllowDDL = true
+	config.Query.MaxRowsAffected = 1000
+	p, connStr := newTestInstance(t, config)
 
-	// A normal-length query should succeed — the default (100000) is applied, not 0.
-	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1 AS num"})
+	// 1500 rows: within the estimate allowance, but over the hard cap.
+	setupTable(t, p, "CREATE TABLE items (id int, status text)")
+	setupTable(t, p, "INSERT INTO items SELECT g, 'new' FROM generate_series(1, 1500) AS g")
+	analyzeTable(t, connStr, "items")
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "UPDATE items SET status = 'done' WHERE id > 0"})
+	if !strings.Contains(output.Error, "statement would affect 1500 rows, exceeding cap 1000") {
+		t.Fatalf("expected hard cap error, got %q", output.Error)
+	}
+	count := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT count(*) AS n FROM items WHERE status = 'done'"})
+	if count.Rows[0]["n"] != int64(0) {
+		t.Fatalf("expected update rolled back, got %v updated rows", count.Rows[0]["n"])
+	}
+
+	// Inserts are covered by the hard cap too.
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "INSERT INTO items SELECT g, 'x' FROM generate_series(1, 1001) AS g"})
+	if !strings.Contains(output.Error, "statement would affect 1001 rows, exceeding cap 1000") {
+		t.Fatalf("expected hard cap error for insert, got %q", output.Error)
+	}
+
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "UPDATE items SET status = 'done' WHERE id <= 1000"})
 	if output.Error != "" {
-		t.Fatalf("unexpected error with default max_sql_length: %s", output.Error)
+		t.Fatalf("expected write at the cap to succeed, got %q", output.Error)
 	}
-	if len(output.Rows) != 1 {
-		t.Fatalf("expected 1 row, got %d", len(output.Rows))
+	if output.RowsAffected != 1000 {
+		t.Fatalf("expected 1000 rows affected, got %d", output.RowsAffected)
 	}
 }
 
-func TestClose_SubsequentOperationsFail(t *testing.T) {
-	t.Parallel()
+func terminateBackend(t *testing.T, p, admin *pgmcp.PostgresMcp) {
+	t.Helper()
 	ctx := context.Background()
-	connStr := acquireTestDB(t)
-	config := defaultConfig()
+	pidOutput := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT pg_backend_pid() AS pid"})
+	if pidOutput.Error != "" {
+		t.Fatalf("failed to get backend pid: %s", pidOutput.Error)
+	}
+	pid := pidOutput.Rows[0]["pid"]
+	killOutput := admin.Query(ctx, pgmcp.QueryInput{SQL: fmt.Sprintf("SELECT pg_terminate_backend(%v) AS killed", pid)})
+	if killOutput.Error != "" {
+		t.Fatalf("failed to terminate backend: %s", killOutput.Error)
+	}
+	if killOutput.Rows[0]["killed"] != true {
+		t.Fatalf("expected backend %v to be terminated, got %v", pid, killOutput.Rows[0]["killed"])
+	}
+	// pg_terminate_backend only signals; give the backend a moment to exit.
+	time.Sleep(200 * time.Millisecond)
+}
+
+func TestQuery_ReadRecoversAfterBackendTerminated(t *testing.T) {
+	t.Parallel()
+	admin, connStr := newTestInstance(t, defaultConfig())
 
+	// A single pooled connection guarantees the next query reuses the terminated backend.
+	config := defaultConfig()
+	config.Pool.MaxConns = 1
+	ctx := context.Background()
 	p, err := pgmcp.New(ctx, connStr, config, testLogger())
 	if err != nil {
-		t.Fatalf("failed to create pgmcp instance: %v", err)
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
 	}
+	defer p.Close(ctx)
 
-	// Verify the instance works before closing.
-	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 1 AS num"})
+	terminateBackend(t, p, admin)
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 1 AS one"})
 	if output.Error != "" {
-		t.Fatalf("unexpected error before close: %s", output.Error)
+		t.Fatalf("expected read to recover on a fresh connection, got error: %s", output.Error)
 	}
-	if len(output.Rows) != 1 {
-		t.Fatalf("expected 1 row, got %d", len(output.Rows))
+	if output.Rows[0]["one"] != int32(1) {
+		t.Fatalf("expected one=1, got %v (%T)", output.Rows[0]["one"], output.Rows[0]["one"])
 	}
+}
 
-	// Close the instance.
-	p.Close(ctx)
+func TestQuery_WriteNotRetriedAfterBackendTerminated(t *testing.T) {
+	t.Parallel()
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	admin, connStr := newTestInstance(t, setupConfig)
+	setupTable(t, admin, "CREATE TABLE conn_recovery_writes (id serial PRIMARY KEY, name text)")
 
-	// Query should return an error in output.Error (pool is closed).
-	output = p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 1 AS num"})
+	config := defaultConfig()
+	config.Pool.MaxConns = 1
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
+	}
+	defer p.Close(ctx)
+
+	terminateBackend(t, p, admin)
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO conn_recovery_writes (name) VALUES ('once')"})
 	if output.Error == "" {
-		t.Fatalf("expected error after close, got none")
+		t.Fatal("expected write on a terminated connection to fail without retry")
 	}
 
-	// ListTables should return a Go error.
-	_, err = p.ListTables(ctx, pgmcp.ListTablesInput{})
-	if err == nil {
-		t.Fatalf("expected error from ListTables after close, got nil")
+	verifyOutput := admin.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS cnt FROM conn_recovery_writes"})
+	if verifyOutput.Error != "" {
+		t.Fatalf("verification query failed: %s", verifyOutput.Error)
+	}
+	if cnt := verifyOutput.Rows[0]["cnt"]; cnt != int64(0) {
+		t.Fatalf("expected 0 rows (write not retried), got %v", cnt)
 	}
 
-	// DescribeTable should return a Go error.
-	_, err = p.DescribeTable(ctx, pgmcp.DescribeTableInput{Table: "nonexistent"})
-	if err == nil {
-		t.Fatalf("expected error from DescribeTable after close, got nil")
+	// The pool discards the broken connection, so the next write succeeds.
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO conn_recovery_writes (name) VALUES ('after')"})
+	if output.Error != "" {
+		t.Fatalf("expected next write to succeed on a fresh connection, got: %s", output.Error)
 	}
 }
 
-// --- Logging Tests ---
+// --- WaitReady Tests ---
 
-// parseLogLines splits a buffer into JSON log entries, returning only non-empty lines.
-func parseLogLines(buf *bytes.Buffer) []map[string]interface{} {
-	var entries []map[string]interface{}
-	for _, line := range strings.Split(buf.String(), "\n") {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		var entry map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &entry); err == nil {
-			entries = append(entries, entry)
-		}
+// startDelayedProxy starts a TCP proxy in front of the database at targetAddr.
+// Until readyAfter elapses, accepted connections are closed immediately (simulating
+// a database that is still booting); afterwards, connections are forwarded.
+func startDelayedProxy(t *testing.T, targetAddr string, readyAfter time.Duration) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start proxy listener: %v", err)
 	}
-	return entries
+	t.Cleanup(func() { ln.Close() })
+
+	readyAt := time.Now().Add(readyAfter)
+	go func() {
+		for {
+			clientConn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if time.Now().Before(readyAt) {
+				clientConn.Close()
+				continue
+			}
+			go func() {
+				defer clientConn.Close()
+				dbConn, err := net.Dial("tcp", targetAddr)
+				if err != nil {
+					return
+				}
+				defer dbConn.Close()
+				go io.Copy(dbConn, clientConn)
+				io.Copy(clientConn, dbConn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
 }
 
-func TestQuery_LogsExecutionDetails(t *testing.T) {
+func TestWaitReady_SucceedsOnceReachable(t *testing.T) {
 	t.Parallel()
-	ctx := context.Background()
 	connStr := acquireTestDB(t)
+	u, err := url.Parse(connStr)
+	if err != nil {
+		t.Fatalf("failed to parse connStr: %v", err)
+	}
+	u.Host = startDelayedProxy(t, u.Host, 1500*time.Millisecond)
 
-	var buf bytes.Buffer
-	logger := zerolog.New(&buf)
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, u.String(), defaultConfig(), testLogger())
+	if err != nil {
+		t.Fatalf("failed to create PostgresMcp: %v", err)
+	}
+	defer p.Close(ctx)
+
+	probeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := p.WaitReady(probeCtx); err != nil {
+		t.Fatalf("expected WaitReady to succeed once proxy is reachable, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("expected WaitReady to wait for the delayed database, returned after %s", elapsed)
+	}
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 1 AS one"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error after WaitReady: %s", output.Error)
+	}
+	if output.Rows[0]["one"] != int32(1) {
+		t.Fatalf("expected one=1, got %v", output.Rows[0]["one"])
+	}
+}
+
+func TestWaitReady_StartupAssertionsOnceReachable(t *testing.T) {
+	t.Parallel()
+	connStr := acquireTestDB(t)
+	u, err := url.Parse(connStr)
+	if err != nil {
+		t.Fatalf("failed to parse connStr: %v", err)
+	}
+	u.Host = startDelayedProxy(t, u.Host, 1500*time.Millisecond)
 
+	// New succeeds while the database is unreachable; the assertions wait for WaitReady.
+	ctx := context.Background()
 	config := defaultConfig()
-	config.Protection.AllowDDL = true
-	p, err := pgmcp.New(ctx, connStr, config, logger)
+	config.StartupAssertions = []pgmcp.StartupAssertion{{SQL: "SELECT current_database()", Expect: "production"}}
+	p, err := pgmcp.New(ctx, u.String(), config, testLogger())
 	if err != nil {
-		t.Fatalf("failed to create instance: %v", err)
+		t.Fatalf("expected New to succeed before the database is reachable, got: %v", err)
 	}
-	t.Cleanup(func() { p.Close(ctx) })
+	defer p.Close(ctx)
 
-	// Setup table and insert data.
-	setupOutput := p.Query(ctx, pgmcp.QueryInput{SQL: "CREATE TABLE log_test (id serial PRIMARY KEY, name text)"})
-	if setupOutput.Error != "" {
-		t.Fatalf("setup failed: %s", setupOutput.Error)
+	probeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	err = p.WaitReady(probeCtx)
+	if err == nil || !strings.Contains(err.Error(), `startup assertion "SELECT current_database()" failed`) {
+		t.Fatalf("expected the assertion to run and fail once the database is reachable, got: %v", err)
 	}
-	setupOutput = p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO log_test (name) VALUES ('Alice'), ('Bob')"})
-	if setupOutput.Error != "" {
-		t.Fatalf("insert failed: %s", setupOutput.Error)
+}
+
+func TestWaitReady_TimezoneFallbackOnceReachable(t *testing.T) {
+	t.Parallel()
+	connStr := acquireTestDB(t)
+	u, err := url.Parse(connStr)
+	if err != nil {
+		t.Fatalf("failed to parse connStr: %v", err)
 	}
+	u.Host = startDelayedProxy(t, u.Host, 1500*time.Millisecond)
 
-	// Clear buffer to capture only the test query log.
-	buf.Reset()
+	// The timezone is verified once the database is reachable, not skipped.
+	ctx := context.Background()
+	config := defaultConfig()
+	config.Timezone = "Local"
+	config.TimezoneFallback = pgmcp.TimezoneFallbackUTC
+	p, err := pgmcp.New(ctx, u.String(), config, testLogger())
+	if err != nil {
+		t.Fatalf("expected New to succeed before the database is reachable, got: %v", err)
+	}
+	defer p.Close(ctx)
 
-	// Execute a SELECT query.
-	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT id, name FROM log_test ORDER BY id"})
+	probeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := p.WaitReady(probeCtx); err != nil {
+		t.Fatalf("expected WaitReady to fall back to UTC once the database is reachable, got: %v", err)
+	}
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT current_setting('timezone') AS tz"})
 	if output.Error != "" {
 		t.Fatalf("unexpected error: %s", output.Error)
 	}
+	if output.Rows[0]["tz"] != "UTC" {
+		t.Fatalf("expected UTC, got %v", output.Rows[0]["tz"])
+	}
+}
 
-	entries := parseLogLines(&buf)
-	if len(entries) == 0 {
-		t.Fatal("expected at least one log entry, got none")
+func TestWaitReady_TimesOut(t *testing.T) {
+	t.Parallel()
+	connStr := acquireTestDB(t)
+	u, err := url.Parse(connStr)
+	if err != nil {
+		t.Fatalf("failed to parse connStr: %v", err)
 	}
+	u.Host = startDelayedProxy(t, u.Host, time.Hour)
 
-	// Find the "query executed" log entry.
-	var queryLog map[string]interface{}
-	for _, entry := range entries {
-		if msg, ok := entry["message"].(string); ok && msg == "query executed" {
-			queryLog = entry
-			break
-		}
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, u.String(), defaultConfig(), testLogger())
+	if err != nil {
+		t.Fatalf("failed to create PostgresMcp: %v", err)
 	}
-	if queryLog == nil {
-		t.Fatalf("expected 'query executed' log entry, got entries: %v", entries)
+	defer p.Close(ctx)
+
+	probeCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	err = p.WaitReady(probeCtx)
+	if err == nil {
+		t.Fatal("expected WaitReady to fail when the database never becomes reachable")
+	}
+	if !strings.Contains(err.Error(), "database not ready after") {
+		t.Fatalf("expected 'database not ready after' in error, got: %v", err)
+	}
+}
+
+// keys returns the keys of a map for error messages.
+func keys(m map[string]interface{}) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
 	}
+	return ks
+}
+
+// logPassthroughBeforeHook passes queries through unchanged (for logging tests).
+type logPassthroughBeforeHook struct{}
+
+func (h *logPassthroughBeforeHook) Run(ctx context.Context, query string) (string, error) {
+	return query, nil
+}
+
+// logPassthroughAfterHook passes results through unchanged (for logging tests).
+type logPassthroughAfterHook struct{}
 
-	// Verify required fields.
-	sql, ok := queryLog["sql"].(string)
-	if !ok || sql == "" {
-		t.Fatalf("expected non-empty 'sql' field in log, got %v", queryLog["sql"])
+func (h *logPassthroughAfterHook) Run(ctx context.Context, result *pgmcp.QueryOutput) (*pgmcp.QueryOutput, error) {
+	return result, nil
+}
+
+func TestCancelAll(t *testing.T) {
+	t.Parallel()
+	p, _ := newTestInstance(t, defaultConfig())
+	ctx := context.Background()
+
+	const queries = 3
+	outputs := make(chan *pgmcp.QueryOutput, queries)
+	for i := 0; i < queries; i++ {
+		go func() { outputs <- p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT pg_sleep(30) AS cancel_all_sleep"}) }()
 	}
-	if !strings.Contains(sql, "SELECT id, name FROM log_test") {
-		t.Fatalf("expected sql to contain query text, got %q", sql)
+	running := func() string {
+		output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS n FROM pg_stat_activity WHERE query LIKE '%AS cancel_all_sleep' AND state = 'active' AND pid <> pg_backend_pid()"})
+		if output.Error != "" {
+			t.Fatalf("unexpected error: %s", output.Error)
+		}
+		return fmt.Sprint(output.Rows[0]["n"])
 	}
-
-	if _, ok := queryLog["duration"]; !ok {
-		t.Fatalf("expected 'duration' field in log, got keys: %v", keys(queryLog))
+	for deadline := time.Now().Add(10 * time.Second); running() != fmt.Sprint(queries); {
+		if time.Now().After(deadline) {
+			t.Fatal("slow queries did not start")
+		}
+		time.Sleep(20 * time.Millisecond)
 	}
 
-	rowCount, ok := queryLog["row_count"].(float64)
-	if !ok {
-		t.Fatalf("expected 'row_count' as number, got %T: %v", queryLog["row_count"], queryLog["row_count"])
+	start := time.Now()
+	if cancelled := p.CancelAll(); cancelled != queries {
+		t.Fatalf("expected %d cancelled queries, got %d", queries, cancelled)
+	}
+	for i := 0; i < queries; i++ {
+		select {
+		case output := <-outputs:
+			if !strings.Contains(output.Error, "cancel") {
+				t.Fatalf("expected a cancellation error, got %q", output.Error)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("query did not return promptly after CancelAll")
+		}
 	}
-	if int(rowCount) != 2 {
-		t.Fatalf("expected row_count=2, got %v", rowCount)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected queries to return promptly, took %s", elapsed)
 	}
 
-	if _, ok := queryLog["rows_affected"]; !ok {
-		t.Fatalf("expected 'rows_affected' field in log, got keys: %v", keys(queryLog))
+	// The cancel requests stop the statements on the server too.
+	for deadline := time.Now().Add(5 * time.Second); running() != "0"; {
+		if time.Now().After(deadline) {
+			t.Fatal("slow queries still running on the server after CancelAll")
+		}
+		time.Sleep(20 * time.Millisecond)
 	}
 
-	// Verify log level is "info".
-	if level, ok := queryLog["level"].(string); ok && level != "info" {
-		t.Fatalf("expected log level 'info', got %q", level)
+	// The pool stays open.
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 1 AS ok"})
+	if output.Error != "" {
+		t.Fatalf("expected queries after CancelAll to succeed, got %q", output.Error)
 	}
 }
 
-func TestQuery_LogsOptionalPipelineFields(t *testing.T) {
+func TestQuery_RegTypesAsNames(t *testing.T) {
 	t.Parallel()
-	ctx := context.Background()
-	connStr := acquireTestDB(t)
-
-	var buf bytes.Buffer
-	logger := zerolog.New(&buf)
-
-	// Configure hooks, sanitization, and timeout rules.
 	config := defaultConfig()
 	config.Protection.AllowDDL = true
-	config.DefaultHookTimeoutSeconds = 5
-	config.BeforeQueryHooks = []pgmcp.BeforeQueryHookEntry{
-		{Name: "passthrough", Hook: &logPassthroughBeforeHook{}},
+	p, connStr := newTestInstance(t, config)
+	setupTable(t, p, "CREATE TABLE reg_users (id int PRIMARY KEY, name text)")
+	ctx := context.Background()
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT 'reg_users'::regclass AS rel, 'int4'::regtype AS typ, 'pg_catalog'::regnamespace AS nsp, 'now'::regproc AS proc"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	config.AfterQueryHooks = []pgmcp.AfterQueryHookEntry{
-		{Name: "accept_all", Hook: &logPassthroughAfterHook{}},
+	expected := map[string]interface{}{"rel": "reg_users", "typ": "integer", "nsp": "pg_catalog", "proc": "now"}
+	if !reflect.DeepEqual(output.Rows[0], expected) {
+		t.Fatalf("expected %v, got %v", expected, output.Rows[0])
 	}
-	config.Sanitization = []pgmcp.SanitizationRule{
-		{Pattern: `\d{3}-\d{3}-\d{4}`, Replacement: "***-***-****"},
+
+	// OID columns stay numbers unless query.resolve_reg_types is set.
+	sql := "SELECT c.oid, c.relnamespace, a.atttypid, c.reltoastrelid, c.oid::int8 AS raw FROM pg_class c JOIN pg_attribute a ON a.attrelid = c.oid WHERE c.oid = 'reg_users'::regclass AND a.attname = 'id'"
+	output = p.Query(ctx, pgmcp.QueryInput{SQL: sql})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
 	}
-	config.Query.TimeoutRules = []pgmcp.TimeoutRule{
-		{Pattern: "log_pipeline_test", TimeoutSeconds: 25},
+	if _, ok := output.Rows[0]["oid"].(uint32); !ok {
+		t.Fatalf("expected a numeric oid, got %T %v", output.Rows[0]["oid"], output.Rows[0]["oid"])
 	}
 
-	p, err := pgmcp.New(ctx, connStr, config, logger)
+	config.Query.ResolveRegTypes = true
+	resolving, err := pgmcp.New(ctx, connStr, config, testLogger())
 	if err != nil {
-		t.Fatalf("failed to create instance: %v", err)
-	}
-	t.Cleanup(func() { p.Close(ctx) })
-
-	setupOutput := p.Query(ctx, pgmcp.QueryInput{SQL: "CREATE TABLE log_pipeline_test (id serial, phone text)"})
-	if setupOutput.Error != "" {
-		t.Fatalf("setup failed: %s", setupOutput.Error)
-	}
-	setupOutput = p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO log_pipeline_test (phone) VALUES ('555-123-4567')"})
-	if setupOutput.Error != "" {
-		t.Fatalf("insert failed: %s", setupOutput.Error)
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
 	}
-
-	buf.Reset()
-
-	output := p.Query(ctx, pgmcp.QueryInput{SQL: "SELECT * FROM log_pipeline_test"})
+	defer resolving.Close(ctx)
+	output = resolving.Query(ctx, pgmcp.QueryInput{SQL: sql})
 	if output.Error != "" {
 		t.Fatalf("unexpected error: %s", output.Error)
 	}
-
-	entries := parseLogLines(&buf)
-	var queryLog map[string]interface{}
-	for _, entry := range entries {
-		if msg, ok := entry["message"].(string); ok && msg == "query executed" {
-			queryLog = entry
-			break
-		}
-	}
-	if queryLog == nil {
-		t.Fatalf("expected 'query executed' log entry, got entries: %v", entries)
+	row := output.Rows[0]
+	if row["oid"] != "reg_users" || row["relnamespace"] != "public" || row["atttypid"] != "integer" {
+		t.Fatalf("expected catalog OIDs as names, got %v", row)
 	}
-
-	// Verify optional before_hooks field.
-	beforeHooks, ok := queryLog["before_hooks"].([]interface{})
-	if !ok {
-		t.Fatalf("expected 'before_hooks' as array, got %T: %v", queryLog["before_hooks"], queryLog["before_hooks"])
+	// The text column gives reg_users a toast table.
+	if toast, ok := row["reltoastrelid"].(string); ok && !strings.Contains(toast, "pg_toast") {
+		t.Fatalf("expected a toast table name, got %v", toast)
 	}
-	if len(beforeHooks) != 1 || beforeHooks[0] != "passthrough" {
-		t.Fatalf("expected before_hooks=[passthrough], got %v", beforeHooks)
+	// Computed columns are untouched.
+	if _, ok := row["raw"].(int64); !ok {
+		t.Fatalf("expected computed column to stay a number, got %T %v", row["raw"], row["raw"])
 	}
+}
 
-	// Verify optional after_hooks field.
-	afterHooks, ok := queryLog["after_hooks"].([]interface{})
-	if !ok {
-		t.Fatalf("expected 'after_hooks' as array, got %T: %v", queryLog["after_hooks"], queryLog["after_hooks"])
+// runDeadlockingInsert runs an INSERT through p that deadlocks with another session: the
+// INSERT takes advisory lock 2 and waits for lock 1, held by the other session, which
+// then waits for lock 2. The INSERT waits first, so its deadlock check runs first and it
+// is the victim. The other session commits once it gets lock 2.
+func runDeadlockingInsert(t *testing.T, p *pgmcp.PostgresMcp, connStr string) *pgmcp.QueryOutput {
+	t.Helper()
+	ctx := context.Background()
+	other, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
 	}
-	if len(afterHooks) != 1 || afterHooks[0] != "accept_all" {
-		t.Fatalf("expected after_hooks=[accept_all], got %v", afterHooks)
+	defer other.Close(ctx)
+	if _, err := other.Exec(ctx, "BEGIN"); err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	if _, err := other.Exec(ctx, "SELECT pg_advisory_xact_lock(1)"); err != nil {
+		t.Fatalf("failed to lock: %v", err)
 	}
 
-	// Verify optional timeout_rule field.
-	timeoutRule, ok := queryLog["timeout_rule"].(string)
-	if !ok || timeoutRule == "" {
-		t.Fatalf("expected non-empty 'timeout_rule' field, got %v", queryLog["timeout_rule"])
+	outputs := make(chan *pgmcp.QueryOutput, 1)
+	go func() {
+		outputs <- p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO deadlock_log SELECT 1 FROM (SELECT pg_advisory_xact_lock(2), pg_advisory_xact_lock(1)) AS locks"})
+	}()
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		var waiting bool
+		err := other.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_locks WHERE locktype = 'advisory' AND objid = 1 AND NOT granted AND database = (SELECT oid FROM pg_database WHERE datname = current_database()))").Scan(&waiting)
+		if err != nil {
+			t.Fatalf("failed to check locks: %v", err)
+		}
+		if waiting {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("INSERT never waited for the advisory lock")
+		}
+		time.Sleep(20 * time.Millisecond)
 	}
-	if timeoutRule != "log_pipeline_test" {
-		t.Fatalf("expected timeout_rule='log_pipeline_test', got %q", timeoutRule)
+	if _, err := other.Exec(ctx, "SELECT pg_advisory_xact_lock(2)"); err != nil {
+		t.Fatalf("expected the other session to get the lock once the INSERT was aborted, got %v", err)
 	}
-
-	// Verify optional sanitized field.
-	sanitizedVal, ok := queryLog["sanitized"].(bool)
-	if !ok || !sanitizedVal {
-		t.Fatalf("expected sanitized=true, got %v", queryLog["sanitized"])
+	if _, err := other.Exec(ctx, "COMMIT"); err != nil {
+		t.Fatalf("failed to commit: %v", err)
 	}
+	return <-outputs
 }
 
-func TestListTables_LogsExecution(t *testing.T) {
+func TestQuery_RetryOnDeadlock(t *testing.T) {
 	t.Parallel()
-	ctx := context.Background()
-	connStr := acquireTestDB(t)
-
-	var buf bytes.Buffer
-	logger := zerolog.New(&buf)
-
 	config := defaultConfig()
 	config.Protection.AllowDDL = true
-	p, err := pgmcp.New(ctx, connStr, config, logger)
-	if err != nil {
-		t.Fatalf("failed to create instance: %v", err)
-	}
-	t.Cleanup(func() { p.Close(ctx) })
+	p, connStr := newTestInstance(t, config)
+	setupTable(t, p, "CREATE TABLE deadlock_log (id int)")
 
-	setupOutput := p.Query(ctx, pgmcp.QueryInput{SQL: "CREATE TABLE lt_log_test (id serial PRIMARY KEY)"})
-	if setupOutput.Error != "" {
-		t.Fatalf("setup failed: %s", setupOutput.Error)
+	// Without retries the deadlock victim fails.
+	output := runDeadlockingInsert(t, p, connStr)
+	if !strings.Contains(output.Error, "deadlock detected") {
+		t.Fatalf("expected a deadlock error, got %q", output.Error)
 	}
 
-	buf.Reset()
-
-	_, err = p.ListTables(ctx, pgmcp.ListTablesInput{})
+	config.Query.RetryOnDeadlock = 2
+	retrying, err := pgmcp.New(context.Background(), connStr, config, testLogger())
 	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-
-	entries := parseLogLines(&buf)
-	var ltLog map[string]interface{}
-	for _, entry := range entries {
-		if msg, ok := entry["message"].(string); ok && msg == "ListTables executed" {
-			ltLog = entry
-			break
-		}
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
 	}
-	if ltLog == nil {
-		t.Fatalf("expected 'ListTables executed' log entry, got entries: %v", entries)
+	defer retrying.Close(context.Background())
+	output = runDeadlockingInsert(t, retrying, connStr)
+	if output.Error != "" {
+		t.Fatalf("expected the retried INSERT to succeed, got %q", output.Error)
 	}
-
-	if _, ok := ltLog["duration"]; !ok {
-		t.Fatalf("expected 'duration' field, got keys: %v", keys(ltLog))
+	if output.RowsAffected != 1 {
+		t.Fatalf("expected 1 row inserted, got %d", output.RowsAffected)
 	}
 
-	tableCount, ok := ltLog["table_count"].(float64)
-	if !ok {
-		t.Fatalf("expected 'table_count' as number, got %T: %v", ltLog["table_count"], ltLog["table_count"])
-	}
-	if int(tableCount) < 1 {
-		t.Fatalf("expected table_count >= 1, got %v", tableCount)
+	// The aborted attempts were rolled back: only the successful one inserted a row.
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT count(*) AS n FROM deadlock_log"})
+	if output.Error != "" || fmt.Sprint(output.Rows[0]["n"]) != "1" {
+		t.Fatalf("expected exactly one row, got %v err=%q", output.Rows, output.Error)
 	}
 }
 
-func TestDescribeTable_LogsExecution(t *testing.T) {
+func TestQuery_ReturnCommitInfo(t *testing.T) {
 	t.Parallel()
-	ctx := context.Background()
-	connStr := acquireTestDB(t)
-
-	var buf bytes.Buffer
-	logger := zerolog.New(&buf)
-
 	config := defaultConfig()
 	config.Protection.AllowDDL = true
-	p, err := pgmcp.New(ctx, connStr, config, logger)
-	if err != nil {
-		t.Fatalf("failed to create instance: %v", err)
-	}
-	t.Cleanup(func() { p.Close(ctx) })
+	config.Query.ReturnCommitInfo = true
+	p, _ := newTestInstance(t, config)
+	setupTable(t, p, "CREATE TABLE commit_info (id int)")
+	ctx := context.Background()
 
-	setupOutput := p.Query(ctx, pgmcp.QueryInput{SQL: "CREATE TABLE dt_log_test (id serial PRIMARY KEY, name text)"})
-	if setupOutput.Error != "" {
-		t.Fatalf("setup failed: %s", setupOutput.Error)
+	// parseLSN turns "X/Y" into a comparable position.
+	parseLSN := func(lsn string) uint64 {
+		t.Helper()
+		hi, lo, ok := strings.Cut(lsn, "/")
+		h, herr := strconv.ParseUint(hi, 16, 32)
+		l, lerr := strconv.ParseUint(lo, 16, 32)
+		if !ok || herr != nil || lerr != nil {
+			t.Fatalf("expected an LSN like 0/16B3748, got %q", lsn)
+		}
+		return h<<32 | l
 	}
 
-	buf.Reset()
-
-	_, err = p.DescribeTable(ctx, pgmcp.DescribeTableInput{Table: "dt_log_test"})
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+	first := p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO commit_info VALUES (1)"})
+	if first.Error != "" {
+		t.Fatalf("unexpected error: %s", first.Error)
+	}
+	if first.TxID <= 0 {
+		t.Fatalf("expected a transaction ID, got %d", first.TxID)
 	}
+	firstLSN := parseLSN(first.CommitLSN)
 
-	entries := parseLogLines(&buf)
-	var dtLog map[string]interface{}
-	for _, entry := range entries {
-		if msg, ok := entry["message"].(string); ok && msg == "DescribeTable executed" {
-			dtLog = entry
-			break
-		}
+	second := p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO commit_info VALUES (2)"})
+	if second.Error != "" {
+		t.Fatalf("unexpected error: %s", second.Error)
 	}
-	if dtLog == nil {
-		t.Fatalf("expected 'DescribeTable executed' log entry, got entries: %v", entries)
+	if second.TxID <= first.TxID || parseLSN(second.CommitLSN) <= firstLSN {
+		t.Fatalf("expected the later write to have a later txid and LSN, got %d %s then %d %s", first.TxID, first.CommitLSN, second.TxID, second.CommitLSN)
 	}
 
-	if _, ok := dtLog["duration"]; !ok {
-		t.Fatalf("expected 'duration' field, got keys: %v", keys(dtLog))
+	// The transaction ID is the one the write committed under.
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: fmt.Sprintf("SELECT txid_status(%d) AS status", first.TxID)})
+	if output.Error != "" || output.Rows[0]["status"] != "committed" {
+		t.Fatalf("expected txid %d to be committed, got %v err=%q", first.TxID, output.Rows, output.Error)
 	}
 
-	schema, ok := dtLog["schema"].(string)
-	if !ok || schema != "public" {
-		t.Fatalf("expected schema='public', got %v", dtLog["schema"])
+	// Reads, and writes that changed nothing, get neither.
+	for _, sql := range []string{"SELECT * FROM commit_info", "UPDATE commit_info SET id = 3 WHERE id = 99"} {
+		output := p.Query(ctx, pgmcp.QueryInput{SQL: sql})
+		if output.Error != "" {
+			t.Fatalf("unexpected error: %s", output.Error)
+		}
+		if output.TxID != 0 || output.CommitLSN != "" {
+			t.Fatalf("%s: expected no commit info, got txid %d lsn %q", sql, output.TxID, output.CommitLSN)
+		}
 	}
+}
 
-	table, ok := dtLog["table"].(string)
-	if !ok || table != "dt_log_test" {
-		t.Fatalf("expected table='dt_log_test', got %v", dtLog["table"])
-	}
+func TestQuery_ErrorDetailMode(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, connStr := newTestInstance(t, config)
+	setupTable(t, p, "CREATE TABLE detail_users (email text CONSTRAINT detail_users_email_key UNIQUE)")
+	setupTable(t, p, "INSERT INTO detail_users VALUES ('a@example.com')")
+	ctx := context.Background()
+	insert := pgmcp.QueryInput{SQL: "INSERT INTO detail_users VALUES ('a@example.com')"}
 
-	tableType, ok := dtLog["type"].(string)
-	if !ok || tableType != "table" {
-		t.Fatalf("expected type='table', got %v", dtLog["type"])
+	output := p.Query(ctx, insert)
+	if !strings.Contains(output.Error, `duplicate key value violates unique constraint "detail_users_email_key"`) {
+		t.Fatalf("expected the full error in full mode, got %q", output.Error)
 	}
 
-	columnCount, ok := dtLog["column_count"].(float64)
-	if !ok {
-		t.Fatalf("expected 'column_count' as number, got %T: %v", dtLog["column_count"], dtLog["column_count"])
-	}
-	if int(columnCount) != 2 {
-		t.Fatalf("expected column_count=2, got %v", columnCount)
+	config.ErrorDetailMode = pgmcp.ErrorDetailMinimal
+	config.ErrorPrompts = []pgmcp.ErrorPromptRule{{Pattern: "detail_users_email_key", Message: "That email is taken."}}
+	minimal, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
 	}
-}
-
-// keys returns the keys of a map for error messages.
-func keys(m map[string]interface{}) []string {
-	ks := make([]string, 0, len(m))
-	for k := range m {
-		ks = append(ks, k)
+	defer minimal.Close(ctx)
+	output = minimal.Query(ctx, insert)
+	expected := "database error (SQLSTATE 23505): a constraint was violated\n\nThat email is taken."
+	if output.Error != expected {
+		t.Fatalf("expected %q, got %q", expected, output.Error)
 	}
-	return ks
 }
 
-// logPassthroughBeforeHook passes queries through unchanged (for logging tests).
-type logPassthroughBeforeHook struct{}
+func TestQuery_AllowedQueryFingerprints(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, connStr := newTestInstance(t, config)
+	setupTable(t, p, "CREATE TABLE catalog_users (id int, name text)")
+	setupTable(t, p, "INSERT INTO catalog_users VALUES (1, 'alice'), (2, 'bob')")
+	ctx := context.Background()
 
-func (h *logPassthroughBeforeHook) Run(ctx context.Context, query string) (string, error) {
-	return query, nil
-}
+	fp, err := pgmcp.QueryFingerprint("SELECT name FROM catalog_users WHERE id = $1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config.Protection.AllowedQueryFingerprints = []string{fp}
+	gateway, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
+	}
+	defer gateway.Close(ctx)
 
-// logPassthroughAfterHook passes results through unchanged (for logging tests).
-type logPassthroughAfterHook struct{}
+	output := gateway.Query(ctx, pgmcp.QueryInput{SQL: "SELECT name FROM catalog_users WHERE id = 2"})
+	if output.Error != "" {
+		t.Fatalf("expected the approved query to run, got %q", output.Error)
+	}
+	if len(output.Rows) != 1 || output.Rows[0]["name"] != "bob" {
+		t.Fatalf("expected bob, got %v", output.Rows)
+	}
 
-func (h *logPassthroughAfterHook) Run(ctx context.Context, result *pgmcp.QueryOutput) (*pgmcp.QueryOutput, error) {
-	return result, nil
+	output = gateway.Query(ctx, pgmcp.QueryInput{SQL: "SELECT name FROM catalog_users"})
+	if !strings.Contains(output.Error, "query is not in the allowlist of approved queries") {
+		t.Fatalf("expected the unapproved query to be blocked, got %q", output.Error)
+	}
+	if output.ErrorKind != pgmcp.ErrorKindProtection {
+		t.Fatalf("expected error kind %q, got %q", pgmcp.ErrorKindProtection, output.ErrorKind)
+	}
 }
@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
@@ -44,6 +45,11 @@ func run(configPath string, input io.Reader, output io.Writer) error {
 	cfg.Connection.Port = p.promptPositiveInt("connection.port", cfg.Connection.Port, "must be > 0")
 	cfg.Connection.DBName = p.promptRequiredStringWithHint("connection.dbname", cfg.Connection.DBName, "required")
 	cfg.Connection.SSLMode = p.promptEnum("connection.sslmode", cfg.Connection.SSLMode, sslModes)
+	if cfg.Connection.SSLMode == "verify-ca" || cfg.Connection.SSLMode == "verify-full" {
+		cfg.Connection.SSLRootCert = p.promptStringWithHint("connection.sslrootcert", cfg.Connection.SSLRootCert, "path to CA certificate (PEM)")
+		cfg.Connection.SSLCert = p.promptStringWithHint("connection.sslcert", cfg.Connection.SSLCert, "path to client certificate (PEM), empty if not using client certs")
+		cfg.Connection.SSLKey = p.promptStringWithHint("connection.sslkey", cfg.Connection.SSLKey, "path to client private key (PEM), empty if not using client certs")
+	}
 
 	// Server
 	fmt.Fprintf(output, "\n=== Server ===\n")
@@ -81,29 +87,7 @@ func run(configPath string, input io.Reader, output io.Writer) error {
 
 	// Protection
 	fmt.Fprintf(output, "\n=== Protection ===\n")
-	cfg.Protection.AllowSet = p.promptBool("protection.allow_set", cfg.Protection.AllowSet)
-	cfg.Protection.AllowDrop = p.promptBool("protection.allow_drop", cfg.Protection.AllowDrop)
-	cfg.Protection.AllowTruncate = p.promptBool("protection.allow_truncate", cfg.Protection.AllowTruncate)
-	cfg.Protection.AllowDo = p.promptBool("protection.allow_do", cfg.Protection.AllowDo)
-	cfg.Protection.AllowCopyFrom = p.promptBool("protection.allow_copy_from", cfg.Protection.AllowCopyFrom)
-	cfg.Protection.AllowCopyTo = p.promptBool("protection.allow_copy_to", cfg.Protection.AllowCopyTo)
-	cfg.Protection.AllowCreateFunction = p.promptBool("protection.allow_create_function", cfg.Protection.AllowCreateFunction)
-	cfg.Protection.AllowPrepare = p.promptBool("protection.allow_prepare", cfg.Protection.AllowPrepare)
-	cfg.Protection.AllowDeleteWithoutWhere = p.promptBool("protection.allow_delete_without_where", cfg.Protection.AllowDeleteWithoutWhere)
-	cfg.Protection.AllowUpdateWithoutWhere = p.promptBool("protection.allow_update_without_where", cfg.Protection.AllowUpdateWithoutWhere)
-	cfg.Protection.AllowAlterSystem = p.promptBool("protection.allow_alter_system", cfg.Protection.AllowAlterSystem)
-	cfg.Protection.AllowMerge = p.promptBool("protection.allow_merge", cfg.Protection.AllowMerge)
-	cfg.Protection.AllowGrantRevoke = p.promptBool("protection.allow_grant_revoke", cfg.Protection.AllowGrantRevoke)
-	cfg.Protection.AllowManageRoles = p.promptBool("protection.allow_manage_roles", cfg.Protection.AllowManageRoles)
-	cfg.Protection.AllowCreateExtension = p.promptBool("protection.allow_create_extension", cfg.Protection.AllowCreateExtension)
-	cfg.Protection.AllowLockTable = p.promptBool("protection.allow_lock_table", cfg.Protection.AllowLockTable)
-	cfg.Protection.AllowListenNotify = p.promptBool("protection.allow_listen_notify", cfg.Protection.AllowListenNotify)
-	cfg.Protection.AllowMaintenance = p.promptBool("protection.allow_maintenance", cfg.Protection.AllowMaintenance)
-	cfg.Protection.AllowDDL = p.promptBool("protection.allow_ddl", cfg.Protection.AllowDDL)
-	cfg.Protection.AllowDiscard = p.promptBool("protection.allow_discard", cfg.Protection.AllowDiscard)
-	cfg.Protection.AllowComment = p.promptBool("protection.allow_comment", cfg.Protection.AllowComment)
-	cfg.Protection.AllowCreateTrigger = p.promptBool("protection.allow_create_trigger", cfg.Protection.AllowCreateTrigger)
-	cfg.Protection.AllowCreateRule = p.promptBool("protection.allow_create_rule", cfg.Protection.AllowCreateRule)
+	p.promptProtection(&cfg.Protection)
 
 	// Array fields
 	fmt.Fprintf(output, "\n=== Timeout Rules ===\n")
@@ -320,6 +304,27 @@ func (p *prompter) promptBool(field string, current bool) bool {
 	}
 }
 
+// promptProtection prompts for every rule in pgmcp.ProtectionRules, in config field order.
+// allow_merge_delete is only asked when MERGE is allowed.
+func (p *prompter) promptProtection(cfg *pgmcp.ProtectionConfig) {
+	fields := reflect.ValueOf(cfg).Elem()
+	for _, rule := range pgmcp.ProtectionRules() {
+		if rule.Field == "AllowMergeDelete" && !cfg.AllowMerge {
+			continue
+		}
+		field := fields.FieldByName(rule.Field)
+		if field.Kind() != reflect.Pointer {
+			field.SetBool(p.promptBool(rule.ConfigKey, field.Bool()))
+			continue
+		}
+		// AllowUpsert: nil means the default (allowed), kept unless changed.
+		current := field.IsNil() || field.Elem().Bool()
+		if value := p.promptBool(rule.ConfigKey, current); value != current || !field.IsNil() {
+			field.Set(reflect.ValueOf(&value))
+		}
+	}
+}
+
 func (p *prompter) promptDuration(field string, current string, hint string) string {
 	for {
 		fmt.Fprintf(p.output, "%s [%s] (%s: %q): ", field, hint, p.valueLabel(), current)
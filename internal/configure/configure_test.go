@@ -35,29 +35,29 @@ func validExistingConfig() *pgmcp.ServerConfig {
 
 // allEnterInputs returns enough empty lines to accept defaults for every prompt
 // in the wizard. Each empty line means "accept current/default value".
-// Count: 4 connection + 3 server + 3 logging + 5 pool + 5 query + 3 general + 23 protection + 5 array editors (c for each) = 51
+// Count: 4 connection + 3 server + 3 logging + 5 pool + 5 query + 3 general + 31 protection + 5 array editors (c for each) = 59
 //
 // Prompt index map:
 //
-//	0-3:   connection (host, port, dbname, sslmode)
+//	0-3:   connection (host, port, dbname, sslmode; verify-ca/verify-full inserts sslrootcert, sslcert, sslkey after 3)
 //	4-6:   server (port, health_check_enabled, health_check_path)
 //	7-9:   logging (level, format, output)
 //	10-14: pool (max_conns, min_conns, max_conn_lifetime, max_conn_idle_time, health_check_period)
 //	15-19: query (default_timeout, list_tables_timeout, describe_table_timeout, max_sql_length, max_result_length)
 //	20-22: general (read_only, timezone, default_hook_timeout)
-//	23-45: protection (23 bool fields)
-//	46-50: array editors (timeout_rules, error_prompts, sanitization, before_query hooks, after_query hooks)
+//	23-53: protection (one per pgmcp.ProtectionRules entry; allow_merge = y inserts allow_merge_delete after 35)
+//	54-58: array editors (timeout_rules, error_prompts, sanitization, before_query hooks, after_query hooks)
 func allEnterInputs(overrides map[int]string) string {
-	lines := make([]string, 51)
+	lines := make([]string, 59)
 	for i := range lines {
 		lines[i] = ""
 	}
-	// Array editors need "c" to continue (indices 46-50)
-	lines[46] = "c"
-	lines[47] = "c"
-	lines[48] = "c"
-	lines[49] = "c"
-	lines[50] = "c"
+	// Array editors need "c" to continue (indices 54-58)
+	lines[54] = "c"
+	lines[55] = "c"
+	lines[56] = "c"
+	lines[57] = "c"
+	lines[58] = "c"
 	for k, v := range overrides {
 		lines[k] = v
 	}
@@ -1383,3 +1383,127 @@ func TestPromptPositiveInt_RejectsEnterWhenCurrentNegative(t *testing.T) {
 func newScanner(input string) *bufio.Scanner {
 	return bufio.NewScanner(strings.NewReader(input))
 }
+
+func TestRun_NewConfig_VerifySSLModePromptsForCertPaths(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+
+	// Choosing verify-full (index 3) inserts sslrootcert, sslcert, sslkey prompts right after it.
+	lines := strings.Split(allEnterInputs(map[int]string{2: "testdb", 3: "verify-full"}), "\n")
+	certLines := []string{"/etc/pg/root.crt", "/etc/pg/client.crt", "/etc/pg/client.key"}
+	lines = append(lines[:4], append(certLines, lines[4:]...)...)
+	input := strings.Join(lines, "\n")
+	var output bytes.Buffer
+
+	err := run(configPath, strings.NewReader(input), &output)
+	if err != nil {
+		t.Fatalf("run() returned error: %v", err)
+	}
+
+	data, _ := os.ReadFile(configPath)
+	var cfg pgmcp.ServerConfig
+	json.Unmarshal(data, &cfg)
+
+	if cfg.Connection.SSLMode != "verify-full" {
+		t.Errorf("expected sslmode 'verify-full', got %q", cfg.Connection.SSLMode)
+	}
+	if cfg.Connection.SSLRootCert != "/etc/pg/root.crt" {
+		t.Errorf("expected sslrootcert '/etc/pg/root.crt', got %q", cfg.Connection.SSLRootCert)
+	}
+	if cfg.Connection.SSLCert != "/etc/pg/client.crt" {
+		t.Errorf("expected sslcert '/etc/pg/client.crt', got %q", cfg.Connection.SSLCert)
+	}
+	if cfg.Connection.SSLKey != "/etc/pg/client.key" {
+		t.Errorf("expected sslkey '/etc/pg/client.key', got %q", cfg.Connection.SSLKey)
+	}
+	// Remaining prompts stay aligned: server.port keeps its default.
+	if cfg.Server.Port != 8080 {
+		t.Errorf("expected server.port 8080, got %d", cfg.Server.Port)
+	}
+}
+
+func TestRun_NewConfig_AllowMergePromptsForMergeDelete(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+
+	// Enabling allow_merge (index 35) inserts the allow_merge_delete prompt right after it.
+	lines := strings.Split(allEnterInputs(map[int]string{2: "testdb", 35: "y"}), "\n")
+	lines = append(lines[:36], append([]string{"y"}, lines[36:]...)...)
+	input := strings.Join(lines, "\n")
+	var output bytes.Buffer
+
+	if err := run(configPath, strings.NewReader(input), &output); err != nil {
+		t.Fatalf("run() returned error: %v", err)
+	}
+
+	data, _ := os.ReadFile(configPath)
+	var cfg pgmcp.ServerConfig
+	json.Unmarshal(data, &cfg)
+
+	if !cfg.Protection.AllowMerge || !cfg.Protection.AllowMergeDelete {
+		t.Errorf("expected allow_merge and allow_merge_delete, got %v and %v", cfg.Protection.AllowMerge, cfg.Protection.AllowMergeDelete)
+	}
+	// Remaining prompts stay aligned: allow_grant_revoke keeps its default.
+	if cfg.Protection.AllowGrantRevoke {
+		t.Errorf("expected allow_grant_revoke to keep its default")
+	}
+}
+
+func TestRun_NewConfig_PromptsForEveryProtectionRule(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+
+	// allow_set_local (index 24), allow_upsert (index 49), block_comments (index 52).
+	input := allEnterInputs(map[int]string{2: "testdb", 24: "y", 49: "n", 52: "y"})
+	var output bytes.Buffer
+
+	if err := run(configPath, strings.NewReader(input), &output); err != nil {
+		t.Fatalf("run() returned error: %v", err)
+	}
+	for _, rule := range pgmcp.ProtectionRules() {
+		if rule.Field != "AllowMergeDelete" && !strings.Contains(output.String(), rule.ConfigKey+" (") {
+			t.Errorf("expected a prompt for %s", rule.ConfigKey)
+		}
+	}
+
+	data, _ := os.ReadFile(configPath)
+	var cfg pgmcp.ServerConfig
+	json.Unmarshal(data, &cfg)
+
+	if !cfg.Protection.AllowSetLocal {
+		t.Errorf("expected allow_set_local to be set")
+	}
+	if cfg.Protection.AllowUpsert == nil || *cfg.Protection.AllowUpsert {
+		t.Errorf("expected allow_upsert false, got %v", cfg.Protection.AllowUpsert)
+	}
+	if !cfg.Protection.BlockComments {
+		t.Errorf("expected block_comments to be set")
+	}
+	// Remaining prompts stay aligned: block_volatile_in_read_only keeps its default.
+	if cfg.Protection.BlockVolatileInReadOnly {
+		t.Errorf("expected block_volatile_in_read_only to keep its default")
+	}
+}
+
+func TestRun_NewConfig_NonVerifySSLModeSkipsCertPrompts(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+
+	input := allEnterInputs(map[int]string{2: "testdb", 3: "require"})
+	var output bytes.Buffer
+
+	if err := run(configPath, strings.NewReader(input), &output); err != nil {
+		t.Fatalf("run() returned error: %v", err)
+	}
+	if strings.Contains(output.String(), "connection.sslrootcert") {
+		t.Errorf("expected no sslrootcert prompt for sslmode 'require'")
+	}
+}
@@ -3,18 +3,21 @@ package errprompt
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 )
 
 // Rule is the error prompt matcher's own rule type.
 type Rule struct {
-	Pattern string
-	Message string
+	Pattern  string
+	Message  string
+	Priority int // higher is evaluated (and its message emitted) first; ties keep slice order
 }
 
 type compiledRule struct {
-	pattern *regexp.Regexp
-	message string
+	pattern  *regexp.Regexp
+	message  string
+	priority int
 }
 
 // Matcher checks error messages against patterns and returns guidance prompts.
@@ -30,12 +33,13 @@ func NewMatcher(rules []Rule) (*Matcher, error) {
 		if err != nil {
 			return nil, fmt.Errorf("errprompt: invalid regex pattern %q: %v", r.Pattern, err)
 		}
-		compiled[i] = compiledRule{pattern: re, message: r.Message}
+		compiled[i] = compiledRule{pattern: re, message: r.Message, priority: r.Priority}
 	}
+	sort.SliceStable(compiled, func(i, j int) bool { return compiled[i].priority > compiled[j].priority })
 	return &Matcher{rules: compiled}, nil
 }
 
-// Match checks error message against all rules (top to bottom).
+// Match checks error message against all rules (by descending Priority, then top to bottom).
 // Returns all matching prompt messages joined with newline separators.
 // Returns empty string if no match.
 func (m *Matcher) Match(errMsg string) string {
@@ -70,6 +70,26 @@ func TestMultipleMatches(t *testing.T) {
 	}
 }
 
+func TestMultipleMatches_PriorityOrder(t *testing.T) {
+	t.Parallel()
+	m, err := NewMatcher([]Rule{
+		{Pattern: `(?i)permission denied`, Message: "Check your privileges."},
+		{Pattern: `(?i)denied.*table`, Message: "Verify table access grants.", Priority: 1},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := m.Match("permission denied for table users")
+	expected := "Verify table access grants.\nCheck your privileges."
+	if got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	patterns := m.MatchedPatterns("permission denied for table users")
+	if len(patterns) != 2 || patterns[0] != `(?i)denied.*table` || patterns[1] != `(?i)permission denied` {
+		t.Fatalf("expected patterns in priority order, got %v", patterns)
+	}
+}
+
 func TestEmptyRules(t *testing.T) {
 	t.Parallel()
 	m, err := NewMatcher([]Rule{})
@@ -0,0 +1,58 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Budget tracks the aggregate wall-clock time all hooks of a single query may consume.
+// Per-hook timeouts are clamped to the remaining budget. A nil *Budget means unlimited.
+// A Budget is scoped to one query and is not safe for concurrent use.
+type Budget struct {
+	total     time.Duration
+	remaining time.Duration
+}
+
+// NewBudget creates a Budget allowing total time across all hooks.
+func NewBudget(total time.Duration) *Budget {
+	return &Budget{total: total, remaining: total}
+}
+
+// Clamp returns the effective timeout for the next hook: the smaller of timeout and the
+// remaining budget. clamped is true when the budget, not the hook's own timeout, is the limit.
+func (b *Budget) Clamp(timeout time.Duration) (effective time.Duration, clamped bool) {
+	if b == nil || timeout <= b.remaining {
+		return timeout, false
+	}
+	return b.remaining, true
+}
+
+// Spend deducts time a hook actually took from the remaining budget.
+func (b *Budget) Spend(d time.Duration) {
+	if b == nil {
+		return
+	}
+	b.remaining -= d
+	if b.remaining < 0 {
+		b.remaining = 0
+	}
+}
+
+// Err returns the error reported when the budget is exhausted.
+func (b *Budget) Err() error {
+	return fmt.Errorf("total hook time budget exceeded (budget: %s)", b.total)
+}
+
+type budgetKey struct{}
+
+// WithBudget returns a context carrying the given hook time budget.
+func WithBudget(ctx context.Context, b *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+// BudgetFromContext returns the hook time budget carried by ctx, or nil if none.
+func BudgetFromContext(ctx context.Context) *Budget {
+	b, _ := ctx.Value(budgetKey{}).(*Budget)
+	return b
+}
@@ -176,8 +176,15 @@ func (r *Runner) RunAfterQuery(ctx context.Context, resultJSON string) (string,
 }
 
 func (r *Runner) executeHook(ctx context.Context, hook compiledHook, input string) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(ctx, hook.timeout)
+	budget := BudgetFromContext(ctx)
+	timeout, clamped := budget.Clamp(hook.timeout)
+	if timeout <= 0 {
+		return nil, budget.Err()
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
+	start := time.Now()
+	defer func() { budget.Spend(time.Since(start)) }()
 
 	// Command and args are passed separately — no shell interpretation.
 	// exec.Command(name, args...) executes the binary directly.
@@ -197,6 +204,9 @@ func (r *Runner) executeHook(ctx context.Context, hook compiledHook, input strin
 		// Hooks are critical guardrails — any failure stops the pipeline.
 		// This covers: non-zero exit code, crash, timeout (context deadline exceeded).
 		if ctx.Err() == context.DeadlineExceeded {
+			if clamped {
+				return nil, budget.Err()
+			}
 			return nil, fmt.Errorf("hook timed out: %s", hook.command)
 		}
 		return nil, fmt.Errorf("hook failed (command: %s): %w", hook.command, err)
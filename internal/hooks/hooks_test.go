@@ -674,3 +674,84 @@ func TestBeforeQuery_NoShellInjection(t *testing.T) {
 		t.Fatalf("expected shell metacharacters to be treated as literals.\nexpected: %q\ngot:      %q", expected, result)
 	}
 }
+
+// --- Total Budget Tests ---
+
+func TestBeforeQuery_TotalBudgetExceeded(t *testing.T) {
+	t.Parallel()
+	// Each hook sleeps 0.4s, well under its 5s timeout; three of them exceed the 1s budget.
+	r, err := NewRunner(Config{
+		DefaultTimeout: 5 * time.Second,
+		BeforeQuery: []HookEntry{
+			{Pattern: ".*", Command: hookScript("sleep_then_accept.sh"), Args: []string{"0.4"}},
+			{Pattern: ".*", Command: hookScript("sleep_then_accept.sh"), Args: []string{"0.4"}},
+			{Pattern: ".*", Command: hookScript("sleep_then_accept.sh"), Args: []string{"0.4"}},
+		},
+	}, testLogger())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := WithBudget(context.Background(), NewBudget(1*time.Second))
+	_, executed, err := r.RunBeforeQuery(ctx, "SELECT 1")
+	if err == nil {
+		t.Fatal("expected budget error")
+	}
+	expected := "before_query hook error: total hook time budget exceeded (budget: 1s)"
+	if err.Error() != expected {
+		t.Fatalf("expected %q, got %q", expected, err.Error())
+	}
+	if len(executed) != 3 {
+		t.Fatalf("expected 3 executed hooks, got %d", len(executed))
+	}
+}
+
+func TestAfterQuery_TotalBudgetSharedWithBeforeQuery(t *testing.T) {
+	t.Parallel()
+	r, err := NewRunner(Config{
+		DefaultTimeout: 5 * time.Second,
+		BeforeQuery: []HookEntry{
+			{Pattern: ".*", Command: hookScript("sleep_then_accept.sh"), Args: []string{"0.6"}},
+		},
+		AfterQuery: []HookEntry{
+			{Pattern: ".*", Command: hookScript("sleep_then_accept.sh"), Args: []string{"0.6"}},
+		},
+	}, testLogger())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := WithBudget(context.Background(), NewBudget(1*time.Second))
+	if _, _, err := r.RunBeforeQuery(ctx, "SELECT 1"); err != nil {
+		t.Fatalf("unexpected before_query error: %v", err)
+	}
+	_, _, err = r.RunAfterQuery(ctx, `{"columns":[],"rows":[]}`)
+	if err == nil {
+		t.Fatal("expected budget error")
+	}
+	expected := "after_query hook error: total hook time budget exceeded (budget: 1s)"
+	if err.Error() != expected {
+		t.Fatalf("expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestBudget_Clamp(t *testing.T) {
+	t.Parallel()
+	var unlimited *Budget
+	if d, clamped := unlimited.Clamp(3 * time.Second); d != 3*time.Second || clamped {
+		t.Fatalf("nil budget: expected (3s, false), got (%s, %v)", d, clamped)
+	}
+
+	b := NewBudget(2 * time.Second)
+	if d, clamped := b.Clamp(1 * time.Second); d != 1*time.Second || clamped {
+		t.Fatalf("expected (1s, false), got (%s, %v)", d, clamped)
+	}
+	b.Spend(1500 * time.Millisecond)
+	if d, clamped := b.Clamp(1 * time.Second); d != 500*time.Millisecond || !clamped {
+		t.Fatalf("expected (500ms, true), got (%s, %v)", d, clamped)
+	}
+	b.Spend(1 * time.Second)
+	if d, clamped := b.Clamp(1 * time.Second); d != 0 || !clamped {
+		t.Fatalf("expected (0s, true), got (%s, %v)", d, clamped)
+	}
+}
@@ -1,14 +1,20 @@
 package protection
 
 import (
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 
 	pg_query "github.com/pganalyze/pg_query_go/v6"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // Config is the protection checker's own config type.
 type Config struct {
 	AllowSet                bool
+	AllowSetLocal           bool // SET LOCAL without AllowSet, except for isServerManagedVar settings
 	AllowDrop               bool
 	AllowTruncate           bool
 	AllowDo                 bool
@@ -20,6 +26,8 @@ type Config struct {
 	AllowUpdateWithoutWhere bool
 	AllowAlterSystem        bool
 	AllowMerge              bool
+	AllowMergeDelete        bool // MERGE ... THEN DELETE; only checked when AllowMerge is set
+	BlockUpsert             bool // INSERT ... ON CONFLICT (DO UPDATE or DO NOTHING); inverted so the zero value allows upserts
 	AllowGrantRevoke        bool
 	AllowManageRoles        bool
 	AllowCreateExtension    bool
@@ -31,25 +39,160 @@ type Config struct {
 	AllowComment            bool
 	AllowCreateTrigger      bool
 	AllowCreateRule         bool
+	AllowTempTables         bool // CREATE TEMP TABLE without AllowDDL; CREATE TEMP TABLE AS only outside ReadOnly
 	ReadOnly                bool
+	// LockSessionRole blocks agent-issued role changes (SET ROLE, RESET ROLE,
+	// SET SESSION AUTHORIZATION, and set_config('role' or 'session_authorization', ...) or
+	// set_config with a non-constant name) regardless of AllowSet. Enabled when the
+	// server enforces a session role at connection checkout.
+	LockSessionRole bool
+	// MaxInListItems rejects `x IN (...)` lists with more items than this. 0 means unlimited.
+	MaxInListItems int
+	// MaxValuesRows rejects VALUES lists with more rows than this. 0 means unlimited.
+	MaxValuesRows int
+	// BlockExplainAnalyze rejects EXPLAIN ANALYZE (which executes the statement) even for
+	// otherwise-allowed statements; plain EXPLAIN is unaffected.
+	BlockExplainAnalyze bool
+	// MaxIdentifierLength rejects identifiers longer than this many bytes, which Postgres
+	// would otherwise silently truncate. 0 means DefaultMaxIdentifierLength.
+	MaxIdentifierLength int
+	// MaxStatementCandidates rejects SQL with more than this many semicolons before it is
+	// scanned or parsed, as a cheap size limit on raw input. Semicolons in strings and
+	// comments are counted too, so this can reject a valid single statement. 0 means no limit.
+	MaxStatementCandidates int
+	// AllowedExtensions, when non-empty, restricts CREATE EXTENSION (with AllowCreateExtension)
+	// to these extension names.
+	AllowedExtensions []string
+	// BlockRegexOperators rejects the POSIX regex operators (~, ~*, !~, !~*, also with
+	// ANY/ALL), the regexp_* functions, the functions behind the operators (textregexeq,
+	// ...) and the regex form of substring() anywhere in the statement.
+	BlockRegexOperators bool
+	// BlockSimilarity rejects SIMILAR TO, the SIMILAR form of substring(), and pg_trgm
+	// similarity functions and operators.
+	BlockSimilarity bool
+	// BlockComments rejects SQL containing -- or /* */ comments, found with the scanner so
+	// comment markers inside string literals and quoted identifiers are not matched.
+	BlockComments bool
+	// AllowedQueryFingerprints, when non-empty, rejects any statement whose pg_query
+	// fingerprint (see Fingerprint) is not listed. Other rules still apply to listed ones.
+	AllowedQueryFingerprints []string
+	// TreatAsWriteFunctions lists functions, as name or schema.name, that write even when
+	// called from a SELECT. In ReadOnly mode a statement calling one is rejected; see also
+	// CallsWriteFunction. Matching is case-insensitive; a name entry matches the function in
+	// any schema, and a schema.name entry also matches unqualified calls of that name.
+	TreatAsWriteFunctions []string
 }
 
+// DefaultMaxIdentifierLength is Postgres's identifier limit (NAMEDATALEN - 1) in a default build.
+const DefaultMaxIdentifierLength = 63
+
+// ParseError is returned by Check when pg_query cannot parse the SQL, so no rule could be
+// evaluated. Postgres itself may still accept the statement.
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string { return "SQL parse error: " + e.Err.Error() }
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
 // Checker validates SQL statements against protection rules.
 type Checker struct {
-	config Config
+	config         Config
+	fingerprints   map[string]bool // AllowedQueryFingerprints; nil when empty
+	writeFunctions map[string]bool // TreatAsWriteFunctions, lowercased; nil when empty
+	writeFuncNames map[string]bool // the function names of writeFunctions, without schema
 }
 
 // NewChecker creates a new Checker with the given config.
 func NewChecker(config Config) *Checker {
-	return &Checker{config: config}
+	if config.MaxIdentifierLength == 0 {
+		config.MaxIdentifierLength = DefaultMaxIdentifierLength
+	}
+	c := &Checker{config: config}
+	for _, fp := range config.AllowedQueryFingerprints {
+		if c.fingerprints == nil {
+			c.fingerprints = map[string]bool{}
+		}
+		c.fingerprints[strings.ToLower(fp)] = true
+	}
+	for _, fn := range config.TreatAsWriteFunctions {
+		if c.writeFunctions == nil {
+			c.writeFunctions = map[string]bool{}
+			c.writeFuncNames = map[string]bool{}
+		}
+		fn = strings.ToLower(fn)
+		c.writeFunctions[fn] = true
+		c.writeFuncNames[fn[strings.LastIndex(fn, ".")+1:]] = true
+	}
+	return c
+}
+
+// CallsWriteFunction reports whether sql calls a function listed in
+// TreatAsWriteFunctions, so a SELECT calling it can be run and committed as a write.
+// Returns false if sql does not parse.
+func (c *Checker) CallsWriteFunction(sql string) bool {
+	if c.writeFunctions == nil {
+		return false
+	}
+	result, err := pg_query.Parse(sql)
+	if err != nil {
+		return false
+	}
+	for _, rawStmt := range result.Stmts {
+		if c.writeFunctionCall(rawStmt.Stmt) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFunctionCall returns the name, lowercased as written, of the first function listed in
+// TreatAsWriteFunctions that node calls, or "" if none.
+func (c *Checker) writeFunctionCall(node *pg_query.Node) string {
+	var found string
+	Walk(node.ProtoReflect(), func(m protoreflect.Message) error {
+		call, ok := m.Interface().(*pg_query.FuncCall)
+		if !ok || len(call.Funcname) == 0 {
+			return nil
+		}
+		parts := make([]string, len(call.Funcname))
+		for i, part := range call.Funcname {
+			parts[i] = strings.ToLower(part.GetString_().GetSval())
+		}
+		name, fn := strings.Join(parts, "."), parts[len(parts)-1]
+		// An unqualified entry matches the function in any schema. Without the search_path,
+		// an unqualified call may resolve to any listed schema.function of that name.
+		if c.writeFunctions[name] || c.writeFunctions[fn] || (len(parts) == 1 && c.writeFuncNames[fn]) {
+			found = name
+			return StopWalk
+		}
+		return nil
+	})
+	return found
+}
+
+// Fingerprint returns the pg_query fingerprint of sql: 16 hex digits identifying the
+// statement's structure. Literal values, parameters ($1), the number of items in an IN
+// list, comments, whitespace, and keyword case do not change it.
+func Fingerprint(sql string) (string, error) {
+	fp, err := pg_query.Fingerprint(sql)
+	if err != nil {
+		return "", &ParseError{Err: err}
+	}
+	return fp, nil
 }
 
 // Check parses SQL with pg_query_go and walks the AST.
 // Returns nil if allowed, descriptive error if blocked.
 func (c *Checker) Check(sql string) error {
+	if err := c.checkRawInput(sql); err != nil {
+		return err
+	}
+
 	result, err := pg_query.Parse(sql)
 	if err != nil {
-		return fmt.Errorf("SQL parse error: %w", err)
+		return &ParseError{Err: err}
 	}
 
 	if len(result.Stmts) == 0 {
@@ -60,14 +203,386 @@ func (c *Checker) Check(sql string) error {
 		return fmt.Errorf("multi-statement queries are not allowed: found %d statements", len(result.Stmts))
 	}
 
+	if c.fingerprints != nil {
+		fp, err := Fingerprint(sql)
+		if err != nil {
+			return err
+		}
+		if !c.fingerprints[fp] {
+			return fmt.Errorf("query is not in the allowlist of approved queries (fingerprint %s): only the approved queries may run, with any literal values, so use one of those instead of writing a new query", fp)
+		}
+	}
+
 	for _, rawStmt := range result.Stmts {
 		if err := c.checkNode(rawStmt.Stmt); err != nil {
 			return err
 		}
+		if c.config.MaxInListItems > 0 || c.config.MaxValuesRows > 0 {
+			if err := Walk(rawStmt.Stmt.ProtoReflect(), c.checkListSizes); err != nil {
+				return err
+			}
+		}
+		if c.config.ReadOnly && c.writeFunctions != nil {
+			if name := c.writeFunctionCall(rawStmt.Stmt); name != "" {
+				return fmt.Errorf("function %s() is not allowed in read-only mode: it is configured as a function that writes (treat_as_write_functions)", name)
+			}
+		}
+		if c.config.BlockRegexOperators || c.config.BlockSimilarity {
+			if err := Walk(rawStmt.Stmt.ProtoReflect(), c.checkPatternMatching); err != nil {
+				return err
+			}
+		}
+		if c.config.LockSessionRole {
+			if err := Walk(rawStmt.Stmt.ProtoReflect(), checkRoleSetConfig); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkRoleSetConfig enforces LockSessionRole on set_config() calls, which change a setting
+// from any expression just like SET does. The setting name must be a string constant, so
+// a computed name cannot hide a role change.
+func checkRoleSetConfig(m protoreflect.Message) error {
+	call, ok := m.Interface().(*pg_query.FuncCall)
+	if !ok || len(call.Funcname) == 0 || !strings.EqualFold(call.Funcname[len(call.Funcname)-1].GetString_().GetSval(), "set_config") {
+		return nil
+	}
+	if len(call.Args) == 0 {
+		return nil
+	}
+	name := call.Args[0].GetAConst().GetSval()
+	if name == nil {
+		return fmt.Errorf("set_config() with a non-constant setting name is not allowed: session role is enforced by server configuration, so pass the setting name as a string literal")
+	}
+	if isRoleVar(name.Sval) {
+		return fmt.Errorf("set_config('%s', ...) is not allowed: session role is enforced by server configuration", name.Sval)
+	}
+	return nil
+}
+
+// explainAnalyzeEnabled reports whether EXPLAIN options turn on ANALYZE, either as
+// EXPLAIN ANALYZE or EXPLAIN (ANALYZE [true|on|1]).
+func explainAnalyzeEnabled(options []*pg_query.Node) bool {
+	for _, opt := range options {
+		def := opt.GetDefElem()
+		if def != nil && strings.EqualFold(def.Defname, "analyze") {
+			return ExplainOptionEnabled(def)
+		}
+	}
+	return false
+}
+
+// ExplainOptionEnabled reports whether an EXPLAIN option is turned on: given without a
+// value, or with any value other than false/off/0/no (so FORMAT JSON counts as on).
+func ExplainOptionEnabled(def *pg_query.DefElem) bool {
+	if def.Arg == nil {
+		return true
+	}
+	switch arg := def.Arg.Node.(type) {
+	case *pg_query.Node_String_:
+		switch strings.ToLower(arg.String_.Sval) {
+		case "false", "off", "0", "no":
+			return false
+		}
+		return true
+	case *pg_query.Node_Boolean:
+		return arg.Boolean.Boolval
+	case *pg_query.Node_Integer:
+		return arg.Integer.Ival != 0
+	}
+	return true
+}
+
+// checkRawInput is a cheap guard run before parsing: it rejects null bytes (which the C
+// parser would treat as end of input), over-long identifiers, and, with BlockComments,
+// comments, using only the scanner.
+func (c *Checker) checkRawInput(sql string) error {
+	if i := strings.IndexByte(sql, 0); i >= 0 {
+		return fmt.Errorf("SQL contains a null byte at offset %d: remove it and retry", i)
+	}
+	// Counted before scanning so abusive input is never tokenized or parsed. The parser
+	// enforces a single statement; this only bounds the raw input.
+	if max := c.config.MaxStatementCandidates; max > 0 {
+		if n := strings.Count(sql, ";"); n > max {
+			return fmt.Errorf("SQL exceeds the raw input limit: found %d semicolons (including any in string literals and comments), more than max_statement_candidates (%d)", n, max)
+		}
+	}
+	scan, err := pg_query.Scan(sql)
+	if err != nil {
+		return nil // leave the error to the parser, which reports it with more context
+	}
+	for i, tok := range scan.Tokens {
+		if c.config.BlockComments && (tok.Token == pg_query.Token_SQL_COMMENT || tok.Token == pg_query.Token_C_COMMENT) {
+			return fmt.Errorf("SQL comments are not allowed: comments can hide intent from reviewers and log readers; remove the comment at offset %d and retry", tok.Start)
+		}
+		var ident string
+		switch tok.Token {
+		case pg_query.Token_IDENT:
+			ident = sql[tok.Start:tok.End]
+			if len(ident) >= 2 && ident[0] == '"' {
+				ident = strings.ReplaceAll(ident[1:len(ident)-1], `""`, `"`)
+			}
+		case pg_query.Token_UIDENT:
+			// U&"d\0061t", optionally followed by UESCAPE 'c' choosing the escape character.
+			escape := byte('\\')
+			if i+2 < len(scan.Tokens) && scan.Tokens[i+1].Token == pg_query.Token_UESCAPE {
+				if lit := sql[scan.Tokens[i+2].Start:scan.Tokens[i+2].End]; len(lit) == 3 {
+					escape = lit[1]
+				}
+			}
+			ident = decodeUnicodeIdent(strings.ReplaceAll(sql[tok.Start+3:tok.End-1], `""`, `"`), escape)
+		default:
+			continue
+		}
+		if len(ident) > c.config.MaxIdentifierLength {
+			return fmt.Errorf("identifier %q is %d bytes, exceeding the maximum of %d bytes: Postgres would silently truncate it, so use a shorter name", ident, len(ident), c.config.MaxIdentifierLength)
+		}
+	}
+	return nil
+}
+
+// decodeUnicodeIdent decodes the escapes of a U&"..." identifier body: escape followed by
+// four hex digits, escape + and six hex digits, or a doubled escape. Invalid escapes are
+// kept as written and left to the parser to report.
+func decodeUnicodeIdent(body string, escape byte) string {
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] != escape || i+1 >= len(body) {
+			b.WriteByte(body[i])
+			continue
+		}
+		if body[i+1] == escape {
+			b.WriteByte(escape)
+			i++
+			continue
+		}
+		digits, start := 4, i+1
+		if body[i+1] == '+' {
+			digits, start = 6, i+2
+		}
+		if start+digits <= len(body) {
+			if r, err := strconv.ParseUint(body[start:start+digits], 16, 32); err == nil {
+				b.WriteRune(rune(r))
+				i = start + digits - 1
+				continue
+			}
+		}
+		b.WriteByte(body[i])
+	}
+	return b.String()
+}
+
+// checkListSizes enforces MaxInListItems and MaxValuesRows on a single AST node.
+// It is applied to every node in the tree, so lists in subqueries and CTEs are covered.
+func (c *Checker) checkListSizes(m protoreflect.Message) error {
+	switch n := m.Interface().(type) {
+	case *pg_query.A_Expr:
+		if c.config.MaxInListItems > 0 && n.Kind == pg_query.A_Expr_Kind_AEXPR_IN {
+			if list, ok := n.Rexpr.GetNode().(*pg_query.Node_List); ok && len(list.List.Items) > c.config.MaxInListItems {
+				return fmt.Errorf("IN list with %d items exceeds maximum of %d items: use a JOIN against a table or a subquery instead", len(list.List.Items), c.config.MaxInListItems)
+			}
+		}
+	case *pg_query.SelectStmt:
+		if c.config.MaxValuesRows > 0 && len(n.ValuesLists) > c.config.MaxValuesRows {
+			return fmt.Errorf("VALUES list with %d rows exceeds maximum of %d rows: split the statement into smaller batches", len(n.ValuesLists), c.config.MaxValuesRows)
+		}
+	}
+	return nil
+}
+
+// regexOperators are the POSIX regular expression match operators.
+var regexOperators = map[string]bool{"~": true, "~*": true, "!~": true, "!~*": true}
+
+// regexFunctions are the functions implementing the regex operators, callable directly.
+var regexFunctions = map[string]bool{
+	"textregexeq": true, "textregexne": true, "texticregexeq": true, "texticregexne": true,
+	"nameregexeq": true, "nameregexne": true, "nameicregexeq": true, "nameicregexne": true,
+	"bpcharregexeq": true, "bpcharregexne": true, "bpcharicregexeq": true, "bpcharicregexne": true,
+}
+
+// similarFunctions translate a SIMILAR TO pattern into a regex.
+var similarFunctions = map[string]bool{"similar_escape": true, "similar_to_escape": true}
+
+// trigramOperators are pg_trgm's similarity operators. % and <-> are left out because
+// they are also modulo and geometric/vector distance.
+var trigramOperators = map[string]bool{
+	"<%": true, "%>": true, "<<%": true, "%>>": true,
+	"<<->": true, "<->>": true, "<<<->": true, "<->>>": true,
+}
+
+// trigramFunctions are pg_trgm's similarity functions.
+var trigramFunctions = map[string]bool{
+	"similarity": true, "word_similarity": true, "strict_word_similarity": true,
+}
+
+// checkPatternMatching enforces BlockRegexOperators and BlockSimilarity on a single AST
+// node. LIKE and ILIKE are always allowed.
+func (c *Checker) checkPatternMatching(m protoreflect.Message) error {
+	switch n := m.Interface().(type) {
+	case *pg_query.A_Expr:
+		if n.Kind == pg_query.A_Expr_Kind_AEXPR_SIMILAR {
+			if c.config.BlockSimilarity {
+				return fmt.Errorf("SIMILAR TO is not allowed: cannot use ordinary indexes and can scan whole tables at high CPU cost; use LIKE or ILIKE instead")
+			}
+			return nil
+		}
+		// Prefix operators have no left operand: ~5 is bitwise NOT, not a regex match.
+		// col ~ ANY(array) and col !~ ALL(array) apply the operator to each element.
+		switch n.Kind {
+		case pg_query.A_Expr_Kind_AEXPR_OP, pg_query.A_Expr_Kind_AEXPR_OP_ANY, pg_query.A_Expr_Kind_AEXPR_OP_ALL:
+		default:
+			return nil
+		}
+		if len(n.Name) == 0 || n.Lexpr == nil {
+			return nil
+		}
+		// OPERATOR(pg_catalog.~) puts the schema first; the operator is the last element.
+		op := n.Name[len(n.Name)-1].GetString_().GetSval()
+		if c.config.BlockRegexOperators && regexOperators[op] {
+			return fmt.Errorf("regular expression operator %s is not allowed: cannot use ordinary indexes and can scan whole tables at high CPU cost; use LIKE or ILIKE instead", op)
+		}
+		if c.config.BlockSimilarity && trigramOperators[op] {
+			return fmt.Errorf("trigram similarity operator %s is not allowed: cannot use ordinary indexes and can scan whole tables at high CPU cost", op)
+		}
+	case *pg_query.SubLink:
+		// col ~ ANY (SELECT ...) carries the operator on the sublink.
+		if len(n.OperName) == 0 {
+			return nil
+		}
+		op := n.OperName[len(n.OperName)-1].GetString_().GetSval()
+		if c.config.BlockRegexOperators && regexOperators[op] {
+			return fmt.Errorf("regular expression operator %s is not allowed: cannot use ordinary indexes and can scan whole tables at high CPU cost; use LIKE or ILIKE instead", op)
+		}
+		if c.config.BlockSimilarity && trigramOperators[op] {
+			return fmt.Errorf("trigram similarity operator %s is not allowed: cannot use ordinary indexes and can scan whole tables at high CPU cost", op)
+		}
+	case *pg_query.FuncCall:
+		if len(n.Funcname) == 0 {
+			return nil
+		}
+		name := strings.ToLower(n.Funcname[len(n.Funcname)-1].GetString_().GetSval())
+		if c.config.BlockRegexOperators && (strings.HasPrefix(name, "regexp_") || regexFunctions[name]) {
+			return fmt.Errorf("regular expression function %s() is not allowed: cannot use ordinary indexes and can scan whole tables at high CPU cost; use LIKE or ILIKE instead", name)
+		}
+		if c.config.BlockSimilarity && trigramFunctions[name] {
+			return fmt.Errorf("trigram similarity function %s() is not allowed: cannot use ordinary indexes and can scan whole tables at high CPU cost", name)
+		}
+		if c.config.BlockSimilarity && similarFunctions[name] {
+			return fmt.Errorf("SIMILAR TO pattern function %s() is not allowed: cannot use ordinary indexes and can scan whole tables at high CPU cost; use LIKE or ILIKE instead", name)
+		}
+		if name == "substring" && hasPatternArgs(n.Args) {
+			// substring(x FROM pattern) is substring(x, pattern), a POSIX regex match;
+			// substring(x SIMILAR pattern ESCAPE e) is substring(x, pattern, e).
+			if len(n.Args) == 2 && c.config.BlockRegexOperators {
+				return fmt.Errorf("substring() with a regular expression pattern is not allowed: cannot use ordinary indexes and can scan whole tables at high CPU cost; use integer start and count arguments (cast columns with ::int)")
+			}
+			if len(n.Args) == 3 && c.config.BlockSimilarity {
+				return fmt.Errorf("substring() with a SIMILAR pattern is not allowed: cannot use ordinary indexes and can scan whole tables at high CPU cost; use integer start and count arguments (cast columns with ::int)")
+			}
+		}
+	}
+	return nil
+}
+
+// hasPatternArgs reports whether a substring() call may take the pattern form: the
+// argument types are unknown before planning, so any argument after the first that is not
+// clearly an integer could be a pattern.
+func hasPatternArgs(args []*pg_query.Node) bool {
+	if len(args) < 2 {
+		return false
+	}
+	for _, arg := range args[1:] {
+		if !isIntegerExpr(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// integerTypes are the type names an integer cast can use.
+var integerTypes = map[string]bool{"int2": true, "int4": true, "int8": true, "int": true, "integer": true, "smallint": true, "bigint": true}
+
+// integerFunctions return an integer from text, as used for substring() bounds.
+var integerFunctions = map[string]bool{
+	"length": true, "char_length": true, "character_length": true, "octet_length": true,
+	"strpos": true, "position": true,
+}
+
+// isIntegerExpr reports whether n is clearly an integer: an integer literal, an integer
+// cast, a length or position function, or arithmetic on such values.
+func isIntegerExpr(n *pg_query.Node) bool {
+	switch e := n.GetNode().(type) {
+	case *pg_query.Node_AConst:
+		return e.AConst.GetIval() != nil
+	case *pg_query.Node_TypeCast:
+		names := e.TypeCast.GetTypeName().GetNames()
+		return len(names) > 0 && integerTypes[strings.ToLower(names[len(names)-1].GetString_().GetSval())]
+	case *pg_query.Node_FuncCall:
+		names := e.FuncCall.GetFuncname()
+		return len(names) > 0 && integerFunctions[strings.ToLower(names[len(names)-1].GetString_().GetSval())]
+	case *pg_query.Node_AExpr:
+		a := e.AExpr
+		if a.Kind != pg_query.A_Expr_Kind_AEXPR_OP || len(a.Name) != 1 {
+			return false
+		}
+		switch a.Name[0].GetString_().GetSval() {
+		case "+", "-", "*", "/":
+		default:
+			return false
+		}
+		return (a.Lexpr == nil || isIntegerExpr(a.Lexpr)) && isIntegerExpr(a.Rexpr)
+	}
+	return false
+}
+
+// Walk calls fn on m and every protobuf message reachable from it (depth-first).
+// pg_query_go ASTs are protobuf messages, so this visits every node of the parse tree.
+// fn may return SkipChildren to skip the current message's descendants, or StopWalk to
+// end the walk (Walk then returns nil); any other error ends the walk and is returned.
+func Walk(m protoreflect.Message, fn func(protoreflect.Message) error) error {
+	if err := walk(m, fn); err != StopWalk {
+		return err
 	}
 	return nil
 }
 
+// SkipChildren is returned by a Walk callback to skip the current message's descendants.
+var SkipChildren = errors.New("skip children")
+
+// StopWalk is returned by a Walk callback to end the walk once it has what it needs.
+var StopWalk = errors.New("stop walk")
+
+func walk(m protoreflect.Message, fn func(protoreflect.Message) error) error {
+	if err := fn(m); err == SkipChildren {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var err error
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Kind() != protoreflect.MessageKind && fd.Kind() != protoreflect.GroupKind {
+			return true
+		}
+		switch {
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				if err = walk(list.Get(i).Message(), fn); err != nil {
+					return false
+				}
+			}
+		case fd.IsMap():
+			// pg_query ASTs have no map fields.
+		default:
+			err = walk(v.Message(), fn)
+		}
+		return err == nil
+	})
+	return err
+}
+
 // checkNode recursively checks a single AST node and its CTEs against protection rules.
 func (c *Checker) checkNode(node *pg_query.Node) error {
 	if node == nil {
@@ -94,30 +609,44 @@ func (c *Checker) checkNode(node *pg_query.Node) error {
 				return fmt.Errorf("SET %s is blocked in read-only mode: cannot change transaction read-only setting", varSetStmt.Name)
 			}
 		}
+		if c.config.LockSessionRole && isRoleVar(varSetStmt.Name) {
+			verb := "SET"
+			if varSetStmt.Kind == pg_query.VariableSetKind_VAR_RESET {
+				verb = "RESET"
+			}
+			varName := strings.ToUpper(strings.ReplaceAll(varSetStmt.Name, "_", " "))
+			return fmt.Errorf("%s %s is not allowed: session role is enforced by server configuration", verb, varName)
+		}
+		if !c.config.AllowSet && c.config.AllowSetLocal && varSetStmt.IsLocal && varSetStmt.Kind != pg_query.VariableSetKind_VAR_SET_MULTI {
+			if isServerManagedVar(varSetStmt.Name) {
+				return fmt.Errorf("SET LOCAL %s is not allowed: this setting is managed by the server", varSetStmt.Name)
+			}
+			break
+		}
 		if !c.config.AllowSet {
 			switch varSetStmt.Kind {
 			case pg_query.VariableSetKind_VAR_RESET_ALL:
-				return fmt.Errorf("RESET ALL is not allowed")
+				return fmt.Errorf("RESET ALL is not allowed: changes session settings, including timeouts and the transaction's read-only mode")
 			case pg_query.VariableSetKind_VAR_RESET:
-				return fmt.Errorf("RESET statements are not allowed: RESET %s", varSetStmt.Name)
+				return fmt.Errorf("RESET statements are not allowed: RESET %s changes session settings, including timeouts and the transaction's read-only mode", varSetStmt.Name)
 			default:
-				return fmt.Errorf("SET statements are not allowed: SET %s", varSetStmt.Name)
+				return fmt.Errorf("SET statements are not allowed: SET %s changes session settings, including timeouts and the transaction's read-only mode", varSetStmt.Name)
 			}
 		}
 
 	case *pg_query.Node_DropStmt:
 		if !c.config.AllowDrop {
-			return fmt.Errorf("DROP statements are not allowed")
+			return fmt.Errorf("DROP statements are not allowed: permanently removes database objects and their data")
 		}
 
 	case *pg_query.Node_DropdbStmt:
 		if !c.config.AllowDrop {
-			return fmt.Errorf("DROP DATABASE is not allowed")
+			return fmt.Errorf("DROP DATABASE is not allowed: permanently removes database objects and their data")
 		}
 
 	case *pg_query.Node_TruncateStmt:
 		if !c.config.AllowTruncate {
-			return fmt.Errorf("TRUNCATE statements are not allowed")
+			return fmt.Errorf("TRUNCATE statements are not allowed: deletes every row of a table without firing row-level DELETE triggers")
 		}
 
 	case *pg_query.Node_DoStmt:
@@ -125,24 +654,39 @@ func (c *Checker) checkNode(node *pg_query.Node) error {
 			return fmt.Errorf("DO $$ blocks are not allowed: DO blocks can execute arbitrary SQL bypassing protection checks")
 		}
 
+	case *pg_query.Node_InsertStmt:
+		if c.config.BlockUpsert && n.InsertStmt.OnConflictClause != nil {
+			if n.InsertStmt.OnConflictClause.Action == pg_query.OnConflictAction_ONCONFLICT_UPDATE {
+				return fmt.Errorf("INSERT ... ON CONFLICT DO UPDATE is not allowed: upserts modify existing rows, only plain INSERTs are permitted")
+			}
+			return fmt.Errorf("INSERT ... ON CONFLICT DO NOTHING is not allowed: only plain INSERTs are permitted, so conflicts must surface as errors")
+		}
+
 	case *pg_query.Node_DeleteStmt:
 		if !c.config.AllowDeleteWithoutWhere && n.DeleteStmt.WhereClause == nil {
-			return fmt.Errorf("DELETE without WHERE clause is not allowed")
+			return fmt.Errorf("DELETE without WHERE clause is not allowed: deletes every row of the table")
 		}
 
 	case *pg_query.Node_UpdateStmt:
 		if !c.config.AllowUpdateWithoutWhere && n.UpdateStmt.WhereClause == nil {
-			return fmt.Errorf("UPDATE without WHERE clause is not allowed")
+			return fmt.Errorf("UPDATE without WHERE clause is not allowed: updates every row of the table")
 		}
 
 	case *pg_query.Node_MergeStmt:
 		if !c.config.AllowMerge {
 			return fmt.Errorf("MERGE statements are not allowed: MERGE can perform INSERT, UPDATE, and DELETE operations bypassing individual DML protection rules")
 		}
+		if !c.config.AllowMergeDelete {
+			for _, clause := range n.MergeStmt.MergeWhenClauses {
+				if clause.GetMergeWhenClause().GetCommandType() == pg_query.CmdType_CMD_DELETE {
+					return fmt.Errorf("MERGE with DELETE action is not allowed: deletes rows without a WHERE clause of its own; use WHEN MATCHED THEN UPDATE, or a separate DELETE with a WHERE clause")
+				}
+			}
+		}
 
 	case *pg_query.Node_CopyStmt:
 		if !c.config.AllowCopyFrom && n.CopyStmt.IsFrom {
-			return fmt.Errorf("COPY FROM is not allowed")
+			return fmt.Errorf("COPY FROM is not allowed: bulk-loads data into tables")
 		}
 		if !c.config.AllowCopyTo && !n.CopyStmt.IsFrom {
 			return fmt.Errorf("COPY TO is not allowed: can export/exfiltrate data from tables")
@@ -172,6 +716,9 @@ func (c *Checker) checkNode(node *pg_query.Node) error {
 		}
 
 	case *pg_query.Node_ExplainStmt:
+		if c.config.BlockExplainAnalyze && explainAnalyzeEnabled(n.ExplainStmt.Options) {
+			return fmt.Errorf("EXPLAIN ANALYZE is not allowed: it executes the statement for real. Use plain EXPLAIN (without ANALYZE) to see the estimated plan")
+		}
 		if n.ExplainStmt.Query != nil {
 			if err := c.checkNode(n.ExplainStmt.Query); err != nil {
 				return err
@@ -218,6 +765,17 @@ func (c *Checker) checkNode(node *pg_query.Node) error {
 		if !c.config.AllowCreateExtension {
 			return fmt.Errorf("CREATE EXTENSION is not allowed: can load arbitrary server-side code into PostgreSQL")
 		}
+		if len(c.config.AllowedExtensions) > 0 && !slices.Contains(c.config.AllowedExtensions, n.CreateExtensionStmt.Extname) {
+			return fmt.Errorf("extension %q is not in the allowlist", n.CreateExtensionStmt.Extname)
+		}
+		// CASCADE also installs the extension's dependencies, which the allowlist does not cover.
+		if len(c.config.AllowedExtensions) > 0 {
+			for _, opt := range n.CreateExtensionStmt.Options {
+				if opt.GetDefElem().GetDefname() == "cascade" {
+					return fmt.Errorf("CREATE EXTENSION ... CASCADE is not allowed with an extension allowlist: it installs dependencies that are not in the allowlist; create each required extension first")
+				}
+			}
+		}
 
 	case *pg_query.Node_LockStmt:
 		if !c.config.AllowLockTable {
@@ -256,47 +814,72 @@ func (c *Checker) checkNode(node *pg_query.Node) error {
 
 	case *pg_query.Node_CreateStmt:
 		if !c.config.AllowDDL {
-			return fmt.Errorf("CREATE TABLE is not allowed: DDL operations are blocked")
+			if !isTempRelation(n.CreateStmt.Relation) {
+				return fmt.Errorf("CREATE TABLE is not allowed: DDL operations are blocked because they change the schema")
+			}
+			if !c.config.AllowTempTables {
+				return fmt.Errorf("CREATE TEMP TABLE is not allowed: %s", tempTablesReason)
+			}
+			// A temp child of a permanent table is DDL on that table: it changes what scans of
+			// the parent can reach, and PARTITION OF attaches to the parent's partition tree.
+			if n.CreateStmt.Partbound != nil {
+				return fmt.Errorf("CREATE TEMP TABLE ... PARTITION OF is not allowed: DDL operations are blocked because they change the schema")
+			}
+			if len(n.CreateStmt.InhRelations) > 0 {
+				return fmt.Errorf("CREATE TEMP TABLE ... INHERITS is not allowed: DDL operations are blocked because they change the schema")
+			}
 		}
 
 	case *pg_query.Node_AlterTableStmt:
 		if !c.config.AllowDDL {
-			return fmt.Errorf("ALTER TABLE is not allowed: DDL operations are blocked")
+			return fmt.Errorf("ALTER TABLE is not allowed: DDL operations are blocked because they change the schema")
 		}
 
 	case *pg_query.Node_IndexStmt:
 		if !c.config.AllowDDL {
-			return fmt.Errorf("CREATE INDEX is not allowed: DDL operations are blocked")
+			return fmt.Errorf("CREATE INDEX is not allowed: DDL operations are blocked because they change the schema")
 		}
 
 	case *pg_query.Node_CreateSchemaStmt:
 		if !c.config.AllowDDL {
-			return fmt.Errorf("CREATE SCHEMA is not allowed: DDL operations are blocked")
+			return fmt.Errorf("CREATE SCHEMA is not allowed: DDL operations are blocked because they change the schema")
 		}
 
 	case *pg_query.Node_ViewStmt:
 		if !c.config.AllowDDL {
-			return fmt.Errorf("CREATE VIEW is not allowed: DDL operations are blocked")
+			return fmt.Errorf("CREATE VIEW is not allowed: DDL operations are blocked because they change the schema")
 		}
 
 	case *pg_query.Node_CreateSeqStmt:
 		if !c.config.AllowDDL {
-			return fmt.Errorf("CREATE SEQUENCE is not allowed: DDL operations are blocked")
+			return fmt.Errorf("CREATE SEQUENCE is not allowed: DDL operations are blocked because they change the schema")
 		}
 
 	case *pg_query.Node_CreateTableAsStmt:
+		// In read-only mode CREATE TEMP TABLE runs in a read-write transaction, where the AS
+		// query could write to permanent tables.
+		isTempTable := n.CreateTableAsStmt.Objtype == pg_query.ObjectType_OBJECT_TABLE &&
+			n.CreateTableAsStmt.Into != nil && isTempRelation(n.CreateTableAsStmt.Into.Rel)
+		if isTempTable && c.config.AllowTempTables && c.config.ReadOnly {
+			return fmt.Errorf("CREATE TEMP TABLE AS is not allowed in read-only mode: its query would run in a read-write transaction; use CREATE TEMP TABLE, then INSERT INTO it ... SELECT")
+		}
 		if !c.config.AllowDDL {
-			return fmt.Errorf("CREATE TABLE AS / CREATE MATERIALIZED VIEW is not allowed: DDL operations are blocked")
+			if !isTempTable {
+				return fmt.Errorf("CREATE TABLE AS / CREATE MATERIALIZED VIEW is not allowed: DDL operations are blocked because they change the schema")
+			}
+			if !c.config.AllowTempTables {
+				return fmt.Errorf("CREATE TEMP TABLE AS is not allowed: %s", tempTablesReason)
+			}
 		}
 
 	case *pg_query.Node_AlterSeqStmt:
 		if !c.config.AllowDDL {
-			return fmt.Errorf("ALTER SEQUENCE is not allowed: DDL operations are blocked")
+			return fmt.Errorf("ALTER SEQUENCE is not allowed: DDL operations are blocked because they change the schema")
 		}
 
 	case *pg_query.Node_RenameStmt:
 		if !c.config.AllowDDL {
-			return fmt.Errorf("RENAME is not allowed: DDL operations are blocked")
+			return fmt.Errorf("RENAME is not allowed: DDL operations are blocked because they change the schema")
 		}
 
 	case *pg_query.Node_DiscardStmt:
@@ -402,6 +985,49 @@ func (c *Checker) checkCTEs(node *pg_query.Node) error {
 	return nil
 }
 
+// tempTablesReason is why CREATE TEMP TABLE needs AllowTempTables.
+const tempTablesReason = "temp tables shadow permanent tables of the same name for the rest of the session"
+
+// isTempRelation reports whether rv names a temporary table: CREATE TEMP/TEMPORARY TABLE,
+// or a table created in the pg_temp schema.
+func isTempRelation(rv *pg_query.RangeVar) bool {
+	return rv != nil && (rv.Relpersistence == "t" || rv.Schemaname == "pg_temp")
+}
+
+// IsTempTableCreate reports whether sql is a single CREATE TEMP TABLE or
+// CREATE TEMP TABLE AS statement.
+func IsTempTableCreate(sql string) bool {
+	result, err := pg_query.Parse(sql)
+	if err != nil || len(result.Stmts) != 1 {
+		return false
+	}
+	stmt := result.Stmts[0].Stmt
+	if create := stmt.GetCreateStmt(); create != nil {
+		return isTempRelation(create.Relation)
+	}
+	createAs := stmt.GetCreateTableAsStmt()
+	return createAs != nil && createAs.Objtype == pg_query.ObjectType_OBJECT_TABLE &&
+		createAs.Into != nil && isTempRelation(createAs.Into.Rel)
+}
+
+func isRoleVar(name string) bool {
+	name = strings.ToLower(name)
+	return name == "role" || name == "session_authorization"
+}
+
+// isServerManagedVar reports whether name is a setting that AllowSetLocal must not let
+// agents change: read-only mode, timeouts, and the session role. Setting names are
+// case-insensitive, but pg_query keeps the case of quoted names ("STATEMENT_TIMEOUT"), so
+// these checks lowercase them.
+func isServerManagedVar(name string) bool {
+	switch strings.ToLower(name) {
+	case "statement_timeout", "lock_timeout", "idle_in_transaction_session_timeout", "transaction_timeout":
+		return true
+	}
+	return isTransactionReadOnlyVar(name) || isRoleVar(name)
+}
+
 func isTransactionReadOnlyVar(name string) bool {
+	name = strings.ToLower(name)
 	return name == "default_transaction_read_only" || name == "transaction_read_only"
 }
@@ -1,8 +1,14 @@
 package protection
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // helper: default config with all Allow* false, ReadOnly false.
@@ -215,6 +221,46 @@ func TestSet_ResetSingleAllowed(t *testing.T) {
 	assertAllowed(t, c, "RESET work_mem")
 }
 
+func TestSetLocal_BlockedByDefault(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(defaultConfig())
+	assertBlocked(t, c, "SET LOCAL work_mem = '256MB'", "SET statements are not allowed: SET work_mem")
+}
+
+func TestSetLocal_AllowSetLocal(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{AllowSetLocal: true})
+	assertAllowed(t, c, "SET LOCAL work_mem = '256MB'")
+	assertAllowed(t, c, "SET LOCAL enable_seqscan TO off")
+	assertAllowed(t, c, "SET LOCAL work_mem TO DEFAULT")
+	// Session-wide SET and RESET still need AllowSet.
+	assertBlocked(t, c, "SET work_mem = '256MB'", "SET statements are not allowed: SET work_mem")
+	assertBlocked(t, c, "RESET work_mem", "RESET statements are not allowed")
+}
+
+func TestSetLocal_ServerManagedSettingsBlocked(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{AllowSetLocal: true})
+	assertBlocked(t, c, "SET LOCAL statement_timeout = 0", "SET LOCAL statement_timeout is not allowed: this setting is managed by the server")
+	assertBlocked(t, c, "SET LOCAL lock_timeout = 0", "SET LOCAL lock_timeout is not allowed")
+	assertBlocked(t, c, "SET LOCAL idle_in_transaction_session_timeout = 0", "SET LOCAL idle_in_transaction_session_timeout is not allowed")
+	assertBlocked(t, c, "SET LOCAL transaction_read_only = off", "SET LOCAL transaction_read_only is not allowed")
+	assertBlocked(t, c, "SET LOCAL ROLE postgres", "SET LOCAL role is not allowed")
+	assertBlocked(t, c, "SET LOCAL TRANSACTION READ WRITE", "SET statements are not allowed")
+	// Quoted names keep their case but name the same settings.
+	assertBlocked(t, c, `SET LOCAL "STATEMENT_TIMEOUT" = 0`, "SET LOCAL STATEMENT_TIMEOUT is not allowed: this setting is managed by the server")
+	assertBlocked(t, c, `SET LOCAL "Transaction_Read_Only" = off`, "SET LOCAL Transaction_Read_Only is not allowed")
+	assertBlocked(t, c, `SET LOCAL "ROLE" = postgres`, "SET LOCAL ROLE is not allowed")
+}
+
+func TestSetLocal_ReadOnlyStillBlocksReadOnlyVars(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{AllowSetLocal: true, ReadOnly: true})
+	assertBlocked(t, c, "SET LOCAL transaction_read_only = off", "blocked in read-only mode")
+	assertBlocked(t, c, `SET LOCAL "TRANSACTION_READ_ONLY" = off`, "blocked in read-only mode")
+	assertAllowed(t, c, "SET LOCAL work_mem = '64MB'")
+}
+
 // --- DO Block Protection ---
 
 func TestDo_Simple(t *testing.T) {
@@ -477,6 +523,26 @@ func TestExplain_AnalyzeUpdateWithWhereAllowed(t *testing.T) {
 	assertAllowed(t, c, "EXPLAIN ANALYZE UPDATE users SET active = false WHERE id = 1")
 }
 
+func TestExplain_BlockExplainAnalyze(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{BlockExplainAnalyze: true})
+	assertAllowed(t, c, "EXPLAIN SELECT * FROM users")
+	assertAllowed(t, c, "EXPLAIN (FORMAT JSON, COSTS false) SELECT * FROM users")
+	assertAllowed(t, c, "EXPLAIN (ANALYZE false) SELECT * FROM users")
+	assertAllowed(t, c, "EXPLAIN (ANALYZE off) SELECT * FROM users")
+	assertBlocked(t, c, "EXPLAIN ANALYZE SELECT * FROM users", "EXPLAIN ANALYZE is not allowed")
+	assertBlocked(t, c, "EXPLAIN (ANALYZE) SELECT * FROM users", "Use plain EXPLAIN")
+	assertBlocked(t, c, "EXPLAIN (ANALYZE true, BUFFERS) SELECT * FROM users", "EXPLAIN ANALYZE is not allowed")
+	assertBlocked(t, c, "EXPLAIN (ANALYZE 1) SELECT * FROM users", "EXPLAIN ANALYZE is not allowed")
+	assertBlocked(t, c, "EXPLAIN (FORMAT JSON, ANALYZE on) SELECT * FROM users", "EXPLAIN ANALYZE is not allowed")
+}
+
+func TestExplain_AnalyzeAllowedByDefault(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(defaultConfig())
+	assertAllowed(t, c, "EXPLAIN (ANALYZE true) SELECT * FROM users")
+}
+
 func TestExplain_TruncateParseError(t *testing.T) {
 	t.Parallel()
 	c := NewChecker(defaultConfig())
@@ -865,6 +931,26 @@ func TestMerge_Allowed(t *testing.T) {
 	assertAllowed(t, c, "MERGE INTO target t USING source s ON t.id = s.id WHEN MATCHED THEN UPDATE SET name = s.name")
 }
 
+func TestMerge_DeleteActionBlockedWhenMergeAllowed(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{AllowMerge: true})
+	assertBlocked(t, c, "MERGE INTO target t USING source s ON t.id = s.id WHEN MATCHED THEN DELETE", "MERGE with DELETE action is not allowed")
+	assertBlocked(t, c, "MERGE INTO target t USING source s ON t.id = s.id WHEN MATCHED AND s.gone THEN DELETE WHEN MATCHED THEN UPDATE SET name = s.name", "MERGE with DELETE action is not allowed")
+}
+
+func TestMerge_UpdateInsertAllowedWithoutMergeDelete(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{AllowMerge: true})
+	assertAllowed(t, c, "MERGE INTO target t USING source s ON t.id = s.id WHEN MATCHED THEN UPDATE SET name = s.name WHEN NOT MATCHED THEN INSERT (id, name) VALUES (s.id, s.name)")
+	assertAllowed(t, c, "MERGE INTO target t USING source s ON t.id = s.id WHEN MATCHED THEN DO NOTHING")
+}
+
+func TestMerge_DeleteAllowedWithAllowMergeDelete(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{AllowMerge: true, AllowMergeDelete: true})
+	assertAllowed(t, c, "MERGE INTO target t USING source s ON t.id = s.id WHEN MATCHED THEN DELETE")
+}
+
 // --- GRANT / REVOKE Protection ---
 
 func TestGrant_Table(t *testing.T) {
@@ -991,6 +1077,27 @@ func TestCreateExtension_Allowed(t *testing.T) {
 	assertAllowed(t, c, "CREATE EXTENSION pg_trgm")
 }
 
+func TestCreateExtension_Allowlist(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{AllowCreateExtension: true, AllowedExtensions: []string{"pg_trgm", "pgcrypto"}})
+	assertAllowed(t, c, "CREATE EXTENSION pg_trgm")
+	assertAllowed(t, c, "CREATE EXTENSION IF NOT EXISTS pgcrypto WITH SCHEMA public")
+	assertBlocked(t, c, "CREATE EXTENSION plpython3u", `extension "plpython3u" is not in the allowlist`)
+	assertBlocked(t, c, `CREATE EXTENSION "plpython3u"`, `extension "plpython3u" is not in the allowlist`)
+	assertBlocked(t, c, "CREATE EXTENSION pg_trgm CASCADE", "CREATE EXTENSION ... CASCADE is not allowed with an extension allowlist")
+	assertBlocked(t, c, "CREATE EXTENSION IF NOT EXISTS pgcrypto WITH SCHEMA public CASCADE", "CREATE EXTENSION ... CASCADE is not allowed with an extension allowlist")
+
+	// Without an allowlist, CASCADE is allowed.
+	c = NewChecker(Config{AllowCreateExtension: true})
+	assertAllowed(t, c, "CREATE EXTENSION pg_trgm CASCADE")
+}
+
+func TestCreateExtension_AllowlistRequiresAllowCreateExtension(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{AllowedExtensions: []string{"pg_trgm"}})
+	assertBlocked(t, c, "CREATE EXTENSION pg_trgm", "CREATE EXTENSION is not allowed")
+}
+
 // --- LOCK TABLE Protection ---
 
 func TestLockTable_Basic(t *testing.T) {
@@ -1217,6 +1324,78 @@ func TestDDL_CreateTable_Allowed(t *testing.T) {
 	assertAllowed(t, c, "CREATE TABLE test (id int)")
 }
 
+func TestDDL_CreateTempTable_Blocked(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(defaultConfig())
+	assertBlocked(t, c, "CREATE TEMP TABLE scratch (id int)", "CREATE TEMP TABLE is not allowed: temp tables shadow permanent tables")
+	assertBlocked(t, c, "CREATE TEMP TABLE scratch AS SELECT 1", "CREATE TEMP TABLE AS is not allowed: temp tables shadow permanent tables")
+}
+
+// --- Temp Tables ---
+
+func TestTempTables_Allowed(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{AllowTempTables: true})
+	assertAllowed(t, c, "CREATE TEMP TABLE scratch (id int)")
+	assertAllowed(t, c, "CREATE TEMPORARY TABLE scratch (id int) ON COMMIT DROP")
+	assertAllowed(t, c, "CREATE TABLE pg_temp.scratch (id int)")
+	assertAllowed(t, c, "CREATE TEMP TABLE scratch (LIKE users)")
+	assertAllowed(t, c, "CREATE TEMP TABLE scratch AS SELECT * FROM users")
+	assertAllowed(t, c, "CREATE TABLE pg_temp.scratch AS SELECT * FROM users")
+	// Permanent tables still need AllowDDL.
+	assertBlocked(t, c, "CREATE TABLE scratch (id int)", "CREATE TABLE is not allowed: DDL operations are blocked")
+	assertBlocked(t, c, "CREATE UNLOGGED TABLE scratch (id int)", "CREATE TABLE is not allowed: DDL operations are blocked")
+	assertBlocked(t, c, "CREATE TABLE public.scratch (id int)", "CREATE TABLE is not allowed: DDL operations are blocked")
+	assertBlocked(t, c, "CREATE TABLE scratch AS SELECT * FROM users", "CREATE TABLE AS / CREATE MATERIALIZED VIEW is not allowed: DDL operations are blocked")
+	assertBlocked(t, c, "CREATE MATERIALIZED VIEW mv AS SELECT * FROM users", "CREATE TABLE AS / CREATE MATERIALIZED VIEW is not allowed: DDL operations are blocked")
+}
+
+func TestTempTables_BlocksInheritsAndPartitionOf(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{AllowTempTables: true})
+	assertBlocked(t, c, "CREATE TEMP TABLE scratch () INHERITS (users)", "CREATE TEMP TABLE ... INHERITS is not allowed: DDL operations are blocked")
+	assertBlocked(t, c, "CREATE TEMP TABLE scratch PARTITION OF events FOR VALUES IN (1)", "CREATE TEMP TABLE ... PARTITION OF is not allowed: DDL operations are blocked")
+	// AllowDDL permits both.
+	c = NewChecker(Config{AllowTempTables: true, AllowDDL: true})
+	assertAllowed(t, c, "CREATE TEMP TABLE scratch () INHERITS (users)")
+	assertAllowed(t, c, "CREATE TEMP TABLE scratch PARTITION OF events FOR VALUES IN (1)")
+}
+
+func TestTempTables_ReadOnlyBlocksCreateTableAs(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{AllowTempTables: true, ReadOnly: true})
+	assertAllowed(t, c, "CREATE TEMP TABLE scratch (id int)")
+	assertAllowed(t, c, "INSERT INTO scratch SELECT id FROM users")
+	assertBlocked(t, c, "CREATE TEMP TABLE scratch AS SELECT id FROM users", "CREATE TEMP TABLE AS is not allowed in read-only mode: its query would run in a read-write transaction")
+	assertBlocked(t, c, "CREATE TABLE scratch (id int)", "CREATE TABLE is not allowed: DDL operations are blocked")
+	// AllowDDL does not lift it: the read-write transaction comes from AllowTempTables.
+	c = NewChecker(Config{AllowTempTables: true, AllowDDL: true, ReadOnly: true})
+	assertBlocked(t, c, "CREATE TEMP TABLE scratch AS SELECT id FROM users", "CREATE TEMP TABLE AS is not allowed in read-only mode")
+}
+
+func TestIsTempTableCreate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		sql      string
+		expected bool
+	}{
+		{"CREATE TEMP TABLE scratch (id int)", true},
+		{"CREATE TEMPORARY TABLE scratch (id int)", true},
+		{"CREATE TABLE pg_temp.scratch (id int)", true},
+		{"CREATE TEMP TABLE scratch AS SELECT 1", true},
+		{"CREATE TABLE scratch (id int)", false},
+		{"CREATE TABLE scratch AS SELECT 1", false},
+		{"INSERT INTO scratch VALUES (1)", false},
+		{"CREATE TEMP TABLE a (id int); CREATE TEMP TABLE b (id int)", false},
+		{"not sql", false},
+	}
+	for _, tt := range tests {
+		if got := IsTempTableCreate(tt.sql); got != tt.expected {
+			t.Errorf("IsTempTableCreate(%q) = %v, expected %v", tt.sql, got, tt.expected)
+		}
+	}
+}
+
 func TestDDL_AlterTable_Allowed(t *testing.T) {
 	t.Parallel()
 	c := NewChecker(Config{AllowDDL: true})
@@ -1561,6 +1740,33 @@ func TestAllowInsertOnConflict(t *testing.T) {
 	assertAllowed(t, c, "INSERT INTO users (id, name) VALUES (1, 'test') ON CONFLICT (id) DO UPDATE SET name = 'test'")
 }
 
+func TestBlockUpsert_PlainInsertAllowed(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{BlockUpsert: true})
+	assertAllowed(t, c, "INSERT INTO users (id, name) VALUES (1, 'test')")
+	assertAllowed(t, c, "INSERT INTO users (id, name) SELECT id, name FROM staging RETURNING id")
+}
+
+func TestBlockUpsert_OnConflictDoUpdate(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{BlockUpsert: true})
+	assertBlocked(t, c, "INSERT INTO users (id, name) VALUES (1, 'test') ON CONFLICT (id) DO UPDATE SET name = 'test'", "INSERT ... ON CONFLICT DO UPDATE is not allowed")
+	assertBlocked(t, c, "WITH ins AS (INSERT INTO users (id, name) VALUES (1, 'test') ON CONFLICT (id) DO UPDATE SET name = 'test' RETURNING *) SELECT * FROM ins", "ON CONFLICT DO UPDATE is not allowed")
+	assertBlocked(t, c, "EXPLAIN INSERT INTO users (id) VALUES (1) ON CONFLICT (id) DO UPDATE SET id = 2", "ON CONFLICT DO UPDATE is not allowed")
+}
+
+func TestBlockUpsert_OnConflictDoNothing(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{BlockUpsert: true})
+	assertBlocked(t, c, "INSERT INTO users (id, name) VALUES (1, 'test') ON CONFLICT DO NOTHING", "INSERT ... ON CONFLICT DO NOTHING is not allowed")
+}
+
+func TestBlockUpsert_DefaultAllowsOnConflictDoNothing(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(defaultConfig())
+	assertAllowed(t, c, "INSERT INTO users (id, name) VALUES (1, 'test') ON CONFLICT DO NOTHING")
+}
+
 func TestAllowCreateTable(t *testing.T) {
 	t.Parallel()
 	c := NewChecker(Config{AllowDDL: true})
@@ -1765,6 +1971,33 @@ func TestSQLInjection_CommentBased(t *testing.T) {
 	assertAllowed(t, c, "SELECT * FROM users -- WHERE admin = true")
 }
 
+func TestBlockComments_LineComment(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{BlockComments: true})
+	assertBlocked(t, c, "SELECT * FROM users -- WHERE admin = true", "SQL comments are not allowed: comments can hide intent from reviewers and log readers; remove the comment at offset 20 and retry")
+}
+
+func TestBlockComments_BlockComment(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{BlockComments: true})
+	assertBlocked(t, c, "SELECT /* hidden */ * FROM users", "SQL comments are not allowed: comments can hide intent from reviewers and log readers; remove the comment at offset 7 and retry")
+	assertBlocked(t, c, "SELECT * FROM users WHERE id = 1 /* nested /* inner */ outer */", "SQL comments are not allowed")
+}
+
+func TestBlockComments_MarkersInLiteralsAllowed(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{BlockComments: true})
+	assertAllowed(t, c, "SELECT * FROM users WHERE name = 'a -- b' AND note = '/* c */'")
+	assertAllowed(t, c, `SELECT 1 AS "x -- y"`)
+	assertAllowed(t, c, "SELECT $$ -- not a comment $$")
+}
+
+func TestBlockComments_OffByDefault(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(defaultConfig())
+	assertAllowed(t, c, "SELECT /* note */ 1 -- trailing")
+}
+
 func TestSQLInjection_MultiStatement(t *testing.T) {
 	t.Parallel()
 	c := NewChecker(defaultConfig())
@@ -1778,6 +2011,27 @@ func TestSQLInjection_Stacked(t *testing.T) {
 	assertBlocked(t, c, "SELECT 1; DELETE FROM users; --", "multi-statement queries are not allowed: found 2 statements")
 }
 
+func TestParseError_Type(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(defaultConfig())
+
+	err := c.Check("SELEC 1")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected *ParseError, got %T: %v", err, err)
+	}
+	if !strings.HasPrefix(err.Error(), "SQL parse error: syntax error at or near") {
+		t.Fatalf("unexpected message: %q", err.Error())
+	}
+
+	// Rule violations and empty input are not parse failures.
+	for _, sql := range []string{"DROP TABLE users", "", "SELECT 1; SELECT 2"} {
+		if err := c.Check(sql); errors.As(err, &parseErr) {
+			t.Errorf("Check(%q): expected a non-parse error, got *ParseError: %v", sql, err)
+		}
+	}
+}
+
 func TestEmptySQL(t *testing.T) {
 	t.Parallel()
 	c := NewChecker(defaultConfig())
@@ -1789,3 +2043,419 @@ func TestWhitespaceOnlySQL(t *testing.T) {
 	c := NewChecker(defaultConfig())
 	assertBlocked(t, c, "   ", "SQL parse error: empty query")
 }
+
+// --- LockSessionRole ---
+
+func TestLockSessionRole_BlocksRoleChanges(t *testing.T) {
+	t.Parallel()
+	cfg := allAllowedConfig()
+	cfg.LockSessionRole = true
+	c := NewChecker(cfg)
+	assertBlocked(t, c, "SET ROLE postgres", "SET ROLE is not allowed: session role is enforced by server configuration")
+	assertBlocked(t, c, "SET LOCAL ROLE postgres", "SET ROLE is not allowed: session role is enforced by server configuration")
+	assertBlocked(t, c, "SET ROLE NONE", "SET ROLE is not allowed: session role is enforced by server configuration")
+	assertBlocked(t, c, "RESET ROLE", "RESET ROLE is not allowed: session role is enforced by server configuration")
+	assertBlocked(t, c, "SET SESSION AUTHORIZATION postgres", "SET SESSION AUTHORIZATION is not allowed: session role is enforced by server configuration")
+	assertBlocked(t, c, "SET SESSION AUTHORIZATION DEFAULT", "SET SESSION AUTHORIZATION is not allowed: session role is enforced by server configuration")
+	assertBlocked(t, c, "RESET SESSION AUTHORIZATION", "RESET SESSION AUTHORIZATION is not allowed: session role is enforced by server configuration")
+	assertBlocked(t, c, `SET "ROLE" = postgres`, "SET ROLE is not allowed: session role is enforced by server configuration")
+}
+
+func TestLockSessionRole_BlocksSetConfig(t *testing.T) {
+	t.Parallel()
+	cfg := allAllowedConfig()
+	cfg.LockSessionRole = true
+	c := NewChecker(cfg)
+	assertBlocked(t, c, "SELECT set_config('role', 'postgres', false)", "set_config('role', ...) is not allowed: session role is enforced by server configuration")
+	assertBlocked(t, c, "SELECT pg_catalog.set_config('session_authorization', 'postgres', true)", "set_config('session_authorization', ...) is not allowed")
+	assertBlocked(t, c, "SELECT SET_CONFIG('ROLE', 'postgres', false)", "set_config('ROLE', ...) is not allowed")
+	assertBlocked(t, c, "SELECT id FROM users WHERE set_config('role', 'postgres', false) IS NOT NULL", "set_config('role', ...) is not allowed")
+	assertBlocked(t, c, "SELECT set_config('ro' || 'le', 'postgres', false)", "set_config() with a non-constant setting name is not allowed")
+	assertBlocked(t, c, "SELECT set_config(name, 'postgres', false) FROM settings", "set_config() with a non-constant setting name is not allowed")
+	assertAllowed(t, c, "SELECT set_config('search_path', 'public', false)")
+
+	// Without LockSessionRole, set_config is not restricted here.
+	assertAllowed(t, NewChecker(allAllowedConfig()), "SELECT set_config('role', 'postgres', false)")
+}
+
+func TestLockSessionRole_OtherSetAllowed(t *testing.T) {
+	t.Parallel()
+	cfg := allAllowedConfig()
+	cfg.LockSessionRole = true
+	c := NewChecker(cfg)
+	assertAllowed(t, c, "SET search_path = public")
+	assertAllowed(t, c, "RESET ALL")
+}
+
+func TestLockSessionRole_DisabledAllowsSetRole(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(allAllowedConfig())
+	assertAllowed(t, c, "SET ROLE postgres")
+	assertAllowed(t, c, "RESET ROLE")
+}
+
+// --- IN-list / VALUES size limits ---
+
+// inList builds "1, 2, ..., n".
+func inList(n int) string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = fmt.Sprintf("%d", i+1)
+	}
+	return strings.Join(items, ", ")
+}
+
+// valuesRows builds "(1, 'x'), (2, 'x'), ..." with n rows.
+func valuesRows(n int) string {
+	rows := make([]string, n)
+	for i := range rows {
+		rows[i] = fmt.Sprintf("(%d, 'x')", i+1)
+	}
+	return strings.Join(rows, ", ")
+}
+
+func TestMaxInListItems_Oversized(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{MaxInListItems: 100})
+	assertBlocked(t, c, "SELECT * FROM users WHERE id IN ("+inList(101)+")", "IN list with 101 items exceeds maximum of 100 items")
+}
+
+func TestMaxInListItems_AtLimit(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{MaxInListItems: 100})
+	assertAllowed(t, c, "SELECT * FROM users WHERE id IN ("+inList(100)+")")
+}
+
+func TestMaxInListItems_NotIn(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{MaxInListItems: 3})
+	assertBlocked(t, c, "SELECT * FROM users WHERE id NOT IN (1, 2, 3, 4)", "IN list with 4 items exceeds maximum of 3 items")
+}
+
+func TestMaxInListItems_NestedInSubqueryAndCTE(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{MaxInListItems: 3})
+	assertBlocked(t, c, "SELECT * FROM orders WHERE user_id IN (SELECT id FROM users WHERE id IN (1, 2, 3, 4))", "IN list with 4 items")
+	assertBlocked(t, c, "WITH u AS (SELECT * FROM users WHERE id IN (1, 2, 3, 4)) SELECT * FROM u", "IN list with 4 items")
+	assertBlocked(t, c, "UPDATE users SET active = false WHERE id IN (1, 2, 3, 4)", "IN list with 4 items")
+}
+
+func TestMaxInListItems_SubqueryNotCounted(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{MaxInListItems: 1})
+	assertAllowed(t, c, "SELECT * FROM orders WHERE user_id IN (SELECT id FROM users)")
+}
+
+func TestMaxInListItems_ZeroIsUnlimited(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(defaultConfig())
+	assertAllowed(t, c, "SELECT * FROM users WHERE id IN ("+inList(5000)+")")
+}
+
+func TestMaxValuesRows_OversizedInsert(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{MaxValuesRows: 50})
+	assertBlocked(t, c, "INSERT INTO users (id, name) VALUES "+valuesRows(51), "VALUES list with 51 rows exceeds maximum of 50 rows")
+}
+
+func TestMaxValuesRows_AtLimit(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{MaxValuesRows: 50})
+	assertAllowed(t, c, "INSERT INTO users (id, name) VALUES "+valuesRows(50))
+}
+
+func TestMaxValuesRows_StandaloneAndInFrom(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{MaxValuesRows: 2})
+	assertBlocked(t, c, "VALUES "+valuesRows(3), "VALUES list with 3 rows exceeds maximum of 2 rows")
+	assertBlocked(t, c, "SELECT * FROM (VALUES "+valuesRows(3)+") AS v(id, name)", "VALUES list with 3 rows")
+}
+
+func TestMaxValuesRows_ZeroIsUnlimited(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(defaultConfig())
+	assertAllowed(t, c, "INSERT INTO users (id, name) VALUES "+valuesRows(2000))
+}
+
+func TestBlockRegexOperators(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{BlockRegexOperators: true})
+	assertBlocked(t, c, "SELECT * FROM logs WHERE body ~ '.*error.*'", "regular expression operator ~ is not allowed")
+	assertBlocked(t, c, "SELECT * FROM logs WHERE body !~* 'x'", "regular expression operator !~* is not allowed")
+	assertBlocked(t, c, "SELECT * FROM logs WHERE body OPERATOR(pg_catalog.~) 'x'", "regular expression operator ~ is not allowed")
+	assertBlocked(t, c, "SELECT id FROM users WHERE id IN (SELECT user_id FROM logs WHERE body ~ 'x')", "regular expression operator ~")
+	assertBlocked(t, c, "SELECT regexp_replace(body, 'a+', 'b') FROM logs", "regular expression function regexp_replace() is not allowed")
+	// SIMILAR TO is only blocked by BlockSimilarity.
+	assertAllowed(t, c, "SELECT * FROM logs WHERE body SIMILAR TO '%(a|b)%'")
+	// Prefix ~ is bitwise NOT.
+	assertAllowed(t, c, "SELECT ~5")
+	assertAllowed(t, c, "SELECT id FROM users WHERE ~flags & 4 = 0")
+}
+
+func TestBlockRegexOperators_Bypasses(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{BlockRegexOperators: true})
+	// ANY/ALL apply the operator to each array element.
+	assertBlocked(t, c, "SELECT * FROM logs WHERE body ~ ANY(ARRAY['a+', 'b+'])", "regular expression operator ~ is not allowed")
+	assertBlocked(t, c, "SELECT * FROM logs WHERE body !~ ALL(ARRAY['a+'])", "regular expression operator !~ is not allowed")
+	assertBlocked(t, c, "SELECT * FROM logs WHERE body ~* SOME(SELECT pattern FROM rules)", "regular expression operator ~* is not allowed")
+	// The functions behind the operators, and the other regexp_* functions.
+	for _, fn := range []string{"textregexeq", "texticregexne", "pg_catalog.nameregexeq", "bpcharicregexeq"} {
+		assertBlocked(t, c, "SELECT * FROM logs WHERE "+fn+"(body, 'a+')", "regular expression function")
+	}
+	for _, fn := range []string{"regexp_like", "regexp_match", "regexp_matches", "regexp_count", "regexp_substr"} {
+		assertBlocked(t, c, "SELECT "+fn+"(body, 'a+') FROM logs", "regular expression function "+fn+"() is not allowed")
+	}
+	// substring(x FROM pattern) and substring(x, pattern) are regex matches.
+	assertBlocked(t, c, "SELECT substring(body FROM 'a+') FROM logs", "substring() with a regular expression pattern is not allowed")
+	assertBlocked(t, c, "SELECT substring(body, 'a+') FROM logs", "substring() with a regular expression pattern is not allowed")
+	assertBlocked(t, c, "SELECT substring(body FROM pattern) FROM logs", "substring() with a regular expression pattern is not allowed")
+	// Integer forms are fine.
+	assertAllowed(t, c, "SELECT substring(body FROM 2) FROM logs")
+	assertAllowed(t, c, "SELECT substring(body FROM 2 FOR 10) FROM logs")
+	assertAllowed(t, c, "SELECT substring(body, 1, length(body) - 1) FROM logs")
+	assertAllowed(t, c, "SELECT substring(body, start::int, strpos(body, ':') + 1) FROM logs")
+	// The SIMILAR form is left to BlockSimilarity.
+	assertAllowed(t, c, "SELECT substring(body SIMILAR '%#\"a+#\"%' ESCAPE '#') FROM logs")
+	// ANY/ALL with other operators are fine.
+	assertAllowed(t, c, "SELECT * FROM logs WHERE id = ANY(ARRAY[1, 2])")
+}
+
+func TestBlockSimilarity(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{BlockSimilarity: true})
+	assertBlocked(t, c, "SELECT * FROM logs WHERE body SIMILAR TO '%(a|b)%'", "SIMILAR TO is not allowed")
+	assertBlocked(t, c, "SELECT * FROM logs WHERE body NOT SIMILAR TO 'a%'", "SIMILAR TO is not allowed")
+	assertBlocked(t, c, "SELECT similarity(name, 'jon') FROM users", "trigram similarity function similarity() is not allowed")
+	assertBlocked(t, c, "SELECT * FROM users WHERE 'jon' <% name", "trigram similarity operator <% is not allowed")
+	// Shared with modulo, so not blocked.
+	assertAllowed(t, c, "SELECT id % 2 FROM users")
+	assertAllowed(t, c, "SELECT * FROM logs WHERE body ~ 'x'")
+}
+
+func TestBlockSimilarity_Bypasses(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{BlockSimilarity: true})
+	assertBlocked(t, c, "SELECT substring(body SIMILAR '%#\"a+#\"%' ESCAPE '#') FROM logs", "substring() with a SIMILAR pattern is not allowed")
+	assertBlocked(t, c, "SELECT substring(body, '%#\"a+#\"%', '#') FROM logs", "substring() with a SIMILAR pattern is not allowed")
+	assertBlocked(t, c, "SELECT * FROM logs WHERE body ~ similar_to_escape('%(a|b)%')", "SIMILAR TO pattern function similar_to_escape() is not allowed")
+	assertBlocked(t, c, "SELECT * FROM users WHERE name <% ANY(ARRAY['jon'])", "trigram similarity operator <% is not allowed")
+	assertAllowed(t, c, "SELECT substring(body FROM 2 FOR 10) FROM logs")
+	// The regex form is left to BlockRegexOperators.
+	assertAllowed(t, c, "SELECT substring(body FROM 'a+') FROM logs")
+}
+
+func TestPatternMatching_LikeAllowed(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{BlockRegexOperators: true, BlockSimilarity: true})
+	assertAllowed(t, c, "SELECT * FROM users WHERE name LIKE 'jo%'")
+	assertAllowed(t, c, "SELECT * FROM users WHERE name NOT ILIKE '%x%'")
+}
+
+func TestPatternMatching_AllowedByDefault(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(defaultConfig())
+	assertAllowed(t, c, "SELECT * FROM logs WHERE body ~ '.*error.*'")
+	assertAllowed(t, c, "SELECT * FROM logs WHERE body SIMILAR TO '%(a|b)%'")
+}
+
+func TestRawInput_NullByte(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(defaultConfig())
+	assertBlocked(t, c, "SELECT 1\x00; DROP TABLE users", "SQL contains a null byte at offset 8")
+}
+
+func TestMaxIdentifierLength_DefaultIsNamedatalen(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(defaultConfig())
+	assertAllowed(t, c, "SELECT * FROM "+strings.Repeat("a", 63))
+	assertBlocked(t, c, "SELECT * FROM "+strings.Repeat("a", 64), "is 64 bytes, exceeding the maximum of 63 bytes")
+}
+
+func TestMaxIdentifierLength_QuotedIdentifier(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(defaultConfig())
+	// Doubled quotes count as one byte each.
+	assertAllowed(t, c, `SELECT "`+strings.Repeat(`""`, 63)+`" FROM users`)
+	assertBlocked(t, c, `SELECT "`+strings.Repeat("b", 70)+`" FROM users`, "is 70 bytes")
+	// Only the surrounding pair is stripped: leading and trailing escaped quotes count.
+	assertAllowed(t, c, `SELECT """`+strings.Repeat("a", 61)+`""" FROM users`)
+	assertBlocked(t, c, `SELECT """`+strings.Repeat("a", 62)+`""" FROM users`, "is 64 bytes")
+}
+
+func TestMaxIdentifierLength_UnicodeEscapedIdentifier(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(defaultConfig())
+	// Measured after decoding: each \0061 is one byte, each \00e9 (é) two.
+	assertAllowed(t, c, `SELECT U&"`+strings.Repeat(`\0061`, 63)+`" FROM users`)
+	assertBlocked(t, c, `SELECT U&"`+strings.Repeat(`\00e9`, 32)+`" FROM users`, "is 64 bytes")
+	assertBlocked(t, c, `SELECT U&"`+strings.Repeat(`\+0000e9`, 32)+`" FROM users`, "is 64 bytes")
+	assertBlocked(t, c, `SELECT U&"`+strings.Repeat(`!00e9`, 32)+`" UESCAPE '!' FROM users`, "is 64 bytes")
+	assertAllowed(t, c, `SELECT U&"`+strings.Repeat(`!00e9`, 31)+`" UESCAPE '!' FROM users`)
+}
+
+func TestMaxIdentifierLength_StringLiteralsIgnored(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(defaultConfig())
+	assertAllowed(t, c, "SELECT '"+strings.Repeat("x", 500)+"'")
+}
+
+func TestMaxIdentifierLength_Configurable(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{MaxIdentifierLength: 10})
+	assertBlocked(t, c, "SELECT * FROM eleven_char", "exceeding the maximum of 10 bytes")
+	assertAllowed(t, c, "SELECT * FROM ten_chars_")
+}
+
+func TestMaxStatementCandidates_OffByDefault(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(defaultConfig())
+	// A single statement whose text holds many semicolons is fine.
+	assertAllowed(t, c, "INSERT INTO notes (body) VALUES ('"+strings.Repeat("a;", 500)+"')")
+	assertBlocked(t, c, "SELECT 1; SELECT 2", "multi-statement queries are not allowed")
+}
+
+func TestMaxStatementCandidates_HugeInputRejectedFast(t *testing.T) {
+	t.Parallel()
+	cfg := defaultConfig()
+	cfg.MaxStatementCandidates = 100
+	c := NewChecker(cfg)
+	sql := strings.Repeat("SELECT 1; ", 500000)
+	start := time.Now()
+	assertBlocked(t, c, sql, "SQL exceeds the raw input limit: found 500000 semicolons (including any in string literals and comments), more than max_statement_candidates (100)")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected early rejection, took %s", elapsed)
+	}
+}
+
+func TestMaxStatementCandidates_Configurable(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(Config{MaxStatementCandidates: 2})
+	assertAllowed(t, c, "SELECT ';';")
+	assertBlocked(t, c, "SELECT ';;';", "found 3 semicolons (including any in string literals and comments), more than max_statement_candidates (2)")
+}
+
+func TestAllowedQueryFingerprints(t *testing.T) {
+	t.Parallel()
+	byID, err := Fingerprint("SELECT name, email FROM users WHERE id = $1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deleteAll, err := Fingerprint("DELETE FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config := defaultConfig()
+	config.AllowedQueryFingerprints = []string{strings.ToUpper(byID), deleteAll}
+	c := NewChecker(config)
+
+	// Literals, comments, whitespace, and keyword case do not matter.
+	assertAllowed(t, c, "SELECT name, email FROM users WHERE id = 42")
+	assertAllowed(t, c, "select name, email\n  from users where id = 'abc' -- lookup")
+
+	// Any structural change does.
+	assertBlocked(t, c, "SELECT name, email, password FROM users WHERE id = 42", "query is not in the allowlist of approved queries")
+	assertBlocked(t, c, "SELECT name, email FROM users WHERE id = 42 OR true", "query is not in the allowlist")
+	assertBlocked(t, c, "SELECT name, email FROM admins WHERE id = 42", "query is not in the allowlist")
+
+	// Listed statements must still pass the other rules.
+	assertBlocked(t, c, "DELETE FROM users", "DELETE without WHERE clause is not allowed")
+
+	var parseErr *ParseError
+	if _, err := Fingerprint("SELEC 1"); !errors.As(err, &parseErr) {
+		t.Fatalf("expected a parse error, got %v", err)
+	}
+}
+
+func TestTreatAsWriteFunctions(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.TreatAsWriteFunctions = []string{"dangerous_fn", "Audit.Record_Event"}
+	writable := NewChecker(config)
+	config.ReadOnly = true
+	readOnly := NewChecker(config)
+
+	tests := []struct {
+		sql    string
+		writes bool
+	}{
+		{"SELECT dangerous_fn()", true},
+		{"SELECT DANGEROUS_FN(1, 2)", true},
+		{"SELECT public.dangerous_fn()", true},
+		{"SELECT * FROM users WHERE id IN (SELECT dangerous_fn())", true},
+		{"WITH x AS (SELECT dangerous_fn() AS v) SELECT v FROM x", true},
+		{"SELECT audit.record_event('login')", true},
+		{"SELECT record_event('login')", true}, // may resolve to audit.record_event via search_path
+		{"SELECT other.other_fn()", false},
+		{"SELECT dangerous_fn FROM t", false}, // a column, not a call
+		{"SELECT now()", false},
+	}
+	for _, tt := range tests {
+		if got := writable.CallsWriteFunction(tt.sql); got != tt.writes {
+			t.Errorf("CallsWriteFunction(%q) = %v, want %v", tt.sql, got, tt.writes)
+		}
+		if tt.writes {
+			assertAllowed(t, writable, tt.sql)
+			assertBlocked(t, readOnly, tt.sql, "is not allowed in read-only mode: it is configured as a function that writes")
+		} else {
+			assertAllowed(t, readOnly, tt.sql)
+		}
+	}
+
+	if NewChecker(defaultConfig()).CallsWriteFunction("SELECT dangerous_fn()") {
+		t.Error("expected no write functions without treat_as_write_functions")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	t.Parallel()
+	tree, err := pg_query.Parse("SELECT f(1), (SELECT g(2)), h(3)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	root := tree.Stmts[0].Stmt.ProtoReflect()
+	calls := func(fn func(name string, m protoreflect.Message) error) []string {
+		var names []string
+		err := Walk(root, func(m protoreflect.Message) error {
+			call, ok := m.Interface().(*pg_query.FuncCall)
+			if !ok {
+				return fn("", m)
+			}
+			name := call.Funcname[0].GetString_().GetSval()
+			names = append(names, name)
+			return fn(name, m)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return names
+	}
+
+	all := calls(func(string, protoreflect.Message) error { return nil })
+	if strings.Join(all, ",") != "f,g,h" {
+		t.Fatalf("expected every call, got %v", all)
+	}
+	skipped := calls(func(_ string, m protoreflect.Message) error {
+		if _, ok := m.Interface().(*pg_query.SubLink); ok {
+			return SkipChildren
+		}
+		return nil
+	})
+	if strings.Join(skipped, ",") != "f,h" {
+		t.Fatalf("expected the subquery skipped, got %v", skipped)
+	}
+	stopped := calls(func(name string, _ protoreflect.Message) error {
+		if name == "g" {
+			return StopWalk
+		}
+		return nil
+	})
+	if strings.Join(stopped, ",") != "f,g" {
+		t.Fatalf("expected the walk to stop at g, got %v", stopped)
+	}
+
+	boom := errors.New("boom")
+	if err := Walk(root, func(protoreflect.Message) error { return boom }); err != boom {
+		t.Fatalf("expected the callback's error, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package sanitize
+
+import "slices"
+
+// presets holds the built-in rule sets, in the order they are applied when several are
+// enabled: card numbers before SSNs and phone numbers, so shorter patterns never split a
+// longer number.
+var presets = []struct {
+	name  string
+	rules []Rule
+}{
+	// 13 to 19 digits, optionally grouped by spaces or dashes; the last four are kept.
+	// There is no Luhn check, so any such digit run is masked.
+	{"credit_card", []Rule{{Pattern: `\b(?:\d[ -]?){9,15}(\d{4})\b`, Replacement: "****${1}"}}},
+	// NNN-NN-NNNN; the last four are kept.
+	{"ssn", []Rule{{Pattern: `\b\d{3}-\d{2}-(\d{4})\b`, Replacement: "***-**-${1}"}}},
+	// NANP numbers with an optional +1, e.g. (212) 555-0123, 212.555.0123, +1 212 555 0123;
+	// the last four are kept.
+	{"us_phone", []Rule{{Pattern: `(?:\+1[-. ]?\(?[2-9]\d{2}\)?|\([2-9]\d{2}\)|\b[2-9]\d{2})[-. ]?[2-9]\d{2}[-. ]?(\d{4})\b`, Replacement: "***-***-${1}"}}},
+	// The local part is masked, the domain kept.
+	{"email", []Rule{{Pattern: `\b[A-Za-z0-9._%+-]+@((?:[A-Za-z0-9-]+\.)+[A-Za-z]{2,})\b`, Replacement: "***@${1}"}}},
+}
+
+// PresetNames returns the names of the built-in rule sets, in the order they are applied.
+func PresetNames() []string {
+	names := make([]string, len(presets))
+	for i, p := range presets {
+		names[i] = p.name
+	}
+	return names
+}
+
+// PresetRules returns the rules of the named built-in sets, in application order
+// (regardless of the order of names). ok is false if a name is unknown.
+func PresetRules(names []string) (rules []Rule, ok bool) {
+	for _, name := range names {
+		if !slices.Contains(PresetNames(), name) {
+			return nil, false
+		}
+	}
+	for _, p := range presets {
+		if slices.Contains(names, p.name) {
+			rules = append(rules, p.rules...)
+		}
+	}
+	return rules, true
+}
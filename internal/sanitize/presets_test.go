@@ -0,0 +1,81 @@
+package sanitize
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPresets(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		preset string
+		input  string
+		want   string
+	}{
+		{"email", "alice.smith+news@example.co.uk", "***@example.co.uk"},
+		{"email", "contact bob_99@mail.company.com today", "contact ***@mail.company.com today"},
+		{"email", "not an email: user@localhost, @handle, a@b", "not an email: user@localhost, @handle, a@b"},
+
+		{"us_phone", "(212) 555-0123", "***-***-0123"},
+		{"us_phone", "call 212-555-0123 or 212.555.0199", "call ***-***-0123 or ***-***-0199"},
+		{"us_phone", "+1 212 555 0123", "***-***-0123"},
+		{"us_phone", "+12125550123", "***-***-0123"},
+		{"us_phone", "2125550123", "***-***-0123"},
+		{"us_phone", "order 1125550123, zip 10001, 555-0123", "order 1125550123, zip 10001, 555-0123"},
+		{"us_phone", "21255501234", "21255501234"},
+
+		{"ssn", "123-45-6789", "***-**-6789"},
+		{"ssn", "SSN: 078-05-1120.", "SSN: ***-**-1120."},
+		{"ssn", "123456789, 1234-56-7890, 2024-01-15", "123456789, 1234-56-7890, 2024-01-15"},
+
+		{"credit_card", "4111111111111111", "****1111"},
+		{"credit_card", "card 4111 1111 1111 1234 exp 12/27", "card ****1234 exp 12/27"},
+		{"credit_card", "5500-0000-0000-0004", "****0004"},
+		{"credit_card", "378282246310005", "****0005"},
+		{"credit_card", "id 123456789012, ts 2024-01-15 12:30:00", "id 123456789012, ts 2024-01-15 12:30:00"},
+	}
+	for _, tt := range tests {
+		rules, ok := PresetRules([]string{tt.preset})
+		if !ok || len(rules) == 0 {
+			t.Fatalf("preset %q: expected rules", tt.preset)
+		}
+		s, err := NewSanitizer(rules)
+		if err != nil {
+			t.Fatalf("preset %q: unexpected error: %v", tt.preset, err)
+		}
+		if got := s.sanitizeValue(tt.input); got != tt.want {
+			t.Errorf("preset %q: %q: expected %q, got %q", tt.preset, tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestPresets_Combined(t *testing.T) {
+	t.Parallel()
+	// Listed order does not matter: card numbers are masked before phone numbers.
+	rules, ok := PresetRules([]string{"email", "us_phone", "ssn", "credit_card"})
+	if !ok {
+		t.Fatal("expected all presets to be known")
+	}
+	s, err := NewSanitizer(rules)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	input := "jane@example.com, 212-555-0123, 123-45-6789, 4242 4242 4242 4242"
+	want := "***@example.com, ***-***-0123, ***-**-6789, ****4242"
+	if got := s.sanitizeValue(input); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestPresetRules_Unknown(t *testing.T) {
+	t.Parallel()
+	if _, ok := PresetRules([]string{"email", "passport"}); ok {
+		t.Fatal("expected unknown preset to be rejected")
+	}
+	if rules, ok := PresetRules(nil); !ok || rules != nil {
+		t.Fatalf("expected no rules for no presets, got %v", rules)
+	}
+	if names := PresetNames(); !slices.Equal(names, []string{"credit_card", "ssn", "us_phone", "email"}) {
+		t.Fatalf("unexpected preset names %v", names)
+	}
+}
@@ -3,17 +3,20 @@ package sanitize
 import (
 	"fmt"
 	"regexp"
+	"sort"
 )
 
 // Rule is the sanitizer's own rule type.
 type Rule struct {
 	Pattern     string
 	Replacement string
+	Priority    int // higher is applied first; ties keep slice order
 }
 
 type compiledRule struct {
 	pattern     *regexp.Regexp
 	replacement string
+	priority    int
 }
 
 // Sanitizer applies regex-based sanitization to result row field values.
@@ -29,8 +32,9 @@ func NewSanitizer(rules []Rule) (*Sanitizer, error) {
 		if err != nil {
 			return nil, fmt.Errorf("sanitize: invalid regex pattern %q: %v", r.Pattern, err)
 		}
-		compiled[i] = compiledRule{pattern: re, replacement: r.Replacement}
+		compiled[i] = compiledRule{pattern: re, replacement: r.Replacement, priority: r.Priority}
 	}
+	sort.SliceStable(compiled, func(i, j int) bool { return compiled[i].priority > compiled[j].priority })
 	return &Sanitizer{rules: compiled}, nil
 }
 
@@ -71,6 +71,24 @@ func TestMultipleRulesOrdering(t *testing.T) {
 	}
 }
 
+func TestMultipleRulesPriorityOrdering(t *testing.T) {
+	t.Parallel()
+	// The "xxx" rule is listed second but has higher priority, so it runs before the phone
+	// rule and finds nothing to replace; the phone rule's "xxx" output survives.
+	rules := []Rule{
+		phoneRule,
+		{Pattern: `xxx`, Replacement: "***", Priority: 1},
+	}
+	s, err := NewSanitizer(rules)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result := s.sanitizeValue("+62821233447")
+	if result != "+62xxx447" {
+		t.Fatalf("expected +62xxx447, got %v", result)
+	}
+}
+
 func TestSanitizeJSONBField(t *testing.T) {
 	t.Parallel()
 	s, err := NewSanitizer([]Rule{phoneRule})
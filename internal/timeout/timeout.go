@@ -3,13 +3,15 @@ package timeout
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"time"
 )
 
 // Rule is the timeout manager's own rule type.
 type Rule struct {
-	Pattern string
-	Timeout time.Duration
+	Pattern  string
+	Timeout  time.Duration
+	Priority int // higher is evaluated first; ties keep slice order
 }
 
 // Config is the timeout manager's own config type.
@@ -19,8 +21,9 @@ type Config struct {
 }
 
 type compiledRule struct {
-	pattern *regexp.Regexp
-	timeout time.Duration
+	pattern  *regexp.Regexp
+	timeout  time.Duration
+	priority int
 }
 
 // Manager resolves query timeouts based on SQL pattern matching.
@@ -37,13 +40,15 @@ func NewManager(config Config) (*Manager, error) {
 		if err != nil {
 			return nil, fmt.Errorf("timeout: invalid regex pattern %q: %v", r.Pattern, err)
 		}
-		compiled[i] = compiledRule{pattern: re, timeout: r.Timeout}
+		compiled[i] = compiledRule{pattern: re, timeout: r.Timeout, priority: r.Priority}
 	}
+	sort.SliceStable(compiled, func(i, j int) bool { return compiled[i].priority > compiled[j].priority })
 	return &Manager{rules: compiled, defaultTimeout: config.DefaultTimeout}, nil
 }
 
 // GetTimeout returns the timeout for the given SQL.
-// First matching rule wins. Falls back to default.
+// Rules are evaluated by descending Priority, then slice order; the first match wins.
+// Falls back to default.
 func (m *Manager) GetTimeout(sql string) time.Duration {
 	t, _ := m.GetTimeoutWithPattern(sql)
 	return t
@@ -44,6 +44,50 @@ func TestStopOnFirstMatch(t *testing.T) {
 	}
 }
 
+func TestPriorityOverridesSliceOrder(t *testing.T) {
+	t.Parallel()
+	// A broad SELECT rule listed first would normally win; the specific pg_sleep rule has higher priority.
+	m, err := NewManager(Config{
+		DefaultTimeout: 30 * time.Second,
+		Rules: []Rule{
+			{Pattern: "(?i)^SELECT", Timeout: 10 * time.Second},
+			{Pattern: "pg_sleep", Timeout: 2 * time.Second, Priority: 10},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, pattern := m.GetTimeoutWithPattern("SELECT pg_sleep(5)")
+	if got != 2*time.Second || pattern != "pg_sleep" {
+		t.Errorf("expected 2s from pg_sleep (higher priority), got %v from %q", got, pattern)
+	}
+	got, pattern = m.GetTimeoutWithPattern("SELECT 1")
+	if got != 10*time.Second || pattern != "(?i)^SELECT" {
+		t.Errorf("expected 10s from SELECT rule, got %v from %q", got, pattern)
+	}
+}
+
+func TestEqualPriorityKeepsSliceOrder(t *testing.T) {
+	t.Parallel()
+	m, err := NewManager(Config{
+		DefaultTimeout: 30 * time.Second,
+		Rules: []Rule{
+			{Pattern: "low", Timeout: 1 * time.Second, Priority: -1},
+			{Pattern: "JOIN", Timeout: 60 * time.Second, Priority: 5},
+			{Pattern: "pg_stat", Timeout: 5 * time.Second, Priority: 5},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := m.GetTimeout("SELECT * FROM pg_stat JOIN low")
+	if got != 60*time.Second {
+		t.Errorf("expected 60s (first of equal-priority rules wins), got %v", got)
+	}
+}
+
 func TestDefaultTimeout(t *testing.T) {
 	t.Parallel()
 	m, err := NewManager(Config{
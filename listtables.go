@@ -1,8 +1,11 @@
 package pgmcp
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -18,20 +21,65 @@ SELECT
         WHEN 'p' THEN 'partitioned_table'
     END AS type,
     pg_catalog.pg_get_userbyid(c.relowner) AS owner,
-    NOT has_schema_privilege(n.oid, 'USAGE') AS schema_access_limited
+    NOT has_schema_privilege(n.oid, 'USAGE') AS schema_access_limited,
+    quote_ident(n.nspname) || '.' || quote_ident(c.relname) AS quoted_name
+` + listTablesFromSQL + `
+ORDER BY n.nspname, c.relname
+LIMIT $3;
+`
+
+// listTablesFromSQL selects the relations ListTables reports. $1 is the schema filter and
+// $2 the name filter; an empty string matches everything.
+const listTablesFromSQL = `
 FROM pg_catalog.pg_class c
 LEFT JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
 WHERE c.relkind IN ('r', 'v', 'm', 'f', 'p')
   AND n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')
   AND has_table_privilege(c.oid, 'SELECT')
-ORDER BY n.nspname, c.relname;
-`
+  AND ($1::text = '' OR n.nspname = $1::text)
+  AND ($2::text = '' OR strpos(lower(c.relname), lower($2::text)) > 0)`
+
+// countTablesSQL counts every relation matching the filters, for ListTablesOutput.TotalCount
+// when the list is truncated.
+const countTablesSQL = `SELECT count(*)` + listTablesFromSQL
+
+// defaultMaxTablesListed is query.max_tables_listed when 0.
+const defaultMaxTablesListed = 10000
+
+// sortTableEntries sorts tables by schema, then name, in byte order or case-insensitively
+// (query.sort_discovery_results). Sorted in Go so the order does not depend on the
+// database's collation. Case-insensitive ties (e.g. "Users" and "users") fall back to
+// byte order, keeping the result deterministic.
+func sortTableEntries(tables []TableEntry, caseInsensitive bool) {
+	compare := strings.Compare
+	if caseInsensitive {
+		compare = func(a, b string) int {
+			if c := strings.Compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+				return c
+			}
+			return strings.Compare(a, b)
+		}
+	}
+	slices.SortFunc(tables, func(a, b TableEntry) int {
+		if c := compare(a.Schema, b.Schema); c != 0 {
+			return c
+		}
+		return compare(a.Name, b.Name)
+	})
+}
 
-// ListTables returns all tables, views, materialized views, and foreign tables
-// accessible to the current user. Does NOT go through the hook/protection/sanitization pipeline.
+// ListTables returns the tables, views, materialized views, and foreign tables accessible
+// to the current user, optionally filtered by schema and name, up to
+// query.max_tables_listed. Does NOT go through the hook/protection/sanitization pipeline.
 func (p *PostgresMcp) ListTables(ctx context.Context, input ListTablesInput) (*ListTablesOutput, error) {
 	startTime := time.Now()
 
+	ctx, done, err := p.inflight.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ListTables: %w", err)
+	}
+	defer done()
+
 	// 1. Acquire semaphore
 	select {
 	case p.semaphore <- struct{}{}:
@@ -39,6 +87,9 @@ func (p *PostgresMcp) ListTables(ctx context.Context, input ListTablesInput) (*L
 		return nil, fmt.Errorf("ListTables: failed to acquire query slot: all %d connection slots are in use, context cancelled while waiting: %w", cap(p.semaphore), ctx.Err())
 	}
 	defer func() { <-p.semaphore }()
+	if err := p.ensureStarted(ctx); err != nil {
+		return nil, fmt.Errorf("ListTables: %w", err)
+	}
 
 	// 2. Apply configurable timeout
 	queryCtx, cancel := context.WithTimeout(ctx, time.Duration(p.config.Query.ListTablesTimeoutSeconds)*time.Second)
@@ -51,7 +102,9 @@ func (p *PostgresMcp) ListTables(ctx context.Context, input ListTablesInput) (*L
 	}
 	defer conn.Release()
 
-	rows, err := conn.Query(queryCtx, listTablesSQL)
+	// One row past the cap tells whether the list is truncated without counting.
+	maxTables := cmp.Or(p.config.Query.MaxTablesListed, defaultMaxTablesListed)
+	rows, err := conn.Query(queryCtx, listTablesSQL, input.Schema, input.Name, maxTables+1)
 	if err != nil {
 		return nil, fmt.Errorf("ListTables query failed: %w", err)
 	}
@@ -60,23 +113,64 @@ func (p *PostgresMcp) ListTables(ctx context.Context, input ListTablesInput) (*L
 	var tables []TableEntry
 	for rows.Next() {
 		var entry TableEntry
-		if err := rows.Scan(&entry.Schema, &entry.Name, &entry.Type, &entry.Owner, &entry.SchemaAccessLimited); err != nil {
+		var quotedName string
+		if err := rows.Scan(&entry.Schema, &entry.Name, &entry.Type, &entry.Owner, &entry.SchemaAccessLimited, &quotedName); err != nil {
 			return nil, fmt.Errorf("ListTables scan failed: %w", err)
 		}
+		if p.config.Query.IncludeQuotedNames {
+			entry.QuotedName = quotedName
+		}
 		tables = append(tables, entry)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("ListTables rows error: %w", err)
 	}
 
+	rows.Close()
+
+	output := &ListTablesOutput{}
+	if len(tables) > maxTables {
+		tables = tables[:maxTables]
+		output.Truncated = true
+		if err := conn.QueryRow(queryCtx, countTablesSQL, input.Schema, input.Name).Scan(&output.TotalCount); err != nil {
+			return nil, fmt.Errorf("ListTables count failed: %w", err)
+		}
+	}
 	if tables == nil {
 		tables = []TableEntry{}
 	}
+	if p.config.Query.SortDiscoveryResults {
+		sortTableEntries(tables, p.config.Query.SortDiscoveryCaseInsensitive)
+	}
 
 	p.logger.Info().
 		Dur("duration", time.Since(startTime)).
 		Int("table_count", len(tables)).
+		Bool("truncated", output.Truncated).
 		Msg("ListTables executed")
 
-	return &ListTablesOutput{Tables: tables}, nil
+	if input.GroupBySchema {
+		output.Tables = []TableEntry{}
+		output.Schemas = groupTablesBySchema(tables)
+	} else {
+		output.Tables = tables
+	}
+	return output, nil
+}
+
+// groupTablesBySchema nests tables under their schemas (ListTablesInput.GroupBySchema),
+// keeping schemas in order of first appearance and tables in their listed order.
+func groupTablesBySchema(tables []TableEntry) []SchemaTables {
+	groups := []SchemaTables{}
+	index := map[string]int{}
+	for _, entry := range tables {
+		i, ok := index[entry.Schema]
+		if !ok {
+			i = len(groups)
+			index[entry.Schema] = i
+			groups = append(groups, SchemaTables{Schema: entry.Schema})
+		}
+		groups[i].Tables = append(groups[i].Tables, entry)
+	}
+	return groups
 }
@@ -44,6 +44,62 @@ func TestListTables_Basic(t *testing.T) {
 	}
 }
 
+func TestListTables_QuotedName(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Query.IncludeQuotedNames = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, `CREATE TABLE "MixedCase" (id int)`)
+	setupTable(t, p, `CREATE TABLE "order items" (id int)`)
+	setupTable(t, p, "CREATE TABLE plain_name (id int)")
+
+	output, err := p.ListTables(context.Background(), pgmcp.ListTablesInput{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	quoted := map[string]string{}
+	for _, tbl := range output.Tables {
+		quoted[tbl.Name] = tbl.QuotedName
+	}
+	expected := map[string]string{
+		"MixedCase":   `public."MixedCase"`,
+		"order items": `public."order items"`,
+		"plain_name":  "public.plain_name",
+	}
+	for name, want := range expected {
+		if quoted[name] != want {
+			t.Fatalf("expected quoted_name %s for %q, got %q", want, name, quoted[name])
+		}
+	}
+
+	// The quoted name is usable as-is in a query.
+	q := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT count(*) FROM " + quoted["order items"]})
+	if q.Error != "" {
+		t.Fatalf("expected quoted_name to be usable in SQL, got error: %s", q.Error)
+	}
+}
+
+func TestListTables_QuotedNameOmittedByDefault(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, `CREATE TABLE "MixedCase" (id int)`)
+
+	output, err := p.ListTables(context.Background(), pgmcp.ListTablesInput{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, tbl := range output.Tables {
+		if tbl.QuotedName != "" {
+			t.Fatalf("expected no quoted_name without include_quoted_names, got %q", tbl.QuotedName)
+		}
+	}
+}
+
 func TestListTables_IncludesViews(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
@@ -548,3 +604,145 @@ func TestListTables_BypassesHookProtectionSanitizationPipeline(t *testing.T) {
 		t.Fatalf("expected 'hook rejected' in error, got %q", queryOutput.Error)
 	}
 }
+
+func TestListTables_SortDiscoveryResults(t *testing.T) {
+	t.Parallel()
+	connStr := acquireTestDB(t)
+	ctx := context.Background()
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setup, err := pgmcp.New(ctx, connStr, setupConfig, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create setup instance: %v", err)
+	}
+	for _, name := range []string{`"Zebra"`, "apple", `"Mango"`, "banana"} {
+		setupTable(t, setup, "CREATE TABLE "+name+" (id int)")
+	}
+	setup.Close(ctx)
+
+	tests := []struct {
+		caseInsensitive bool
+		expected        string
+	}{
+		{false, "Mango,Zebra,apple,banana"},
+		{true, "apple,banana,Mango,Zebra"},
+	}
+	for _, tt := range tests {
+		config := defaultConfig()
+		config.Query.SortDiscoveryResults = true
+		config.Query.SortDiscoveryCaseInsensitive = tt.caseInsensitive
+		p, err := pgmcp.New(ctx, connStr, config, testLogger())
+		if err != nil {
+			t.Fatalf("failed to create instance: %v", err)
+		}
+		output, err := p.ListTables(ctx, pgmcp.ListTablesInput{})
+		p.Close(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var names []string
+		for _, tbl := range output.Tables {
+			if tbl.Schema == "public" {
+				names = append(names, tbl.Name)
+			}
+		}
+		if got := strings.Join(names, ","); got != tt.expected {
+			t.Fatalf("case_insensitive=%v: expected %s, got %s", tt.caseInsensitive, tt.expected, got)
+		}
+	}
+}
+
+func TestListTables_MaxTablesListed(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Query.MaxTablesListed = 20
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE SCHEMA archive")
+	for i := 0; i < 30; i++ {
+		setupTable(t, p, fmt.Sprintf("CREATE TABLE many_%02d (id int)", i))
+	}
+	for i := 0; i < 5; i++ {
+		setupTable(t, p, fmt.Sprintf("CREATE TABLE archive.old_%d (id int)", i))
+	}
+
+	ctx := context.Background()
+	output, err := p.ListTables(ctx, pgmcp.ListTablesInput{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(output.Tables) != 20 || !output.Truncated || output.TotalCount != 35 {
+		t.Fatalf("expected 20 of 35 tables, truncated; got %d tables, truncated=%v, total_count=%d", len(output.Tables), output.Truncated, output.TotalCount)
+	}
+
+	// Filters narrow the list below the cap.
+	output, err = p.ListTables(ctx, pgmcp.ListTablesInput{Schema: "archive"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(output.Tables) != 5 || output.Truncated || output.TotalCount != 0 {
+		t.Fatalf("expected 5 archive tables, not truncated; got %d, truncated=%v, total_count=%d", len(output.Tables), output.Truncated, output.TotalCount)
+	}
+	output, err = p.ListTables(ctx, pgmcp.ListTablesInput{Name: "MANY_1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(output.Tables) != 10 || output.Truncated {
+		t.Fatalf("expected the 10 many_1x tables, not truncated; got %d, truncated=%v", len(output.Tables), output.Truncated)
+	}
+	for _, tbl := range output.Tables {
+		if !strings.HasPrefix(tbl.Name, "many_1") {
+			t.Errorf("unexpected table %s.%s for name filter", tbl.Schema, tbl.Name)
+		}
+	}
+}
+
+func TestListTables_GroupBySchema(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.Protection.AllowDDL = true
+	config.Query.SortDiscoveryResults = true
+	p, _ := newTestInstance(t, config)
+
+	setupTable(t, p, "CREATE SCHEMA reporting")
+	setupTable(t, p, "CREATE SCHEMA staging")
+	setupTable(t, p, "CREATE TABLE public.users (id int)")
+	setupTable(t, p, "CREATE TABLE public.orders (id int)")
+	setupTable(t, p, "CREATE TABLE reporting.daily_sales (id int)")
+	setupTable(t, p, "CREATE VIEW reporting.top_users AS SELECT id FROM public.users")
+	setupTable(t, p, "CREATE TABLE staging.raw_orders (id int)")
+
+	ctx := context.Background()
+	output, err := p.ListTables(ctx, pgmcp.ListTablesInput{GroupBySchema: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(output.Tables) != 0 {
+		t.Fatalf("expected no flat tables when grouped, got %d", len(output.Tables))
+	}
+	var got []string
+	for _, group := range output.Schemas {
+		var names []string
+		for _, tbl := range group.Tables {
+			if tbl.Schema != group.Schema {
+				t.Errorf("table %s.%s listed under schema %s", tbl.Schema, tbl.Name, group.Schema)
+			}
+			names = append(names, tbl.Name)
+		}
+		got = append(got, group.Schema+":"+strings.Join(names, ","))
+	}
+	expected := "public:orders,users reporting:daily_sales,top_users staging:raw_orders"
+	if strings.Join(got, " ") != expected {
+		t.Fatalf("expected groups %q, got %q", expected, strings.Join(got, " "))
+	}
+
+	// The flat list stays the default.
+	output, err = p.ListTables(ctx, pgmcp.ListTablesInput{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(output.Tables) != 5 || output.Schemas != nil {
+		t.Fatalf("expected 5 flat tables and no groups, got %d tables and %d groups", len(output.Tables), len(output.Schemas))
+	}
+}
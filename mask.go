@@ -0,0 +1,117 @@
+package pgmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// maskValue replaces every value of a masked column, including NULLs.
+const maskValue = "***"
+
+// columnMasker replaces values of the columns listed in Config.MaskColumns.
+type columnMasker struct {
+	byName  map[string]bool            // "column" entries: any result column with this name
+	byTable map[string]map[string]bool // "table.column" entries: table name → column names
+}
+
+// newColumnMasker builds a masker from MaskColumns entries ("column" or "table.column").
+// Entries have already been validated by New().
+func newColumnMasker(entries []string) *columnMasker {
+	m := &columnMasker{byName: map[string]bool{}, byTable: map[string]map[string]bool{}}
+	for _, entry := range entries {
+		table, column, qualified := strings.Cut(entry, ".")
+		if !qualified {
+			m.byName[entry] = true
+			continue
+		}
+		if m.byTable[table] == nil {
+			m.byTable[table] = map[string]bool{}
+		}
+		m.byTable[table][column] = true
+	}
+	return m
+}
+
+// hasRules returns true if any columns are configured for masking.
+func (m *columnMasker) hasRules() bool {
+	return m != nil && (len(m.byName) > 0 || len(m.byTable) > 0)
+}
+
+// mask replaces values of matching columns in result. Columns read from a table are
+// matched by their source table and column (resolved from the field's table OID and
+// attribute number), so aliases do not hide them; computed expressions, which have no
+// source column, are matched by their output name against "column" entries.
+func (m *columnMasker) mask(ctx context.Context, tx pgx.Tx, result *QueryOutput, fields []pgconn.FieldDescription) error {
+	sources, err := resolveSourceColumns(ctx, tx, fields)
+	if err != nil {
+		return err
+	}
+	for i, fd := range fields {
+		src, ok := sources[sourceKey{fd.TableOID, fd.TableAttributeNumber}]
+		if ok && !m.byName[src.column] && !m.byTable[src.table][src.column] {
+			continue
+		}
+		if !ok && !m.byName[fd.Name] {
+			continue
+		}
+		for _, row := range result.Rows {
+			row[result.Columns[i]] = maskValue // the row key differs from fd.Name for duplicate names
+		}
+	}
+	return nil
+}
+
+// sourceKey identifies a result column's source: its table OID and attribute number.
+type sourceKey struct {
+	tableOID uint32
+	attnum   uint16
+}
+
+// sourceColumn is the table and column name a result column was read from.
+type sourceColumn struct {
+	table  string
+	column string
+}
+
+// resolveSourceColumns maps the source table OID and attribute number of fields to table
+// and column names through pg_attribute. Only queried when some column comes from a table.
+func resolveSourceColumns(ctx context.Context, tx pgx.Tx, fields []pgconn.FieldDescription) (map[sourceKey]sourceColumn, error) {
+	var oids []uint32
+	var attnums []int16
+	for _, fd := range fields {
+		if fd.TableOID != 0 && fd.TableAttributeNumber > 0 {
+			oids = append(oids, fd.TableOID)
+			attnums = append(attnums, int16(fd.TableAttributeNumber))
+		}
+	}
+	if len(oids) == 0 {
+		return nil, nil
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT a.attrelid, a.attnum, c.relname, a.attname
+		FROM unnest($1::oid[], $2::int2[]) AS f(rel, num)
+		JOIN pg_catalog.pg_attribute a ON a.attrelid = f.rel AND a.attnum = f.num
+		JOIN pg_catalog.pg_class c ON c.oid = a.attrelid`, oids, attnums)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve source columns for mask_columns: %w", err)
+	}
+	defer rows.Close()
+	sources := make(map[sourceKey]sourceColumn, len(oids))
+	for rows.Next() {
+		var oid uint32
+		var attnum int16
+		var src sourceColumn
+		if err := rows.Scan(&oid, &attnum, &src.table, &src.column); err != nil {
+			return nil, fmt.Errorf("failed to resolve source columns for mask_columns: %w", err)
+		}
+		sources[sourceKey{oid, uint16(attnum)}] = src
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to resolve source columns for mask_columns: %w", err)
+	}
+	return sources, nil
+}
@@ -1,15 +1,18 @@
 package pgmcp
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"fmt"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
-// RegisterMCPTools registers Query, ListTables, and DescribeTable
-// as MCP tools on the given MCP server.
+// RegisterMCPTools registers Query, ListTables, DescribeTable, and DescribeQuery as MCP
+// tools on the given MCP server, plus wait_for_notification when
+// protection.allow_listen_notify is set.
 func RegisterMCPTools(mcpServer *server.MCPServer, pgMcp *PostgresMcp) {
 	// Query tool
 	queryTool := mcp.NewTool("query",
@@ -18,6 +21,9 @@ func RegisterMCPTools(mcpServer *server.MCPServer, pgMcp *PostgresMcp) {
 			mcp.Required(),
 			mcp.Description("The SQL query to execute"),
 		),
+		mcp.WithBoolean("include_plan",
+			mcp.Description("For SELECT queries, also return plan_summary (estimated rows, total cost, top plan node, sequential scans) from EXPLAIN"),
+		),
 	)
 
 	mcpServer.AddTool(queryTool, pgMcp.loggedToolHandler("query", func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -25,7 +31,7 @@ func RegisterMCPTools(mcpServer *server.MCPServer, pgMcp *PostgresMcp) {
 		if err != nil {
 			return mcp.NewToolResultError("sql parameter is required"), nil
 		}
-		output := pgMcp.Query(ctx, QueryInput{SQL: sql})
+		output := pgMcp.Query(withMCPCallInfo(ctx), QueryInput{SQL: sql, IncludePlan: req.GetBool("include_plan", false)})
 		if output.Error != "" {
 			return mcp.NewToolResultError(output.Error), nil
 		}
@@ -38,12 +44,21 @@ func RegisterMCPTools(mcpServer *server.MCPServer, pgMcp *PostgresMcp) {
 
 	// ListTables tool
 	listTablesTool := mcp.NewTool("list_tables",
-		mcp.WithDescription("List all tables, views, materialized views, and foreign tables in the database that are accessible to the current user."),
+		mcp.WithDescription("List all tables, views, materialized views, and foreign tables in the database that are accessible to the current user. If the result is marked truncated, narrow it with the schema and name filters."),
+		mcp.WithString("schema",
+			mcp.Description("Only list relations in this schema"),
+		),
+		mcp.WithString("name",
+			mcp.Description("Only list relations whose name contains this text (case-insensitive)"),
+		),
+		mcp.WithBoolean("group_by_schema",
+			mcp.Description("Return relations nested by schema in 'schemas' instead of the flat 'tables' list (default: false)"),
+		),
 		mcp.WithReadOnlyHintAnnotation(true),
 	)
 
 	mcpServer.AddTool(listTablesTool, pgMcp.loggedToolHandler("list_tables", func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		output, err := pgMcp.ListTables(ctx, ListTablesInput{})
+		output, err := pgMcp.ListTables(ctx, ListTablesInput{Schema: req.GetString("schema", ""), Name: req.GetString("name", ""), GroupBySchema: req.GetBool("group_by_schema", false)})
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
@@ -64,6 +79,9 @@ func RegisterMCPTools(mcpServer *server.MCPServer, pgMcp *PostgresMcp) {
 		mcp.WithString("schema",
 			mcp.Description("The schema name (defaults to 'public')"),
 		),
+		mcp.WithNumber("sample_rows",
+			mcp.Description(fmt.Sprintf("Number of example rows to include (default %d, max %d)", pgMcp.config.Query.DefaultSampleRows, cmp.Or(pgMcp.config.Query.MaxSampleRows, defaultMaxSampleRows))),
+		),
 		mcp.WithReadOnlyHintAnnotation(true),
 	)
 
@@ -74,7 +92,7 @@ func RegisterMCPTools(mcpServer *server.MCPServer, pgMcp *PostgresMcp) {
 		}
 		schema := req.GetString("schema", "")
 
-		output, err := pgMcp.DescribeTable(ctx, DescribeTableInput{Table: table, Schema: schema})
+		output, err := pgMcp.DescribeTable(ctx, DescribeTableInput{Table: table, Schema: schema, SampleRows: req.GetInt("sample_rows", 0)})
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
@@ -84,6 +102,96 @@ func RegisterMCPTools(mcpServer *server.MCPServer, pgMcp *PostgresMcp) {
 		}
 		return mcp.NewToolResultText(string(jsonBytes)), nil
 	}))
+
+	// DescribeQuery tool
+	describeQueryTool := mcp.NewTool("describe_query",
+		mcp.WithDescription("Return the result columns and their types for a SQL statement without executing it. Use it to check the shape of an expensive query, or the RETURNING columns of a write, before running it."),
+		mcp.WithString("sql",
+			mcp.Required(),
+			mcp.Description("The SQL statement to describe"),
+		),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+
+	mcpServer.AddTool(describeQueryTool, pgMcp.loggedToolHandler("describe_query", func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sql, err := req.RequireString("sql")
+		if err != nil {
+			return mcp.NewToolResultError("sql parameter is required"), nil
+		}
+		output, err := pgMcp.DescribeQuery(ctx, DescribeQueryInput{SQL: sql})
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		jsonBytes, err := json.Marshal(output)
+		if err != nil {
+			return mcp.NewToolResultError("failed to marshal describe query result"), nil
+		}
+		return mcp.NewToolResultText(string(jsonBytes)), nil
+	}))
+
+	if pgMcp.config.ExposeCapabilities {
+		capabilitiesTool := mcp.NewTool("capabilities",
+			mcp.WithDescription("Report which SQL operations are allowed or blocked, whether the database is read-only, and the query limits (SQL length, result size, row caps, timeout). Check it before writing statements that might be rejected."),
+			mcp.WithReadOnlyHintAnnotation(true),
+		)
+
+		mcpServer.AddTool(capabilitiesTool, pgMcp.loggedToolHandler("capabilities", func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			jsonBytes, err := json.Marshal(pgMcp.Capabilities(ctx))
+			if err != nil {
+				return mcp.NewToolResultError("failed to marshal capabilities"), nil
+			}
+			return mcp.NewToolResultText(string(jsonBytes)), nil
+		}))
+	}
+
+	if !pgMcp.config.Protection.AllowListenNotify {
+		return
+	}
+
+	// WaitForNotification tool
+	waitTool := mcp.NewTool("wait_for_notification",
+		mcp.WithDescription("Wait for a NOTIFY on a channel (e.g. to learn when a job finishes). Blocks until a notification arrives or the timeout passes; only notifications sent after the call starts are seen."),
+		mcp.WithString("channel",
+			mcp.Required(),
+			mcp.Description("The channel to LISTEN on"),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("How long to wait (default 30, max 300)"),
+		),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+
+	mcpServer.AddTool(waitTool, pgMcp.loggedToolHandler("wait_for_notification", func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		channel, err := req.RequireString("channel")
+		if err != nil {
+			return mcp.NewToolResultError("channel parameter is required"), nil
+		}
+		output, err := pgMcp.WaitForNotification(ctx, channel, req.GetInt("timeout_seconds", 30))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		jsonBytes, err := json.Marshal(output)
+		if err != nil {
+			return mcp.NewToolResultError("failed to marshal notification"), nil
+		}
+		return mcp.NewToolResultText(string(jsonBytes)), nil
+	}))
+}
+
+// withMCPCallInfo sets CallInfo from the MCP session (client name and session ID), unless
+// the context already carries one.
+func withMCPCallInfo(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(callInfoKey{}).(CallInfo); ok {
+		return ctx
+	}
+	var info CallInfo
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		info.SessionID = session.SessionID()
+		if withInfo, ok := session.(server.SessionWithClientInfo); ok {
+			info.Agent = withInfo.GetClientInfo().Name
+		}
+	}
+	return WithCallInfo(ctx, info)
 }
 
 // loggedToolHandler wraps a tool handler to log request and response lengths.
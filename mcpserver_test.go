@@ -7,6 +7,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -312,8 +313,8 @@ func TestMCPServer_ToolsList(t *testing.T) {
 		t.Fatalf("expected tools array, got %T: %v", resultObj["tools"], resultObj["tools"])
 	}
 
-	if len(tools) != 3 {
-		t.Fatalf("expected 3 tools, got %d", len(tools))
+	if len(tools) != 4 {
+		t.Fatalf("expected 4 tools, got %d", len(tools))
 	}
 
 	toolNames := map[string]bool{}
@@ -322,9 +323,31 @@ func TestMCPServer_ToolsList(t *testing.T) {
 		toolNames[toolMap["name"].(string)] = true
 	}
 
-	for _, expected := range []string{"query", "list_tables", "describe_table"} {
+	for _, expected := range []string{"query", "list_tables", "describe_table", "describe_query"} {
 		if !toolNames[expected] {
 			t.Fatalf("expected tool %q in list, got %v", expected, toolNames)
 		}
 	}
 }
+
+func TestMCPServer_CapabilitiesTool(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.ExposeCapabilities = true
+	s := startMCPTestServer(t, config, "")
+
+	result := s.jsonRPC(t, "tools/call", map[string]interface{}{
+		"name":      "capabilities",
+		"arguments": map[string]interface{}{},
+	})
+	resultObj := result["result"].(map[string]interface{})
+	content := resultObj["content"].([]interface{})
+	text := content[0].(map[string]interface{})["text"].(string)
+	var caps pgmcp.CapabilitiesOutput
+	if err := json.Unmarshal([]byte(text), &caps); err != nil {
+		t.Fatalf("failed to parse capabilities: %v\n%s", err, text)
+	}
+	if !slices.Contains(caps.Allowed, "SELECT") || !slices.Contains(caps.Blocked, "DROP") {
+		t.Fatalf("expected SELECT allowed and DROP blocked, got %+v", caps)
+	}
+}
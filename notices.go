@@ -0,0 +1,45 @@
+package pgmcp
+
+import (
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// noticeCollector gathers NOTICE/WARNING messages per connection while a query runs
+// (query.capture_notices). pgx delivers notices through a single pool-wide OnNotice
+// callback, so messages are routed to the buffer registered for the sending connection.
+type noticeCollector struct {
+	mu     sync.Mutex
+	byConn map[*pgconn.PgConn][]string
+}
+
+func newNoticeCollector() *noticeCollector {
+	return &noticeCollector{byConn: make(map[*pgconn.PgConn][]string)}
+}
+
+// onNotice is installed as pgconn.Config.OnNotice. Notices from connections that are
+// not currently capturing (e.g. during connection setup) are dropped.
+func (c *noticeCollector) onNotice(conn *pgconn.PgConn, n *pgconn.Notice) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if buf, ok := c.byConn[conn]; ok {
+		c.byConn[conn] = append(buf, n.Severity+": "+n.Message)
+	}
+}
+
+// start begins capturing notices sent on conn.
+func (c *noticeCollector) start(conn *pgconn.PgConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.byConn[conn] = []string{}
+}
+
+// stop ends capturing on conn and returns the notices received since start.
+func (c *noticeCollector) stop(conn *pgconn.PgConn) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	notices := c.byConn[conn]
+	delete(c.byConn, conn)
+	return notices
+}
@@ -0,0 +1,71 @@
+package pgmcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxNotificationWaitSeconds caps WaitForNotification's timeout, so an agent cannot hold
+// a dedicated connection open indefinitely.
+const maxNotificationWaitSeconds = 300
+
+// maxNotificationWaiters caps concurrent WaitForNotification calls. Each opens its own
+// connection outside the pool, so this bounds the extra connections waits can open.
+const maxNotificationWaiters = 4
+
+// WaitForNotification listens on channel using a dedicated connection (not from the pool,
+// and not holding a query slot) and returns the first notification received, or TimedOut
+// after timeoutSeconds (at most 300). Only notifications sent after the LISTEN takes effect
+// are seen. At most 4 calls wait at once; further calls fail right away. Requires
+// protection.allow_listen_notify.
+func (p *PostgresMcp) WaitForNotification(ctx context.Context, channel string, timeoutSeconds int) (*NotificationOutput, error) {
+	if !p.config.Protection.AllowListenNotify {
+		return nil, errors.New("WaitForNotification: LISTEN is not allowed: enable protection.allow_listen_notify")
+	}
+	if channel == "" {
+		return nil, errors.New("WaitForNotification: channel is required")
+	}
+	if timeoutSeconds <= 0 || timeoutSeconds > maxNotificationWaitSeconds {
+		return nil, fmt.Errorf("WaitForNotification: timeout_seconds must be between 1 and %d, got %d", maxNotificationWaitSeconds, timeoutSeconds)
+	}
+
+	ctx, done, err := p.inflight.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("WaitForNotification: %w", err)
+	}
+	defer done()
+
+	select {
+	case p.waiters <- struct{}{}:
+	default:
+		return nil, fmt.Errorf("WaitForNotification: all %d notification waits are in use, retry after one finishes", cap(p.waiters))
+	}
+	defer func() { <-p.waiters }()
+	if err := p.ensureStarted(ctx); err != nil {
+		return nil, fmt.Errorf("WaitForNotification: %w", err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	conn, err := connectOutsidePool(waitCtx, p.pool.Config())
+	if err != nil {
+		return nil, fmt.Errorf("WaitForNotification: %w", err)
+	}
+	defer closeConn(conn)
+	if _, err := conn.Exec(waitCtx, "LISTEN "+quoteIdent(channel)); err != nil {
+		return nil, fmt.Errorf("WaitForNotification: LISTEN failed: %w", err)
+	}
+
+	n, err := conn.WaitForNotification(waitCtx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return &NotificationOutput{Channel: channel, TimedOut: true}, nil
+		}
+		return nil, fmt.Errorf("WaitForNotification: %w", err)
+	}
+	p.logger.Info().Str("channel", channel).Uint32("sender_pid", n.PID).Msg("notification received")
+	return &NotificationOutput{Channel: n.Channel, Payload: n.Payload, SenderPID: n.PID}, nil
+}
@@ -1,9 +1,14 @@
 package pgmcp
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -23,14 +28,30 @@ type PostgresMcp struct {
 	config        Config
 	pool          *pgxpool.Pool
 	semaphore     chan struct{}
+	waiters       chan struct{} // WaitForNotification calls, capped separately from queries
 	protection    *protection.Checker
+	readOnlyProt  *protection.Checker    // protection with ReadOnly set, for QueryInput.ForceReadOnly
 	cmdHooks      *hooks.Runner          // command-based hooks (CLI mode)
 	goBeforeHooks []BeforeQueryHookEntry // Go function hooks (library mode)
 	goAfterHooks  []AfterQueryHookEntry  // Go function hooks (library mode)
 	sanitizer     *sanitize.Sanitizer
+	masker        *columnMasker
+	notices       *noticeCollector    // nil unless query.capture_notices
+	secdef        *funcCatalogChecker // nil unless protection.block_security_definer_calls
+	volatile      *funcCatalogChecker // nil unless protection.block_volatile_in_read_only
 	errPrompts    *errprompt.Matcher
 	timeoutMgr    *timeout.Manager
 	logger        zerolog.Logger
+	commentTmpl   *template.Template      // nil unless statement_comment is set
+	requestSeq    atomic.Uint64           // default CallInfo.RequestID for statement comments
+	tenants       *tenantLimiter          // nil unless max_concurrent_per_tenant is set
+	readFlights   *readFlights            // nil unless query.single_flight_reads
+	scratch       *scratchSessions        // nil unless protection.allow_temp_tables
+	conns         *connTracker            // connections checked out of the pool, for CancelAll
+	inflight      inflightTracker         // running tool calls, drained by Shutdown
+	startupMu     sync.Mutex              // serializes runStartupChecks
+	startupDone   atomic.Bool             // startup checks passed, see WaitReady
+	timezone      *atomic.Pointer[string] // set on connections by AfterConnect; see verifyTimezone
 }
 
 // Option is a functional option for New().
@@ -53,7 +74,8 @@ func WithServerHooks(h ServerHooksConfig) Option {
 // In library mode, connString is required — Config.Connection fields are ignored
 // (the CLI is responsible for building connString from Config.Connection + prompted credentials).
 // Panics on invalid config values. Returns error for runtime failures (e.g., pool creation)
-// and invalid regex patterns in error_prompts, sanitization, timeout_rules, and server_hooks.
+// and invalid regex patterns in error_prompts, sanitization, timeout_rules, and server_hooks
+// (or an invalid statement_comment template).
 func New(ctx context.Context, connString string, config Config, logger zerolog.Logger, opts ...Option) (*PostgresMcp, error) {
 	o := &options{}
 	for _, opt := range opts {
@@ -62,6 +84,10 @@ func New(ctx context.Context, connString string, config Config, logger zerolog.L
 
 	// --- Config validation (panics on invalid config) ---
 
+	if err := ApplyPreset(&config); err != nil {
+		panic("pgmcp: " + err.Error())
+	}
+
 	if connString == "" {
 		panic("pgmcp: connString must be non-empty")
 	}
@@ -85,12 +111,63 @@ func New(ctx context.Context, connString string, config Config, logger zerolog.L
 	if config.Query.MaxResultLength == 0 {
 		config.Query.MaxResultLength = 100000
 	}
+	if config.Query.MaxJSONDepth == 0 {
+		config.Query.MaxJSONDepth = defaultMaxJSONDepth
+	}
 	if config.Query.MaxSQLLength < 0 {
 		panic("pgmcp: query.max_sql_length must be > 0")
 	}
+	if config.Query.MaxJSONDepth < 0 {
+		panic("pgmcp: query.max_json_depth must be > 0")
+	}
+	if config.Query.ColumnTypeDetails && !config.Query.IncludeColumnTypes {
+		panic("pgmcp: query.column_type_details requires query.include_column_types")
+	}
+	switch config.Query.MaxSQLLengthUnit {
+	case "", MaxSQLLengthBytes, MaxSQLLengthRunes:
+	default:
+		panic(fmt.Sprintf("pgmcp: query.max_sql_length_unit must be %q or %q, got %q", MaxSQLLengthBytes, MaxSQLLengthRunes, config.Query.MaxSQLLengthUnit))
+	}
+	switch config.Query.BooleanFormat {
+	case "", BooleanFormatBool, BooleanFormatInt, BooleanFormatTF:
+	default:
+		panic(fmt.Sprintf("pgmcp: query.boolean_format must be %q, %q, or %q, got %q", BooleanFormatBool, BooleanFormatInt, BooleanFormatTF, config.Query.BooleanFormat))
+	}
+	if config.Query.DefaultSampleRows < 0 {
+		panic(fmt.Sprintf("pgmcp: query.default_sample_rows must be >= 0, got %d", config.Query.DefaultSampleRows))
+	}
+	if config.Query.RetryOnDeadlock < 0 {
+		panic(fmt.Sprintf("pgmcp: query.retry_on_deadlock must be >= 0, got %d", config.Query.RetryOnDeadlock))
+	}
+	if config.Query.MaxTablesListed < 0 {
+		panic(fmt.Sprintf("pgmcp: query.max_tables_listed must be >= 0, got %d", config.Query.MaxTablesListed))
+	}
+	if config.Query.MaxSampleRows < 0 {
+		panic(fmt.Sprintf("pgmcp: query.max_sample_rows must be >= 0, got %d", config.Query.MaxSampleRows))
+	}
+	if maxSample := cmp.Or(config.Query.MaxSampleRows, defaultMaxSampleRows); config.Query.DefaultSampleRows > maxSample {
+		panic(fmt.Sprintf("pgmcp: query.default_sample_rows (%d) must not exceed query.max_sample_rows (%d)", config.Query.DefaultSampleRows, maxSample))
+	}
+	switch config.Query.SpecialFloatMode {
+	case "", SpecialFloatString, SpecialFloatNull, SpecialFloatError:
+	default:
+		panic(fmt.Sprintf("pgmcp: query.special_float_mode must be %q, %q, or %q, got %q", SpecialFloatString, SpecialFloatNull, SpecialFloatError, config.Query.SpecialFloatMode))
+	}
 	if config.Query.MaxResultLength < 0 {
 		panic("pgmcp: query.max_result_length must be > 0")
 	}
+	if config.Query.MaxRowsAffected < 0 {
+		panic("pgmcp: query.max_rows_affected must be >= 0")
+	}
+	if config.Query.LockTimeoutMillis < 0 {
+		panic("pgmcp: query.lock_timeout_millis must be >= 0")
+	}
+	if config.Query.ExplainSlowQueriesMillis < 0 {
+		panic("pgmcp: query.explain_slow_queries_millis must be >= 0")
+	}
+	if config.Query.AutoLimit < 0 {
+		panic("pgmcp: query.auto_limit must be >= 0")
+	}
 
 	// Validate hook configuration: Go hooks and command hooks are mutually exclusive
 	hasGoHooks := len(config.BeforeQueryHooks) > 0 || len(config.AfterQueryHooks) > 0
@@ -104,6 +181,101 @@ func New(ctx context.Context, connString string, config Config, logger zerolog.L
 		panic("pgmcp: default_hook_timeout_seconds must be > 0 when Go hooks are configured")
 	}
 
+	if config.Protection.MaxInListItems < 0 {
+		panic(fmt.Sprintf("pgmcp: protection.max_in_list_items must be >= 0, got %d", config.Protection.MaxInListItems))
+	}
+	if config.Protection.MaxValuesRows < 0 {
+		panic(fmt.Sprintf("pgmcp: protection.max_values_rows must be >= 0, got %d", config.Protection.MaxValuesRows))
+	}
+	switch config.Protection.OnParseFailure {
+	case "", OnParseFailureBlock, OnParseFailureAllowReads:
+	default:
+		panic(fmt.Sprintf("pgmcp: protection.on_parse_failure must be %q or %q, got %q", OnParseFailureBlock, OnParseFailureAllowReads, config.Protection.OnParseFailure))
+	}
+	for i, a := range config.StartupAssertions {
+		if a.SQL == "" {
+			panic(fmt.Sprintf("pgmcp: startup_assertions[%d].sql must be non-empty", i))
+		}
+	}
+	if config.Query.SortDiscoveryCaseInsensitive && !config.Query.SortDiscoveryResults {
+		panic("pgmcp: query.sort_discovery_case_insensitive requires query.sort_discovery_results")
+	}
+	switch config.Query.DuplicateColumnMode {
+	case "", DuplicateColumnsSuffix, DuplicateColumnsQualify, DuplicateColumnsError:
+	default:
+		panic(fmt.Sprintf("pgmcp: query.duplicate_column_mode must be %q, %q, or %q, got %q", DuplicateColumnsSuffix, DuplicateColumnsQualify, DuplicateColumnsError, config.Query.DuplicateColumnMode))
+	}
+	switch config.Query.ExplainOptionPolicy.Action {
+	case "", ExplainOptionStrip, ExplainOptionReject:
+	default:
+		panic(fmt.Sprintf("pgmcp: query.explain_option_policy.action must be %q or %q, got %q", ExplainOptionStrip, ExplainOptionReject, config.Query.ExplainOptionPolicy.Action))
+	}
+	for _, name := range config.Query.ExplainOptionPolicy.Disallowed {
+		if !explainOptions[strings.ToLower(name)] {
+			panic(fmt.Sprintf("pgmcp: query.explain_option_policy.disallowed has unknown EXPLAIN option %q", name))
+		}
+	}
+	if config.MaxConcurrentPerTenant < 0 {
+		panic(fmt.Sprintf("pgmcp: max_concurrent_per_tenant must be >= 0, got %d", config.MaxConcurrentPerTenant))
+	}
+	for i, tc := range config.TypeCodecs {
+		if tc.Name == "" && tc.OID == 0 {
+			panic(fmt.Sprintf("pgmcp: type_codecs[%d] must set Name or OID", i))
+		}
+		if tc.Decode == nil {
+			panic(fmt.Sprintf("pgmcp: type_codecs[%d] (%s) has nil Decode", i, tc.Name))
+		}
+	}
+	if config.Protection.MaxIdentifierLength < 0 {
+		panic(fmt.Sprintf("pgmcp: protection.max_identifier_length must be >= 0, got %d", config.Protection.MaxIdentifierLength))
+	}
+	if config.Protection.MaxStatementCandidates < 0 {
+		panic(fmt.Sprintf("pgmcp: protection.max_statement_candidates must be >= 0, got %d", config.Protection.MaxStatementCandidates))
+	}
+	for _, entry := range config.MaskColumns {
+		if entry == "" || strings.Count(entry, ".") > 1 || strings.HasPrefix(entry, ".") || strings.HasSuffix(entry, ".") {
+			panic(fmt.Sprintf("pgmcp: invalid mask_columns entry %q: expected \"column\" or \"table.column\"", entry))
+		}
+	}
+	for _, fp := range config.Protection.AllowedQueryFingerprints {
+		if _, err := strconv.ParseUint(fp, 16, 64); err != nil || len(fp) != 16 {
+			panic(fmt.Sprintf("pgmcp: protection.allowed_query_fingerprints entry %q is not a fingerprint: expected 16 hex digits, as returned by QueryFingerprint", fp))
+		}
+	}
+	for _, fn := range config.Protection.TreatAsWriteFunctions {
+		if fn == "" || strings.Count(fn, ".") > 1 || strings.HasPrefix(fn, ".") || strings.HasSuffix(fn, ".") {
+			panic(fmt.Sprintf("pgmcp: invalid protection.treat_as_write_functions entry %q: expected \"function\" or \"schema.function\"", fn))
+		}
+	}
+	switch config.ErrorDetailMode {
+	case "", ErrorDetailFull, ErrorDetailMinimal:
+	default:
+		panic(fmt.Sprintf("pgmcp: error_detail_mode must be %q or %q, got %q", ErrorDetailFull, ErrorDetailMinimal, config.ErrorDetailMode))
+	}
+	if config.SanitizationMaxScannedCells < 0 {
+		panic(fmt.Sprintf("pgmcp: sanitization_max_scanned_cells must be >= 0, got %d", config.SanitizationMaxScannedCells))
+	}
+	switch config.SanitizationOverLimit {
+	case "", SanitizationOverLimitReject, SanitizationOverLimitSkip:
+	default:
+		panic(fmt.Sprintf("pgmcp: sanitization_over_limit must be %q or %q, got %q", SanitizationOverLimitReject, SanitizationOverLimitSkip, config.SanitizationOverLimit))
+	}
+	presetRules, ok := sanitize.PresetRules(config.SanitizationPresets)
+	if !ok {
+		panic(fmt.Sprintf("pgmcp: sanitization_presets must only contain %s, got %q", strings.Join(sanitize.PresetNames(), ", "), config.SanitizationPresets))
+	}
+	if config.MaxTotalHookSeconds < 0 {
+		panic(fmt.Sprintf("pgmcp: max_total_hook_seconds must be >= 0, got %d", config.MaxTotalHookSeconds))
+	}
+	switch config.HookProtectionOrder {
+	case "", HookProtectionOrderHooksFirst, HookProtectionOrderProtectionFirst:
+	default:
+		panic(fmt.Sprintf("pgmcp: hook_protection_order must be %q or %q, got %q", HookProtectionOrderHooksFirst, HookProtectionOrderProtectionFirst, config.HookProtectionOrder))
+	}
+	if config.HookIdleInTransactionTimeoutSeconds < 0 {
+		panic(fmt.Sprintf("pgmcp: hook_idle_in_transaction_timeout_seconds must be >= 0, got %d", config.HookIdleInTransactionTimeoutSeconds))
+	}
+
 	// Validate per-hook timeouts for Go hooks
 	for _, entry := range config.BeforeQueryHooks {
 		if entry.Timeout < 0 {
@@ -116,12 +288,30 @@ func New(ctx context.Context, connString string, config Config, logger zerolog.L
 		}
 	}
 
+	// Validate session role (must be a valid Postgres identifier: non-empty, <= 63 bytes, no NUL)
+	if config.SessionRole != "" {
+		if len(config.SessionRole) > 63 {
+			panic(fmt.Sprintf("pgmcp: session_role %q exceeds 63 bytes", config.SessionRole))
+		}
+		if strings.ContainsRune(config.SessionRole, 0) {
+			panic("pgmcp: session_role must not contain NUL bytes")
+		}
+	}
+
 	// Validate timeout rules
 	for _, rule := range config.Query.TimeoutRules {
 		if rule.TimeoutSeconds <= 0 {
 			panic(fmt.Sprintf("pgmcp: timeout_rule with pattern %q has timeout_seconds <= 0", rule.Pattern))
 		}
 	}
+	for _, tier := range config.Query.TimeoutByEstimatedRows {
+		if tier.MinRows < 0 {
+			panic(fmt.Sprintf("pgmcp: query.timeout_by_estimated_rows tier has min_rows %d, must be >= 0", tier.MinRows))
+		}
+		if tier.TimeoutSeconds <= 0 {
+			panic(fmt.Sprintf("pgmcp: query.timeout_by_estimated_rows tier with min_rows %d has timeout_seconds <= 0", tier.MinRows))
+		}
+	}
 
 	// --- Configure pgxpool ---
 
@@ -156,25 +346,75 @@ func New(ctx context.Context, connString string, config Config, logger zerolog.L
 		}
 		poolConfig.HealthCheckPeriod = d
 	}
+	if config.Pool.StartupProbeTimeout != "" {
+		if _, err := time.ParseDuration(config.Pool.StartupProbeTimeout); err != nil {
+			panic(fmt.Sprintf("pgmcp: invalid pool.startup_probe_timeout %q: %v", config.Pool.StartupProbeTimeout, err))
+		}
+	}
 
-	// Set AfterConnect hook for session-level settings
-	if config.ReadOnly || config.Timezone != "" {
-		poolConfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
-			if config.ReadOnly {
-				if _, err := conn.Exec(ctx, "SET default_transaction_read_only = on"); err != nil {
-					return fmt.Errorf("failed to SET default_transaction_read_only: %w", err)
-				}
+	switch config.TimezoneFallback {
+	case "", TimezoneFallbackError, TimezoneFallbackUTC:
+	default:
+		panic(fmt.Sprintf("pgmcp: timezone_fallback must be %q or %q, got %q", TimezoneFallbackError, TimezoneFallbackUTC, config.TimezoneFallback))
+	}
+	switch config.Pool.MaxConnectionsCheck {
+	case "", MaxConnectionsCheckWarn, MaxConnectionsCheckError, MaxConnectionsCheckOff:
+	default:
+		panic(fmt.Sprintf("pgmcp: pool.max_connections_check must be %q, %q, or %q, got %q", MaxConnectionsCheckWarn, MaxConnectionsCheckError, MaxConnectionsCheckOff, config.Pool.MaxConnectionsCheck))
+	}
+
+	var notices *noticeCollector
+	if config.Query.CaptureNotices {
+		notices = newNoticeCollector()
+		poolConfig.ConnConfig.OnNotice = notices.onNotice
+	}
+
+	// Set on connections by AfterConnect. WaitReady verifies it against the server, and
+	// replaces it with UTC when rejected and timezone_fallback is "utc".
+	timezone := new(atomic.Pointer[string])
+	timezone.Store(&config.Timezone)
+
+	// Set AfterConnect hook for session-level settings and type registration
+	poolConfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		if config.ReadOnly {
+			if _, err := conn.Exec(ctx, "SET default_transaction_read_only = on"); err != nil {
+				return fmt.Errorf("failed to SET default_transaction_read_only: %w", err)
 			}
-			if config.Timezone != "" {
-				escaped := strings.ReplaceAll(config.Timezone, "'", "''")
-				if _, err := conn.Exec(ctx, fmt.Sprintf("SET timezone = '%s'", escaped)); err != nil {
-					return fmt.Errorf("failed to SET timezone: %w", err)
-				}
+		}
+		if tz := *timezone.Load(); tz != "" {
+			escaped := strings.ReplaceAll(tz, "'", "''")
+			if _, err := conn.Exec(ctx, fmt.Sprintf("SET timezone = '%s'", escaped)); err != nil {
+				return fmt.Errorf("failed to SET timezone: %w", err)
+			}
+		}
+		if err := registerVectorType(ctx, conn); err != nil {
+			return err
+		}
+		return registerTypeCodecs(ctx, conn, config.TypeCodecs)
+	}
+
+	// Enforce session role on every connection checkout. Server-issued, so it bypasses
+	// AllowSet; agent-issued role changes are blocked by protection (LockSessionRole).
+	if config.SessionRole != "" {
+		setRoleSQL := "SET ROLE " + quoteIdent(config.SessionRole)
+		poolConfig.PrepareConn = func(ctx context.Context, conn *pgx.Conn) (bool, error) {
+			if _, err := conn.Exec(ctx, setRoleSQL); err != nil {
+				return false, fmt.Errorf("failed to SET ROLE %s: %w", config.SessionRole, err)
 			}
-			return nil
+			return true, nil
+		}
+		poolConfig.AfterRelease = func(conn *pgx.Conn) bool {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err := conn.Exec(ctx, "RESET ROLE")
+			return err == nil // destroy the connection if the role could not be reset
 		}
 	}
 
+	// Track checked-out connections so CancelAll can send the server cancel requests.
+	conns := newConnTracker()
+	poolConfig.ConnConfig.Tracer = conns
+
 	// --- Create pool ---
 
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
@@ -184,34 +424,18 @@ func New(ctx context.Context, connString string, config Config, logger zerolog.L
 
 	// --- Initialize internal components ---
 
-	protectionChecker := protection.NewChecker(protection.Config{
-		AllowSet:                config.Protection.AllowSet,
-		AllowDrop:               config.Protection.AllowDrop,
-		AllowTruncate:           config.Protection.AllowTruncate,
-		AllowDo:                 config.Protection.AllowDo,
-		AllowCopyFrom:           config.Protection.AllowCopyFrom,
-		AllowCopyTo:             config.Protection.AllowCopyTo,
-		AllowCreateFunction:     config.Protection.AllowCreateFunction,
-		AllowPrepare:            config.Protection.AllowPrepare,
-		AllowDeleteWithoutWhere: config.Protection.AllowDeleteWithoutWhere,
-		AllowUpdateWithoutWhere: config.Protection.AllowUpdateWithoutWhere,
-		AllowAlterSystem:        config.Protection.AllowAlterSystem,
-		AllowMerge:              config.Protection.AllowMerge,
-		AllowGrantRevoke:        config.Protection.AllowGrantRevoke,
-		AllowManageRoles:        config.Protection.AllowManageRoles,
-		AllowCreateExtension:    config.Protection.AllowCreateExtension,
-		AllowLockTable:          config.Protection.AllowLockTable,
-		AllowListenNotify:       config.Protection.AllowListenNotify,
-		AllowMaintenance:        config.Protection.AllowMaintenance,
-		AllowDDL:                config.Protection.AllowDDL,
-		AllowDiscard:            config.Protection.AllowDiscard,
-		AllowComment:            config.Protection.AllowComment,
-		AllowCreateTrigger:      config.Protection.AllowCreateTrigger,
-		AllowCreateRule:         config.Protection.AllowCreateRule,
-		ReadOnly:                config.ReadOnly,
-	})
+	protectionConfig := mapProtectionConfig(config.Protection)
+	protectionConfig.ReadOnly = config.ReadOnly
+	protectionConfig.LockSessionRole = config.SessionRole != ""
+	protectionConfig.BlockExplainAnalyze = config.Query.BlockExplainAnalyze
+	protectionChecker := protection.NewChecker(protectionConfig)
+	readOnlyChecker := protectionChecker
+	if !config.ReadOnly {
+		protectionConfig.ReadOnly = true
+		readOnlyChecker = protection.NewChecker(protectionConfig)
+	}
 
-	san, err := sanitize.NewSanitizer(mapSanitizationRules(config.Sanitization))
+	san, err := sanitize.NewSanitizer(append(mapSanitizationRules(config.Sanitization), presetRules...))
 	if err != nil {
 		return nil, fmt.Errorf("invalid sanitization config: %w", err)
 	}
@@ -222,8 +446,9 @@ func New(ctx context.Context, connString string, config Config, logger zerolog.L
 	timeoutRules := make([]timeout.Rule, len(config.Query.TimeoutRules))
 	for i, r := range config.Query.TimeoutRules {
 		timeoutRules[i] = timeout.Rule{
-			Pattern: r.Pattern,
-			Timeout: time.Duration(r.TimeoutSeconds) * time.Second,
+			Pattern:  r.Pattern,
+			Timeout:  time.Duration(r.TimeoutSeconds) * time.Second,
+			Priority: r.Priority,
 		}
 	}
 	tmgr, err := timeout.NewManager(timeout.Config{
@@ -234,6 +459,35 @@ func New(ctx context.Context, connString string, config Config, logger zerolog.L
 		return nil, fmt.Errorf("invalid timeout_rules config: %w", err)
 	}
 
+	var secdef, volatile *funcCatalogChecker
+	if config.Protection.BlockSecurityDefinerCalls {
+		secdef = newSecurityDefinerChecker()
+	}
+	if config.Protection.BlockVolatileInReadOnly {
+		volatile = newVolatileChecker()
+	}
+	var tenants *tenantLimiter
+	if config.MaxConcurrentPerTenant > 0 {
+		tenants = newTenantLimiter(config.MaxConcurrentPerTenant)
+	}
+	var flights *readFlights
+	if config.Query.SingleFlightReads {
+		flights = newReadFlights()
+	}
+	var scratch *scratchSessions
+	if config.Protection.AllowTempTables {
+		scratch = newScratchSessions(func(ctx context.Context) (*pgx.Conn, error) {
+			return connectOutsidePool(ctx, pool.Config())
+		}, conns)
+	}
+
+	var commentTmpl *template.Template
+	if config.StatementComment != "" {
+		if commentTmpl, err = parseStatementComment(config.StatementComment); err != nil {
+			return nil, fmt.Errorf("invalid statement_comment: %w", err)
+		}
+	}
+
 	// Initialize command hooks if configured
 	var cmdHooks *hooks.Runner
 	if hasCmdHooks {
@@ -263,28 +517,237 @@ func New(ctx context.Context, connString string, config Config, logger zerolog.L
 		config:        config,
 		pool:          pool,
 		semaphore:     make(chan struct{}, config.Pool.MaxConns),
+		waiters:       make(chan struct{}, maxNotificationWaiters),
 		protection:    protectionChecker,
+		readOnlyProt:  readOnlyChecker,
 		cmdHooks:      cmdHooks,
 		goBeforeHooks: config.BeforeQueryHooks,
 		goAfterHooks:  config.AfterQueryHooks,
 		sanitizer:     san,
+		masker:        newColumnMasker(config.MaskColumns),
+		notices:       notices,
 		errPrompts:    matcher,
 		timeoutMgr:    tmgr,
 		logger:        logger,
+		commentTmpl:   commentTmpl,
+		secdef:        secdef,
+		volatile:      volatile,
+		tenants:       tenants,
+		readFlights:   flights,
+		scratch:       scratch,
+		conns:         conns,
+		timezone:      timezone,
 	}, nil
 }
 
+// runStartupAssertions runs each assertion and compares its single value, in text form,
+// with the expected value. NULL never matches.
+func runStartupAssertions(ctx context.Context, pool *pgxpool.Pool, assertions []StartupAssertion) error {
+	for _, a := range assertions {
+		rows, err := pool.Query(ctx, a.SQL, pgx.QueryResultFormats{pgx.TextFormatCode})
+		if err != nil {
+			return fmt.Errorf("startup assertion %q failed: %w", a.SQL, err)
+		}
+		var got *string
+		if rows.Next() {
+			if raw := rows.RawValues(); len(raw) > 0 && raw[0] != nil {
+				v := string(raw[0])
+				got = &v
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("startup assertion %q failed: %w", a.SQL, err)
+		}
+		if got == nil {
+			return fmt.Errorf("startup assertion %q failed: returned no value, expected %q", a.SQL, a.Expect)
+		}
+		if *got != a.Expect {
+			return fmt.Errorf("startup assertion %q failed: returned %q, expected %q", a.SQL, *got, a.Expect)
+		}
+	}
+	return nil
+}
+
+// runStartupChecks runs the checks that need a reachable database, unless they already
+// passed.
+func (p *PostgresMcp) runStartupChecks(ctx context.Context) error {
+	p.startupMu.Lock()
+	defer p.startupMu.Unlock()
+	if p.startupDone.Load() {
+		return nil
+	}
+	if p.config.Timezone != "" {
+		tz, err := verifyTimezone(ctx, p.pool.Config().ConnConfig, p.config.Timezone, p.config.TimezoneFallback, p.logger)
+		if err != nil {
+			return err
+		}
+		p.timezone.Store(&tz)
+	}
+	if err := runStartupAssertions(ctx, p.pool, p.config.StartupAssertions); err != nil {
+		return err
+	}
+	if err := checkMaxConnections(ctx, p.pool, p.config.Pool.MaxConns, p.config.Pool.MaxConnectionsCheck, p.logger); err != nil {
+		return err
+	}
+	p.startupDone.Store(true)
+	return nil
+}
+
+// ensureStarted runs the startup checks before a tool call uses the database, for callers
+// that did not call WaitReady. Only checks that guard the database (timezone,
+// startup_assertions, and max_connections_check "error") hold calls back: until they pass,
+// every call fails with the check's error.
+func (p *PostgresMcp) ensureStarted(ctx context.Context) error {
+	if p.startupDone.Load() {
+		return nil
+	}
+	if p.config.Timezone == "" && len(p.config.StartupAssertions) == 0 && p.config.Pool.MaxConnectionsCheck != MaxConnectionsCheckError {
+		return nil
+	}
+	if err := p.runStartupChecks(ctx); err != nil {
+		return fmt.Errorf("database startup check failed, so no query runs until it passes: %w", err)
+	}
+	return nil
+}
+
 // Ping verifies the database connection by acquiring a connection and running a simple query.
 func (p *PostgresMcp) Ping(ctx context.Context) error {
 	return p.pool.Ping(ctx)
 }
 
-// Close closes the connection pool. Accepts context for API forward-compatibility,
-// but does not currently use it — pgxpool.Pool.Close() does not support context-based shutdown.
+// WaitReady pings the database until a connection succeeds or ctx expires, then runs the
+// startup checks (timezone verification, startup_assertions, and
+// pool.max_connections_check) once. New does not query the database, so call WaitReady
+// before serving: on cold start (e.g. docker-compose) the database may still be booting.
+// Tool calls made without it run the checks first and fail while they fail.
+// Retries with exponential backoff capped at 2 seconds, logging each failed attempt. A
+// failed check is returned without retrying.
+func (p *PostgresMcp) WaitReady(ctx context.Context) error {
+	backoff := 100 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := p.probe(ctx)
+		if err == nil {
+			if attempt > 1 {
+				p.logger.Info().Int("attempts", attempt).Msg("database is ready")
+			}
+			return p.runStartupChecks(ctx)
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("database not ready after %d attempt(s): %w", attempt, err)
+		}
+		p.logger.Info().Err(err).Int("attempt", attempt).Dur("retry_in", backoff).Msg("database not ready, retrying")
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("database not ready after %d attempt(s): %w", attempt, err)
+		}
+		backoff *= 2
+		if backoff > 2*time.Second {
+			backoff = 2 * time.Second
+		}
+	}
+}
+
+// probe checks that the database accepts connections. Until the startup checks have
+// verified the timezone it connects outside the pool: pool connections set the timezone,
+// so one the server rejects would fail every ping instead of being reported.
+func (p *PostgresMcp) probe(ctx context.Context) error {
+	if p.config.Timezone == "" || p.startupDone.Load() {
+		return p.pool.Ping(ctx)
+	}
+	conn, err := pgx.ConnectConfig(ctx, p.pool.Config().ConnConfig)
+	if err != nil {
+		return err
+	}
+	return conn.Close(ctx)
+}
+
+// connectOutsidePool opens a connection with the pool's connect settings and session setup
+// (read_only, timezone, session_role), on a connection the pool does not own.
+func connectOutsidePool(ctx context.Context, poolConfig *pgxpool.Config) (*pgx.Conn, error) {
+	conn, err := pgx.ConnectConfig(ctx, poolConfig.ConnConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	if poolConfig.AfterConnect != nil {
+		if err := poolConfig.AfterConnect(ctx, conn); err != nil {
+			closeConn(conn)
+			return nil, err
+		}
+	}
+	if poolConfig.PrepareConn != nil {
+		if _, err := poolConfig.PrepareConn(ctx, conn); err != nil {
+			closeConn(conn)
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// closeConn closes a connection opened by connectOutsidePool.
+func closeConn(conn *pgx.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn.Close(ctx)
+}
+
+// Close closes the connection pool and the connections of sessions holding temp tables
+// (protection.allow_temp_tables), waiting until ctx expires for calls still using one.
+// pgxpool.Pool.Close() does not support context-based shutdown.
 func (p *PostgresMcp) Close(ctx context.Context) {
+	if p.scratch != nil {
+		p.scratch.close(ctx)
+	}
 	p.pool.Close()
 }
 
+// mapProtectionConfig converts a pgmcp ProtectionConfig to the internal protection.Config.
+// ReadOnly, LockSessionRole, and BlockExplainAnalyze come from outside ProtectionConfig
+// and are set by the caller.
+func mapProtectionConfig(cfg ProtectionConfig) protection.Config {
+	return protection.Config{
+		AllowSet:                 cfg.AllowSet,
+		AllowSetLocal:            cfg.AllowSetLocal,
+		AllowDrop:                cfg.AllowDrop,
+		AllowTruncate:            cfg.AllowTruncate,
+		AllowDo:                  cfg.AllowDo,
+		AllowCopyFrom:            cfg.AllowCopyFrom,
+		AllowCopyTo:              cfg.AllowCopyTo,
+		AllowCreateFunction:      cfg.AllowCreateFunction,
+		AllowPrepare:             cfg.AllowPrepare,
+		AllowDeleteWithoutWhere:  cfg.AllowDeleteWithoutWhere,
+		AllowUpdateWithoutWhere:  cfg.AllowUpdateWithoutWhere,
+		AllowAlterSystem:         cfg.AllowAlterSystem,
+		AllowMerge:               cfg.AllowMerge,
+		AllowMergeDelete:         cfg.AllowMergeDelete,
+		BlockUpsert:              cfg.AllowUpsert != nil && !*cfg.AllowUpsert,
+		AllowGrantRevoke:         cfg.AllowGrantRevoke,
+		AllowManageRoles:         cfg.AllowManageRoles,
+		AllowCreateExtension:     cfg.AllowCreateExtension,
+		AllowLockTable:           cfg.AllowLockTable,
+		AllowListenNotify:        cfg.AllowListenNotify,
+		AllowMaintenance:         cfg.AllowMaintenance,
+		AllowDDL:                 cfg.AllowDDL,
+		AllowDiscard:             cfg.AllowDiscard,
+		AllowComment:             cfg.AllowComment,
+		AllowCreateTrigger:       cfg.AllowCreateTrigger,
+		AllowCreateRule:          cfg.AllowCreateRule,
+		AllowTempTables:          cfg.AllowTempTables,
+		MaxInListItems:           cfg.MaxInListItems,
+		MaxValuesRows:            cfg.MaxValuesRows,
+		MaxIdentifierLength:      cfg.MaxIdentifierLength,
+		MaxStatementCandidates:   cfg.MaxStatementCandidates,
+		AllowedExtensions:        cfg.AllowedExtensions,
+		BlockRegexOperators:      cfg.BlockRegexOperators,
+		BlockSimilarity:          cfg.BlockSimilarity,
+		BlockComments:            cfg.BlockComments,
+		AllowedQueryFingerprints: cfg.AllowedQueryFingerprints,
+		TreatAsWriteFunctions:    cfg.TreatAsWriteFunctions,
+	}
+}
+
 // mapSanitizationRules converts pgmcp SanitizationRules to internal sanitize.Rules.
 func mapSanitizationRules(rules []SanitizationRule) []sanitize.Rule {
 	result := make([]sanitize.Rule, len(rules))
@@ -292,6 +755,7 @@ func mapSanitizationRules(rules []SanitizationRule) []sanitize.Rule {
 		result[i] = sanitize.Rule{
 			Pattern:     r.Pattern,
 			Replacement: r.Replacement,
+			Priority:    r.Priority,
 		}
 	}
 	return result
@@ -302,8 +766,9 @@ func mapErrorPromptRules(rules []ErrorPromptRule) []errprompt.Rule {
 	result := make([]errprompt.Rule, len(rules))
 	for i, r := range rules {
 		result[i] = errprompt.Rule{
-			Pattern: r.Pattern,
-			Message: r.Message,
+			Pattern:  r.Pattern,
+			Message:  r.Message,
+			Priority: r.Priority,
 		}
 	}
 	return result
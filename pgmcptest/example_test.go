@@ -0,0 +1,42 @@
+package pgmcptest_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	pgmcp "github.com/rickchristie/postgres-mcp"
+	"github.com/rickchristie/postgres-mcp/pgmcptest"
+)
+
+// aliasHook is the kind of hook an embedder would test: it rewrites the query.
+type aliasHook struct{}
+
+func (h *aliasHook) Run(_ context.Context, query string) (string, error) {
+	return strings.ReplaceAll(query, "FROM widgets_alias", "FROM pgmcptest_widgets"), nil
+}
+
+func TestStart_WithGoHook(t *testing.T) {
+	t.Parallel()
+	config := pgmcptest.DefaultConfig()
+	config.Protection.AllowDDL = true
+	config.Protection.AllowDrop = true
+	config.DefaultHookTimeoutSeconds = 5
+	config.BeforeQueryHooks = []pgmcp.BeforeQueryHookEntry{{Name: "alias", Hook: &aliasHook{}}}
+	p, _ := pgmcptest.Start(t, config)
+
+	pgmcptest.Exec(t, p, "CREATE TABLE pgmcptest_widgets (id int, name text)")
+	// Runs before Start's cleanup closes p; the database is shared with other tests.
+	t.Cleanup(func() { pgmcptest.Exec(t, p, "DROP TABLE pgmcptest_widgets") })
+	output := pgmcptest.Exec(t, p, "INSERT INTO pgmcptest_widgets VALUES (1, 'gear')")
+	if output.RowsAffected != 1 {
+		t.Fatalf("expected 1 row affected, got %d", output.RowsAffected)
+	}
+
+	rows := pgmcptest.QueryRows(t, p, "SELECT id, name FROM widgets_alias")
+	expected := []map[string]interface{}{{"id": int32(1), "name": "gear"}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("expected %v, got %v", expected, rows)
+	}
+}
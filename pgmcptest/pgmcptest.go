@@ -0,0 +1,104 @@
+// Package pgmcptest helps embedders test their hooks, codecs, and converters against a
+// real PostgreSQL database, the way pgmcp's own tests do.
+//
+// Set PGMCPTEST_DSN to a connection string for a disposable database; tests using Start
+// are skipped when it is unset:
+//
+//	func TestMyHook(t *testing.T) {
+//		config := pgmcptest.DefaultConfig()
+//		config.Protection.AllowDDL = true
+//		config.BeforeQueryHooks = []pgmcp.BeforeQueryHookEntry{{Name: "mine", Hook: &myHook{}}}
+//		p, _ := pgmcptest.Start(t, config)
+//		pgmcptest.Exec(t, p, "CREATE TABLE widgets (id int)")
+//		output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT * FROM widgets"})
+//		// assertions on output
+//	}
+//
+// Every instance started from the same DSN shares one database, so tests that run in
+// parallel should use distinct table names (or separate databases).
+package pgmcptest
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	pgmcp "github.com/rickchristie/postgres-mcp"
+	"github.com/rs/zerolog"
+)
+
+// DSNEnv is the environment variable ConnString reads the database connection string from.
+const DSNEnv = "PGMCPTEST_DSN"
+
+// readyTimeout bounds Start's wait for the database to become ready.
+const readyTimeout = 30 * time.Second
+
+// ConnString returns the connection string in $PGMCPTEST_DSN, skipping the test when it
+// is unset.
+func ConnString(tb testing.TB) string {
+	tb.Helper()
+	dsn := os.Getenv(DSNEnv)
+	if dsn == "" {
+		tb.Skipf("%s is not set; set it to a disposable PostgreSQL database to run this test", DSNEnv)
+	}
+	return dsn
+}
+
+// DefaultConfig returns a Config that passes New's validation: 5 pool connections, a 30s
+// query timeout, 10s ListTables/DescribeTable timeouts, and 100000-byte SQL and result
+// limits. All protection rules keep their (blocking) defaults.
+func DefaultConfig() pgmcp.Config {
+	return pgmcp.Config{
+		Pool: pgmcp.PoolConfig{MaxConns: 5},
+		Query: pgmcp.QueryConfig{
+			DefaultTimeoutSeconds:       30,
+			ListTablesTimeoutSeconds:    10,
+			DescribeTableTimeoutSeconds: 10,
+			MaxSQLLength:                100000,
+			MaxResultLength:             100000,
+		},
+	}
+}
+
+// Start creates a PostgresMcp connected to ConnString's database with config and opts and
+// waits for it to be ready (running its startup checks), failing the test if either
+// fails. The returned cleanup closes it; it also runs automatically when the test ends,
+// so calling it is only needed to close early.
+func Start(tb testing.TB, config pgmcp.Config, opts ...pgmcp.Option) (*pgmcp.PostgresMcp, func()) {
+	tb.Helper()
+	dsn := ConnString(tb)
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, dsn, config, zerolog.Nop(), opts...)
+	if err != nil {
+		tb.Fatalf("pgmcptest: failed to create PostgresMcp: %v", err)
+	}
+	var once sync.Once
+	cleanup := func() { once.Do(func() { p.Close(ctx) }) }
+	tb.Cleanup(cleanup)
+	readyCtx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+	if err := p.WaitReady(readyCtx); err != nil {
+		tb.Fatalf("pgmcptest: PostgresMcp not ready: %v", err)
+	}
+	return p, cleanup
+}
+
+// Exec runs sql (typically DDL or DML to set up fixtures) through p.Query, failing the
+// test if it returns an error. The instance's protection rules apply, so enable what the
+// statement needs (e.g. Protection.AllowDDL) in its config.
+func Exec(tb testing.TB, p *pgmcp.PostgresMcp, sql string) *pgmcp.QueryOutput {
+	tb.Helper()
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: sql})
+	if output.Error != "" {
+		tb.Fatalf("pgmcptest: %q failed: %s", sql, output.Error)
+	}
+	return output
+}
+
+// QueryRows runs sql through p.Query and returns its rows, failing the test on error.
+func QueryRows(tb testing.TB, p *pgmcp.PostgresMcp, sql string) []map[string]interface{} {
+	tb.Helper()
+	return Exec(tb, p, sql).Rows
+}
@@ -169,6 +169,62 @@ func TestPgxTypes_DoublePrecision(t *testing.T) {
 	})
 }
 
+// specialFloatInstance sets up real and double precision columns, scalar and array, holding
+// NaN and ±Infinity under query.special_float_mode.
+func specialFloatInstance(t *testing.T, mode string) *pgmcp.PostgresMcp {
+	t.Helper()
+	config := pgxTypeConfig()
+	config.Query.SpecialFloatMode = mode
+	p, _ := newTestInstance(t, config)
+	setupTable(t, p, `CREATE TABLE t (r real, d double precision, ra real[], da double precision[])`)
+	setupTable(t, p, `INSERT INTO t VALUES
+		(1.5, 2.5, ARRAY[1.5, 'NaN']::real[], ARRAY['Infinity', 2.5]::float8[]),
+		('NaN', 'Infinity', NULL, ARRAY['-Infinity']::float8[]),
+		('-Infinity', NULL, NULL, NULL)`)
+	return p
+}
+
+func TestPgxTypes_SpecialFloatModeString(t *testing.T) {
+	t.Parallel()
+	p := specialFloatInstance(t, pgmcp.SpecialFloatString)
+	rows := queryRows(t, p, `SELECT r, d, ra, da FROM t ORDER BY ctid`)
+	assertColumn(t, rows, "r", []interface{}{float32(1.5), "NaN", "-Infinity"})
+	assertColumn(t, rows, "d", []interface{}{float64(2.5), "Infinity", nil})
+	assertColumn(t, rows, "ra", []interface{}{[]interface{}{float32(1.5), "NaN"}, nil, nil})
+	assertColumn(t, rows, "da", []interface{}{[]interface{}{"Infinity", float64(2.5)}, []interface{}{"-Infinity"}, nil})
+}
+
+func TestPgxTypes_SpecialFloatModeNull(t *testing.T) {
+	t.Parallel()
+	p := specialFloatInstance(t, pgmcp.SpecialFloatNull)
+	rows := queryRows(t, p, `SELECT r, d, ra, da FROM t ORDER BY ctid`)
+	assertColumn(t, rows, "r", []interface{}{float32(1.5), nil, nil})
+	assertColumn(t, rows, "d", []interface{}{float64(2.5), nil, nil})
+	assertColumn(t, rows, "ra", []interface{}{[]interface{}{float32(1.5), nil}, nil, nil})
+	assertColumn(t, rows, "da", []interface{}{[]interface{}{nil, float64(2.5)}, []interface{}{nil}, nil})
+}
+
+func TestPgxTypes_SpecialFloatModeError(t *testing.T) {
+	t.Parallel()
+	p := specialFloatInstance(t, pgmcp.SpecialFloatError)
+
+	// Finite values pass.
+	rows := queryRows(t, p, `SELECT r, d FROM t WHERE r = 1.5`)
+	assertColumn(t, rows, "r", []interface{}{float32(1.5)})
+
+	for _, sql := range []string{
+		`SELECT r FROM t`,
+		`SELECT d FROM t`,
+		`SELECT ra FROM t`,
+		`SELECT da FROM t WHERE r = 1.5`,
+	} {
+		output := p.Query(context.Background(), pgmcp.QueryInput{SQL: sql})
+		if !strings.Contains(output.Error, "contains NaN or Infinity") {
+			t.Fatalf("%s: expected non-finite float error, got %q (rows %v)", sql, output.Error, output.Rows)
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Monetary Type
 // ---------------------------------------------------------------------------
@@ -325,25 +381,37 @@ func TestPgxTypes_Date(t *testing.T) {
 	t.Parallel()
 	p, _ := newTestInstance(t, pgxTypeConfig())
 	setupTable(t, p, `CREATE TABLE t (v date)`)
-	setupTable(t, p, `INSERT INTO t VALUES ('2024-01-15'),('1970-01-01'),('9999-12-31'),(NULL)`)
+	setupTable(t, p, `INSERT INTO t VALUES ('2024-01-15'),('1970-01-01'),('9999-12-31'),(NULL),('infinity')`)
 	rows := queryRows(t, p, `SELECT v FROM t ORDER BY ctid`)
-	if len(rows) != 4 {
-		t.Fatalf("expected 4 rows, got %d", len(rows))
+	// date -> time.Time -> "YYYY-MM-DD", no time component
+	assertColumn(t, rows, "v", []interface{}{
+		"2024-01-15", "1970-01-01", "9999-12-31", nil, "infinity",
+	})
+}
+
+func TestPgxTypes_DateArray(t *testing.T) {
+	t.Parallel()
+	p, _ := newTestInstance(t, pgxTypeConfig())
+	rows := queryRows(t, p, `SELECT ARRAY['2024-01-15'::date, NULL] AS v`)
+	v, ok := rows[0]["v"].([]interface{})
+	if !ok || len(v) != 2 || v[0] != "2024-01-15" || v[1] != nil {
+		t.Fatalf("expected [2024-01-15 <nil>], got %v (%T)", rows[0]["v"], rows[0]["v"])
 	}
+}
+
+func TestPgxTypes_DateAsTimestamp(t *testing.T) {
+	t.Parallel()
+	config := pgxTypeConfig()
+	config.Query.DateAsTimestamp = true
+	p, _ := newTestInstance(t, config)
+	rows := queryRows(t, p, `SELECT '2024-01-15'::date AS d, '2024-01-15 10:30:00'::timestamp AS ts`)
 	// date -> time.Time -> RFC3339Nano, time component zeroed
-	dateRe := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T00:00:00`)
-	for i := 0; i < 3; i++ {
-		v, ok := rows[i]["v"].(string)
-		if !ok {
-			t.Errorf("row %d: expected string, got %T", i, rows[i]["v"])
-			continue
-		}
-		if !dateRe.MatchString(v) {
-			t.Errorf("row %d: expected date RFC3339Nano format with zeroed time, got %q", i, v)
-		}
+	dateRe := regexp.MustCompile(`^2024-01-15T00:00:00`)
+	if v, ok := rows[0]["d"].(string); !ok || !dateRe.MatchString(v) {
+		t.Errorf("expected date RFC3339Nano format with zeroed time, got %v (%T)", rows[0]["d"], rows[0]["d"])
 	}
-	if rows[3]["v"] != nil {
-		t.Errorf("row 3: expected nil, got %v (%T)", rows[3]["v"], rows[3]["v"])
+	if v, ok := rows[0]["ts"].(string); !ok || !strings.HasPrefix(v, "2024-01-15T10:30:00") {
+		t.Errorf("expected timestamp unaffected, got %v (%T)", rows[0]["ts"], rows[0]["ts"])
 	}
 }
 
@@ -407,6 +475,42 @@ func TestPgxTypes_Interval(t *testing.T) {
 	})
 }
 
+// ---------------------------------------------------------------------------
+// Catalog Types ("char", name)
+// ---------------------------------------------------------------------------
+
+func TestPgxTypes_QChar(t *testing.T) {
+	t.Parallel()
+	p, _ := newTestInstance(t, pgxTypeConfig())
+	rows := queryRows(t, p, `SELECT relkind, relpersistence FROM pg_catalog.pg_class WHERE oid = 'pg_catalog.pg_class'::regclass`)
+	assertColumn(t, rows, "relkind", []interface{}{"r"})
+	assertColumn(t, rows, "relpersistence", []interface{}{"p"})
+
+	rows = queryRows(t, p, `SELECT typtype, typcategory FROM pg_catalog.pg_type WHERE oid = 'pg_catalog.int4'::regtype`)
+	assertColumn(t, rows, "typtype", []interface{}{"b"})
+	assertColumn(t, rows, "typcategory", []interface{}{"N"})
+}
+
+func TestPgxTypes_QCharArrayAndEmpty(t *testing.T) {
+	t.Parallel()
+	p, _ := newTestInstance(t, pgxTypeConfig())
+	rows := queryRows(t, p, `SELECT ARRAY['a'::"char", NULL, 'z'::"char"] AS a, ''::"char" AS e, NULL::"char" AS n`)
+	assertColumn(t, rows, "a", []interface{}{[]interface{}{"a", nil, "z"}})
+	assertColumn(t, rows, "e", []interface{}{""})
+	assertColumn(t, rows, "n", []interface{}{nil})
+}
+
+func TestPgxTypes_Name(t *testing.T) {
+	t.Parallel()
+	p, _ := newTestInstance(t, pgxTypeConfig())
+	rows := queryRows(t, p, `SELECT relname, ARRAY[relname, 'other'::name] AS names FROM pg_catalog.pg_class WHERE oid = 'pg_catalog.pg_type'::regclass`)
+	assertColumn(t, rows, "relname", []interface{}{"pg_type"})
+	assertColumn(t, rows, "names", []interface{}{[]interface{}{"pg_type", "other"}})
+
+	rows = queryRows(t, p, `SELECT typname FROM pg_catalog.pg_type WHERE oid = 'pg_catalog.int4'::regtype`)
+	assertColumn(t, rows, "typname", []interface{}{"int4"})
+}
+
 // ---------------------------------------------------------------------------
 // Boolean Type
 // ---------------------------------------------------------------------------
@@ -420,6 +524,36 @@ func TestPgxTypes_Boolean(t *testing.T) {
 	assertColumn(t, rows, "v", []interface{}{true, false, nil})
 }
 
+// assertBooleanFormat checks boolean and boolean[] output under query.boolean_format.
+func assertBooleanFormat(t *testing.T, format string, scalar, array []interface{}) {
+	t.Helper()
+	config := pgxTypeConfig()
+	config.Query.BooleanFormat = format
+	p, _ := newTestInstance(t, config)
+	setupTable(t, p, `CREATE TABLE t (v boolean, a boolean[], j jsonb)`)
+	setupTable(t, p, `INSERT INTO t VALUES (true, ARRAY[true,NULL,false], '{"b":true}'),(false, NULL, NULL),(NULL, NULL, NULL)`)
+	rows := queryRows(t, p, `SELECT v, a, j FROM t ORDER BY ctid`)
+	assertColumn(t, rows, "v", scalar)
+	assertColumn(t, rows, "a", []interface{}{array, nil, nil})
+	// Booleans inside JSON are left alone.
+	assertColumn(t, rows, "j", []interface{}{map[string]interface{}{"b": true}, nil, nil})
+}
+
+func TestPgxTypes_BooleanFormatBool(t *testing.T) {
+	t.Parallel()
+	assertBooleanFormat(t, pgmcp.BooleanFormatBool, []interface{}{true, false, nil}, []interface{}{true, nil, false})
+}
+
+func TestPgxTypes_BooleanFormatInt(t *testing.T) {
+	t.Parallel()
+	assertBooleanFormat(t, pgmcp.BooleanFormatInt, []interface{}{1, 0, nil}, []interface{}{1, nil, 0})
+}
+
+func TestPgxTypes_BooleanFormatTF(t *testing.T) {
+	t.Parallel()
+	assertBooleanFormat(t, pgmcp.BooleanFormatTF, []interface{}{"t", "f", nil}, []interface{}{"t", nil, "f"})
+}
+
 // ---------------------------------------------------------------------------
 // UUID Type
 // ---------------------------------------------------------------------------
@@ -572,6 +706,39 @@ func TestPgxTypes_JSONB_Nested(t *testing.T) {
 	})
 }
 
+func TestPgxTypes_JSONB_MaxDepth(t *testing.T) {
+	t.Parallel()
+	config := pgxTypeConfig()
+	config.Query.MaxJSONDepth = 2
+	config.Sanitization = []pgmcp.SanitizationRule{{Pattern: "secret", Replacement: "***"}}
+	p, _ := newTestInstance(t, config)
+	rows := queryRows(t, p, `SELECT '{"a":{"b":{"c":"secret"}},"d":["secret",[1]]}'::jsonb AS v`)
+	assertColumn(t, rows, "v", []interface{}{
+		map[string]interface{}{
+			"a": map[string]interface{}{"b": "<max depth exceeded>"},
+			"d": []interface{}{"***", "<max depth exceeded>"},
+		},
+	})
+}
+
+func TestPgxTypes_JSONB_DefaultMaxDepth(t *testing.T) {
+	t.Parallel()
+	p, _ := newTestInstance(t, pgxTypeConfig())
+	// 150 nested arrays: the 101st and deeper are cut at the default depth of 100.
+	rows := queryRows(t, p, `SELECT (repeat('[', 150) || repeat(']', 150))::jsonb AS v`)
+	v := rows[0]["v"]
+	for depth := 1; depth <= 100; depth++ {
+		arr, ok := v.([]interface{})
+		if !ok || len(arr) != 1 {
+			t.Fatalf("depth %d: expected single-element array, got %v (%T)", depth, v, v)
+		}
+		v = arr[0]
+	}
+	if v != "<max depth exceeded>" {
+		t.Fatalf("expected marker at depth 101, got %v (%T)", v, v)
+	}
+}
+
 func TestPgxTypes_JSONB_Null(t *testing.T) {
 	t.Parallel()
 	p, _ := newTestInstance(t, pgxTypeConfig())
@@ -991,6 +1158,68 @@ func TestPgxTypes_Circle(t *testing.T) {
 	assertColumn(t, rows, "v", []interface{}{"<(1,1),5>", nil})
 }
 
+func geometryObjectConfig() pgmcp.Config {
+	config := pgxTypeConfig()
+	config.Query.GeometryAsObject = true
+	return config
+}
+
+func TestPgxTypes_PointAsObject(t *testing.T) {
+	t.Parallel()
+	p, _ := newTestInstance(t, geometryObjectConfig())
+	setupTable(t, p, `CREATE TABLE t (v point)`)
+	setupTable(t, p, `INSERT INTO t VALUES ('(1.5,2.5)'),(NULL)`)
+	rows := queryRows(t, p, `SELECT v FROM t ORDER BY ctid`)
+	assertColumn(t, rows, "v", []interface{}{
+		map[string]interface{}{"x": 1.5, "y": 2.5}, nil,
+	})
+}
+
+func TestPgxTypes_BoxAsObject(t *testing.T) {
+	t.Parallel()
+	p, _ := newTestInstance(t, geometryObjectConfig())
+	setupTable(t, p, `CREATE TABLE t (v box)`)
+	setupTable(t, p, `INSERT INTO t VALUES ('(0,0),(1,1)'),(NULL)`)
+	rows := queryRows(t, p, `SELECT v FROM t ORDER BY ctid`)
+	assertColumn(t, rows, "v", []interface{}{
+		map[string]interface{}{
+			"high": map[string]interface{}{"x": 1.0, "y": 1.0},
+			"low":  map[string]interface{}{"x": 0.0, "y": 0.0},
+		},
+		nil,
+	})
+}
+
+func TestPgxTypes_CircleAsObject(t *testing.T) {
+	t.Parallel()
+	p, _ := newTestInstance(t, geometryObjectConfig())
+	setupTable(t, p, `CREATE TABLE t (v circle)`)
+	setupTable(t, p, `INSERT INTO t VALUES ('<(1,1),5>'),(NULL)`)
+	rows := queryRows(t, p, `SELECT v FROM t ORDER BY ctid`)
+	assertColumn(t, rows, "v", []interface{}{
+		map[string]interface{}{
+			"center": map[string]interface{}{"x": 1.0, "y": 1.0},
+			"radius": 5.0,
+		},
+		nil,
+	})
+}
+
+func TestPgxTypes_GeometryArrayAsObject(t *testing.T) {
+	t.Parallel()
+	p, _ := newTestInstance(t, geometryObjectConfig())
+	rows := queryRows(t, p, `SELECT ARRAY['[(0,0),(1,2)]'::lseg, NULL] AS v`)
+	assertColumn(t, rows, "v", []interface{}{
+		[]interface{}{
+			map[string]interface{}{
+				"start": map[string]interface{}{"x": 0.0, "y": 0.0},
+				"end":   map[string]interface{}{"x": 1.0, "y": 2.0},
+			},
+			nil,
+		},
+	})
+}
+
 // ---------------------------------------------------------------------------
 // Bit String Types
 // ---------------------------------------------------------------------------
@@ -1067,6 +1296,42 @@ func TestPgxTypes_TsQuery(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// pgvector
+// ---------------------------------------------------------------------------
+
+func TestPgxTypes_Vector(t *testing.T) {
+	t.Parallel()
+	connStr := acquireTestDB(t)
+	ctx := context.Background()
+
+	// The vector type is looked up when a connection opens, so install the extension
+	// with one instance and query with a fresh one.
+	setup, err := pgmcp.New(ctx, connStr, pgxTypeConfig(), testLogger())
+	if err != nil {
+		t.Fatalf("failed to create setup instance: %v", err)
+	}
+	output := setup.Query(ctx, pgmcp.QueryInput{SQL: `CREATE EXTENSION IF NOT EXISTS vector`})
+	if output.Error != "" {
+		setup.Close(ctx)
+		t.Skipf("vector extension not available: %s", output.Error)
+	}
+	setupTable(t, setup, `CREATE TABLE t (v vector(3))`)
+	setupTable(t, setup, `INSERT INTO t VALUES ('[0.5,-1,2.25]'),(NULL)`)
+	setup.Close(ctx)
+
+	p, err := pgmcp.New(ctx, connStr, pgxTypeConfig(), testLogger())
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	defer p.Close(ctx)
+	rows := queryRows(t, p, `SELECT v FROM t ORDER BY ctid`)
+	assertColumn(t, rows, "v", []interface{}{[]float32{0.5, -1, 2.25}, nil})
+
+	rows = queryRows(t, p, `SELECT ARRAY['[1,2]'::vector, NULL] AS v`)
+	assertColumn(t, rows, "v", []interface{}{[]interface{}{[]float32{1, 2}, nil}})
+}
+
 // ---------------------------------------------------------------------------
 // XML Type
 // ---------------------------------------------------------------------------
@@ -0,0 +1,397 @@
+package pgmcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+)
+
+// largeTableRows is the estimated row count (pg_class.reltuples) at or above which a
+// sequentially scanned table is reported as a large seq scan in PlanSummary.
+const largeTableRows = 10000
+
+// explainNode is the subset of an EXPLAIN (FORMAT JSON) plan node used for PlanSummary
+// and row estimates.
+type explainNode struct {
+	NodeType     string        `json:"Node Type"`
+	Operation    string        `json:"Operation"` // ModifyTable only: "Insert", "Update", "Delete", "Merge"
+	RelationName string        `json:"Relation Name"`
+	Schema       string        `json:"Schema"`
+	PlanRows     float64       `json:"Plan Rows"`
+	TotalCost    float64       `json:"Total Cost"`
+	Filter       string        `json:"Filter"`
+	SortKey      []string      `json:"Sort Key"`
+	Plans        []explainNode `json:"Plans"`
+}
+
+// isSelectStatement returns true if the SQL is a single SELECT statement (including VALUES
+// and set operations), the only statements QueryInput.IncludePlan explains.
+func isSelectStatement(sql string) bool {
+	result, err := pg_query.Parse(sql)
+	if err != nil || len(result.Stmts) != 1 {
+		return false
+	}
+	sel := result.Stmts[0].Stmt.GetSelectStmt()
+	return sel != nil && sel.IntoClause == nil
+}
+
+// isExplainStatement returns true if the SQL is a single EXPLAIN statement.
+func isExplainStatement(sql string) bool {
+	result, err := pg_query.Parse(sql)
+	if err != nil || len(result.Stmts) != 1 {
+		return false
+	}
+	return result.Stmts[0].Stmt.GetExplainStmt() != nil
+}
+
+// maxRowsAffectedEstimateFactor is how far the planner's estimate for an UPDATE/DELETE may
+// exceed query.max_rows_affected before it is rejected up front. Estimates can be far off,
+// so only obviously-huge operations are rejected early; the hard cap after execution is exact.
+const maxRowsAffectedEstimateFactor = 10
+
+// isUpdateOrDelete returns true if the SQL is a single UPDATE or DELETE statement.
+func isUpdateOrDelete(sql string) bool {
+	result, err := pg_query.Parse(sql)
+	if err != nil || len(result.Stmts) != 1 {
+		return false
+	}
+	switch result.Stmts[0].Stmt.Node.(type) {
+	case *pg_query.Node_UpdateStmt, *pg_query.Node_DeleteStmt:
+		return true
+	}
+	return false
+}
+
+// checkEstimatedRowsAffected rejects an UPDATE/DELETE whose planner row estimate exceeds
+// query.max_rows_affected by more than maxRowsAffectedEstimateFactor, before it runs.
+func (p *PostgresMcp) checkEstimatedRowsAffected(ctx context.Context, tx pgx.Tx, sql string) error {
+	root, err := explainRoot(ctx, tx, sql, "FORMAT JSON")
+	if err != nil {
+		return err
+	}
+	var estimate float64
+	var walk func(n explainNode)
+	walk = func(n explainNode) {
+		if n.NodeType == "ModifyTable" && (n.Operation == "Update" || n.Operation == "Delete") && len(n.Plans) > 0 {
+			estimate += n.Plans[0].PlanRows
+			return
+		}
+		for _, child := range n.Plans {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	limit := p.config.Query.MaxRowsAffected
+	if estimate > float64(limit*maxRowsAffectedEstimateFactor) {
+		return withKind(ErrorKindProtection, fmt.Errorf("statement is estimated to affect about %.0f rows, exceeding cap %d (query.max_rows_affected): narrow the WHERE clause or split it into batches", estimate, limit))
+	}
+	return nil
+}
+
+// timeoutForEstimatedRows plans sql on its own connection and returns the
+// query.timeout_by_estimated_rows tier matching its row estimate, with a label for the
+// query log. If EXPLAIN fails, it returns fallback and the statement reports any real error.
+func (p *PostgresMcp) timeoutForEstimatedRows(ctx context.Context, sql string, fallback time.Duration) (time.Duration, string) {
+	explainCtx, cancel := context.WithTimeout(ctx, fallback)
+	defer cancel()
+	conn, err := p.pool.Acquire(explainCtx)
+	if err != nil {
+		return fallback, ""
+	}
+	defer conn.Release()
+	tx, err := conn.BeginTx(explainCtx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fallback, ""
+	}
+	defer tx.Rollback(ctx)
+	root, err := explainRoot(explainCtx, tx, sql, "FORMAT JSON")
+	if err != nil {
+		p.logger.Debug().Err(err).Msg("failed to estimate rows for query.timeout_by_estimated_rows, using the default timeout")
+		return fallback, ""
+	}
+	return estimatedRowsTimeout(p.config.Query.TimeoutByEstimatedRows, maxPlanRows(root), fallback)
+}
+
+// maxPlanRows returns the largest row estimate of any node in the plan, so a big scan
+// feeding an aggregate counts as big even though the query returns one row.
+func maxPlanRows(n explainNode) float64 {
+	rows := n.PlanRows
+	for _, child := range n.Plans {
+		rows = max(rows, maxPlanRows(child))
+	}
+	return rows
+}
+
+// estimatedRowsTimeout returns the timeout of the tier with the highest MinRows at or below
+// estimate, or fallback (and an empty label) if none qualifies.
+func estimatedRowsTimeout(tiers []EstimatedRowsTimeout, estimate float64, fallback time.Duration) (time.Duration, string) {
+	best := -1
+	for i, tier := range tiers {
+		if estimate >= float64(tier.MinRows) && (best < 0 || tier.MinRows > tiers[best].MinRows) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return fallback, ""
+	}
+	return time.Duration(tiers[best].TimeoutSeconds) * time.Second, fmt.Sprintf("timeout_by_estimated_rows: min_rows %d", tiers[best].MinRows)
+}
+
+// explainRoot runs EXPLAIN (options) for sql inside tx and returns the root plan node.
+// EXPLAIN without ANALYZE only plans the query; it does not execute it.
+func explainRoot(ctx context.Context, tx pgx.Tx, sql, options string) (explainNode, error) {
+	raw, err := explainRaw(ctx, tx, sql, options)
+	if err != nil {
+		return explainNode{}, err
+	}
+	return parseExplainRoot(raw)
+}
+
+// parseExplainRoot returns the root plan node of EXPLAIN (FORMAT JSON) output.
+func parseExplainRoot(raw []byte) (explainNode, error) {
+	var plans []struct {
+		Plan explainNode `json:"Plan"`
+	}
+	if err := json.Unmarshal(raw, &plans); err != nil {
+		return explainNode{}, fmt.Errorf("failed to parse query plan: %w", err)
+	}
+	if len(plans) == 0 {
+		return explainNode{}, fmt.Errorf("failed to parse query plan: empty plan")
+	}
+	return plans[0].Plan, nil
+}
+
+// explainRaw runs EXPLAIN (options) for sql inside tx and returns its output.
+func explainRaw(ctx context.Context, tx pgx.Tx, sql, options string) ([]byte, error) {
+	var raw []byte
+	if err := tx.QueryRow(ctx, "EXPLAIN ("+options+") "+sql).Scan(&raw); err != nil {
+		return nil, fmt.Errorf("failed to explain query: %w", err)
+	}
+	return raw, nil
+}
+
+// auditPlan returns the EXPLAIN (FORMAT JSON) plan of sql for the audit record, or nil for
+// statements EXPLAIN does not support. EXPLAIN runs in a savepoint, so a failure leaves
+// tx usable and the statement itself reports any real error.
+func auditPlan(ctx context.Context, tx pgx.Tx, sql string) json.RawMessage {
+	if !isExplainable(sql) {
+		return nil
+	}
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return nil
+	}
+	raw, err := explainRaw(ctx, sp, sql, "FORMAT JSON")
+	if err != nil {
+		sp.Rollback(ctx)
+		return nil
+	}
+	if err := sp.Commit(ctx); err != nil {
+		return nil
+	}
+	return raw
+}
+
+// logSlowQueryPlan logs the estimated plan (plain EXPLAIN, so nothing runs again) of a
+// read that exceeded query.explain_slow_queries_millis, at warn level, and returns it, or
+// nil if it could not be captured.
+func (p *PostgresMcp) logSlowQueryPlan(ctx context.Context, tx pgx.Tx, sql string, elapsed time.Duration) []byte {
+	if !isExplainable(sql) {
+		return nil
+	}
+	event := p.logger.Warn().Str("sql", truncateForLog(sql, 200)).Dur("duration", elapsed)
+	raw, err := explainRaw(ctx, tx, sql, "FORMAT JSON")
+	if err != nil {
+		event.Err(err).Msg("slow query, failed to capture plan")
+		return nil
+	}
+	event.RawJSON("plan", raw).Msg("slow query plan")
+	return raw
+}
+
+// isExplainable returns true if the SQL is a single statement plain EXPLAIN accepts:
+// SELECT/VALUES, INSERT, UPDATE, DELETE, or MERGE.
+func isExplainable(sql string) bool {
+	result, err := pg_query.Parse(sql)
+	if err != nil || len(result.Stmts) != 1 {
+		return false
+	}
+	switch result.Stmts[0].Stmt.Node.(type) {
+	case *pg_query.Node_SelectStmt, *pg_query.Node_InsertStmt, *pg_query.Node_UpdateStmt,
+		*pg_query.Node_DeleteStmt, *pg_query.Node_MergeStmt:
+		return true
+	}
+	return false
+}
+
+// explainPlan runs EXPLAIN (FORMAT JSON) for sql inside tx and summarizes the plan. With
+// advise (query.plan_advisories) it also returns the plan's advisories.
+func explainPlan(ctx context.Context, tx pgx.Tx, sql string, advise bool) (*PlanSummary, []string, error) {
+	root, err := explainRoot(ctx, tx, sql, "FORMAT JSON, VERBOSE")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	summary := &PlanSummary{
+		NodeType:      root.NodeType,
+		EstimatedRows: root.PlanRows,
+		TotalCost:     root.TotalCost,
+	}
+	scans := seqScans(root)
+	for _, n := range scans {
+		summary.SeqScanTables = append(summary.SeqScanTables, n.Schema+"."+n.RelationName)
+	}
+
+	tableRows, err := seqScanTableRows(ctx, tx, scans)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, rows := range tableRows {
+		if rows >= largeTableRows {
+			summary.LargeSeqScan = true
+		}
+	}
+	if !advise {
+		return summary, nil, nil
+	}
+	return summary, adviseOnPlan(root, scans, tableRows), nil
+}
+
+// seqScans returns the plan's sequential scans of tables, in plan order.
+func seqScans(n explainNode) []explainNode {
+	var scans []explainNode
+	if n.NodeType == "Seq Scan" && n.RelationName != "" {
+		scans = append(scans, n)
+	}
+	for _, child := range n.Plans {
+		scans = append(scans, seqScans(child)...)
+	}
+	return scans
+}
+
+// seqScanTableRows returns the estimated row count (pg_class.reltuples) of each
+// sequentially scanned table, by index into scans; tables it cannot resolve are left out.
+// Nodes without a schema (EXPLAIN without VERBOSE) are resolved through the search_path.
+func seqScanTableRows(ctx context.Context, tx pgx.Tx, scans []explainNode) (map[int]float64, error) {
+	if len(scans) == 0 {
+		return nil, nil
+	}
+	schemas := make([]string, len(scans))
+	names := make([]string, len(scans))
+	for i, n := range scans {
+		schemas[i], names[i] = n.Schema, n.RelationName
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT r.i - 1, c.reltuples::float8
+		FROM unnest($1::text[], $2::text[]) WITH ORDINALITY AS r(schema_name, rel_name, i)
+		JOIN pg_catalog.pg_class c ON c.oid = to_regclass(CASE
+			WHEN r.schema_name = '' THEN format('%I', r.rel_name)
+			ELSE format('%I.%I', r.schema_name, r.rel_name)
+		END)`, schemas, names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up table sizes for query plan: %w", err)
+	}
+	tableRows := make(map[int]float64, len(scans))
+	var i int
+	var reltuples float64
+	if _, err := pgx.ForEachRow(rows, []any{&i, &reltuples}, func() error {
+		tableRows[i] = reltuples
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to look up table sizes for query plan: %w", err)
+	}
+	return tableRows, nil
+}
+
+// planAdvisories returns the advisories (query.plan_advisories) for EXPLAIN (FORMAT JSON)
+// output captured inside tx.
+func planAdvisories(ctx context.Context, tx pgx.Tx, raw []byte) ([]string, error) {
+	root, err := parseExplainRoot(raw)
+	if err != nil {
+		return nil, err
+	}
+	scans := seqScans(root)
+	tableRows, err := seqScanTableRows(ctx, tx, scans)
+	if err != nil {
+		return nil, err
+	}
+	return adviseOnPlan(root, scans, tableRows), nil
+}
+
+// adviseOnPlan derives human-readable advisories from the plan's estimates: sequential
+// scans of large tables, large sorts, and nested loops that rescan a table for each outer
+// row. They are heuristics; the planner may well have chosen the best plan available.
+// scans and tableRows are the plan's Seq Scan nodes and their tables' row estimates.
+func adviseOnPlan(root explainNode, scans []explainNode, tableRows map[int]float64) []string {
+	var advisories []string
+	seen := make(map[string]bool)
+	add := func(advisory string) {
+		if !seen[advisory] {
+			seen[advisory] = true
+			advisories = append(advisories, advisory)
+		}
+	}
+
+	for i, n := range scans {
+		rows, ok := tableRows[i]
+		if !ok || rows < largeTableRows {
+			continue
+		}
+		if n.Filter != "" {
+			add(fmt.Sprintf("sequential scan on large table '%s' (est %s rows) filtered by %s — consider an index on the filter column", planRelation(n), approxRows(rows), n.Filter))
+		} else {
+			add(fmt.Sprintf("sequential scan on large table '%s' (est %s rows) reads every row — add a WHERE clause or LIMIT if not all rows are needed", planRelation(n), approxRows(rows)))
+		}
+	}
+
+	var walk func(n explainNode)
+	walk = func(n explainNode) {
+		switch {
+		case n.NodeType == "Sort" && n.PlanRows >= largeTableRows:
+			add(fmt.Sprintf("sort of an estimated %s rows on %s — an index matching the ORDER BY could avoid the sort", approxRows(n.PlanRows), strings.Join(n.SortKey, ", ")))
+		case n.NodeType == "Nested Loop" && len(n.Plans) == 2 && n.Plans[0].PlanRows >= largeTableRows:
+			inner := n.Plans[1]
+			if inner.NodeType == "Materialize" && len(inner.Plans) == 1 {
+				inner = inner.Plans[0]
+			}
+			if inner.NodeType == "Seq Scan" && inner.RelationName != "" {
+				add(fmt.Sprintf("nested loop rescans table '%s' for each of an estimated %s outer rows — consider an index on the join column", planRelation(inner), approxRows(n.Plans[0].PlanRows)))
+			}
+		}
+		for _, child := range n.Plans {
+			walk(child)
+		}
+	}
+	walk(root)
+	return advisories
+}
+
+// planRelation returns the node's table name, schema-qualified when the plan includes the
+// schema (EXPLAIN VERBOSE).
+func planRelation(n explainNode) string {
+	if n.Schema == "" {
+		return n.RelationName
+	}
+	return n.Schema + "." + n.RelationName
+}
+
+// approxRows formats a row estimate compactly for advisories, e.g. 2M, 15.3K, or 640.
+func approxRows(rows float64) string {
+	for _, unit := range []struct {
+		size   float64
+		suffix string
+	}{{1e9, "B"}, {1e6, "M"}, {1e3, "K"}} {
+		if rows >= unit.size {
+			return strconv.FormatFloat(math.Round(rows/unit.size*10)/10, 'f', -1, 64) + unit.suffix
+		}
+	}
+	return strconv.FormatFloat(math.Round(rows), 'f', -1, 64)
+}
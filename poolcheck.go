@@ -0,0 +1,72 @@
+package pgmcp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// PoolConfig.MaxConnectionsCheck values.
+const (
+	// MaxConnectionsCheckWarn (the default) logs a warning when pool.max_conns could
+	// exhaust the server's connection slots.
+	MaxConnectionsCheckWarn = "warn"
+	// MaxConnectionsCheckError makes WaitReady fail instead, including when the check
+	// query fails.
+	MaxConnectionsCheckError = "error"
+	// MaxConnectionsCheckOff skips the check.
+	MaxConnectionsCheckOff = "off"
+)
+
+// maxConnectionsCheckTimeout bounds the check, so a slow server does not hold up
+// WaitReady in warn mode.
+const maxConnectionsCheckTimeout = 5 * time.Second
+
+// maxConnectionsSQL returns the connection slots open to ordinary roles (max_connections
+// less the superuser_reserved_connections and, on Postgres 16+, reserved_connections
+// slots) and the number of other client connections.
+const maxConnectionsSQL = `
+SELECT current_setting('max_connections')::int
+       - current_setting('superuser_reserved_connections')::int
+       - COALESCE(current_setting('reserved_connections', true)::int, 0),
+       (SELECT count(*) FROM pg_catalog.pg_stat_activity
+        WHERE backend_type = 'client backend' AND pid <> pg_catalog.pg_backend_pid())`
+
+// CheckMaxConnections compares pool.max_conns with the connection slots the server has
+// left, as pool.max_connections_check does in WaitReady, and returns any problem (or a
+// failed check query) as an error whatever the configured mode. gopgmcp doctor uses it.
+func (p *PostgresMcp) CheckMaxConnections(ctx context.Context) error {
+	return checkMaxConnections(ctx, p.pool, p.config.Pool.MaxConns, MaxConnectionsCheckError, p.logger)
+}
+
+// checkMaxConnections compares pool.max_conns with the connection slots the server has
+// left (pool.max_connections_check). In warn mode problems are logged and nil is returned.
+func checkMaxConnections(ctx context.Context, pool *pgxpool.Pool, maxConns int, mode string, logger zerolog.Logger) error {
+	if mode == MaxConnectionsCheckOff {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, maxConnectionsCheckTimeout)
+	defer cancel()
+	var slots, inUse int
+	if err := pool.QueryRow(ctx, maxConnectionsSQL).Scan(&slots, &inUse); err != nil {
+		if mode == MaxConnectionsCheckError {
+			return fmt.Errorf("failed to check pool.max_conns against max_connections (pool.max_connections_check): %w", err)
+		}
+		logger.Info().Err(err).Msg("skipped checking pool.max_conns against the server's max_connections")
+		return nil
+	}
+	// Connections this pool already opened (e.g. for min_conns) are not other clients.
+	others := max(inUse-(int(pool.Stat().TotalConns())-1), 0)
+	if maxConns <= slots-others {
+		return nil
+	}
+	msg := fmt.Sprintf("pool.max_conns (%d) could exhaust the server's connections: max_connections leaves %d slots for non-superusers and other clients hold %d, leaving %d", maxConns, slots, others, max(slots-others, 0))
+	if mode == MaxConnectionsCheckError {
+		return fmt.Errorf("%s (pool.max_connections_check)", msg)
+	}
+	logger.Warn().Int("max_conns", maxConns).Int("server_slots", slots).Int("other_clients", others).Msg(msg)
+	return nil
+}
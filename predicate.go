@@ -0,0 +1,127 @@
+package pgmcp
+
+import (
+	"strconv"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+)
+
+// alwaysFalsePredicateNote is QueryOutput.PredicateNote for query.warn_on_always_false_predicate.
+const alwaysFalsePredicateNote = "query has an always-false predicate and will return no rows"
+
+// hasAlwaysFalsePredicate reports whether the top-level WHERE clause of a single SELECT,
+// UPDATE, or DELETE can never be true: FALSE, NULL, a comparison with NULL, a failing
+// comparison between constants such as 1=0, or an AND with such a term (or an OR of only
+// such terms). Only constants are evaluated; a predicate that is false because of the data
+// is not detected.
+func hasAlwaysFalsePredicate(sql string) bool {
+	tree, err := pg_query.Parse(sql)
+	if err != nil || len(tree.Stmts) != 1 {
+		return false
+	}
+	var where *pg_query.Node
+	switch stmt := tree.Stmts[0].Stmt.Node.(type) {
+	case *pg_query.Node_SelectStmt:
+		if stmt.SelectStmt.Op != pg_query.SetOperation_SETOP_NONE {
+			return false
+		}
+		where = stmt.SelectStmt.WhereClause
+	case *pg_query.Node_UpdateStmt:
+		where = stmt.UpdateStmt.WhereClause
+	case *pg_query.Node_DeleteStmt:
+		where = stmt.DeleteStmt.WhereClause
+	}
+	return where != nil && alwaysFalse(where)
+}
+
+func alwaysFalse(node *pg_query.Node) bool {
+	switch n := node.Node.(type) {
+	case *pg_query.Node_AConst:
+		if n.AConst.Isnull {
+			return true
+		}
+		b := n.AConst.GetBoolval()
+		return b != nil && !b.Boolval
+	case *pg_query.Node_BoolExpr:
+		switch n.BoolExpr.Boolop {
+		case pg_query.BoolExprType_AND_EXPR:
+			for _, arg := range n.BoolExpr.Args {
+				if alwaysFalse(arg) {
+					return true
+				}
+			}
+		case pg_query.BoolExprType_OR_EXPR:
+			for _, arg := range n.BoolExpr.Args {
+				if !alwaysFalse(arg) {
+					return false
+				}
+			}
+			return true
+		}
+	case *pg_query.Node_AExpr:
+		if n.AExpr.Kind != pg_query.A_Expr_Kind_AEXPR_OP || len(n.AExpr.Name) != 1 {
+			return false
+		}
+		result, ok := compareConstants(n.AExpr.Name[0].GetString_().GetSval(), n.AExpr.Lexpr, n.AExpr.Rexpr)
+		return ok && !result
+	}
+	return false
+}
+
+// compareConstants evaluates op on two constant operands: numbers with any comparison
+// operator, strings with = and <>. A comparison with NULL (e.g. col = NULL) is never true.
+// ok is false for anything else.
+func compareConstants(op string, left, right *pg_query.Node) (result, ok bool) {
+	switch op {
+	case "=", "<>", "<", "<=", ">", ">=":
+	default:
+		return false, false
+	}
+	if left.GetAConst().GetIsnull() || right.GetAConst().GetIsnull() {
+		return false, true
+	}
+	if l, lok := constNumber(left); lok {
+		r, rok := constNumber(right)
+		if !rok {
+			return false, false
+		}
+		switch op {
+		case "=":
+			return l == r, true
+		case "<>":
+			return l != r, true
+		case "<":
+			return l < r, true
+		case "<=":
+			return l <= r, true
+		case ">":
+			return l > r, true
+		case ">=":
+			return l >= r, true
+		}
+		return false, false
+	}
+	l, r := left.GetAConst().GetSval(), right.GetAConst().GetSval()
+	if l == nil || r == nil {
+		return false, false
+	}
+	switch op {
+	case "=":
+		return l.Sval == r.Sval, true
+	case "<>":
+		return l.Sval != r.Sval, true
+	}
+	return false, false
+}
+
+func constNumber(node *pg_query.Node) (float64, bool) {
+	c := node.GetAConst()
+	switch v := c.GetVal().(type) {
+	case *pg_query.A_Const_Ival:
+		return float64(v.Ival.Ival), true
+	case *pg_query.A_Const_Fval:
+		f, err := strconv.ParseFloat(v.Fval.Fval, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,90 @@
+package pgmcp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// presets maps each Config.Preset name to the flags it turns on. Flags a preset does not
+// mention keep their own (usually blocking) values.
+var presets = map[string]func(*Config){
+	// Exploration only: every statement runs in a read-only transaction and all Allow*
+	// rules stay blocked.
+	PresetReadOnlyAnalytics: func(c *Config) {
+		c.ReadOnly = true
+	},
+	// Application-style reads and writes: DML (including MERGE), SET LOCAL, and temp tables.
+	// DDL, DROP/TRUNCATE, roles, grants, extensions, functions, and writes without WHERE
+	// stay blocked.
+	PresetReadWriteApp: func(c *Config) {
+		c.Protection.AllowMerge = true
+		c.Protection.AllowSetLocal = true
+		c.Protection.AllowTempTables = true
+	},
+	// Everything except ALTER SYSTEM and UPDATE/DELETE without WHERE, which stay blocked as
+	// foot-guns even for administrators.
+	PresetAdmin: func(c *Config) {
+		p := &c.Protection
+		p.AllowSet = true
+		p.AllowSetLocal = true
+		p.AllowDrop = true
+		p.AllowTruncate = true
+		p.AllowDo = true
+		p.AllowCopyFrom = true
+		p.AllowCopyTo = true
+		p.AllowCreateFunction = true
+		p.AllowPrepare = true
+		p.AllowMerge = true
+		p.AllowMergeDelete = true
+		p.AllowGrantRevoke = true
+		p.AllowManageRoles = true
+		p.AllowCreateExtension = true
+		p.AllowLockTable = true
+		p.AllowListenNotify = true
+		p.AllowMaintenance = true
+		p.AllowDDL = true
+		p.AllowDiscard = true
+		p.AllowComment = true
+		p.AllowCreateTrigger = true
+		p.AllowCreateRule = true
+		p.AllowTempTables = true
+	},
+}
+
+// ApplyPreset turns on the flags of config.Preset. Fields the preset does not set are left
+// as they are, so flags enabled before the call stay enabled; to turn one of the preset's
+// flags back off, change it after calling ApplyPreset. New calls it for configs that have
+// not been through ApplyPreset or ParseServerConfig. Returns an error for unknown presets.
+func ApplyPreset(config *Config) error {
+	if config.Preset == "" || config.presetApplied {
+		return nil
+	}
+	apply, ok := presets[config.Preset]
+	if !ok {
+		return fmt.Errorf("preset must be %q, %q, or %q, got %q", PresetReadOnlyAnalytics, PresetReadWriteApp, PresetAdmin, config.Preset)
+	}
+	apply(config)
+	config.presetApplied = true
+	return nil
+}
+
+// ParseServerConfig parses a JSON server config file. When it names a preset, the preset
+// is applied first and the file's own fields are decoded over it, so any flag set in the
+// file, true or false, overrides the preset.
+func ParseServerConfig(data []byte) (*ServerConfig, error) {
+	var probe struct {
+		Preset string `json:"preset"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+	var config ServerConfig
+	config.Preset = probe.Preset
+	if err := ApplyPreset(&config.Config); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
@@ -0,0 +1,138 @@
+package pgmcp_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	pgmcp "github.com/rickchristie/postgres-mcp"
+)
+
+func TestApplyPreset_ReadOnlyAnalytics(t *testing.T) {
+	t.Parallel()
+	config := pgmcp.Config{Preset: pgmcp.PresetReadOnlyAnalytics}
+	if err := pgmcp.ApplyPreset(&config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !config.ReadOnly {
+		t.Fatal("expected ReadOnly to be set")
+	}
+	if !reflect.DeepEqual(config.Protection, pgmcp.ProtectionConfig{}) {
+		t.Fatalf("expected every protection flag left at its default, got %+v", config.Protection)
+	}
+}
+
+func TestApplyPreset_ReadWriteApp(t *testing.T) {
+	t.Parallel()
+	config := pgmcp.Config{Preset: pgmcp.PresetReadWriteApp}
+	if err := pgmcp.ApplyPreset(&config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := pgmcp.ProtectionConfig{
+		AllowMerge:      true,
+		AllowSetLocal:   true,
+		AllowTempTables: true,
+	}
+	if config.ReadOnly || !reflect.DeepEqual(config.Protection, expected) {
+		t.Fatalf("expected read-write with %+v, got read_only=%v %+v", expected, config.ReadOnly, config.Protection)
+	}
+}
+
+func TestApplyPreset_Admin(t *testing.T) {
+	t.Parallel()
+	config := pgmcp.Config{Preset: pgmcp.PresetAdmin}
+	if err := pgmcp.ApplyPreset(&config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := pgmcp.ProtectionConfig{
+		AllowSet:             true,
+		AllowSetLocal:        true,
+		AllowDrop:            true,
+		AllowTruncate:        true,
+		AllowDo:              true,
+		AllowCopyFrom:        true,
+		AllowCopyTo:          true,
+		AllowCreateFunction:  true,
+		AllowPrepare:         true,
+		AllowMerge:           true,
+		AllowMergeDelete:     true,
+		AllowGrantRevoke:     true,
+		AllowManageRoles:     true,
+		AllowCreateExtension: true,
+		AllowLockTable:       true,
+		AllowListenNotify:    true,
+		AllowMaintenance:     true,
+		AllowDDL:             true,
+		AllowDiscard:         true,
+		AllowComment:         true,
+		AllowCreateTrigger:   true,
+		AllowCreateRule:      true,
+		AllowTempTables:      true,
+	}
+	if config.ReadOnly || !reflect.DeepEqual(config.Protection, expected) {
+		t.Fatalf("expected read-write with %+v, got read_only=%v %+v", expected, config.ReadOnly, config.Protection)
+	}
+}
+
+func TestApplyPreset_KeepsOtherFlags(t *testing.T) {
+	t.Parallel()
+	config := pgmcp.Config{Preset: pgmcp.PresetReadWriteApp}
+	config.Protection.AllowDeleteWithoutWhere = true
+	if err := pgmcp.ApplyPreset(&config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !config.Protection.AllowDeleteWithoutWhere || !config.Protection.AllowMerge {
+		t.Fatalf("expected preset flags added to existing ones, got %+v", config.Protection)
+	}
+}
+
+func TestApplyPreset_Unknown(t *testing.T) {
+	t.Parallel()
+	config := pgmcp.Config{Preset: "superuser"}
+	err := pgmcp.ApplyPreset(&config)
+	if err == nil || err.Error() != `preset must be "read-only-analytics", "read-write-app", or "admin", got "superuser"` {
+		t.Fatalf("expected unknown preset error, got %v", err)
+	}
+}
+
+func TestParseServerConfig_FileOverridesPreset(t *testing.T) {
+	t.Parallel()
+	config, err := pgmcp.ParseServerConfig([]byte(`{
+		"preset": "admin",
+		"protection": {"allow_truncate": false, "allow_alter_system": true},
+		"connection": {"dbname": "app"}
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.Protection.AllowTruncate {
+		t.Fatal("expected allow_truncate: false in the file to override the preset")
+	}
+	if !config.Protection.AllowAlterSystem || !config.Protection.AllowDrop {
+		t.Fatalf("expected file and preset flags combined, got %+v", config.Protection)
+	}
+	if config.Connection.DBName != "app" {
+		t.Fatalf("expected server fields parsed, got %+v", config.Connection)
+	}
+
+	// New applies presets only once, so the override survives.
+	if err := pgmcp.ApplyPreset(&config.Config); err != nil || config.Protection.AllowTruncate {
+		t.Fatalf("expected preset not re-applied, got err=%v allow_truncate=%v", err, config.Protection.AllowTruncate)
+	}
+}
+
+func TestParseServerConfig_UnknownPreset(t *testing.T) {
+	t.Parallel()
+	if _, err := pgmcp.ParseServerConfig([]byte(`{"preset": "root"}`)); err == nil {
+		t.Fatal("expected error for unknown preset")
+	}
+}
+
+func TestNew_UnknownPresetPanics(t *testing.T) {
+	t.Parallel()
+	config := validConfig()
+	config.Preset = "root"
+	expectPanic(t, `pgmcp: preset must be "read-only-analytics", "read-write-app", or "admin", got "root"`, func() {
+		pgmcp.New(context.Background(), dummyConnString, config, configTestLogger())
+	})
+}
@@ -0,0 +1,56 @@
+package pgmcp
+
+// RuleInfo documents one toggleable protection rule (ProtectionRules).
+type RuleInfo struct {
+	Name      string `json:"name"`       // what the rule covers, e.g. "DROP"
+	Field     string `json:"field"`      // ProtectionConfig field that controls it, e.g. "AllowDrop"
+	ConfigKey string `json:"config_key"` // JSON config key, e.g. "protection.allow_drop"
+	// DefaultBlocked is true for rules that block by default (Allow* fields) and false for
+	// opt-in blocks (Block* fields) and AllowUpsert.
+	DefaultBlocked bool   `json:"default_blocked"`
+	Rationale      string `json:"rationale"` // why it is blocked, as given in the rule's error message
+}
+
+// protectionRules lists every Allow* and Block* field of ProtectionConfig, in field order.
+var protectionRules = []RuleInfo{
+	{"SET/RESET", "AllowSet", "protection.allow_set", true, "changes session settings, including timeouts and the transaction's read-only mode"},
+	{"SET LOCAL", "AllowSetLocal", "protection.allow_set_local", true, "changes session settings, including timeouts and the transaction's read-only mode"},
+	{"DROP", "AllowDrop", "protection.allow_drop", true, "permanently removes database objects and their data"},
+	{"TRUNCATE", "AllowTruncate", "protection.allow_truncate", true, "deletes every row of a table without firing row-level DELETE triggers"},
+	{"DO blocks", "AllowDo", "protection.allow_do", true, "DO blocks can execute arbitrary SQL bypassing protection checks"},
+	{"COPY FROM", "AllowCopyFrom", "protection.allow_copy_from", true, "bulk-loads data into tables"},
+	{"COPY TO", "AllowCopyTo", "protection.allow_copy_to", true, "can export/exfiltrate data from tables"},
+	{"CREATE FUNCTION/PROCEDURE", "AllowCreateFunction", "protection.allow_create_function", true, "can contain arbitrary SQL bypassing protection checks"},
+	{"PREPARE/EXECUTE/DEALLOCATE", "AllowPrepare", "protection.allow_prepare", true, "prepared statements can be executed later bypassing protection checks"},
+	{"DELETE without WHERE", "AllowDeleteWithoutWhere", "protection.allow_delete_without_where", true, "deletes every row of the table"},
+	{"UPDATE without WHERE", "AllowUpdateWithoutWhere", "protection.allow_update_without_where", true, "updates every row of the table"},
+	{"ALTER SYSTEM", "AllowAlterSystem", "protection.allow_alter_system", true, "can modify server-level configuration (shared_preload_libraries, archive_command, ssl, etc.)"},
+	{"MERGE", "AllowMerge", "protection.allow_merge", true, "MERGE can perform INSERT, UPDATE, and DELETE operations bypassing individual DML protection rules"},
+	{"MERGE ... THEN DELETE", "AllowMergeDelete", "protection.allow_merge_delete", true, "deletes rows without a WHERE clause of its own"},
+	{"GRANT/REVOKE", "AllowGrantRevoke", "protection.allow_grant_revoke", true, "can modify database permissions"},
+	{"CREATE/ALTER/DROP ROLE", "AllowManageRoles", "protection.allow_manage_roles", true, "can modify role privileges including SUPERUSER"},
+	{"CREATE/ALTER EXTENSION", "AllowCreateExtension", "protection.allow_create_extension", true, "can load arbitrary server-side code into PostgreSQL"},
+	{"LOCK TABLE", "AllowLockTable", "protection.allow_lock_table", true, "can acquire exclusive locks causing deadlocks or denial of service"},
+	{"LISTEN/NOTIFY", "AllowListenNotify", "protection.allow_listen_notify", true, "can be used for side-channel communication between sessions"},
+	{"VACUUM/ANALYZE/CLUSTER/REINDEX/REFRESH MATERIALIZED VIEW", "AllowMaintenance", "protection.allow_maintenance", true, "maintenance commands can acquire heavy locks and cause significant I/O load"},
+	{"CREATE/ALTER (DDL)", "AllowDDL", "protection.allow_ddl", true, "DDL operations are blocked because they change the schema"},
+	{"DISCARD", "AllowDiscard", "protection.allow_discard", true, "resets session state including prepared statements and temporary tables"},
+	{"COMMENT ON", "AllowComment", "protection.allow_comment", true, "modifies database object metadata"},
+	{"CREATE TRIGGER", "AllowCreateTrigger", "protection.allow_create_trigger", true, "triggers execute arbitrary function calls on every DML operation, bypassing protection checks"},
+	{"CREATE RULE", "AllowCreateRule", "protection.allow_create_rule", true, "rules rewrite queries at the parser level, can silently transform statements and bypass protection checks"},
+	{"CREATE TEMP TABLE", "AllowTempTables", "protection.allow_temp_tables", true, "temp tables shadow permanent tables of the same name for the rest of the session"},
+	{"SECURITY DEFINER function calls", "BlockSecurityDefinerCalls", "protection.block_security_definer_calls", false, "it runs with its owner's privileges"},
+	{"INSERT ... ON CONFLICT", "AllowUpsert", "protection.allow_upsert", false, "upserts modify existing rows"},
+	{"regex operators (~, ~*, !~, !~*, regexp_*)", "BlockRegexOperators", "protection.block_regex_operators", false, "cannot use ordinary indexes and can scan whole tables at high CPU cost"},
+	{"SIMILAR TO and trigram similarity", "BlockSimilarity", "protection.block_similarity", false, "cannot use ordinary indexes and can scan whole tables at high CPU cost"},
+	{"SQL comments", "BlockComments", "protection.block_comments", false, "comments can hide intent from reviewers and log readers"},
+	{"volatile function calls in read-only mode", "BlockVolatileInReadOnly", "protection.block_volatile_in_read_only", false, "volatile functions can have side effects"},
+}
+
+// ProtectionRules returns documentation for every toggleable protection rule: the config
+// field and key that control it, whether it blocks by default, and why. Rules that cannot
+// be toggled (multi-statement queries, transaction control) are not listed. The returned
+// slice is a copy.
+func ProtectionRules() []RuleInfo {
+	return append([]RuleInfo(nil), protectionRules...)
+}
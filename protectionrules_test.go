@@ -0,0 +1,64 @@
+package pgmcp_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	pgmcp "github.com/rickchristie/postgres-mcp"
+)
+
+func TestProtectionRules_CoverEveryToggle(t *testing.T) {
+	t.Parallel()
+	rules := make(map[string]pgmcp.RuleInfo)
+	for _, rule := range pgmcp.ProtectionRules() {
+		if _, dup := rules[rule.Field]; dup {
+			t.Fatalf("duplicate rule for field %s", rule.Field)
+		}
+		if rule.Name == "" || rule.Rationale == "" {
+			t.Fatalf("rule %s is missing a name or rationale: %+v", rule.Field, rule)
+		}
+		rules[rule.Field] = rule
+	}
+
+	typ := reflect.TypeOf(pgmcp.ProtectionConfig{})
+	toggles := 0
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		kind := field.Type.Kind()
+		if kind == reflect.Pointer {
+			kind = field.Type.Elem().Kind()
+		}
+		isToggle := strings.HasPrefix(field.Name, "Allow") || strings.HasPrefix(field.Name, "Block")
+		if !isToggle || kind != reflect.Bool {
+			continue
+		}
+		toggles++
+		rule, ok := rules[field.Name]
+		if !ok {
+			t.Errorf("ProtectionConfig.%s has no RuleInfo entry in ProtectionRules", field.Name)
+			continue
+		}
+		key := "protection." + strings.Split(field.Tag.Get("json"), ",")[0]
+		if rule.ConfigKey != key {
+			t.Errorf("rule %s: expected config key %q, got %q", field.Name, key, rule.ConfigKey)
+		}
+		// Plain Allow* fields block by default; Block* fields and the *bool AllowUpsert do not.
+		defaultBlocked := strings.HasPrefix(field.Name, "Allow") && field.Type.Kind() == reflect.Bool
+		if rule.DefaultBlocked != defaultBlocked {
+			t.Errorf("rule %s: expected default_blocked %v, got %v", field.Name, defaultBlocked, rule.DefaultBlocked)
+		}
+	}
+	if toggles != len(rules) {
+		t.Errorf("ProtectionRules has %d entries but ProtectionConfig has %d toggles", len(rules), toggles)
+	}
+}
+
+func TestProtectionRules_ReturnsCopy(t *testing.T) {
+	t.Parallel()
+	rules := pgmcp.ProtectionRules()
+	rules[0].Name = "changed"
+	if pgmcp.ProtectionRules()[0].Name == "changed" {
+		t.Fatal("expected ProtectionRules to return a copy")
+	}
+}
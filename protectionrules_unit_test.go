@@ -0,0 +1,82 @@
+package pgmcp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestProtectionRules_RationaleMatchesBlockMessage ties each rule's Rationale to the error
+// the rule actually reports, so the two cannot drift apart.
+func TestProtectionRules_RationaleMatchesBlockMessage(t *testing.T) {
+	t.Parallel()
+	// A statement each rule blocks. Rules are checked with every Allow* false and the
+	// rule's own Block* (or AllowUpsert) switched on.
+	probes := map[string]string{
+		"AllowSet":                "SET work_mem = '1MB'",
+		"AllowSetLocal":           "SET LOCAL work_mem = '1MB'",
+		"AllowDrop":               "DROP TABLE users",
+		"AllowTruncate":           "TRUNCATE users",
+		"AllowDo":                 "DO $$ BEGIN END $$",
+		"AllowCopyFrom":           "COPY users FROM STDIN",
+		"AllowCopyTo":             "COPY users TO STDOUT",
+		"AllowCreateFunction":     "CREATE FUNCTION f() RETURNS int LANGUAGE sql AS 'SELECT 1'",
+		"AllowPrepare":            "PREPARE q AS SELECT 1",
+		"AllowDeleteWithoutWhere": "DELETE FROM users",
+		"AllowUpdateWithoutWhere": "UPDATE users SET name = 'x'",
+		"AllowAlterSystem":        "ALTER SYSTEM SET work_mem = '1MB'",
+		"AllowMerge":              "MERGE INTO users u USING staged s ON u.id = s.id WHEN MATCHED THEN DELETE",
+		"AllowMergeDelete":        "MERGE INTO users u USING staged s ON u.id = s.id WHEN MATCHED THEN DELETE",
+		"AllowGrantRevoke":        "GRANT SELECT ON users TO reader",
+		"AllowManageRoles":        "ALTER ROLE reader SUPERUSER",
+		"AllowCreateExtension":    "CREATE EXTENSION hstore",
+		"AllowLockTable":          "LOCK TABLE users",
+		"AllowListenNotify":       "LISTEN events",
+		"AllowMaintenance":        "VACUUM users",
+		"AllowDDL":                "CREATE TABLE t (id int)",
+		"AllowDiscard":            "DISCARD ALL",
+		"AllowComment":            "COMMENT ON TABLE users IS 'people'",
+		"AllowCreateTrigger":      "CREATE TRIGGER trg BEFORE INSERT ON users FOR EACH ROW EXECUTE FUNCTION f()",
+		"AllowCreateRule":         "CREATE RULE r AS ON INSERT TO users DO INSTEAD NOTHING",
+		"AllowTempTables":         "CREATE TEMP TABLE scratch (id int)",
+		"AllowUpsert":             "INSERT INTO users (id) VALUES (1) ON CONFLICT (id) DO UPDATE SET id = 1",
+		"BlockRegexOperators":     "SELECT * FROM users WHERE name ~ 'a'",
+		"BlockSimilarity":         "SELECT * FROM users WHERE name SIMILAR TO 'a%'",
+		"BlockComments":           "SELECT 1 -- note",
+	}
+	// Rules that need the catalog report their error from a funcCatalogChecker instead.
+	catalog := map[string]*funcCatalogChecker{
+		"BlockSecurityDefinerCalls": newSecurityDefinerChecker(),
+		"BlockVolatileInReadOnly":   newVolatileChecker(),
+	}
+
+	for _, rule := range ProtectionRules() {
+		var err error
+		if checker, ok := catalog[rule.Field]; ok {
+			err = checker.blocked(funcName{name: "f"})
+		} else {
+			sql, ok := probes[rule.Field]
+			if !ok {
+				t.Errorf("rule %s has no probe statement", rule.Field)
+				continue
+			}
+			var cfg ProtectionConfig
+			switch {
+			case rule.Field == "AllowUpsert":
+				cfg.AllowUpsert = new(bool)
+			case rule.Field == "AllowMergeDelete":
+				cfg.AllowMerge = true
+			case strings.HasPrefix(rule.Field, "Block"):
+				reflect.ValueOf(&cfg).Elem().FieldByName(rule.Field).SetBool(true)
+			}
+			err = CheckSQL(sql, cfg)
+		}
+		if err == nil {
+			t.Errorf("rule %s: expected its probe to be blocked", rule.Field)
+			continue
+		}
+		if !strings.Contains(err.Error(), rule.Rationale) {
+			t.Errorf("rule %s: rationale %q does not appear in its error %q", rule.Field, rule.Rationale, err.Error())
+		}
+	}
+}
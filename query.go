@@ -1,42 +1,126 @@
 package pgmcp
 
 import (
+	"cmp"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"net"
 	"net/netip"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 	pg_query "github.com/pganalyze/pg_query_go/v6"
+	"github.com/rickchristie/postgres-mcp/internal/hooks"
+	"github.com/rickchristie/postgres-mcp/internal/protection"
 )
 
+// emptySQLMessage is the error returned when QueryInput.SQL is empty or whitespace-only.
+const emptySQLMessage = "No SQL provided. Supply a SELECT or other statement."
+
 // Query executes the full query pipeline and returns only QueryOutput.
 // All errors (Postgres errors, protection rejections, hook rejections, Go errors)
 // are converted to output.Error. The error message is then evaluated against
 // error_prompts patterns — any matching prompt messages are appended.
 // This means callers only need to check output.Error, never a Go error.
+//
+// When Config.AuditSink is set, every call is recorded there before Query returns.
 func (p *PostgresMcp) Query(ctx context.Context, input QueryInput) *QueryOutput {
+	record := &AuditRecord{Time: time.Now(), OriginalSQL: input.SQL}
+	output := p.query(ctx, input, record)
+	if p.config.AuditSink == nil {
+		return output
+	}
+
+	record.Duration = time.Since(record.Time)
+	record.RowCount = len(output.Rows)
+	record.RowsAffected = output.RowsAffected
+	record.Error = output.Error
+	switch {
+	case output.Error == "":
+		record.Outcome = AuditOutcomeSuccess
+	case record.Outcome == "":
+		record.Outcome = AuditOutcomeError
+	}
+	// Not cancelled with the query: a timed-out query is still recorded.
+	if err := p.config.AuditSink.Record(context.WithoutCancel(ctx), *record); err != nil {
+		p.logger.Error().Err(err).Msg("failed to record audit entry")
+	}
+	return output
+}
+
+// query runs the pipeline for Query, filling record's Outcome (when blocked), FinalSQL,
+// and Plan for the audit sink.
+func (p *PostgresMcp) query(ctx context.Context, input QueryInput, record *AuditRecord) *QueryOutput {
 	startTime := time.Now()
 	sql := input.SQL
 
+	ctx, done, err := p.inflight.begin(ctx)
+	if err != nil {
+		record.Outcome = AuditOutcomeBlocked
+		output := p.handleError(withKind(ErrorKindSemaphore, err))
+		output.Retryable = true
+		return output
+	}
+	defer done()
+
+	// Empty input gets a steerable message instead of the parser's generic "empty query" error.
+	if strings.TrimSpace(sql) == "" {
+		record.Outcome = AuditOutcomeBlocked
+		output := p.handleError(withKind(ErrorKindProtection, errors.New(emptySQLMessage)))
+		output.EmptySQL = true
+		return output
+	}
+
+	// Per-tenant cap, checked before the global semaphore so a tenant over its budget is
+	// turned away instead of queueing for slots other tenants need.
+	if tenant := tenantKey(CallInfoFromContext(ctx)); p.tenants != nil && tenant != "" {
+		if !p.tenants.tryAcquire(tenant) {
+			record.Outcome = AuditOutcomeBlocked
+			output := p.handleError(withKind(ErrorKindSemaphore, fmt.Errorf("tenant %q already has %d queries running, the maximum per tenant (max_concurrent_per_tenant): wait for one to finish and retry", tenant, p.tenants.max)))
+			output.Retryable = true
+			return output
+		}
+		defer p.tenants.release(tenant)
+	}
+
 	// 1. Acquire semaphore (respects context cancellation to prevent deadlock)
 	select {
 	case p.semaphore <- struct{}{}:
 	case <-ctx.Done():
-		return p.handleError(fmt.Errorf("failed to acquire query slot: all %d connection slots are in use, context cancelled while waiting: %w", cap(p.semaphore), ctx.Err()))
+		record.Outcome = AuditOutcomeBlocked
+		return p.handleError(withKind(ErrorKindSemaphore, fmt.Errorf("failed to acquire query slot: all %d connection slots are in use, context cancelled while waiting: %w", cap(p.semaphore), ctx.Err())))
 	}
 	defer func() { <-p.semaphore }()
 
+	// Startup checks, when WaitReady has not run them
+	if err := p.ensureStarted(ctx); err != nil {
+		record.Outcome = AuditOutcomeBlocked
+		return p.handleError(err)
+	}
+
 	// 2. Check SQL length (before any processing — parsing, hooks, protection)
-	if len(sql) > p.config.Query.MaxSQLLength {
-		return p.handleError(fmt.Errorf("SQL query too long: %d bytes exceeds maximum of %d bytes", len(sql), p.config.Query.MaxSQLLength))
+	length, unit := len(sql), "bytes"
+	if p.config.Query.MaxSQLLengthUnit == MaxSQLLengthRunes {
+		length, unit = utf8.RuneCountInString(sql), "characters"
+	}
+	if length > p.config.Query.MaxSQLLength {
+		record.Outcome = AuditOutcomeBlocked
+		return p.handleError(withKind(ErrorKindProtection, fmt.Errorf("SQL query too long: %d %s exceeds maximum of %d %s", length, unit, p.config.Query.MaxSQLLength, unit)))
 	}
 
 	// --- Pipeline tracking ---
@@ -44,70 +128,174 @@ func (p *PostgresMcp) Query(ctx context.Context, input QueryInput) *QueryOutput
 	timeoutRule := ""
 	sanitized := false
 
+	// Hooks share one wall-clock budget per query when max_total_hook_seconds is set.
+	hookCtx := ctx
+	if p.config.MaxTotalHookSeconds > 0 {
+		hookCtx = hooks.WithBudget(ctx, hooks.NewBudget(time.Duration(p.config.MaxTotalHookSeconds)*time.Second))
+	}
+
+	checker := p.protection
+	if input.ForceReadOnly {
+		checker = p.readOnlyProt
+	}
+
+	// With hook_protection_order "protection-then-hooks", the SQL as the agent wrote it is
+	// checked too, so hooks cannot turn a blocked statement into an allowed one. Parse
+	// failures are left to the check after hooks, which applies protection.on_parse_failure.
+	if p.config.HookProtectionOrder == HookProtectionOrderProtectionFirst {
+		if err := checker.Check(sql); err != nil {
+			var parseErr *protection.ParseError
+			if p.config.Protection.OnParseFailure != OnParseFailureAllowReads || !errors.As(err, &parseErr) {
+				record.Outcome = AuditOutcomeBlocked
+				return p.handleError(withKind(ErrorKindProtection, err))
+			}
+		}
+	}
+
 	// 3. Run BeforeQuery hooks (middleware chain)
-	var err error
 	if len(p.goBeforeHooks) > 0 {
-		sql, err = p.runGoBeforeHooks(ctx, sql)
+		sql, err = p.runGoBeforeHooks(hookCtx, sql)
 		for _, entry := range p.goBeforeHooks {
 			beforeHooks = append(beforeHooks, entry.Name)
 		}
 	} else if p.cmdHooks != nil {
-		sql, beforeHooks, err = p.cmdHooks.RunBeforeQuery(ctx, sql)
+		sql, beforeHooks, err = p.cmdHooks.RunBeforeQuery(hookCtx, sql)
 	}
 	if err != nil {
-		return p.handleError(err)
+		record.Outcome = AuditOutcomeBlocked
+		return p.handleError(withKind(ErrorKindHook, err))
 	}
 
 	// 4. Protection check (on potentially modified query)
-	if err := p.protection.Check(sql); err != nil {
-		return p.handleError(err)
+	// With protection.on_parse_failure set to allow reads, a statement the parser rejects
+	// runs unchecked in a server-enforced read-only transaction instead.
+	parseFallback := false
+	if err := checker.Check(sql); err != nil {
+		var parseErr *protection.ParseError
+		if p.config.Protection.OnParseFailure != OnParseFailureAllowReads || !errors.As(err, &parseErr) {
+			record.Outcome = AuditOutcomeBlocked
+			return p.handleError(withKind(ErrorKindProtection, err))
+		}
+		parseFallback = true
+		p.logger.Warn().Err(err).Str("sql", truncateForLog(sql, 200)).Msg("SQL parse failed, executing unchecked in a read-only transaction (protection.on_parse_failure)")
+	}
+
+	// Temp tables live on the connection of the session that created them
+	// (protection.allow_temp_tables), so creating one needs a session to hold it.
+	sessionID := CallInfoFromContext(ctx).SessionID
+	createsTempTable := p.scratch != nil && !parseFallback && protection.IsTempTableCreate(sql)
+	if createsTempTable && sessionID == "" {
+		record.Outcome = AuditOutcomeBlocked
+		return p.handleError(withKind(ErrorKindProtection, errors.New("CREATE TEMP TABLE needs a client session to keep the table for later calls, and this call has none: library callers set CallInfo.SessionID")))
 	}
 
 	// 5. Determine timeout
 	var timeout time.Duration
 	timeout, timeoutRule = p.timeoutMgr.GetTimeoutWithPattern(sql)
+	if timeoutRule == "" && len(p.config.Query.TimeoutByEstimatedRows) > 0 && !parseFallback && isSelectStatement(sql) {
+		timeout, timeoutRule = p.timeoutForEstimatedRows(ctx, sql, timeout)
+	}
 	queryCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	// 6. Acquire connection and execute in transaction
-	conn, err := p.pool.Acquire(queryCtx)
-	if err != nil {
-		return p.handleError(err)
+	// Strip or reject EXPLAIN options per query.explain_option_policy. Like the auto-limit
+	// rewrite below, applied after timeout resolution so timeout_rules see the SQL as written.
+	if p.config.Query.ExplainOptionPolicy.enabled() && !parseFallback {
+		if sql, err = applyExplainOptionPolicy(sql, p.config.Query.ExplainOptionPolicy); err != nil {
+			record.Outcome = AuditOutcomeBlocked
+			return p.handleError(withKind(ErrorKindProtection, err))
+		}
 	}
-	defer conn.Release()
 
-	tx, err := conn.Begin(queryCtx)
-	if err != nil {
-		return p.handleError(err)
+	// Cap top-level SELECTs at query.auto_limit rows. Applied after timeout resolution so
+	// timeout_rules match the SQL as written, not the deparsed rewrite.
+	limitApplied := false
+	if p.config.Query.AutoLimit > 0 {
+		sql, limitApplied = applyAutoLimit(sql, p.config.Query.AutoLimit)
 	}
-	defer tx.Rollback(ctx) // use parent ctx, not queryCtx — if query timed out, queryCtx is cancelled and rollback would fail
 
-	rows, err := tx.Query(queryCtx, sql)
+	// Tag the statement for attribution. Added after protection and the auto-limit rewrite,
+	// so the comment is never checked or deparsed away.
+	comment, err := p.statementComment(ctx)
 	if err != nil {
-		return p.handleError(err)
+		return p.handleError(fmt.Errorf("failed to render statement_comment: %w", err))
 	}
+	uncommented := sql
+	sql = comment + sql
+	record.FinalSQL = sql
 
-	// 7. Collect results
-	result, err := p.collectRows(rows)
+	// 6-7. Acquire connection, execute in transaction, and collect results.
+	// Read-only statements that fail with a connection-level error (e.g. stale pooled
+	// connections after a database restart) are retried once on a fresh connection.
+	// Writes are never retried — the first attempt may have been applied.
+	// In read_only mode every transaction is begun READ ONLY, on top of the session's
+	// default_transaction_read_only, so a write that slips past protection still fails.
+	// SELECTs calling a protection.treat_as_write_functions entry are run and committed as writes.
+	// CREATE TEMP TABLE is the exception: Postgres refuses it in a READ ONLY transaction, but
+	// allows writes to temp tables there, so read_only mode begins it READ WRITE.
+	isReadOnly := parseFallback || input.ForceReadOnly || (isReadOnlyStatement(sql) && !checker.CallsWriteFunction(sql))
+	readWrite := p.config.ReadOnly && !input.ForceReadOnly && createsTempTable
+	opts := execOptions{
+		includePlan: input.IncludePlan && isSelectStatement(sql),
+		unchecked:   parseFallback,
+		readOnly:    input.ForceReadOnly || (p.config.ReadOnly && !readWrite),
+		readWrite:   readWrite,
+		// A session holding temp tables runs every call on its own connection.
+		session:          sessionID,
+		createsTempTable: createsTempTable,
+		// Keys are collected for writes only; reads never affect rows.
+		affectedKeys:  p.config.Query.ReturnAffectedKeys && !isReadOnly,
+		generatedKeys: p.config.Query.AutoReturnGeneratedKeys && !isReadOnly,
+	}
+	if p.config.AuditSink != nil && p.config.AuditExplain {
+		opts.planOut = &record.Plan
+	}
+	// 8-9. Run the statement and the steps that need its transaction. Reads end their
+	// transaction here (no commit needed); writes keep it open until after-hooks approve.
+	var tx pgx.Tx
+	var conn queryConn
+	var result *QueryOutput
+	var skipSanitize bool
+	if isReadOnly {
+		result, skipSanitize, err = p.runRead(ctx, queryCtx, sql, singleFlightKey(uncommented, opts), opts, parseFallback)
+	} else {
+		var exec *execution
+		exec, skipSanitize, err = p.runInTx(ctx, queryCtx, sql, opts, false, parseFallback)
+		if err == nil {
+			defer exec.conn.Release()
+			defer exec.tx.Rollback(ctx) // use parent ctx, not queryCtx — if query timed out, queryCtx is cancelled and rollback would fail
+			tx, conn, result = exec.tx, exec.conn, exec.result
+		}
+	}
 	if err != nil {
 		return p.handleError(err)
 	}
-
-	// 8. Detect read-only vs write statement
-	isReadOnly := isReadOnlyStatement(sql)
-
-	// 9. For read-only queries, rollback immediately (no commit needed)
-	if isReadOnly {
-		tx.Rollback(ctx)
+	result.LimitApplied = limitApplied
+	if p.config.Query.WarnOnAlwaysFalsePredicate && !parseFallback && hasAlwaysFalsePredicate(uncommented) {
+		result.PredicateNote = alwaysFalsePredicateNote
 	}
 
 	// 10. AfterQuery hooks — run BEFORE commit for write queries.
 	// This allows hooks to reject and trigger rollback for writes.
+	// While hooks run, the write transaction sits idle holding its locks, so arm the
+	// server-side idle_in_transaction_session_timeout as a safety net against hung hooks.
+	var idleSafetyNet time.Duration
+	hooksStart := time.Now()
+	if !isReadOnly && p.hasAfterQueryHooks() {
+		idleSafetyNet = timeout
+		if p.config.HookIdleInTransactionTimeoutSeconds > 0 {
+			idleSafetyNet = time.Duration(p.config.HookIdleInTransactionTimeoutSeconds) * time.Second
+		}
+		if _, err := tx.Exec(queryCtx, fmt.Sprintf("SET LOCAL idle_in_transaction_session_timeout = %d", idleSafetyNet.Milliseconds())); err != nil {
+			return p.handleError(fmt.Errorf("failed to set idle_in_transaction_session_timeout: %w", err))
+		}
+		hooksStart = time.Now()
+	}
 	var finalResult *QueryOutput
 	if len(p.goAfterHooks) > 0 {
-		finalResult, err = p.runGoAfterHooks(ctx, result)
+		finalResult, err = p.runGoAfterHooks(hookCtx, result)
 		if err != nil {
-			return p.handleError(err)
+			return p.handleError(withKind(ErrorKindHook, err))
 		}
 		for _, entry := range p.goAfterHooks {
 			afterHooks = append(afterHooks, entry.Name)
@@ -118,9 +306,9 @@ func (p *PostgresMcp) Query(ctx context.Context, input QueryInput) *QueryOutput
 			return p.handleError(err)
 		}
 
-		modifiedJSON, executed, err := p.cmdHooks.RunAfterQuery(ctx, string(resultJSON))
+		modifiedJSON, executed, err := p.cmdHooks.RunAfterQuery(hookCtx, string(resultJSON))
 		if err != nil {
-			return p.handleError(err)
+			return p.handleError(withKind(ErrorKindHook, err))
 		}
 		afterHooks = executed
 
@@ -128,28 +316,64 @@ func (p *PostgresMcp) Query(ctx context.Context, input QueryInput) *QueryOutput
 		dec := json.NewDecoder(strings.NewReader(modifiedJSON))
 		dec.UseNumber()
 		if err := dec.Decode(finalResult); err != nil {
-			return p.handleError(err)
+			return p.handleError(withKind(ErrorKindHook, err))
 		}
 	} else {
 		finalResult = result
 	}
+	normalizeResultShape(finalResult)
+	if p.config.ValidateHookOutput && len(afterHooks) > 0 {
+		if err := validateHookOutput(finalResult); err != nil {
+			return p.handleError(withKind(ErrorKindHook, err))
+		}
+	}
 
 	// 11. For write queries, commit AFTER hooks have approved the result.
 	// Commit uses queryCtx intentionally — ensures entire pipeline completes within query timeout.
 	if !isReadOnly {
+		var txID pgtype.Int8
+		if p.config.Query.ReturnCommitInfo {
+			// NULL when the statement wrote nothing, so no transaction ID was assigned.
+			if err := tx.QueryRow(queryCtx, "SELECT pg_catalog.txid_current_if_assigned()").Scan(&txID); err != nil {
+				return p.handleError(fmt.Errorf("failed to read transaction ID (query.return_commit_info): %w", err))
+			}
+		}
 		if err := tx.Commit(queryCtx); err != nil {
+			// The server's termination message can be lost with the connection, so a
+			// connection error after hooks outlasted the timeout counts too. Any other
+			// failure (serialization, deferred constraints) is the statement's own.
+			if idleSafetyNet > 0 && (isIdleInTransactionTimeout(err) || (time.Since(hooksStart) >= idleSafetyNet && isConnectionError(err))) {
+				return p.handleError(withKind(ErrorKindTimeout, fmt.Errorf("write rolled back: AfterQuery hooks held the transaction open longer than idle_in_transaction_session_timeout (%s), so the server terminated it to release locks: %w", idleSafetyNet, err)))
+			}
 			return p.handleError(err)
 		}
+		if txID.Valid {
+			finalResult.TxID = txID.Int64
+			p.setCommitLSN(ctx, conn, finalResult)
+		}
 	}
 
 	// 12. Apply sanitization (per-field, recursive into JSONB/arrays)
-	sanitized = p.sanitizer.HasRules()
-	finalResult.Rows = p.sanitizer.SanitizeRows(finalResult.Rows)
+	if !skipSanitize {
+		sanitized = p.sanitizer.HasRules()
+		finalResult.Rows = p.sanitizer.SanitizeRows(finalResult.Rows)
+		finalResult.AffectedKeys = p.sanitizer.SanitizeRows(finalResult.AffectedKeys)
+		finalResult.GeneratedKeys = p.sanitizer.SanitizeRows(finalResult.GeneratedKeys)
+	}
 
-	// 13. Apply max result length truncation
+	// 13. Replace NULLs with the configured sentinel (opt-in; JSON null by default)
+	if p.config.Query.NullStringInRows {
+		replaceNulls(finalResult.Rows, p.config.Query.NullString)
+	}
+
+	// 14. Fingerprint the rows, before truncation so the hash covers the full result, then
+	// apply max result length truncation
+	if p.config.Query.IncludeResultHash {
+		finalResult.ResultHash = resultHash(finalResult.Rows)
+	}
 	p.truncateIfNeeded(finalResult)
 
-	// 14. Log successful query execution with pipeline details
+	// 15. Log successful query execution with pipeline details
 	logEvent := p.logger.Info().
 		Str("sql", truncateForLog(sql, 200)).
 		Dur("duration", time.Since(startTime)).
@@ -194,6 +418,164 @@ func isReadOnlyStatement(sql string) bool {
 	}
 }
 
+// runInTx executes sql and runs the steps that need its transaction still open: the
+// command tag and max_rows_affected cap for writes, the sanitization cost guard, duplicate
+// column qualification, masking, key reporting, column types, the row-security note, and
+// the slow-read plan.
+// It reports whether sanitization must be skipped. On success the caller owns exec's
+// connection and transaction; on error both have been released.
+func (p *PostgresMcp) runInTx(ctx, queryCtx context.Context, sql string, opts execOptions, isReadOnly, parseFallback bool) (*execution, bool, error) {
+	// A session's temp tables go with its broken connection; on the pool, a name they
+	// shadowed would silently read the permanent table instead.
+	onScratch := p.scratch.holds(opts.session)
+	exec, err := p.execute(ctx, queryCtx, sql, opts)
+	if err != nil && isReadOnly && !onScratch && queryCtx.Err() == nil && isConnectionError(err) {
+		p.logger.Warn().Err(err).Msg("connection error on read-only query, resetting pool and retrying once")
+		p.pool.Reset()
+		exec, err = p.execute(ctx, queryCtx, sql, opts)
+	}
+	// A deadlock victim's transaction was rolled back whole, so running it again is safe.
+	retries := 0
+	for ; err != nil && isDeadlock(err) && retries < p.config.Query.RetryOnDeadlock && queryCtx.Err() == nil; retries++ {
+		backoff := deadlockRetryBackoff(retries + 1)
+		p.logger.Warn().Err(err).Int("attempt", retries+1).Int("max_retries", p.config.Query.RetryOnDeadlock).Dur("retry_in", backoff).Msg("deadlock detected, retrying the transaction (query.retry_on_deadlock)")
+		select {
+		case <-queryCtx.Done():
+			return nil, false, err
+		case <-time.After(backoff):
+		}
+		exec, err = p.execute(ctx, queryCtx, sql, opts)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if retries > 0 {
+		p.logger.Info().Int("retries", retries).Msg("transaction succeeded after deadlock retries (query.retry_on_deadlock)")
+	}
+	tx, result := exec.tx, exec.result
+	fail := func(err error) (*execution, bool, error) {
+		tx.Rollback(ctx)
+		exec.conn.Release()
+		return nil, false, err
+	}
+
+	// Record the command tag for write statements, mask configured columns (before hooks
+	// and sanitization, so raw values never leave the pipeline), and resolve column types.
+	if !isReadOnly {
+		result.Command = exec.tag.String()
+		result.LastInsertOID = insertOID(exec.tag)
+		// Hard cap: the rollback undoes the write.
+		if limit := p.config.Query.MaxRowsAffected; limit > 0 && exec.tag.RowsAffected() > int64(limit) {
+			return fail(withKind(ErrorKindProtection, fmt.Errorf("statement would affect %d rows, exceeding cap %d (query.max_rows_affected): the write was rolled back, narrow the WHERE clause or split it into batches", exec.tag.RowsAffected(), limit)))
+		}
+	}
+	// Sanitization cost guard, checked before commit so the reject policy rolls back writes.
+	skipSanitize := false
+	if cells := len(result.Rows) * len(exec.fields); p.sanitizer.HasRules() && p.config.SanitizationMaxScannedCells > 0 && cells > p.config.SanitizationMaxScannedCells {
+		if p.config.SanitizationOverLimit != SanitizationOverLimitSkip {
+			return fail(withKind(ErrorKindTruncation, fmt.Errorf("result has %d cells, more than sanitization can scan (sanitization_max_scanned_cells: %d): select fewer rows or columns", cells, p.config.SanitizationMaxScannedCells)))
+		}
+		p.logger.Warn().Int("cells", cells).Int("max_scanned_cells", p.config.SanitizationMaxScannedCells).Msg("result exceeds sanitization_max_scanned_cells, returning it unsanitized (sanitization_over_limit: skip)")
+		skipSanitize = true
+	}
+	if p.config.Query.DuplicateColumnMode == DuplicateColumnsQualify {
+		if err := p.qualifyDuplicateColumns(queryCtx, tx, result, exec.fields); err != nil {
+			return fail(err)
+		}
+	}
+	if p.masker.hasRules() {
+		if err := p.masker.mask(queryCtx, tx, result, exec.fields); err != nil {
+			return fail(err)
+		}
+	}
+	if p.config.Query.ResolveRegTypes {
+		if err := resolveRegNames(queryCtx, tx, result, exec.fields); err != nil {
+			return fail(err)
+		}
+	}
+	// Keys added by query.return_affected_keys or query.auto_return_generated_keys are
+	// reported apart from the (empty) result.
+	if exec.keysOnly {
+		if exec.generatedKeys {
+			result.GeneratedKeys = result.Rows
+		} else {
+			result.AffectedKeys = result.Rows
+		}
+		result.Columns, result.Rows = []string{}, []map[string]interface{}{}
+	}
+	if p.config.Query.IncludeColumnTypes && !exec.keysOnly {
+		if err := p.setColumnTypes(queryCtx, tx, result, exec.fields, exec.dims); err != nil {
+			return fail(err)
+		}
+	}
+
+	if p.config.Query.RowSecurityNotes {
+		note, err := rowSecurityNote(queryCtx, tx, sql)
+		if err != nil {
+			p.logger.Warn().Err(err).Msg("failed to check row-level security, returning the result without a note (query.row_security_notes)")
+		}
+		result.RowSecurityNote = note
+	}
+
+	// Log the estimated plan of slow reads, while their transaction is still open.
+	if limit := p.config.Query.ExplainSlowQueriesMillis; limit > 0 && isReadOnly && !parseFallback && exec.elapsed >= time.Duration(limit)*time.Millisecond {
+		raw := p.logSlowQueryPlan(queryCtx, tx, sql, exec.elapsed)
+		// An IncludePlan request already has its advisories.
+		if raw != nil && p.config.Query.PlanAdvisories && result.PlanSummary == nil {
+			advisories, err := planAdvisories(queryCtx, tx, raw)
+			if err != nil {
+				p.logger.Warn().Err(err).Msg("failed to derive plan advisories, returning the result without them (query.plan_advisories)")
+			}
+			result.Advisories = advisories
+		}
+	}
+
+	return exec, skipSanitize, nil
+}
+
+// runRead runs a read-only statement through runInTx and ends its transaction. With
+// query.single_flight_reads, reads in flight with the same key share one execution, and
+// every caller gets its own deep copy of the result to run hooks and sanitization on.
+// Reads calling volatile functions always run per caller.
+func (p *PostgresMcp) runRead(ctx, queryCtx context.Context, sql, key string, opts execOptions, parseFallback bool) (*QueryOutput, bool, error) {
+	run := func(opts execOptions) (readResult, error) {
+		exec, skipSanitize, err := p.runInTx(ctx, queryCtx, sql, opts, true, parseFallback)
+		if err != nil {
+			return readResult{}, err
+		}
+		exec.tx.Rollback(ctx)
+		exec.conn.Release()
+		return readResult{output: exec.result, skipSanitize: skipSanitize}, nil
+	}
+	// A session's temp tables can shadow the tables other callers read.
+	if p.readFlights == nil || p.scratch.holds(opts.session) || !p.readFlights.coalescible(queryCtx, p.pool, sql) {
+		res, err := run(opts)
+		return res.output, res.skipSanitize, err
+	}
+
+	planOut := opts.planOut
+	shareRun := func() (readResult, error) {
+		var plan json.RawMessage
+		if opts.planOut != nil {
+			opts.planOut = &plan
+		}
+		res, err := run(opts)
+		res.plan = plan
+		return res, err
+	}
+	res, shared, err := p.readFlights.do(queryCtx, key, shareRun)
+	if err != nil {
+		return nil, false, err
+	}
+	if shared {
+		p.logger.Debug().Str("sql", truncateForLog(sql, 200)).Msg("shared the result of an identical query in flight (query.single_flight_reads)")
+	}
+	if planOut != nil {
+		*planOut = res.plan
+	}
+	return cloneQueryOutput(res.output), res.skipSanitize, nil
+}
+
 // runGoBeforeHooks runs Go-interface BeforeQuery hooks in middleware chain.
 func (p *PostgresMcp) runGoBeforeHooks(ctx context.Context, sql string) (string, error) {
 	for _, entry := range p.goBeforeHooks {
@@ -201,12 +583,26 @@ func (p *PostgresMcp) runGoBeforeHooks(ctx context.Context, sql string) (string,
 		if timeout == 0 {
 			timeout = time.Duration(p.config.DefaultHookTimeoutSeconds) * time.Second
 		}
+		budget := hooks.BudgetFromContext(ctx)
+		timeout, clamped := budget.Clamp(timeout)
+		if timeout <= 0 {
+			return "", fmt.Errorf("before_query hook error: %w", budget.Err())
+		}
 		hookCtx, cancel := context.WithTimeout(ctx, timeout)
 
-		modified, err := entry.Hook.Run(hookCtx, sql)
+		start := time.Now()
+		modified, err := p.runBeforeHook(hookCtx, entry, sql)
 		cancel()
+		budget.Spend(time.Since(start))
+		var panicErr *hookPanicError
+		if errors.As(err, &panicErr) {
+			return "", fmt.Errorf("before_query hook error: %w", err)
+		}
 		if err != nil {
 			if hookCtx.Err() == context.DeadlineExceeded {
+				if clamped {
+					return "", fmt.Errorf("before_query hook error: %w", budget.Err())
+				}
 				return "", fmt.Errorf("before_query hook error: hook timed out (name: %s, timeout: %s)", entry.Name, timeout)
 			}
 			return "", fmt.Errorf("before_query hook error: hook rejected query (name: %s): %w", entry.Name, err)
@@ -223,12 +619,26 @@ func (p *PostgresMcp) runGoAfterHooks(ctx context.Context, result *QueryOutput)
 		if timeout == 0 {
 			timeout = time.Duration(p.config.DefaultHookTimeoutSeconds) * time.Second
 		}
+		budget := hooks.BudgetFromContext(ctx)
+		timeout, clamped := budget.Clamp(timeout)
+		if timeout <= 0 {
+			return nil, fmt.Errorf("after_query hook error: %w", budget.Err())
+		}
 		hookCtx, cancel := context.WithTimeout(ctx, timeout)
 
-		modified, err := entry.Hook.Run(hookCtx, result)
+		start := time.Now()
+		modified, err := p.runAfterHook(hookCtx, entry, result)
 		cancel()
+		budget.Spend(time.Since(start))
+		var panicErr *hookPanicError
+		if errors.As(err, &panicErr) {
+			return nil, fmt.Errorf("after_query hook error: %w", err)
+		}
 		if err != nil {
 			if hookCtx.Err() == context.DeadlineExceeded {
+				if clamped {
+					return nil, fmt.Errorf("after_query hook error: %w", budget.Err())
+				}
 				return nil, fmt.Errorf("after_query hook error: hook timed out (name: %s, timeout: %s)", entry.Name, timeout)
 			}
 			return nil, fmt.Errorf("after_query hook error: hook rejected result (name: %s): %w", entry.Name, err)
@@ -238,35 +648,601 @@ func (p *PostgresMcp) runGoAfterHooks(ctx context.Context, result *QueryOutput)
 	return result, nil
 }
 
-// collectRows reads all rows from pgx.Rows and returns a QueryOutput.
-func (p *PostgresMcp) collectRows(rows pgx.Rows) (*QueryOutput, error) {
+// hookPanicError is a panic in a Go hook, recovered so that one buggy hook fails its
+// query (rolling back any write) instead of crashing the process.
+type hookPanicError struct {
+	name  string
+	value interface{}
+}
+
+func (e *hookPanicError) Error() string {
+	return fmt.Sprintf("hook %q panicked: %v", e.name, e.value)
+}
+
+// recoverHookPanic turns a recovered hook panic into *err, logging the stack.
+func (p *PostgresMcp) recoverHookPanic(name string, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	p.logger.Error().Str("hook", name).Interface("panic", r).Str("stack", string(debug.Stack())).Msg("Go hook panicked")
+	*err = &hookPanicError{name: name, value: r}
+}
+
+func (p *PostgresMcp) runBeforeHook(ctx context.Context, entry BeforeQueryHookEntry, sql string) (modified string, err error) {
+	defer p.recoverHookPanic(entry.Name, &err)
+	return entry.Hook.Run(ctx, sql)
+}
+
+func (p *PostgresMcp) runAfterHook(ctx context.Context, entry AfterQueryHookEntry, result *QueryOutput) (modified *QueryOutput, err error) {
+	defer p.recoverHookPanic(entry.Name, &err)
+	return entry.Hook.Run(ctx, result)
+}
+
+// execution holds an executed statement whose transaction is still open.
+type execution struct {
+	conn   queryConn
+	tx     pgx.Tx
+	result *QueryOutput
+	tag    pgconn.CommandTag
+	fields []pgconn.FieldDescription
+	dims   []int // array dimensions per column, collected only for query.column_type_details
+	// elapsed is how long the statement took to run and return all rows.
+	elapsed time.Duration
+	// keysOnly means result holds only the primary keys added by execOptions.affectedKeys
+	// or, when generatedKeys is also set, by execOptions.generatedKeys.
+	keysOnly      bool
+	generatedKeys bool
+}
+
+// execOptions adjusts how execute runs a statement.
+type execOptions struct {
+	includePlan bool // run EXPLAIN first and set PlanSummary
+	// unchecked runs sql in a READ ONLY transaction with statement_timeout set to the time
+	// left on queryCtx, for statements protection could not check (protection.on_parse_failure).
+	unchecked bool
+	// readOnly begins a READ ONLY transaction (QueryInput.ForceReadOnly, or read_only mode).
+	readOnly bool
+	// readWrite begins a READ WRITE transaction, overriding default_transaction_read_only
+	// (CREATE TEMP TABLE in read_only mode with protection.allow_temp_tables).
+	readWrite bool
+	// session is the caller's CallInfo.SessionID. When the session holds temp tables, or
+	// createsTempTable is set, sql runs on the session's own connection (see acquire).
+	session          string
+	createsTempTable bool
+	// affectedKeys adds RETURNING <primary key> to an UPDATE or DELETE without RETURNING
+	// (query.return_affected_keys); execution.keysOnly reports whether it did.
+	affectedKeys bool
+	// generatedKeys adds RETURNING <generated primary key> to an INSERT without RETURNING
+	// (query.auto_return_generated_keys); execution.generatedKeys reports whether it did.
+	generatedKeys bool
+	// planOut, when set, receives the EXPLAIN (FORMAT JSON) output for explainable
+	// statements before they run (audit_explain).
+	planOut *json.RawMessage
+}
+
+// execute acquires a connection, begins a transaction, runs sql, and collects all rows.
+// On success the caller owns conn and tx; on error both have already been released.
+func (p *PostgresMcp) execute(ctx, queryCtx context.Context, sql string, opts execOptions) (*execution, error) {
+	conn, err := p.acquire(queryCtx, opts)
+	if err != nil {
+		return nil, err
+	}
+	var txOptions pgx.TxOptions
+	switch {
+	case opts.unchecked, opts.readOnly:
+		txOptions.AccessMode = pgx.ReadOnly
+	case opts.readWrite:
+		txOptions.AccessMode = pgx.ReadWrite
+	}
+	tx, err := conn.BeginTx(queryCtx, txOptions)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+	if opts.unchecked {
+		deadline, _ := queryCtx.Deadline()
+		if _, err := tx.Exec(queryCtx, fmt.Sprintf("SET LOCAL statement_timeout = %d", max(time.Until(deadline).Milliseconds(), 1))); err != nil {
+			tx.Rollback(ctx)
+			conn.Release()
+			return nil, fmt.Errorf("failed to set statement_timeout: %w", err)
+		}
+	}
+	// Server-issued, so it bypasses AllowSet; scoped to this transaction only.
+	if p.config.Query.LockTimeoutMillis > 0 {
+		if _, err := tx.Exec(queryCtx, fmt.Sprintf("SET LOCAL lock_timeout = %d", p.config.Query.LockTimeoutMillis)); err != nil {
+			tx.Rollback(ctx)
+			conn.Release()
+			return nil, fmt.Errorf("failed to set lock_timeout: %w", err)
+		}
+	}
+	if p.notices != nil {
+		pgConn := conn.Conn().PgConn()
+		p.notices.start(pgConn)
+		defer p.notices.stop(pgConn) // no-op after the explicit stop; covers error returns
+	}
+	if opts.planOut != nil {
+		*opts.planOut = auditPlan(queryCtx, tx, sql)
+	}
+	if p.secdef != nil {
+		if err := p.secdef.check(queryCtx, tx, sql); err != nil {
+			tx.Rollback(ctx)
+			conn.Release()
+			return nil, err
+		}
+	}
+	if p.volatile != nil && opts.readOnly {
+		if err := p.volatile.check(queryCtx, tx, sql); err != nil {
+			tx.Rollback(ctx)
+			conn.Release()
+			return nil, err
+		}
+	}
+	if p.config.Query.MaxRowsAffected > 0 && isUpdateOrDelete(sql) {
+		if err := p.checkEstimatedRowsAffected(queryCtx, tx, sql); err != nil {
+			tx.Rollback(ctx)
+			conn.Release()
+			return nil, err
+		}
+	}
+	var plan *PlanSummary
+	var advisories []string
+	if opts.includePlan {
+		if plan, advisories, err = explainPlan(queryCtx, tx, sql, p.config.Query.PlanAdvisories); err != nil {
+			tx.Rollback(ctx)
+			conn.Release()
+			return nil, err
+		}
+	}
+	keysOnly, generatedKeys := false, false
+	if opts.affectedKeys {
+		if sql, keysOnly, err = returningPrimaryKey(queryCtx, tx, sql); err != nil {
+			tx.Rollback(ctx)
+			conn.Release()
+			return nil, err
+		}
+	}
+	if opts.generatedKeys && !keysOnly {
+		if sql, generatedKeys, err = returningGeneratedKeys(queryCtx, tx, sql); err != nil {
+			tx.Rollback(ctx)
+			conn.Release()
+			return nil, err
+		}
+		keysOnly = generatedKeys
+	}
+	start := time.Now()
+	rows, err := tx.Query(queryCtx, sql)
+	if err != nil {
+		tx.Rollback(ctx)
+		conn.Release()
+		return nil, p.wrapLockTimeout(err)
+	}
+	// Copy: pgconn reuses the field description buffer for the connection's next query.
+	fields := append([]pgconn.FieldDescription(nil), rows.FieldDescriptions()...)
+	var dims []int
+	if p.config.Query.ColumnTypeDetails {
+		dims = make([]int, len(fields))
+	}
+	// EXPLAIN output is already text (or JSON built by Postgres); nothing to convert.
+	convert := p.resultConverter()
+	if isExplainStatement(sql) {
+		convert = func(v interface{}) interface{} { return v }
+	}
+	result, tag, err := p.collectRows(rows, dims, convert)
+	if err != nil {
+		tx.Rollback(ctx)
+		conn.Release()
+		return nil, p.wrapLockTimeout(err)
+	}
+	result.PlanSummary = plan
+	result.Advisories = advisories
+	if p.notices != nil {
+		result.Notices = p.notices.stop(conn.Conn().PgConn())
+	}
+	return &execution{conn: conn, tx: tx, result: result, tag: tag, fields: fields, dims: dims, elapsed: time.Since(start), keysOnly: keysOnly, generatedKeys: generatedKeys}, nil
+}
+
+// connectionLimitCode returns the SQLSTATE if err is 53300 too_many_connections or 53400
+// configuration_limit_exceeded (e.g. a role's CONNECTION LIMIT), typically seen while
+// the pool opens a new connection.
+func connectionLimitCode(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && (pgErr.Code == "53300" || pgErr.Code == "53400") {
+		return pgErr.Code, true
+	}
+	return "", false
+}
+
+// isConnectionError reports whether err is a connection-level failure (broken socket,
+// server shutdown/restart) rather than an error in the query itself.
+func isConnectionError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Class 08 (connection exception), 57P01 admin_shutdown, 57P02 crash_shutdown, 57P03 cannot_connect_now.
+		return strings.HasPrefix(pgErr.Code, "08") || pgErr.Code == "57P01" || pgErr.Code == "57P02" || pgErr.Code == "57P03"
+	}
+	var netErr net.Error
+	return pgconn.SafeToRetry(err) || errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// setCommitLSN sets output.CommitLSN to the WAL insert position read on the connection
+// that just committed a write (query.return_commit_info): at or past the end of its
+// commit record, or further if other sessions wrote meanwhile. The write is already
+// committed, so a failure is logged and the field left empty.
+func (p *PostgresMcp) setCommitLSN(ctx context.Context, conn queryConn, output *QueryOutput) {
+	var lsn string
+	if err := conn.Conn().QueryRow(ctx, "SELECT pg_catalog.pg_current_wal_insert_lsn()::text").Scan(&lsn); err != nil {
+		p.logger.Warn().Err(err).Int64("txid", output.TxID).Msg("failed to read commit LSN, returning the result without it (query.return_commit_info)")
+		return
+	}
+	output.CommitLSN = lsn
+}
+
+// isDeadlock reports whether err is a deadlock_detected error (SQLSTATE 40P01).
+func isDeadlock(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "40P01"
+}
+
+// deadlockRetryBackoff is the wait before deadlock retry attempt (1-based): 50ms, doubling
+// per attempt, capped at 1 second.
+func deadlockRetryBackoff(attempt int) time.Duration {
+	return min(50*time.Millisecond<<min(attempt-1, 5), time.Second)
+}
+
+// wrapLockTimeout explains a lock_timeout cancellation raised while
+// query.lock_timeout_millis is set, so the agent knows the query waited on another
+// transaction's lock rather than failing on its own. A NOWAIT lock that was taken
+// (the same SQLSTATE 55P03) is explained without blaming lock_timeout.
+func (p *PostgresMcp) wrapLockTimeout(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "55P03" {
+		return err
+	}
+	if isLockTimeout(pgErr) {
+		if p.config.Query.LockTimeoutMillis > 0 {
+			return fmt.Errorf("could not obtain lock within lock_timeout (%dms): another transaction holds a conflicting lock, retry later: %w", p.config.Query.LockTimeoutMillis, err)
+		}
+		return err
+	}
+	return fmt.Errorf("lock not available: another transaction holds a conflicting lock and the statement asked not to wait (NOWAIT), retry later: %w", err)
+}
+
+// hasAfterQueryHooks reports whether any Go or command AfterQuery hooks are configured.
+func (p *PostgresMcp) hasAfterQueryHooks() bool {
+	return len(p.goAfterHooks) > 0 || (p.cmdHooks != nil && p.cmdHooks.HasAfterQueryHooks())
+}
+
+// isIdleInTransactionTimeout reports whether err is the server terminating the session
+// because of idle_in_transaction_session_timeout (SQLSTATE 25P03).
+func isIdleInTransactionTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "25P03"
+}
+
+// validateHookOutput checks that AfterQuery hooks left the result internally consistent:
+// every row key must be present in Columns.
+func validateHookOutput(out *QueryOutput) error {
+	if out == nil {
+		return fmt.Errorf("after-hook produced nil output")
+	}
+	columns := make(map[string]bool, len(out.Columns))
+	for _, col := range out.Columns {
+		columns[col] = true
+	}
+	for i, row := range out.Rows {
+		rowKeys := make([]string, 0, len(row))
+		for k := range row {
+			rowKeys = append(rowKeys, k)
+		}
+		sort.Strings(rowKeys) // deterministic error message
+		for _, k := range rowKeys {
+			if !columns[k] {
+				return fmt.Errorf("after-hook produced row key %q not in Columns (row %d)", k, i)
+			}
+		}
+	}
+	return nil
+}
+
+// collectRows reads all rows from pgx.Rows, converting each value with convert, and returns
+// a QueryOutput along with the command tag. If dims is non-nil, it receives each column's
+// array dimensions from the first value that has any (see rawArrayDims). A statement run
+// with QueryExecModeExec yields exactly one result set, so rows is the whole result.
+func (p *PostgresMcp) collectRows(rows pgx.Rows, dims []int, convert func(interface{}) interface{}) (*QueryOutput, pgconn.CommandTag, error) {
 	defer rows.Close()
 
+	// Columns follow the field description order, i.e. the statement's SELECT list order.
+	// Duplicate names are made unique (query.duplicate_column_mode) so no value is lost.
 	fieldDescs := rows.FieldDescriptions()
-	columns := make([]string, len(fieldDescs))
-	for i, fd := range fieldDescs {
-		columns[i] = fd.Name
+	columns, err := p.resultColumnNames(fieldDescs)
+	if err != nil {
+		return nil, pgconn.CommandTag{}, err
 	}
 
+	formatters := p.columnFormatters(fieldDescs)
+
 	resultRows := make([]map[string]interface{}, 0)
 	for rows.Next() {
 		values, err := rows.Values()
 		if err != nil {
-			return nil, err
+			return nil, pgconn.CommandTag{}, err
 		}
 		row := make(map[string]interface{}, len(columns))
 		for i, col := range columns {
-			row[col] = convertValue(values[i])
+			if formatters != nil && formatters[i] != nil {
+				values[i] = formatters[i](values[i])
+			}
+			if p.config.Query.SpecialFloatMode == SpecialFloatError && isFloatOID(fieldDescs[i].DataTypeOID) && hasNonFiniteFloat(values[i]) {
+				return nil, pgconn.CommandTag{}, fmt.Errorf("column %q contains NaN or Infinity, which query.special_float_mode %q rejects: filter them out (e.g. WHERE v NOT IN ('NaN', 'Infinity', '-Infinity')) or cast the column to text", col, SpecialFloatError)
+			}
+			row[col] = convert(values[i])
+		}
+		if dims != nil {
+			raw := rows.RawValues()
+			for i, fd := range fieldDescs {
+				if dims[i] == 0 {
+					dims[i] = rawArrayDims(fd.Format, raw[i])
+				}
+			}
 		}
 		resultRows = append(resultRows, row)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, pgconn.CommandTag{}, err
+	}
+
+	tag := rows.CommandTag()
+
+	return &QueryOutput{Columns: columns, Rows: resultRows, RowsAffected: tag.RowsAffected()}, tag, nil
+}
+
+// columnFormatters returns, per result column, the type-specific formatting applied before
+// conversion, or nil when no column needs any:
+//   - date and date[] become "2006-01-02" strings, unless query.date_as_timestamp
+//   - boolean and boolean[] follow query.boolean_format
+//   - real, double precision and their arrays follow query.special_float_mode "null"
+//   - "char" and "char"[] (catalog columns such as pg_class.relkind) become one-character
+//     strings instead of the byte's code point
+func (p *PostgresMcp) columnFormatters(fieldDescs []pgconn.FieldDescription) []func(interface{}) interface{} {
+	var formatters []func(interface{}) interface{}
+	set := func(i int, f func(interface{}) interface{}) {
+		if formatters == nil {
+			formatters = make([]func(interface{}) interface{}, len(fieldDescs))
+		}
+		formatters[i] = f
+	}
+	for i, fd := range fieldDescs {
+		switch fd.DataTypeOID {
+		case pgtype.DateOID, pgtype.DateArrayOID:
+			if !p.config.Query.DateAsTimestamp {
+				set(i, dateOnly)
+			}
+		case pgtype.Float4OID, pgtype.Float8OID, pgtype.Float4ArrayOID, pgtype.Float8ArrayOID:
+			if p.config.Query.SpecialFloatMode == SpecialFloatNull {
+				set(i, nonFiniteAsNull)
+			}
+		case pgtype.QCharOID, pgtype.QCharArrayOID:
+			set(i, qcharAsString)
+		case pgtype.BoolOID, pgtype.BoolArrayOID:
+			switch p.config.Query.BooleanFormat {
+			case BooleanFormatInt:
+				set(i, boolAsInt)
+			case BooleanFormatTF:
+				set(i, boolAsTF)
+			}
+		}
+	}
+	return formatters
+}
+
+// isFloatOID reports whether oid is real, double precision, or an array of either.
+func isFloatOID(oid uint32) bool {
+	switch oid {
+	case pgtype.Float4OID, pgtype.Float8OID, pgtype.Float4ArrayOID, pgtype.Float8ArrayOID:
+		return true
+	}
+	return false
+}
+
+// isNonFinite reports whether v is a float32 or float64 NaN or ±Infinity.
+func isNonFinite(v interface{}) bool {
+	switch val := v.(type) {
+	case float32:
+		return math.IsNaN(float64(val)) || math.IsInf(float64(val), 0)
+	case float64:
+		return math.IsNaN(val) || math.IsInf(val, 0)
 	}
+	return false
+}
 
-	rowsAffected := rows.CommandTag().RowsAffected()
+// hasNonFiniteFloat reports whether v, or any element of an array v, is non-finite
+// (query.special_float_mode "error").
+func hasNonFiniteFloat(v interface{}) bool {
+	if arr, ok := v.([]interface{}); ok {
+		return slices.ContainsFunc(arr, hasNonFiniteFloat)
+	}
+	return isNonFinite(v)
+}
 
-	return &QueryOutput{Columns: columns, Rows: resultRows, RowsAffected: rowsAffected}, nil
+// nonFiniteAsNull replaces NaN and ±Infinity with nil (query.special_float_mode "null"),
+// recursing into arrays.
+func nonFiniteAsNull(v interface{}) interface{} {
+	switch val := v.(type) {
+	case []interface{}:
+		result := make([]interface{}, len(val))
+		for i, v := range val {
+			result[i] = nonFiniteAsNull(v)
+		}
+		return result
+	}
+	if isNonFinite(v) {
+		return nil
+	}
+	return v
+}
+
+// qcharAsString formats "char" values, which pgx decodes as a rune, as strings. The zero
+// byte, Postgres's empty "char", becomes "". Recurses into arrays.
+func qcharAsString(v interface{}) interface{} {
+	switch val := v.(type) {
+	case rune:
+		if val == 0 {
+			return ""
+		}
+		return string(val)
+	case []interface{}:
+		result := make([]interface{}, len(val))
+		for i, v := range val {
+			result[i] = qcharAsString(v)
+		}
+		return result
+	}
+	return v
+}
+
+// boolAsInt formats booleans as 1 and 0 (query.boolean_format "int"), recursing into arrays.
+func boolAsInt(v interface{}) interface{} {
+	switch val := v.(type) {
+	case bool:
+		if val {
+			return 1
+		}
+		return 0
+	case []interface{}:
+		result := make([]interface{}, len(val))
+		for i, v := range val {
+			result[i] = boolAsInt(v)
+		}
+		return result
+	}
+	return v
+}
+
+// boolAsTF formats booleans as "t" and "f" (query.boolean_format "tf"), recursing into arrays.
+func boolAsTF(v interface{}) interface{} {
+	switch val := v.(type) {
+	case bool:
+		if val {
+			return "t"
+		}
+		return "f"
+	case []interface{}:
+		result := make([]interface{}, len(val))
+		for i, v := range val {
+			result[i] = boolAsTF(v)
+		}
+		return result
+	}
+	return v
+}
+
+// normalizeResultShape makes Columns and Rows non-nil and replaces nil rows with empty
+// ones, so zero-column results (SELECT; SELECT FROM t) and hook output that omits or nulls
+// them serialize as [] / {} and later steps can write to every row.
+func normalizeResultShape(output *QueryOutput) {
+	if output.Columns == nil {
+		output.Columns = []string{}
+	}
+	if output.Rows == nil {
+		output.Rows = []map[string]interface{}{}
+	}
+	for i, row := range output.Rows {
+		if row == nil {
+			output.Rows[i] = map[string]interface{}{}
+		}
+	}
+}
+
+// dateOnly formats the time.Time values pgx decodes date columns to as "2006-01-02",
+// recursing into arrays, and 'infinity'/'-infinity' as those strings.
+func dateOnly(v interface{}) interface{} {
+	switch val := v.(type) {
+	case time.Time:
+		return val.Format(time.DateOnly)
+	case pgtype.InfinityModifier:
+		return val.String()
+	case []interface{}:
+		result := make([]interface{}, len(val))
+		for i, v := range val {
+			result[i] = dateOnly(v)
+		}
+		return result
+	}
+	return v
+}
+
+// insertOID extracts the OID from an INSERT command tag ("INSERT <oid> <rows>").
+// Returns 0 for non-INSERT tags.
+func insertOID(tag pgconn.CommandTag) uint32 {
+	if !tag.Insert() {
+		return 0
+	}
+	fields := strings.Fields(tag.String())
+	if len(fields) != 3 {
+		return 0
+	}
+	oid, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint32(oid)
+}
+
+// resultConverter returns Config.ResultConverter, or DefaultResultConverter when unset,
+// with geometric types handled first when query.geometry_as_object is set. Values are cut
+// to query.max_json_depth before any of them sees them.
+func (p *PostgresMcp) resultConverter() func(interface{}) interface{} {
+	convert := convertValue
+	if p.config.ResultConverter != nil {
+		convert = p.config.ResultConverter
+	}
+	if p.config.Query.GeometryAsObject {
+		convert = geometryObjectConverter(convert)
+	}
+	maxDepth := p.config.Query.MaxJSONDepth
+	return func(v interface{}) interface{} {
+		return convert(limitDepth(v, maxDepth))
+	}
+}
+
+// defaultMaxJSONDepth is query.max_json_depth when unset.
+const defaultMaxJSONDepth = 100
+
+// maxDepthExceeded replaces JSON objects and arrays nested deeper than query.max_json_depth.
+const maxDepthExceeded = "<max depth exceeded>"
+
+// limitDepth replaces maps and slices nested more than depth levels inside v with
+// maxDepthExceeded, in place, so the recursive conversion and sanitization that follow
+// are bounded. The top-level object or array is level 1.
+func limitDepth(v interface{}, depth int) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if depth <= 0 {
+			return maxDepthExceeded
+		}
+		for k, item := range val {
+			val[k] = limitDepth(item, depth-1)
+		}
+	case []interface{}:
+		if depth <= 0 {
+			return maxDepthExceeded
+		}
+		for i, item := range val {
+			val[i] = limitDepth(item, depth-1)
+		}
+	}
+	return v
+}
+
+// DefaultResultConverter is the built-in conversion of pgx-returned values to JSON-friendly
+// Go types (see Type Handling in the README). Custom Config.ResultConverter functions can
+// delegate to it for the values they do not handle.
+func DefaultResultConverter(v interface{}) interface{} {
+	return convertValue(v)
 }
 
 // convertValue converts a pgx-returned value to a JSON-friendly Go type.
@@ -477,6 +1453,17 @@ func convertValue(v interface{}) interface{} {
 // handleError converts any error into a QueryOutput with error message.
 // The error message is evaluated against error_prompts — matching prompt messages are appended.
 func (p *PostgresMcp) handleError(err error) *QueryOutput {
+	// Connection-limit exhaustion is an operational condition, not a query error: tell the
+	// agent to back off instead of surfacing the raw connect error.
+	if code, ok := connectionLimitCode(err); ok {
+		p.logger.Warn().Err(err).Str("sqlstate", code).Msg("database connection limit reached")
+		errMsg := fmt.Sprintf("The database has reached its connection limit (SQLSTATE %s). This is temporary and not caused by your query: wait a few seconds and retry.", code)
+		if prompt := p.errPrompts.Match(errMsg); prompt != "" {
+			errMsg = errMsg + "\n\n" + prompt
+		}
+		return &QueryOutput{Error: errMsg, ErrorKind: ErrorKindDatabase, Retryable: true}
+	}
+
 	errMsg := err.Error()
 	prompt := p.errPrompts.Match(errMsg)
 	patterns := p.errPrompts.MatchedPatterns(errMsg)
@@ -487,10 +1474,16 @@ func (p *PostgresMcp) handleError(err error) *QueryOutput {
 	}
 	logEvent.Msg("query error")
 
+	// Prompts are matched against the full message: they are configured by the operator.
+	if p.config.ErrorDetailMode == ErrorDetailMinimal {
+		if minimal, ok := minimalErrorMessage(err); ok {
+			errMsg = minimal
+		}
+	}
 	if prompt != "" {
 		errMsg = errMsg + "\n\n" + prompt
 	}
-	return &QueryOutput{Error: errMsg}
+	return &QueryOutput{Error: errMsg, ErrorKind: errorKind(err), RowSecurityNote: rowSecurityErrorNote(err)}
 }
 
 // truncateIfNeeded truncates query output rows if they exceed MaxResultLength (in characters).
@@ -500,11 +1493,80 @@ func (p *PostgresMcp) truncateIfNeeded(output *QueryOutput) {
 	if utf8.RuneCountInString(jsonStr) <= p.config.Query.MaxResultLength {
 		return
 	}
+	if p.config.Query.SummarizeOversizeResults && p.summarizeOversize(output) {
+		return
+	}
 	// Truncate to MaxResultLength characters (runes)
 	runes := []rune(jsonStr)
 	truncated := string(runes[:p.config.Query.MaxResultLength])
 	output.Rows = nil
 	output.Error = truncated + "...[truncated] Result is too long! Add limits in your query!"
+	output.ErrorKind = ErrorKindTruncation
+}
+
+// resultHash returns the hex SHA-256 of rows serialized as JSON. encoding/json writes map
+// keys in sorted order, so identical rows in the same order always hash the same.
+func resultHash(rows []map[string]interface{}) string {
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+	jsonBytes, _ := json.Marshal(rows)
+	sum := sha256.Sum256(jsonBytes)
+	return hex.EncodeToString(sum[:])
+}
+
+const (
+	// defaultSummarySampleRows is the oversize-result summary's sample size when
+	// query.default_sample_rows is 0: the first and last 3 rows.
+	defaultSummarySampleRows = 6
+	// defaultMaxSampleRows is query.max_sample_rows when 0.
+	defaultMaxSampleRows = 100
+)
+
+// sampleSize resolves how many preview rows to return: requested when > 0, otherwise
+// query.default_sample_rows, otherwise fallback; clamped to query.max_sample_rows.
+// clamped reports whether an explicit request was cut down.
+func (p *PostgresMcp) sampleSize(requested, fallback int) (n int, clamped bool) {
+	maxRows := cmp.Or(p.config.Query.MaxSampleRows, defaultMaxSampleRows)
+	if requested > 0 {
+		return min(requested, maxRows), requested > maxRows
+	}
+	return min(cmp.Or(p.config.Query.DefaultSampleRows, fallback), maxRows), false
+}
+
+// summarizeOversize replaces output.Rows with output.Summary, whose head/tail sample is
+// sized so the whole serialized output fits within MaxResultLength. Fewer rows are
+// sampled if wide rows don't fit. Returns false if not even an empty sample fits,
+// leaving output unchanged.
+func (p *PostgresMcp) summarizeOversize(output *QueryOutput) bool {
+	rows := output.Rows
+	size, _ := p.sampleSize(0, defaultSummarySampleRows)
+	output.Rows = nil
+	for n := size; n >= 0; n-- {
+		output.Summary = &ResultSummary{
+			Columns:    output.Columns,
+			TotalRows:  len(rows),
+			SampleRows: sampleEdgeRows(rows, n),
+		}
+		jsonBytes, _ := json.Marshal(output)
+		if utf8.RuneCountInString(string(jsonBytes)) <= p.config.Query.MaxResultLength {
+			return true
+		}
+	}
+	output.Rows = rows
+	output.Summary = nil
+	return false
+}
+
+// sampleEdgeRows returns n rows: the first ceil(n/2) and last floor(n/2) (all rows if
+// there are at most n).
+func sampleEdgeRows(rows []map[string]interface{}, n int) []map[string]interface{} {
+	if len(rows) <= n {
+		return append([]map[string]interface{}{}, rows...)
+	}
+	sample := make([]map[string]interface{}, 0, n)
+	sample = append(sample, rows[:(n+1)/2]...)
+	return append(sample, rows[len(rows)-n/2:]...)
 }
 
 // truncateForLog truncates a string for log output to avoid oversized log entries.
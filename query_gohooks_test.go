@@ -2,6 +2,7 @@ package pgmcp_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"testing"
@@ -84,6 +85,14 @@ func (h *addColumnAfterHook) Run(_ context.Context, result *pgmcp.QueryOutput) (
 	return result, nil
 }
 
+// annotateAfterHook attaches fixed annotations to the result.
+type annotateAfterHook struct{}
+
+func (h *annotateAfterHook) Run(_ context.Context, result *pgmcp.QueryOutput) (*pgmcp.QueryOutput, error) {
+	result.Annotations = map[string]interface{}{"risk_score": 0.8, "classification": "pii"}
+	return result, nil
+}
+
 // slowAfterHook sleeps until context is cancelled or duration elapses.
 type slowAfterHook struct {
 	sleepDuration time.Duration
@@ -108,6 +117,20 @@ func (h *captureAfterHook) Run(_ context.Context, result *pgmcp.QueryOutput) (*p
 	return result, nil
 }
 
+// panicBeforeHook panics instead of returning.
+type panicBeforeHook struct{}
+
+func (h *panicBeforeHook) Run(_ context.Context, _ string) (string, error) {
+	panic("enricher bug")
+}
+
+// panicAfterHook panics instead of returning.
+type panicAfterHook struct{}
+
+func (h *panicAfterHook) Run(_ context.Context, _ *pgmcp.QueryOutput) (*pgmcp.QueryOutput, error) {
+	panic("auditor bug")
+}
+
 // --- Test cases ---
 
 func TestQuery_GoBeforeHook_Accept(t *testing.T) {
@@ -154,6 +177,9 @@ func TestQuery_GoBeforeHook_Reject(t *testing.T) {
 	if !strings.Contains(output.Error, "query not allowed by policy") {
 		t.Fatalf("expected rejection message in error, got %q", output.Error)
 	}
+	if output.ErrorKind != pgmcp.ErrorKindHook {
+		t.Fatalf("expected error kind %q, got %q", pgmcp.ErrorKindHook, output.ErrorKind)
+	}
 }
 
 func TestQuery_GoBeforeHook_ModifyQuery(t *testing.T) {
@@ -223,6 +249,76 @@ func TestQuery_GoBeforeHook_ProtectionStillApplied(t *testing.T) {
 	}
 }
 
+func TestQuery_GoBeforeHook_HooksThenProtection_ChecksRewrittenSQL(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 5
+	tracker := &trackingBeforeHook{}
+	config.BeforeQueryHooks = []pgmcp.BeforeQueryHookEntry{
+		{Name: "tracker", Hook: tracker},
+		{Name: "rewriter", Hook: &modifyBeforeHook{replacement: "SELECT 1 AS val"}},
+	}
+	p, _ := newTestInstance(t, config)
+
+	// Default order: only the hook output is checked, so a blocked original is allowed.
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "DROP TABLE users"})
+	if output.Error != "" {
+		t.Fatalf("expected rewritten query to run, got error: %s", output.Error)
+	}
+	if !tracker.called {
+		t.Fatal("expected before hook to run")
+	}
+	if len(output.Rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(output.Rows))
+	}
+}
+
+func TestQuery_GoBeforeHook_ProtectionThenHooks_ChecksOriginalSQL(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 5
+	config.HookProtectionOrder = pgmcp.HookProtectionOrderProtectionFirst
+	tracker := &trackingBeforeHook{}
+	config.BeforeQueryHooks = []pgmcp.BeforeQueryHookEntry{
+		{Name: "tracker", Hook: tracker},
+		{Name: "rewriter", Hook: &modifyBeforeHook{replacement: "SELECT 1 AS val"}},
+	}
+	p, _ := newTestInstance(t, config)
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "DROP TABLE users"})
+	if !strings.Contains(output.Error, "DROP") {
+		t.Fatalf("expected DROP protection error on the original SQL, got %q", output.Error)
+	}
+	if tracker.called {
+		t.Fatal("expected before hooks not to run when the original SQL is blocked")
+	}
+
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 2"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if !tracker.called {
+		t.Fatal("expected before hook to run")
+	}
+}
+
+func TestQuery_GoBeforeHook_ProtectionThenHooks_ChecksRewrittenSQL(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 5
+	config.HookProtectionOrder = pgmcp.HookProtectionOrderProtectionFirst
+	config.BeforeQueryHooks = []pgmcp.BeforeQueryHookEntry{
+		{Name: "sneaky", Hook: &modifyBeforeHook{replacement: "DROP TABLE users"}},
+	}
+	p, _ := newTestInstance(t, config)
+
+	// The original passes, but the second pass still catches what the hook produced.
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1"})
+	if !strings.Contains(output.Error, "DROP") {
+		t.Fatalf("expected DROP protection error on the rewritten SQL, got %q", output.Error)
+	}
+}
+
 func TestQuery_GoAfterHook_Accept(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
@@ -267,6 +363,9 @@ func TestQuery_GoAfterHook_Reject(t *testing.T) {
 	if !strings.Contains(output.Error, "result rejected by audit hook") {
 		t.Fatalf("expected rejection message in error, got %q", output.Error)
 	}
+	if output.ErrorKind != pgmcp.ErrorKindHook {
+		t.Fatalf("expected error kind %q, got %q", pgmcp.ErrorKindHook, output.ErrorKind)
+	}
 }
 
 func TestQuery_GoAfterHook_ModifyResult(t *testing.T) {
@@ -293,6 +392,31 @@ func TestQuery_GoAfterHook_ModifyResult(t *testing.T) {
 	}
 }
 
+func TestQuery_GoAfterHook_Annotations(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 5
+	config.AfterQueryHooks = []pgmcp.AfterQueryHookEntry{
+		{Name: "risk", Hook: &annotateAfterHook{}},
+	}
+	p, _ := newTestInstance(t, config)
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1 AS val"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if output.Annotations["risk_score"] != 0.8 || output.Annotations["classification"] != "pii" {
+		t.Fatalf("expected the hook's annotations, got %v", output.Annotations)
+	}
+	jsonBytes, err := json.Marshal(output)
+	if err != nil {
+		t.Fatalf("failed to marshal output: %v", err)
+	}
+	if !strings.Contains(string(jsonBytes), `"annotations":{"classification":"pii","risk_score":0.8}`) {
+		t.Fatalf("expected annotations in serialized output, got %s", jsonBytes)
+	}
+}
+
 func TestQuery_GoAfterHook_Timeout(t *testing.T) {
 	t.Parallel()
 	config := defaultConfig()
@@ -415,6 +539,65 @@ func TestQuery_GoAfterHook_RejectRollbacksWrite(t *testing.T) {
 	}
 }
 
+func TestQuery_GoBeforeHook_PanicReturnsError(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 5
+	config.BeforeQueryHooks = []pgmcp.BeforeQueryHookEntry{
+		{Name: "enricher", Hook: &panicBeforeHook{}},
+	}
+	p, _ := newTestInstance(t, config)
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1"})
+	if !strings.Contains(output.Error, `hook "enricher" panicked: enricher bug`) {
+		t.Fatalf("expected panic error, got %q", output.Error)
+	}
+	// The instance keeps serving queries.
+	output = p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1"})
+	if !strings.Contains(output.Error, "panicked") {
+		t.Fatalf("expected the hook to panic again, got %q", output.Error)
+	}
+}
+
+func TestQuery_GoAfterHook_PanicRollbacksWrite(t *testing.T) {
+	t.Parallel()
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setupP, connStr := newTestInstance(t, setupConfig)
+	setupTable(t, setupP, "CREATE TABLE users_go_panic (id serial PRIMARY KEY, name text)")
+	setupP.Close(context.Background())
+
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 5
+	config.AfterQueryHooks = []pgmcp.AfterQueryHookEntry{
+		{Name: "auditor", Hook: &panicAfterHook{}},
+	}
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
+	}
+	defer p.Close(ctx)
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO users_go_panic (name) VALUES ('panicked_row')"})
+	if !strings.Contains(output.Error, `hook "auditor" panicked: auditor bug`) {
+		t.Fatalf("expected panic error, got %q", output.Error)
+	}
+
+	verifyP, err := pgmcp.New(ctx, connStr, defaultConfig(), testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create verify instance: %v", err)
+	}
+	defer verifyP.Close(ctx)
+	verifyOutput := verifyP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS cnt FROM users_go_panic"})
+	if verifyOutput.Error != "" {
+		t.Fatalf("verification query failed: %s", verifyOutput.Error)
+	}
+	if cnt := verifyOutput.Rows[0]["cnt"]; cnt != int64(0) {
+		t.Fatalf("expected 0 rows (rollback), got %v (%T)", cnt, cnt)
+	}
+}
+
 func TestQuery_GoAfterHook_AcceptCommitsWrite(t *testing.T) {
 	t.Parallel()
 	// Setup: create table with a non-hooked instance
@@ -874,7 +1057,192 @@ func TestQuery_MaxSQLLength_RejectsBeforeHooks(t *testing.T) {
 	if !strings.Contains(output.Error, "SQL query too long") {
 		t.Fatalf("expected SQL length error, got %q", output.Error)
 	}
+	if output.ErrorKind != pgmcp.ErrorKindProtection {
+		t.Fatalf("expected error kind %q, got %q", pgmcp.ErrorKindProtection, output.ErrorKind)
+	}
 	if tracker.called {
 		t.Fatal("expected BeforeQuery hook to NOT be called when max_sql_length rejects the query")
 	}
 }
+
+// forgetColumnAfterHook adds a row key without adding it to Columns (a buggy hook).
+type forgetColumnAfterHook struct{}
+
+func (h *forgetColumnAfterHook) Run(_ context.Context, result *pgmcp.QueryOutput) (*pgmcp.QueryOutput, error) {
+	for _, row := range result.Rows {
+		row["risk_score"] = 42
+	}
+	return result, nil
+}
+
+func TestQuery_GoAfterHook_ValidateHookOutputRejectsInconsistentResult(t *testing.T) {
+	t.Parallel()
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setupP, connStr := newTestInstance(t, setupConfig)
+	setupTable(t, setupP, "CREATE TABLE users_go_validate (id serial PRIMARY KEY, name text)")
+
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 5
+	config.ValidateHookOutput = true
+	config.AfterQueryHooks = []pgmcp.AfterQueryHookEntry{
+		{Name: "forgetful", Hook: &forgetColumnAfterHook{}},
+	}
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
+	}
+	defer p.Close(ctx)
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO users_go_validate (name) VALUES ('inconsistent') RETURNING *"})
+	expected := `after-hook produced row key "risk_score" not in Columns (row 0)`
+	if output.Error != expected {
+		t.Fatalf("expected error %q, got %q", expected, output.Error)
+	}
+
+	// The write must have been rolled back
+	verifyOutput := setupP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS cnt FROM users_go_validate"})
+	if verifyOutput.Error != "" {
+		t.Fatalf("verification query failed: %s", verifyOutput.Error)
+	}
+	if cnt := verifyOutput.Rows[0]["cnt"]; cnt != int64(0) {
+		t.Fatalf("expected 0 rows (rollback), got %v (%T)", cnt, cnt)
+	}
+}
+
+func TestQuery_GoAfterHook_ValidateHookOutputAcceptsConsistentResult(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 5
+	config.ValidateHookOutput = true
+	config.AfterQueryHooks = []pgmcp.AfterQueryHookEntry{
+		{Name: "adder", Hook: &addColumnAfterHook{}},
+	}
+	p, _ := newTestInstance(t, config)
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1 AS id"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if output.Rows[0]["hook_added"] != "injected" {
+		t.Fatalf("expected hook_added='injected', got %v", output.Rows[0]["hook_added"])
+	}
+}
+
+func TestQuery_GoAfterHook_ValidateHookOutputDisabledByDefault(t *testing.T) {
+	t.Parallel()
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 5
+	config.AfterQueryHooks = []pgmcp.AfterQueryHookEntry{
+		{Name: "forgetful", Hook: &forgetColumnAfterHook{}},
+	}
+	p, _ := newTestInstance(t, config)
+
+	output := p.Query(context.Background(), pgmcp.QueryInput{SQL: "SELECT 1 AS id"})
+	if output.Error != "" {
+		t.Fatalf("unexpected error: %s", output.Error)
+	}
+	if output.Rows[0]["risk_score"] != 42 {
+		t.Fatalf("expected risk_score=42 to pass through, got %v", output.Rows[0]["risk_score"])
+	}
+}
+
+func TestQuery_GoHooks_TotalBudgetExceededRollsBackWrite(t *testing.T) {
+	t.Parallel()
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setupP, connStr := newTestInstance(t, setupConfig)
+	setupTable(t, setupP, "CREATE TABLE users_go_budget (id serial PRIMARY KEY, name text)")
+
+	// Every hook is under its own 5s timeout; together they exceed the 1s total budget.
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 5
+	config.MaxTotalHookSeconds = 1
+	config.BeforeQueryHooks = []pgmcp.BeforeQueryHookEntry{
+		{Name: "slow-before", Hook: &slowBeforeHook{sleepDuration: 400 * time.Millisecond}},
+	}
+	config.AfterQueryHooks = []pgmcp.AfterQueryHookEntry{
+		{Name: "slow-after-1", Hook: &slowAfterHook{sleepDuration: 400 * time.Millisecond}},
+		{Name: "slow-after-2", Hook: &slowAfterHook{sleepDuration: 400 * time.Millisecond}},
+	}
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
+	}
+	defer p.Close(ctx)
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "INSERT INTO users_go_budget (name) VALUES ('slow') RETURNING *"})
+	expected := "after_query hook error: total hook time budget exceeded (budget: 1s)"
+	if output.Error != expected {
+		t.Fatalf("expected error %q, got %q", expected, output.Error)
+	}
+
+	// The write must have been rolled back
+	verifyOutput := setupP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT count(*) AS cnt FROM users_go_budget"})
+	if verifyOutput.Error != "" {
+		t.Fatalf("verification query failed: %s", verifyOutput.Error)
+	}
+	if cnt := verifyOutput.Rows[0]["cnt"]; cnt != int64(0) {
+		t.Fatalf("expected 0 rows (rollback), got %v (%T)", cnt, cnt)
+	}
+}
+
+func TestQuery_GoAfterHook_IdleInTransactionSafetyNetReleasesLocks(t *testing.T) {
+	t.Parallel()
+	setupConfig := defaultConfig()
+	setupConfig.Protection.AllowDDL = true
+	setupP, connStr := newTestInstance(t, setupConfig)
+	setupTable(t, setupP, "CREATE TABLE users_go_idle_tx (id int PRIMARY KEY, name text)")
+	setupTable(t, setupP, "INSERT INTO users_go_idle_tx (id, name) VALUES (1, 'original')")
+
+	// The hook sleeps well past the 1s safety net but within its own 10s timeout.
+	config := defaultConfig()
+	config.DefaultHookTimeoutSeconds = 10
+	config.HookIdleInTransactionTimeoutSeconds = 1
+	config.AfterQueryHooks = []pgmcp.AfterQueryHookEntry{
+		{Name: "slow", Hook: &slowAfterHook{sleepDuration: 4 * time.Second}},
+	}
+	ctx := context.Background()
+	p, err := pgmcp.New(ctx, connStr, config, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create PostgresMcp: %v", err)
+	}
+	defer p.Close(ctx)
+
+	// While the hook is still sleeping, a competing update on the same row must not block
+	// for the hook's full duration: the safety net terminates the idle transaction.
+	type competingResult struct {
+		output  *pgmcp.QueryOutput
+		elapsed time.Duration
+	}
+	competing := make(chan competingResult, 1)
+	start := time.Now()
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		output := setupP.Query(ctx, pgmcp.QueryInput{SQL: "UPDATE users_go_idle_tx SET name = 'competitor' WHERE id = 1"})
+		competing <- competingResult{output: output, elapsed: time.Since(start)}
+	}()
+
+	output := p.Query(ctx, pgmcp.QueryInput{SQL: "UPDATE users_go_idle_tx SET name = 'hooked' WHERE id = 1 RETURNING *"})
+	if !strings.Contains(output.Error, "write rolled back: AfterQuery hooks held the transaction open longer than idle_in_transaction_session_timeout (1s)") {
+		t.Fatalf("expected idle-in-transaction safety net error, got %q", output.Error)
+	}
+
+	res := <-competing
+	if res.output.Error != "" {
+		t.Fatalf("competing update failed: %s", res.output.Error)
+	}
+	if res.elapsed >= 4*time.Second {
+		t.Fatalf("expected competing update to finish before the hook returned, took %s", res.elapsed)
+	}
+
+	verifyOutput := setupP.Query(ctx, pgmcp.QueryInput{SQL: "SELECT name FROM users_go_idle_tx WHERE id = 1"})
+	if verifyOutput.Error != "" {
+		t.Fatalf("verification query failed: %s", verifyOutput.Error)
+	}
+	if name := verifyOutput.Rows[0]["name"]; name != "competitor" {
+		t.Fatalf("expected name='competitor' (hooked write rolled back), got %v", name)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"testing"
 	"time"
+
+	"github.com/rickchristie/postgres-mcp/internal/hooks"
 )
 
 // --- Mock hook implementations for unit tests ---
@@ -75,6 +77,20 @@ func (h *mockRejectAfterHook) Run(_ context.Context, _ *QueryOutput) (*QueryOutp
 	return nil, fmt.Errorf("result rejected")
 }
 
+// mockPanicBeforeHook panics instead of returning.
+type mockPanicBeforeHook struct{}
+
+func (h *mockPanicBeforeHook) Run(_ context.Context, _ string) (string, error) {
+	panic("nil map write")
+}
+
+// mockPanicAfterHook panics instead of returning.
+type mockPanicAfterHook struct{}
+
+func (h *mockPanicAfterHook) Run(_ context.Context, _ *QueryOutput) (*QueryOutput, error) {
+	panic(fmt.Errorf("index out of range"))
+}
+
 // mockSlowAfterHook sleeps until context is cancelled or duration elapses.
 type mockSlowAfterHook struct {
 	sleepDuration time.Duration
@@ -550,6 +566,45 @@ func TestGoAfterHooks_Reject(t *testing.T) {
 	}
 }
 
+func TestGoBeforeHooks_PanicRecovered(t *testing.T) {
+	t.Parallel()
+	next := &mockNeverCalledBeforeHook{}
+	p := newUnitTestInstance(
+		[]BeforeQueryHookEntry{
+			{Name: "enricher", Hook: &mockPanicBeforeHook{}},
+			{Name: "next", Hook: next},
+		},
+		nil,
+		5,
+	)
+
+	_, err := p.runGoBeforeHooks(context.Background(), "SELECT 1")
+	expected := `before_query hook error: hook "enricher" panicked: nil map write`
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected error %q, got %v", expected, err)
+	}
+	if next.called {
+		t.Fatal("expected the chain to stop at the panicking hook")
+	}
+}
+
+func TestGoAfterHooks_PanicRecovered(t *testing.T) {
+	t.Parallel()
+	p := newUnitTestInstance(
+		nil,
+		[]AfterQueryHookEntry{
+			{Name: "auditor", Hook: &mockPanicAfterHook{}},
+		},
+		5,
+	)
+
+	_, err := p.runGoAfterHooks(context.Background(), &QueryOutput{Columns: []string{"val"}})
+	expected := `after_query hook error: hook "auditor" panicked: index out of range`
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected error %q, got %v", expected, err)
+	}
+}
+
 func TestGoAfterHooks_ModifyResult(t *testing.T) {
 	t.Parallel()
 	p := newUnitTestInstance(
@@ -611,3 +666,99 @@ func TestGoAfterHooks_Timeout(t *testing.T) {
 		t.Fatalf("expected error %q, got %q", expected, err.Error())
 	}
 }
+
+// --- Total hook time budget unit tests ---
+
+func TestGoHooks_TotalBudgetExceededAcrossBeforeHooks(t *testing.T) {
+	t.Parallel()
+	// Each hook is well under its own 5s timeout, but together they exceed the 1s budget.
+	p := newUnitTestInstance(
+		[]BeforeQueryHookEntry{
+			{Name: "slow1", Hook: &mockSlowBeforeHook{sleepDuration: 400 * time.Millisecond}},
+			{Name: "slow2", Hook: &mockSlowBeforeHook{sleepDuration: 400 * time.Millisecond}},
+			{Name: "slow3", Hook: &mockSlowBeforeHook{sleepDuration: 400 * time.Millisecond}},
+		},
+		nil,
+		5,
+	)
+
+	ctx := hooks.WithBudget(context.Background(), hooks.NewBudget(1*time.Second))
+	start := time.Now()
+	_, err := p.runGoBeforeHooks(ctx, "SELECT 1")
+	if err == nil {
+		t.Fatal("expected budget error")
+	}
+	expected := `before_query hook error: total hook time budget exceeded (budget: 1s)`
+	if err.Error() != expected {
+		t.Fatalf("expected error %q, got %q", expected, err.Error())
+	}
+	if elapsed := time.Since(start); elapsed > 1500*time.Millisecond {
+		t.Fatalf("expected hooks to stop at the 1s budget, took %s", elapsed)
+	}
+}
+
+func TestGoHooks_TotalBudgetSharedWithAfterHooks(t *testing.T) {
+	t.Parallel()
+	p := newUnitTestInstance(
+		[]BeforeQueryHookEntry{
+			{Name: "slow-before", Hook: &mockSlowBeforeHook{sleepDuration: 600 * time.Millisecond}},
+		},
+		[]AfterQueryHookEntry{
+			{Name: "slow-after", Hook: &mockSlowAfterHook{sleepDuration: 600 * time.Millisecond}},
+		},
+		5,
+	)
+
+	ctx := hooks.WithBudget(context.Background(), hooks.NewBudget(1*time.Second))
+	if _, err := p.runGoBeforeHooks(ctx, "SELECT 1"); err != nil {
+		t.Fatalf("unexpected before hook error: %v", err)
+	}
+	_, err := p.runGoAfterHooks(ctx, &QueryOutput{Columns: []string{"val"}, Rows: []map[string]interface{}{{"val": int32(1)}}})
+	if err == nil {
+		t.Fatal("expected budget error")
+	}
+	expected := `after_query hook error: total hook time budget exceeded (budget: 1s)`
+	if err.Error() != expected {
+		t.Fatalf("expected error %q, got %q", expected, err.Error())
+	}
+}
+
+func TestGoHooks_TotalBudgetNotExceeded(t *testing.T) {
+	t.Parallel()
+	p := newUnitTestInstance(
+		[]BeforeQueryHookEntry{
+			{Name: "slow1", Hook: &mockSlowBeforeHook{sleepDuration: 100 * time.Millisecond}},
+			{Name: "slow2", Hook: &mockSlowBeforeHook{sleepDuration: 100 * time.Millisecond}},
+		},
+		nil,
+		5,
+	)
+
+	ctx := hooks.WithBudget(context.Background(), hooks.NewBudget(2*time.Second))
+	result, err := p.runGoBeforeHooks(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "SELECT 1" {
+		t.Fatalf("expected 'SELECT 1', got %q", result)
+	}
+}
+
+func TestGoHooks_OwnTimeoutReportedWhenBelowBudget(t *testing.T) {
+	t.Parallel()
+	// The hook's own 1s timeout is tighter than the 10s budget, so the per-hook error is reported.
+	p := newUnitTestInstance(
+		[]BeforeQueryHookEntry{
+			{Name: "slow", Timeout: 1 * time.Second, Hook: &mockSlowBeforeHook{sleepDuration: 2 * time.Second}},
+		},
+		nil,
+		5,
+	)
+
+	ctx := hooks.WithBudget(context.Background(), hooks.NewBudget(10*time.Second))
+	_, err := p.runGoBeforeHooks(ctx, "SELECT 1")
+	expected := `before_query hook error: hook timed out (name: slow, timeout: 1s)`
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected error %q, got %v", expected, err)
+	}
+}
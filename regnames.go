@@ -0,0 +1,158 @@
+package pgmcp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// catalogOIDColumns maps system catalog columns holding OIDs to the reg* type that names
+// them, for query.resolve_reg_types. Columns whose target depends on another column, such
+// as pg_depend.objid, are not listed.
+var catalogOIDColumns = map[string]string{
+	"pg_class.oid":               "regclass",
+	"pg_class.relnamespace":      "regnamespace",
+	"pg_class.reltype":           "regtype",
+	"pg_class.relowner":          "regrole",
+	"pg_class.reltoastrelid":     "regclass",
+	"pg_attribute.attrelid":      "regclass",
+	"pg_attribute.atttypid":      "regtype",
+	"pg_attrdef.adrelid":         "regclass",
+	"pg_type.oid":                "regtype",
+	"pg_type.typnamespace":       "regnamespace",
+	"pg_type.typowner":           "regrole",
+	"pg_type.typrelid":           "regclass",
+	"pg_type.typelem":            "regtype",
+	"pg_type.typarray":           "regtype",
+	"pg_type.typbasetype":        "regtype",
+	"pg_proc.oid":                "regprocedure",
+	"pg_proc.pronamespace":       "regnamespace",
+	"pg_proc.proowner":           "regrole",
+	"pg_proc.prorettype":         "regtype",
+	"pg_namespace.oid":           "regnamespace",
+	"pg_namespace.nspowner":      "regrole",
+	"pg_authid.oid":              "regrole",
+	"pg_index.indexrelid":        "regclass",
+	"pg_index.indrelid":          "regclass",
+	"pg_constraint.conrelid":     "regclass",
+	"pg_constraint.confrelid":    "regclass",
+	"pg_constraint.conindid":     "regclass",
+	"pg_constraint.contypid":     "regtype",
+	"pg_inherits.inhrelid":       "regclass",
+	"pg_inherits.inhparent":      "regclass",
+	"pg_trigger.tgrelid":         "regclass",
+	"pg_trigger.tgfoid":          "regprocedure",
+	"pg_policy.polrelid":         "regclass",
+	"pg_sequence.seqrelid":       "regclass",
+	"pg_sequence.seqtypid":       "regtype",
+	"pg_operator.oid":            "regoperator",
+	"pg_collation.oid":           "regcollation",
+	"pg_ts_config.oid":           "regconfig",
+	"pg_ts_dict.oid":             "regdictionary",
+	"pg_statistic_ext.stxrelid":  "regclass",
+	"pg_publication_rel.prrelid": "regclass",
+	"pg_rewrite.ev_class":        "regclass",
+}
+
+// resolveRegNames replaces OIDs read straight from a system catalog column listed in
+// catalogOIDColumns with the object's name (query.resolve_reg_types): pg_class.oid becomes
+// "public.users" or "users", pg_attribute.atttypid becomes "integer". OID 0 (no object) is
+// kept as a number. Columns of other tables, views, and computed columns are untouched.
+func resolveRegNames(ctx context.Context, tx pgx.Tx, result *QueryOutput, fields []pgconn.FieldDescription) error {
+	var rels []uint32
+	var nums []int16
+	for _, fd := range fields {
+		if fd.DataTypeOID == pgtype.OIDOID && fd.TableOID != 0 {
+			rels = append(rels, fd.TableOID)
+			nums = append(nums, int16(fd.TableAttributeNumber))
+		}
+	}
+	if len(rels) == 0 || len(result.Rows) == 0 {
+		return nil
+	}
+
+	// Which of the source columns are known catalog columns.
+	rows, err := tx.Query(ctx, `
+		SELECT f.rel, f.num, c.relname || '.' || a.attname
+		FROM unnest($1::oid[], $2::int2[]) AS f(rel, num)
+		JOIN pg_catalog.pg_class c ON c.oid = f.rel
+		JOIN pg_catalog.pg_attribute a ON a.attrelid = f.rel AND a.attnum = f.num
+		WHERE c.relnamespace = 'pg_catalog'::regnamespace`, rels, nums)
+	if err != nil {
+		return fmt.Errorf("failed to resolve OID column sources: %w", err)
+	}
+	defer rows.Close()
+	type source struct {
+		rel uint32
+		num int16
+	}
+	regTypes := map[source]string{}
+	for rows.Next() {
+		var s source
+		var column string
+		if err := rows.Scan(&s.rel, &s.num, &column); err != nil {
+			return fmt.Errorf("failed to resolve OID column sources: %w", err)
+		}
+		if regType := catalogOIDColumns[column]; regType != "" {
+			regTypes[s] = regType
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to resolve OID column sources: %w", err)
+	}
+	rows.Close()
+
+	for i, fd := range fields {
+		regType, ok := regTypes[source{fd.TableOID, int16(fd.TableAttributeNumber)}]
+		if !ok || fd.DataTypeOID != pgtype.OIDOID {
+			continue
+		}
+		col := result.Columns[i]
+		var oids []uint32
+		seen := map[uint32]bool{}
+		for _, row := range result.Rows {
+			if oid, ok := row[col].(uint32); ok && oid != 0 && !seen[oid] {
+				seen[oid] = true
+				oids = append(oids, oid)
+			}
+		}
+		if len(oids) == 0 {
+			continue
+		}
+		names, err := regNames(ctx, tx, regType, oids)
+		if err != nil {
+			return fmt.Errorf("failed to resolve names for column %q: %w", col, err)
+		}
+		for _, row := range result.Rows {
+			if oid, ok := row[col].(uint32); ok {
+				if name, ok := names[oid]; ok {
+					row[col] = name
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// regNames returns the names of oids as cast to regType, which must come from
+// catalogOIDColumns (it is formatted into the SQL).
+func regNames(ctx context.Context, tx pgx.Tx, regType string, oids []uint32) (map[uint32]string, error) {
+	rows, err := tx.Query(ctx, fmt.Sprintf("SELECT o, o::pg_catalog.%s::text FROM unnest($1::oid[]) AS o", regType), oids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := make(map[uint32]string, len(oids))
+	for rows.Next() {
+		var oid uint32
+		var name string
+		if err := rows.Scan(&oid, &name); err != nil {
+			return nil, err
+		}
+		names[oid] = name
+	}
+	return names, rows.Err()
+}
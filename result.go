@@ -0,0 +1,42 @@
+package pgmcp
+
+import "fmt"
+
+// RenameColumns renames result columns according to mapping (old name → new name),
+// updating Columns and every row's keys consistently. Intended for AfterQuery hooks.
+// Columns not present in mapping are kept; mapping entries for columns not in the
+// result are ignored. Returns an error (leaving out unchanged) if the rename would
+// produce duplicate column names. Swaps (a→b, b→a) are supported.
+func RenameColumns(out *QueryOutput, mapping map[string]string) error {
+	newColumns := make([]string, len(out.Columns))
+	renames := make(map[string]string, len(mapping))
+	owner := make(map[string]string, len(out.Columns)) // final name → original name
+	for i, col := range out.Columns {
+		name := col
+		if renamed, ok := mapping[col]; ok {
+			name = renamed
+			renames[col] = renamed
+		}
+		if prev, dup := owner[name]; dup {
+			return fmt.Errorf("rename columns: %q and %q would both be named %q", prev, col, name)
+		}
+		owner[name] = col
+		newColumns[i] = name
+	}
+	if len(renames) == 0 {
+		return nil
+	}
+
+	for i, row := range out.Rows {
+		renamedRow := make(map[string]interface{}, len(row))
+		for k, v := range row {
+			if renamed, ok := renames[k]; ok {
+				k = renamed
+			}
+			renamedRow[k] = v
+		}
+		out.Rows[i] = renamedRow
+	}
+	out.Columns = newColumns
+	return nil
+}
@@ -0,0 +1,110 @@
+package pgmcp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenameColumns_Basic(t *testing.T) {
+	t.Parallel()
+	out := &QueryOutput{
+		Columns: []string{"id", "full_name", "email"},
+		Rows: []map[string]interface{}{
+			{"id": int32(1), "full_name": "Alice", "email": "a@example.com"},
+			{"id": int32(2), "full_name": "Bob", "email": nil},
+		},
+	}
+
+	if err := RenameColumns(out, map[string]string{"full_name": "name", "email": "contact"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedColumns := []string{"id", "name", "contact"}
+	if !reflect.DeepEqual(out.Columns, expectedColumns) {
+		t.Fatalf("expected columns %v, got %v", expectedColumns, out.Columns)
+	}
+	expectedRows := []map[string]interface{}{
+		{"id": int32(1), "name": "Alice", "contact": "a@example.com"},
+		{"id": int32(2), "name": "Bob", "contact": nil},
+	}
+	if !reflect.DeepEqual(out.Rows, expectedRows) {
+		t.Fatalf("expected rows %v, got %v", expectedRows, out.Rows)
+	}
+}
+
+func TestRenameColumns_MissingColumnIgnored(t *testing.T) {
+	t.Parallel()
+	out := &QueryOutput{
+		Columns: []string{"id"},
+		Rows:    []map[string]interface{}{{"id": int32(1)}},
+	}
+
+	if err := RenameColumns(out, map[string]string{"does_not_exist": "x"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(out.Columns, []string{"id"}) {
+		t.Fatalf("expected columns [id], got %v", out.Columns)
+	}
+	if !reflect.DeepEqual(out.Rows, []map[string]interface{}{{"id": int32(1)}}) {
+		t.Fatalf("expected rows unchanged, got %v", out.Rows)
+	}
+}
+
+func TestRenameColumns_CollisionWithExisting(t *testing.T) {
+	t.Parallel()
+	out := &QueryOutput{
+		Columns: []string{"id", "name"},
+		Rows:    []map[string]interface{}{{"id": int32(1), "name": "Alice"}},
+	}
+
+	err := RenameColumns(out, map[string]string{"name": "id"})
+	if err == nil {
+		t.Fatal("expected collision error")
+	}
+	expected := `rename columns: "id" and "name" would both be named "id"`
+	if err.Error() != expected {
+		t.Fatalf("expected %q, got %q", expected, err.Error())
+	}
+	// Output must be unchanged on error
+	if !reflect.DeepEqual(out.Columns, []string{"id", "name"}) {
+		t.Fatalf("expected columns unchanged, got %v", out.Columns)
+	}
+	if !reflect.DeepEqual(out.Rows, []map[string]interface{}{{"id": int32(1), "name": "Alice"}}) {
+		t.Fatalf("expected rows unchanged, got %v", out.Rows)
+	}
+}
+
+func TestRenameColumns_CollisionBetweenRenames(t *testing.T) {
+	t.Parallel()
+	out := &QueryOutput{
+		Columns: []string{"a", "b"},
+		Rows:    []map[string]interface{}{{"a": 1, "b": 2}},
+	}
+
+	err := RenameColumns(out, map[string]string{"a": "x", "b": "x"})
+	if err == nil {
+		t.Fatal("expected collision error")
+	}
+	expected := `rename columns: "a" and "b" would both be named "x"`
+	if err.Error() != expected {
+		t.Fatalf("expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestRenameColumns_Swap(t *testing.T) {
+	t.Parallel()
+	out := &QueryOutput{
+		Columns: []string{"a", "b"},
+		Rows:    []map[string]interface{}{{"a": 1, "b": 2}},
+	}
+
+	if err := RenameColumns(out, map[string]string{"a": "b", "b": "a"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(out.Columns, []string{"b", "a"}) {
+		t.Fatalf("expected columns [b a], got %v", out.Columns)
+	}
+	if !reflect.DeepEqual(out.Rows, []map[string]interface{}{{"b": 1, "a": 2}}) {
+		t.Fatalf("expected swapped rows, got %v", out.Rows)
+	}
+}
@@ -0,0 +1,83 @@
+package pgmcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+	"github.com/rickchristie/postgres-mcp/internal/protection"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// rowSecurityErrorNote explains an error raised by row-level security, or returns "" for
+// any other error. Policy violations share SQLSTATE 42501 with plain permission errors,
+// so they are told apart by message.
+func rowSecurityErrorNote(err error) string {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "42501" || !strings.Contains(pgErr.Message, "row-level security") {
+		return ""
+	}
+	if strings.HasPrefix(pgErr.Message, "query would be affected by row-level security policy") {
+		return "row_security is off for this session, so Postgres refused a statement that row-level security policies would have filtered instead of silently filtering it"
+	}
+	return "a row-level security policy rejected this statement for the current role: rows written must satisfy the policy's WITH CHECK expression, and rows hidden by its USING expression cannot be updated or deleted"
+}
+
+// rowSecurityNote returns a note naming the tables referenced by sql whose row-level
+// security policies apply to the current role (query.row_security_notes), or "" if none.
+// CTE names that match no table are ignored; views are reported only if they have policies
+// themselves, not for the tables beneath them.
+func rowSecurityNote(ctx context.Context, tx pgx.Tx, sql string) (string, error) {
+	schemas, names := referencedRelations(sql)
+	if len(names) == 0 {
+		return "", nil
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT DISTINCT n.nspname || '.' || c.relname
+		FROM unnest($1::text[], $2::text[]) AS r(schema_name, rel_name)
+		JOIN pg_catalog.pg_class c ON c.oid = pg_catalog.to_regclass(
+			CASE WHEN r.schema_name = '' THEN pg_catalog.quote_ident(r.rel_name)
+			     ELSE pg_catalog.quote_ident(r.schema_name) || '.' || pg_catalog.quote_ident(r.rel_name) END)
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relrowsecurity AND pg_catalog.row_security_active(c.oid)
+		ORDER BY 1`, schemas, names)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up row-level security: %w", err)
+	}
+	tables, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return "", fmt.Errorf("failed to look up row-level security: %w", err)
+	}
+	if len(tables) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("row-level security policies on %s apply to the current role: rows they hide were not returned or changed, so results may differ from what the table holds", strings.Join(tables, ", ")), nil
+}
+
+// referencedRelations returns the distinct relations named anywhere in sql, as parallel
+// schema (empty when unqualified) and name slices. Returns nil if sql does not parse.
+func referencedRelations(sql string) (schemas, names []string) {
+	tree, err := pg_query.Parse(sql)
+	if err != nil {
+		return nil, nil
+	}
+	seen := map[[2]string]bool{}
+	for _, stmt := range tree.Stmts {
+		protection.Walk(stmt.ProtoReflect(), func(m protoreflect.Message) error {
+			if rv, ok := m.Interface().(*pg_query.RangeVar); ok {
+				key := [2]string{rv.Schemaname, rv.Relname}
+				if !seen[key] {
+					seen[key] = true
+					schemas = append(schemas, rv.Schemaname)
+					names = append(names, rv.Relname)
+				}
+			}
+			return nil
+		})
+	}
+	return schemas, names
+}
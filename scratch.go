@@ -0,0 +1,231 @@
+package pgmcp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxScratchSessions caps the client sessions holding temp tables at once
+// (protection.allow_temp_tables). Each holds its own connection outside the pool, so this
+// bounds the extra connections temp tables can open.
+const maxScratchSessions = 4
+
+// scratchIdleTimeout is how long a session's temp tables outlive its last call. The
+// connection is then closed, which drops them.
+const scratchIdleTimeout = 10 * time.Minute
+
+// queryConn is the connection a statement runs on: one acquired from the pool, or the
+// connection of a session holding temp tables.
+type queryConn interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+	Conn() *pgx.Conn
+	Release()
+}
+
+// acquire returns the connection for a statement: the session's own connection when the
+// call's session holds temp tables or opts.createsTempTable is set, else one from the pool.
+func (p *PostgresMcp) acquire(ctx context.Context, opts execOptions) (queryConn, error) {
+	if p.scratch != nil && opts.session != "" {
+		conn, err := p.scratch.acquire(ctx, opts.session, opts.createsTempTable)
+		if err != nil {
+			return nil, err
+		}
+		if conn != nil {
+			return conn, nil
+		}
+	}
+	conn, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// scratchSessions keeps one connection per client session (CallInfo.SessionID) that has
+// created a temp table, so the session's later calls run where its temp tables are. Calls
+// in a session take turns on its connection. Temp tables never reach the pool: the
+// connection is closed, not returned, when the session goes idle or the server closes.
+type scratchSessions struct {
+	connect  func(ctx context.Context) (*pgx.Conn, error)
+	conns    *connTracker
+	mu       sync.Mutex
+	sessions map[string]*scratchSession
+	closed   bool
+}
+
+// scratchSession is one session's connection. lock is held by the call using conn; conn
+// and idle are only touched while holding it.
+type scratchSession struct {
+	id   string
+	lock chan struct{}
+	conn *pgx.Conn   // nil once closed
+	idle *time.Timer // closes the session scratchIdleTimeout after its last call
+}
+
+func newScratchSessions(connect func(ctx context.Context) (*pgx.Conn, error), conns *connTracker) *scratchSessions {
+	return &scratchSessions{connect: connect, conns: conns, sessions: map[string]*scratchSession{}}
+}
+
+// acquire waits for session id's connection. Without one, it opens one when create is set
+// and otherwise returns nil, leaving the call to the pool.
+func (s *scratchSessions) acquire(ctx context.Context, id string, create bool) (queryConn, error) {
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return nil, nil
+		}
+		sess := s.sessions[id]
+		if sess == nil {
+			if !create {
+				s.mu.Unlock()
+				return nil, nil
+			}
+			if len(s.sessions) >= maxScratchSessions {
+				s.mu.Unlock()
+				return nil, withKind(ErrorKindProtection, fmt.Errorf("temp tables are already held by %d sessions, the most allowed at once: retry after one has been idle for %s", maxScratchSessions, scratchIdleTimeout))
+			}
+			sess = &scratchSession{id: id, lock: make(chan struct{}, 1)}
+			sess.lock <- struct{}{}
+			s.sessions[id] = sess
+			s.mu.Unlock()
+
+			conn, err := s.connect(ctx)
+			if err != nil {
+				s.drop(sess)
+				<-sess.lock
+				return nil, fmt.Errorf("failed to open the session's connection for temp tables: %w", err)
+			}
+			sess.conn = conn
+			s.conns.add(conn.PgConn())
+			return &scratchConn{sessions: s, session: sess}, nil
+		}
+		s.mu.Unlock()
+
+		select {
+		case sess.lock <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if sess.conn != nil {
+			if sess.idle != nil {
+				sess.idle.Stop()
+			}
+			s.conns.add(sess.conn.PgConn())
+			return &scratchConn{sessions: s, session: sess}, nil
+		}
+		// Closed while we waited, taking its temp tables with it; look again.
+		<-sess.lock
+	}
+}
+
+// release hands the session's connection to its next call. A connection that broke or
+// was left mid-transaction is closed instead, as the pool would destroy it, and so is
+// every connection once the server has closed.
+func (s *scratchSessions) release(sess *scratchSession) {
+	conn := sess.conn
+	s.conns.remove(conn.PgConn())
+	s.mu.Lock()
+	keep := !s.closed && s.sessions[sess.id] == sess
+	s.mu.Unlock()
+	if !keep || conn.IsClosed() || conn.PgConn().IsBusy() || conn.PgConn().TxStatus() != 'I' {
+		s.drop(sess)
+		closeConn(conn)
+		sess.conn = nil
+	} else if sess.idle == nil {
+		sess.idle = time.AfterFunc(scratchIdleTimeout, func() { s.expire(sess) })
+	} else {
+		sess.idle.Reset(scratchIdleTimeout)
+	}
+	<-sess.lock
+}
+
+// expire closes an idle session. A session in use is left alone: its release restarts
+// the timer.
+func (s *scratchSessions) expire(sess *scratchSession) {
+	select {
+	case sess.lock <- struct{}{}:
+	default:
+		return
+	}
+	defer func() { <-sess.lock }()
+	if sess.conn == nil {
+		return
+	}
+	s.drop(sess)
+	closeConn(sess.conn)
+	sess.conn = nil
+}
+
+// drop removes sess from the sessions map, unless it was already replaced.
+func (s *scratchSessions) drop(sess *scratchSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions[sess.id] == sess {
+		delete(s.sessions, sess.id)
+	}
+}
+
+// close closes every session's connection, waiting until ctx expires for calls still
+// using one; those are closed when the call releases them.
+func (s *scratchSessions) close(ctx context.Context) {
+	s.mu.Lock()
+	s.closed = true
+	sessions := s.sessions
+	s.sessions = map[string]*scratchSession{}
+	s.mu.Unlock()
+	for _, sess := range sessions {
+		select {
+		case sess.lock <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+		if sess.idle != nil {
+			sess.idle.Stop()
+		}
+		if sess.conn != nil {
+			closeConn(sess.conn)
+			sess.conn = nil
+		}
+		<-sess.lock
+	}
+}
+
+// count returns the number of sessions holding temp tables.
+func (s *scratchSessions) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// holds reports whether session id has its own connection.
+func (s *scratchSessions) holds(id string) bool {
+	if s == nil || id == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[id] != nil
+}
+
+// scratchConn is a session's connection, lent to one call.
+type scratchConn struct {
+	sessions *scratchSessions
+	session  *scratchSession
+}
+
+func (c *scratchConn) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	return c.session.conn.BeginTx(ctx, txOptions)
+}
+
+func (c *scratchConn) Conn() *pgx.Conn {
+	return c.session.conn
+}
+
+func (c *scratchConn) Release() {
+	c.sessions.release(c.session)
+}
@@ -0,0 +1,209 @@
+package pgmcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// errShuttingDown is returned for calls that arrive after Shutdown has started.
+var errShuttingDown = errors.New("server is shutting down and not accepting new queries: retry against another instance or after restart")
+
+// inflightTracker counts running tool calls so Shutdown can wait for them. The zero value
+// is ready to use.
+type inflightTracker struct {
+	mu       sync.Mutex
+	draining bool
+	running  int
+	wg       sync.WaitGroup
+	stop     context.Context // cancelled when Shutdown gives up waiting; created lazily
+	stopFn   context.CancelFunc
+}
+
+// begin registers a call. The returned context is cancelled if Shutdown times out; done
+// must be called when the call finishes. Fails once draining has started.
+func (t *inflightTracker) begin(ctx context.Context) (context.Context, func(), error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.draining {
+		return ctx, nil, errShuttingDown
+	}
+	if t.stop == nil {
+		t.stop, t.stopFn = context.WithCancel(context.Background())
+	}
+	t.running++
+	t.wg.Add(1)
+	ctx, cancel := context.WithCancel(ctx)
+	unregister := context.AfterFunc(t.stop, cancel)
+	return ctx, func() {
+		unregister()
+		cancel()
+		t.mu.Lock()
+		t.running--
+		t.mu.Unlock()
+		t.wg.Done()
+	}, nil
+}
+
+// drain rejects new calls and waits for running ones until ctx expires, then cancels the
+// rest and waits for them to return. Returns the number of calls that had to be cancelled.
+func (t *inflightTracker) drain(ctx context.Context) int {
+	t.mu.Lock()
+	t.draining = true
+	t.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return 0
+	case <-ctx.Done():
+	}
+
+	t.mu.Lock()
+	cancelled := t.running
+	if t.stopFn != nil {
+		t.stopFn()
+	}
+	t.mu.Unlock()
+	<-finished
+	return cancelled
+}
+
+// Shutdown stops accepting Query, ListTables, DescribeTable, DescribeQuery, and
+// WaitForNotification calls (Query reports a retryable error), waits for in-flight calls
+// to finish until ctx expires, cancels any still running (rolling back their
+// transactions), and closes the pool. Returns an error if calls had to be cancelled.
+func (p *PostgresMcp) Shutdown(ctx context.Context) error {
+	p.logger.Info().Msg("shutting down: draining in-flight queries")
+	cancelled := p.inflight.drain(ctx)
+	if p.scratch != nil {
+		p.scratch.close(ctx)
+	}
+	p.pool.Close()
+	if cancelled > 0 {
+		p.logger.Warn().Int("cancelled", cancelled).Msg("shutdown timeout reached, cancelled in-flight queries")
+		return fmt.Errorf("shutdown: cancelled %d in-flight queries after drain timeout: %w", cancelled, ctx.Err())
+	}
+	p.logger.Info().Msg("shutdown complete")
+	return nil
+}
+
+// cancelAll cancels every running call without affecting calls that start afterwards.
+// Returns the number of calls cancelled.
+func (t *inflightTracker) cancelAll() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopFn == nil {
+		return 0
+	}
+	t.stopFn()
+	t.stop, t.stopFn = context.WithCancel(context.Background())
+	return t.running
+}
+
+// CancelAll cancels every call in flight (Query, ListTables, DescribeTable, DescribeQuery,
+// and WaitForNotification), an escape hatch for a runaway agent. Each call returns a
+// cancellation error and rolls back its transaction, and the server is sent a cancel
+// request for every connection in use, so statements stop on the database too. Unlike
+// Shutdown, the pool stays open and new calls are accepted right away; calls starting at
+// the same moment may be cancelled too. Returns the number of calls cancelled.
+func (p *PostgresMcp) CancelAll() int {
+	// Snapshot the connections first: cancelled calls release theirs.
+	var backends []*pgconn.PgConn
+	if p.conns != nil {
+		backends = p.conns.acquired()
+	}
+	cancelled := p.inflight.cancelAll()
+
+	var wg sync.WaitGroup
+	for _, conn := range backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), cancelRequestTimeout)
+			defer cancel()
+			if err := conn.CancelRequest(ctx); err != nil {
+				p.logger.Warn().Err(err).Uint32("pid", conn.PID()).Msg("CancelAll: failed to send cancel request")
+			}
+		}()
+	}
+	wg.Wait()
+	p.logger.Warn().Int("cancelled", cancelled).Int("backends", len(backends)).Msg("cancelled all in-flight calls (CancelAll)")
+	return cancelled
+}
+
+// cancelRequestTimeout bounds each cancel request CancelAll sends to the server.
+const cancelRequestTimeout = 5 * time.Second
+
+// connTracker records the connections currently acquired from the pool, so CancelAll can
+// send cancel requests for them. It is installed as the pool's tracer, which pgxpool
+// tells about every acquire and release (including connections it then destroys).
+// Connections outside the pool are tracked with add and remove.
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[*pgconn.PgConn]struct{}
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{conns: map[*pgconn.PgConn]struct{}{}}
+}
+
+func (t *connTracker) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	return ctx
+}
+
+func (t *connTracker) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
+
+func (t *connTracker) TraceAcquireStart(ctx context.Context, _ *pgxpool.Pool, _ pgxpool.TraceAcquireStartData) context.Context {
+	return ctx
+}
+
+func (t *connTracker) TraceAcquireEnd(_ context.Context, _ *pgxpool.Pool, data pgxpool.TraceAcquireEndData) {
+	if data.Conn == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.conns[data.Conn.PgConn()] = struct{}{}
+}
+
+func (t *connTracker) TraceRelease(_ *pgxpool.Pool, data pgxpool.TraceReleaseData) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.conns, data.Conn.PgConn())
+}
+
+// add and remove track a connection in use outside the pool (a session's temp-table
+// connection, see scratchSessions).
+func (t *connTracker) add(conn *pgconn.PgConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.conns[conn] = struct{}{}
+}
+
+func (t *connTracker) remove(conn *pgconn.PgConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.conns, conn)
+}
+
+// acquired returns the connections currently in use.
+func (t *connTracker) acquired() []*pgconn.PgConn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	conns := make([]*pgconn.PgConn, 0, len(t.conns))
+	for conn := range t.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
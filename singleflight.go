@@ -0,0 +1,160 @@
+package pgmcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// readFlights coalesces identical read-only queries in flight (query.single_flight_reads):
+// the first caller runs the query, and callers arriving with the same key while it runs
+// wait for its result instead of running their own.
+type readFlights struct {
+	mu       sync.Mutex
+	calls    map[string]*readFlight // in-flight queries by key; removed when they finish
+	volatile *funcCatalogChecker    // finds volatile calls, which are never coalesced
+}
+
+type readFlight struct {
+	done    chan struct{}
+	waiters int // callers sharing this flight besides the one running it
+	result  readResult
+	err     error
+}
+
+// readResult is what a read shares with coalesced callers: the result before after-hooks
+// and sanitization, and the audit plan when audit_explain is set.
+type readResult struct {
+	output       *QueryOutput
+	skipSanitize bool
+	plan         json.RawMessage
+}
+
+func newReadFlights() *readFlights {
+	return &readFlights{calls: map[string]*readFlight{}, volatile: newVolatileChecker()}
+}
+
+// coalescible returns false if sql calls a volatile function (nextval, random,
+// clock_timestamp, ...): every caller must get its own values, so such reads run per
+// caller. If the lookup fails, sql is not coalesced either.
+func (f *readFlights) coalescible(ctx context.Context, q catalogQuerier, sql string) bool {
+	volatile, err := f.volatile.find(ctx, q, sql)
+	return err == nil && volatile == nil
+}
+
+// do runs fn, unless a call with the same key is already running, in which case it waits
+// for that call's result. shared reports whether the result came from another caller's
+// call. A waiting caller whose ctx ends stops waiting; the running call is unaffected.
+// If the running call fails because its own context was cancelled or timed out, a waiter
+// whose ctx is still live tries again instead of taking on that error.
+func (f *readFlights) do(ctx context.Context, key string, fn func() (readResult, error)) (result readResult, shared bool, err error) {
+	f.mu.Lock()
+	for {
+		call, ok := f.calls[key]
+		if !ok {
+			break
+		}
+		call.waiters++
+		f.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return readResult{}, false, fmt.Errorf("gave up waiting for an identical query in flight (query.single_flight_reads): %w", ctx.Err())
+		}
+		if !errors.Is(call.err, context.Canceled) && !errors.Is(call.err, context.DeadlineExceeded) || ctx.Err() != nil {
+			return call.result, true, call.err
+		}
+		// The running caller went away or ran out of time; that is not this caller's outcome.
+		f.mu.Lock()
+	}
+	// Reported to waiters if fn panics.
+	call := &readFlight{done: make(chan struct{}), err: errors.New("identical query in flight failed (query.single_flight_reads)")}
+	f.calls[key] = call
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+		close(call.done)
+	}()
+	call.result, call.err = fn()
+	return call.result, false, call.err
+}
+
+// singleFlightKey identifies reads that may share one execution: the same SQL as it will
+// run, after hooks and auto_limit but without statement_comment (which may differ per
+// call), with the same options.
+func singleFlightKey(sql string, opts execOptions) string {
+	return fmt.Sprintf("%t,%t,%t\x00%s", opts.includePlan, opts.unchecked, opts.readOnly, sql)
+}
+
+// cloneQueryOutput deep-copies a result so callers sharing a coalesced read can run
+// hooks, sanitization, and truncation on it independently.
+func cloneQueryOutput(o *QueryOutput) *QueryOutput {
+	c := *o
+	c.Columns = slices.Clone(o.Columns)
+	c.ColumnTypes = slices.Clone(o.ColumnTypes)
+	c.ColumnTypeDetails = slices.Clone(o.ColumnTypeDetails)
+	c.Rows = cloneRows(o.Rows)
+	c.Notices = slices.Clone(o.Notices)
+	c.Advisories = slices.Clone(o.Advisories)
+	c.AffectedKeys = cloneRows(o.AffectedKeys)
+	c.GeneratedKeys = cloneRows(o.GeneratedKeys)
+	if o.Annotations != nil {
+		c.Annotations = cloneValue(o.Annotations).(map[string]interface{})
+	}
+	if o.PlanSummary != nil {
+		plan := *o.PlanSummary
+		plan.SeqScanTables = slices.Clone(plan.SeqScanTables)
+		c.PlanSummary = &plan
+	}
+	if o.Summary != nil {
+		summary := *o.Summary
+		summary.Columns = slices.Clone(summary.Columns)
+		summary.SampleRows = cloneRows(summary.SampleRows)
+		c.Summary = &summary
+	}
+	return &c
+}
+
+func cloneRows(rows []map[string]interface{}) []map[string]interface{} {
+	if rows == nil {
+		return nil
+	}
+	cloned := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		cloned[i] = cloneValue(row).(map[string]interface{})
+	}
+	return cloned
+}
+
+// cloneValue deep-copies the maps and slices of a converted value; other values
+// (strings, numbers, json.Number, bools) are immutable and shared.
+func cloneValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if val == nil {
+			return val
+		}
+		cloned := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			cloned[k] = cloneValue(item)
+		}
+		return cloned
+	case []interface{}:
+		if val == nil {
+			return val
+		}
+		cloned := make([]interface{}, len(val))
+		for i, item := range val {
+			cloned[i] = cloneValue(item)
+		}
+		return cloned
+	default:
+		return val
+	}
+}
@@ -0,0 +1,125 @@
+package pgmcp
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+)
+
+// SortRows stably sorts out.Rows by the given columns, in order (ties on the first column
+// are broken by the second, and so on). Values are compared by type: numbers numerically
+// (across int/float widths), strings lexically, false before true. NULLs sort last. Values
+// of different kinds order as bool < number < string < other (compared by JSON encoding).
+//
+// Intended for deterministic assertions against queries without ORDER BY; Query itself
+// never reorders rows.
+func SortRows(out *QueryOutput, byColumns []string) {
+	if out == nil || len(byColumns) == 0 {
+		return
+	}
+	slices.SortStableFunc(out.Rows, func(a, b map[string]interface{}) int {
+		for _, col := range byColumns {
+			if c := compareValues(a[col], b[col]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+}
+
+// Kind ranks for compareValues; nil is last.
+const (
+	kindBool = iota
+	kindNumber
+	kindString
+	kindOther
+	kindNil
+)
+
+// compareValues orders two converted row values (see SortRows).
+func compareValues(a, b interface{}) int {
+	ka, kb := valueKind(a), valueKind(b)
+	if ka != kb {
+		return cmp.Compare(ka, kb)
+	}
+	switch ka {
+	case kindBool:
+		ab, bb := a.(bool), b.(bool)
+		if ab == bb {
+			return 0
+		}
+		if !ab {
+			return -1
+		}
+		return 1
+	case kindNumber:
+		ai, aInt := asInt64(a)
+		bi, bInt := asInt64(b)
+		if aInt && bInt {
+			return cmp.Compare(ai, bi)
+		}
+		return cmp.Compare(asFloat64(a), asFloat64(b))
+	case kindString:
+		return cmp.Compare(a.(string), b.(string))
+	case kindOther:
+		aj, _ := json.Marshal(a)
+		bj, _ := json.Marshal(b)
+		return cmp.Compare(string(aj), string(bj))
+	}
+	return 0
+}
+
+func valueKind(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return kindNil
+	case bool:
+		return kindBool
+	case int, int8, int16, int32, int64, uint8, uint16, uint32, uint64, float32, float64:
+		return kindNumber
+	case string:
+		return kindString
+	default:
+		return kindOther
+	}
+}
+
+// asInt64 returns v as int64 if it is an integer type that fits.
+func asInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		if n <= 1<<63-1 {
+			return int64(n), true
+		}
+	}
+	return 0, false
+}
+
+func asFloat64(v interface{}) float64 {
+	switch n := v.(type) {
+	case float32:
+		return float64(n)
+	case float64:
+		return n
+	case uint64:
+		return float64(n)
+	}
+	i, _ := asInt64(v)
+	return float64(i)
+}
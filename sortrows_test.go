@@ -0,0 +1,88 @@
+package pgmcp
+
+import (
+	"fmt"
+	"testing"
+)
+
+func sortedIDs(out *QueryOutput) string {
+	var got []interface{}
+	for _, row := range out.Rows {
+		got = append(got, row["id"])
+	}
+	return fmt.Sprint(got)
+}
+
+func TestSortRows_MixedNumericWidths(t *testing.T) {
+	t.Parallel()
+	out := &QueryOutput{Rows: []map[string]interface{}{
+		{"id": 1, "v": int64(10)},
+		{"id": 2, "v": int32(-3)},
+		{"id": 3, "v": float64(2.5)},
+		{"id": 4, "v": int16(2)},
+		{"id": 5, "v": float32(100)},
+	}}
+	SortRows(out, []string{"v"})
+	if got := sortedIDs(out); got != "[2 4 3 1 5]" {
+		t.Fatalf("expected numeric order [2 4 3 1 5], got %s", got)
+	}
+}
+
+func TestSortRows_NilsLastAndStable(t *testing.T) {
+	t.Parallel()
+	out := &QueryOutput{Rows: []map[string]interface{}{
+		{"id": 1, "name": nil},
+		{"id": 2, "name": "bob"},
+		{"id": 3, "name": nil},
+		{"id": 4, "name": "alice"},
+		{"id": 5, "name": "bob"},
+	}}
+	SortRows(out, []string{"name"})
+	if got := sortedIDs(out); got != "[4 2 5 1 3]" {
+		t.Fatalf("expected [4 2 5 1 3] (nils last, ties keep order), got %s", got)
+	}
+}
+
+func TestSortRows_MultipleColumns(t *testing.T) {
+	t.Parallel()
+	out := &QueryOutput{Rows: []map[string]interface{}{
+		{"id": 1, "dept": "eng", "age": int32(40)},
+		{"id": 2, "dept": "eng", "age": int32(25)},
+		{"id": 3, "dept": "art", "age": nil},
+		{"id": 4, "dept": "art", "age": int32(30)},
+	}}
+	SortRows(out, []string{"dept", "age"})
+	if got := sortedIDs(out); got != "[4 3 2 1]" {
+		t.Fatalf("expected [4 3 2 1], got %s", got)
+	}
+}
+
+func TestSortRows_MixedKinds(t *testing.T) {
+	t.Parallel()
+	out := &QueryOutput{Rows: []map[string]interface{}{
+		{"id": 1, "v": "text"},
+		{"id": 2, "v": nil},
+		{"id": 3, "v": map[string]interface{}{"k": 1}},
+		{"id": 4, "v": int64(7)},
+		{"id": 5, "v": true},
+		{"id": 6, "v": false},
+		{"id": 7, "v": []interface{}{1, 2}},
+	}}
+	SortRows(out, []string{"v"})
+	// bool < number < string < other (by JSON: "[1,2]" < "{\"k\":1}") < nil
+	if got := sortedIDs(out); got != "[6 5 4 1 7 3 2]" {
+		t.Fatalf("expected [6 5 4 1 7 3 2], got %s", got)
+	}
+}
+
+func TestSortRows_LargeIntegersCompareExactly(t *testing.T) {
+	t.Parallel()
+	out := &QueryOutput{Rows: []map[string]interface{}{
+		{"id": 1, "v": int64(1<<62 + 1)},
+		{"id": 2, "v": int64(1 << 62)},
+	}}
+	SortRows(out, []string{"v"})
+	if got := sortedIDs(out); got != "[2 1]" {
+		t.Fatalf("expected [2 1], got %s", got)
+	}
+}
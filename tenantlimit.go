@@ -0,0 +1,43 @@
+package pgmcp
+
+import "sync"
+
+// tenantLimiter caps concurrent queries per tenant (max_concurrent_per_tenant), so one
+// busy tenant cannot take every slot of the global semaphore.
+type tenantLimiter struct {
+	mu     sync.Mutex
+	max    int
+	active map[string]int // running queries per tenant; entries are removed at zero
+}
+
+func newTenantLimiter(max int) *tenantLimiter {
+	return &tenantLimiter{max: max, active: map[string]int{}}
+}
+
+// tryAcquire takes a slot for tenant without waiting. Returns false if tenant is at its limit.
+func (l *tenantLimiter) tryAcquire(tenant string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[tenant] >= l.max {
+		return false
+	}
+	l.active[tenant]++
+	return true
+}
+
+func (l *tenantLimiter) release(tenant string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[tenant]--; l.active[tenant] <= 0 {
+		delete(l.active, tenant)
+	}
+}
+
+// tenantKey identifies the caller for max_concurrent_per_tenant: CallInfo.Tenant, falling
+// back to Agent. Empty means the call is not attributed and only the global limit applies.
+func tenantKey(info CallInfo) string {
+	if info.Tenant != "" {
+		return info.Tenant
+	}
+	return info.Agent
+}
@@ -0,0 +1,4 @@
+#!/bin/bash
+# AfterQuery hook: replaces the result with one carrying annotations
+cat /dev/stdin > /dev/null
+echo '{"accept": true, "modified_result": "{\"columns\":[\"val\"],\"rows\":[{\"val\":1}],\"annotations\":{\"risk_score\":0.8,\"classification\":\"pii\",\"reasons\":[\"email column\"]}}"}'
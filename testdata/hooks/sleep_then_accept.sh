@@ -0,0 +1,5 @@
+#!/bin/bash
+# Sleeps for the number of seconds given as $1, then accepts.
+cat /dev/stdin > /dev/null
+sleep "$1"
+echo '{"accept": true}'
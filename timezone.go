@@ -0,0 +1,53 @@
+package pgmcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog"
+)
+
+// Config.TimezoneFallback values.
+const (
+	// TimezoneFallbackError (the default) makes WaitReady fail when the server rejects timezone.
+	TimezoneFallbackError = "error"
+	// TimezoneFallbackUTC sets connections to UTC instead, with a warning.
+	TimezoneFallbackUTC = "utc"
+)
+
+// timezoneProbeTimeout bounds the probe connection, so a slow server does not hold up
+// WaitReady.
+const timezoneProbeTimeout = 5 * time.Second
+
+// verifyTimezone checks that the server accepts timezone, which its time zone database may
+// not (it can differ from Go's), and returns the timezone to set on connections: timezone
+// itself, or "UTC" when it was rejected and fallback is TimezoneFallbackUTC. WaitReady
+// calls it once the server is reachable, or the first tool call when WaitReady was not
+// called, so a failed connection is an error.
+func verifyTimezone(ctx context.Context, connConfig *pgx.ConnConfig, timezone, fallback string, logger zerolog.Logger) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timezoneProbeTimeout)
+	defer cancel()
+	conn, err := pgx.ConnectConfig(ctx, connConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify timezone %q: %w", timezone, err)
+	}
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "SELECT pg_catalog.set_config('timezone', $1, false)", timezone)
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "22023" { // invalid_parameter_value
+		if err != nil {
+			logger.Warn().Err(err).Str("timezone", timezone).Msg("skipped verifying timezone")
+		}
+		return timezone, nil
+	}
+	if fallback != TimezoneFallbackUTC {
+		return "", fmt.Errorf("timezone %q is not recognized by the Postgres server, whose time zone database can differ from Go's: %s (set timezone_fallback to %q to use UTC instead)", timezone, pgErr.Message, TimezoneFallbackUTC)
+	}
+	logger.Warn().Str("timezone", timezone).Str("error", pgErr.Message).Msg("timezone is not recognized by the Postgres server, using UTC instead (timezone_fallback)")
+	return "UTC", nil
+}
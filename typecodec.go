@@ -0,0 +1,66 @@
+package pgmcp
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// TypeCodec decodes values of a Postgres type pgx does not know (PostGIS geometry,
+// pgvector's vector, ...) into agent-friendly Go values. Set Name, OID, or both: a Name
+// alone is resolved to its OID (via regtype) on every new connection, which suits types
+// created by extensions whose OIDs differ between databases.
+type TypeCodec struct {
+	Name string // type name as accepted by ::regtype, e.g. "vector" or "public.geometry"
+	OID  uint32
+	// Decode converts the value's text representation (never nil; NULLs are not passed in).
+	// The result then goes through the usual conversion, e.g. time.Time becomes RFC 3339.
+	Decode func(raw []byte) (interface{}, error)
+}
+
+// customCodec adapts a TypeCodec to pgx. It only accepts the text format, so Decode always
+// sees text; encoding and scanning into Go targets fall back to the text codec.
+type customCodec struct {
+	pgtype.TextCodec
+	decode func(raw []byte) (interface{}, error)
+}
+
+func (c *customCodec) FormatSupported(format int16) bool {
+	return format == pgtype.TextFormatCode
+}
+
+func (c *customCodec) PreferredFormat() int16 {
+	return pgtype.TextFormatCode
+}
+
+func (c *customCodec) DecodeDatabaseSQLValue(m *pgtype.Map, oid uint32, format int16, src []byte) (driver.Value, error) {
+	return c.DecodeValue(m, oid, format, src)
+}
+
+func (c *customCodec) DecodeValue(_ *pgtype.Map, _ uint32, _ int16, src []byte) (any, error) {
+	if src == nil {
+		return nil, nil
+	}
+	return c.decode(src)
+}
+
+// registerTypeCodecs registers codecs on conn's type map, resolving names to OIDs first.
+func registerTypeCodecs(ctx context.Context, conn *pgx.Conn, codecs []TypeCodec) error {
+	for _, tc := range codecs {
+		oid := tc.OID
+		if oid == 0 {
+			if err := conn.QueryRow(ctx, "SELECT $1::text::regtype::oid", tc.Name).Scan(&oid); err != nil {
+				return fmt.Errorf("failed to resolve type codec %q: %w", tc.Name, err)
+			}
+		}
+		name := tc.Name
+		if name == "" {
+			name = fmt.Sprintf("oid:%d", oid)
+		}
+		conn.TypeMap().RegisterType(&pgtype.Type{Name: name, OID: oid, Codec: &customCodec{decode: tc.Decode}})
+	}
+	return nil
+}
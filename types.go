@@ -3,6 +3,12 @@ package pgmcp
 // QueryInput is the input for the Query tool.
 type QueryInput struct {
 	SQL string `json:"sql"`
+	// IncludePlan runs EXPLAIN before a SELECT and attaches PlanSummary to the output.
+	// Ignored for other statements.
+	IncludePlan bool `json:"include_plan,omitempty"`
+	// ForceReadOnly runs this query as if read_only were set: read-only protection rules
+	// apply and it executes in a READ ONLY transaction that is never committed.
+	ForceReadOnly bool `json:"force_read_only,omitempty"`
 }
 
 // QueryOutput is the output of the Query tool. All errors (Postgres errors,
@@ -10,14 +16,108 @@ type QueryInput struct {
 // The error message is evaluated against error_prompts and matching prompt
 // messages are appended.
 type QueryOutput struct {
-	Columns      []string                 `json:"columns"`
-	Rows         []map[string]interface{} `json:"rows"`
-	RowsAffected int64                    `json:"rows_affected"`
-	Error        string                   `json:"error,omitempty"`
+	Columns           []string                 `json:"columns"`                       // in SELECT-list order, exactly as returned by Postgres
+	ColumnTypes       []string                 `json:"column_types,omitempty"`        // type of each column, in Columns order, e.g. "int4", "text[]"; only with query.include_column_types
+	ColumnTypeDetails []ColumnType             `json:"column_type_details,omitempty"` // structured types, in Columns order; only with query.column_type_details
+	Rows              []map[string]interface{} `json:"rows"`
+	RowsAffected      int64                    `json:"rows_affected"`
+	Command           string                   `json:"command,omitempty"`         // command tag for writes, e.g. "INSERT 0 3"
+	LastInsertOID     uint32                   `json:"last_insert_oid,omitempty"` // OID from INSERT tag (only for tables WITH OIDS, pre-PG12)
+	TxID              int64                    `json:"txid,omitempty"`            // transaction ID of a committed write, when query.return_commit_info is set
+	CommitLSN         string                   `json:"commit_lsn,omitempty"`      // WAL insert position read right after a write committed, at or past its commit record, e.g. "0/16B3748"; with query.return_commit_info
+	Summary           *ResultSummary           `json:"summary,omitempty"`         // set when an oversize result was summarized; Rows is then nil and the sample is in Summary.SampleRows
+	LimitApplied      bool                     `json:"limit_applied,omitempty"`   // true when query.auto_limit added or reduced the SELECT's LIMIT
+	PlanSummary       *PlanSummary             `json:"plan_summary,omitempty"`    // set when QueryInput.IncludePlan was requested for a SELECT
+	Advisories        []string                 `json:"advisories,omitempty"`      // heuristics from the captured plan, e.g. "sequential scan on large table 'public.orders' (est 2M rows) ...", with query.plan_advisories
+	Notices           []string                 `json:"notices,omitempty"`         // NOTICE/WARNING messages, e.g. "NOTICE: ...", when query.capture_notices is set
+	EmptySQL          bool                     `json:"empty_sql,omitempty"`       // true when the request was rejected because SQL was empty or whitespace-only
+	Retryable         bool                     `json:"retryable,omitempty"`       // true when the error is transient (e.g. the server's connection limit) and the same query may succeed later
+	ResultHash        string                   `json:"result_hash,omitempty"`     // SHA-256 of the rows (order-sensitive), when query.include_result_hash is set
+	AffectedKeys      []map[string]interface{} `json:"affected_keys,omitempty"`   // primary keys of rows changed by an UPDATE/DELETE without RETURNING, when query.return_affected_keys is set
+	GeneratedKeys     []map[string]interface{} `json:"generated_keys,omitempty"`  // server-assigned primary keys (serial, identity) of rows inserted without RETURNING, when query.auto_return_generated_keys is set
+	Error             string                   `json:"error,omitempty"`
+	ErrorKind         string                   `json:"error_kind,omitempty"`        // set with Error: one of the ErrorKind* values, e.g. "protection", "timeout"
+	RowSecurityNote   string                   `json:"row_security_note,omitempty"` // why row-level security may have filtered the result or rejected the statement; on errors always, on success with query.row_security_notes
+	PredicateNote     string                   `json:"predicate_note,omitempty"`    // set when the WHERE clause can never be true, with query.warn_on_always_false_predicate
+	Annotations       map[string]interface{}   `json:"annotations,omitempty"`       // set by AfterQuery hooks, e.g. {"risk_score": 0.8}; returned to the agent as-is, not sanitized
+}
+
+// ColumnType is the structured type of a result column (query.column_type_details).
+type ColumnType struct {
+	Name string `json:"name"`           // e.g. "int4", "int4[]"
+	Base string `json:"base"`           // element type for arrays, otherwise the same as Name
+	Dims int    `json:"dims,omitempty"` // array dimensions, from the first non-empty value (1 if none); 0 for non-arrays
+}
+
+// ResultSummary describes an oversize result that was replaced by a sample of its rows
+// (query.summarize_oversize_results).
+type ResultSummary struct {
+	Columns    []string                 `json:"columns"`
+	TotalRows  int                      `json:"total_rows"`
+	SampleRows []map[string]interface{} `json:"sample_rows"` // first and last few rows, in order
+}
+
+// PlanSummary is a concise view of the planner's estimate for a SELECT (QueryInput.IncludePlan).
+type PlanSummary struct {
+	NodeType      string   `json:"node_type"`                 // top plan node, e.g. "Seq Scan", "Limit", "Hash Join"
+	EstimatedRows float64  `json:"estimated_rows"`            // planner's row estimate for the whole query
+	TotalCost     float64  `json:"total_cost"`                // planner's total cost estimate
+	SeqScanTables []string `json:"seq_scan_tables,omitempty"` // schema-qualified tables read with a sequential scan
+	LargeSeqScan  bool     `json:"large_seq_scan"`            // a sequentially scanned table has an estimated 10,000+ rows
+}
+
+// DescribeQueryInput is the input for the DescribeQuery tool.
+type DescribeQueryInput struct {
+	SQL string `json:"sql"`
+}
+
+// DescribeQueryOutput is the result shape of a statement, found without executing it.
+type DescribeQueryOutput struct {
+	Columns     []string `json:"columns"`      // named as Query would name them (see query.duplicate_column_mode)
+	ColumnTypes []string `json:"column_types"` // in Columns order, e.g. "int4", "text[]"
+	ReturnsRows bool     `json:"returns_rows"` // false for statements producing no rows, e.g. INSERT without RETURNING
+}
+
+// CapabilitiesOutput is the result of Capabilities.
+type CapabilitiesOutput struct {
+	ReadOnly          bool             `json:"read_only"`
+	Allowed           []string         `json:"allowed"` // operations protection accepts, e.g. "SELECT", "MERGE"
+	Blocked           []string         `json:"blocked"` // operations protection rejects
+	AllowedExtensions []string         `json:"allowed_extensions,omitempty"`
+	Limits            CapabilityLimits `json:"limits"`
+}
+
+// CapabilityLimits are the size, row, and time limits applied to queries. Zero means no limit,
+// except for MaxSQLLength, MaxResultLength, and DefaultTimeoutSeconds, which are always set.
+type CapabilityLimits struct {
+	MaxSQLLength          int    `json:"max_sql_length"`
+	MaxSQLLengthUnit      string `json:"max_sql_length_unit"` // "bytes" or "runes"
+	MaxResultLength       int    `json:"max_result_length"`
+	AutoLimit             int    `json:"auto_limit,omitempty"` // SELECTs return at most this many rows
+	MaxRowsAffected       int    `json:"max_rows_affected,omitempty"`
+	DefaultTimeoutSeconds int    `json:"default_timeout_seconds"`
+	MaxInListItems        int    `json:"max_in_list_items,omitempty"`
+	MaxValuesRows         int    `json:"max_values_rows,omitempty"`
+}
+
+// NotificationOutput is the result of WaitForNotification.
+type NotificationOutput struct {
+	Channel   string `json:"channel"`
+	Payload   string `json:"payload"`
+	SenderPID uint32 `json:"sender_pid,omitempty"` // backend PID of the session that sent NOTIFY
+	TimedOut  bool   `json:"timed_out,omitempty"`  // true when no notification arrived in time
 }
 
 // ListTablesInput is the input for the ListTables tool.
-type ListTablesInput struct{}
+type ListTablesInput struct {
+	// Schema, when set, lists only relations in this schema.
+	Schema string `json:"schema,omitempty"`
+	// Name, when set, lists only relations whose name contains it, case-insensitively.
+	Name string `json:"name,omitempty"`
+	// GroupBySchema returns the relations nested by schema in ListTablesOutput.Schemas
+	// instead of as the flat Tables list.
+	GroupBySchema bool `json:"group_by_schema,omitempty"`
+}
 
 // TableEntry represents a single table/view in the ListTables output.
 type TableEntry struct {
@@ -26,27 +126,52 @@ type TableEntry struct {
 	Type                string `json:"type"` // "table", "view", "materialized_view", "foreign_table", "partitioned_table"
 	Owner               string `json:"owner"`
 	SchemaAccessLimited bool   `json:"schema_access_limited,omitempty"`
+	QuotedName          string `json:"quoted_name,omitempty"` // e.g. public."MixedCase"; only with query.include_quoted_names
+}
+
+// SchemaTables is one schema's relations in a grouped ListTables result.
+type SchemaTables struct {
+	Schema string       `json:"schema"`
+	Tables []TableEntry `json:"tables"`
 }
 
 // ListTablesOutput is the output of the ListTables tool.
 type ListTablesOutput struct {
-	Tables []TableEntry `json:"tables"`
-	Error  string       `json:"error,omitempty"`
+	Tables []TableEntry `json:"tables"` // empty with ListTablesInput.GroupBySchema
+	// Schemas holds the relations grouped by schema, with ListTablesInput.GroupBySchema.
+	Schemas []SchemaTables `json:"schemas,omitempty"`
+	// Truncated is set when more relations matched than query.max_tables_listed; TotalCount
+	// is then the number that matched. Narrow the list with the schema and name filters.
+	Truncated  bool   `json:"truncated,omitempty"`
+	TotalCount int    `json:"total_count,omitempty"`
+	Error      string `json:"error,omitempty"`
 }
 
 // DescribeTableInput is the input for the DescribeTable tool.
 type DescribeTableInput struct {
 	Table  string `json:"table"`
 	Schema string `json:"schema"`
+	// SampleRows, when > 0, attaches up to this many example rows (capped at
+	// query.max_sample_rows, default 100) to the output; 0 means query.default_sample_rows.
+	// Sample values go through type conversion, column masking, and sanitization.
+	SampleRows int `json:"sample_rows,omitempty"`
 }
 
 // ColumnInfo describes a single column.
 type ColumnInfo struct {
-	Name         string `json:"name"`
-	Type         string `json:"type"`
-	Nullable     bool   `json:"nullable"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+	// Default is the column's default expression, such as nextval('t_id_seq'::regclass)
+	// for a serial column, or the generation expression of a generated column.
 	Default      string `json:"default,omitempty"`
 	IsPrimaryKey bool   `json:"is_primary_key"`
+	// IsGenerated is set for GENERATED ALWAYS AS (...) STORED columns, which cannot be written.
+	IsGenerated bool `json:"is_generated,omitempty"`
+	// IsIdentity is set for identity columns; IdentityGeneration is "ALWAYS" (an INSERT may
+	// not supply a value without OVERRIDING SYSTEM VALUE) or "BY DEFAULT".
+	IsIdentity         bool   `json:"is_identity,omitempty"`
+	IdentityGeneration string `json:"identity_generation,omitempty"`
 }
 
 // IndexInfo describes a single index.
@@ -84,14 +209,18 @@ type PartitionInfo struct {
 
 // DescribeTableOutput is the output of the DescribeTable tool.
 type DescribeTableOutput struct {
-	Schema      string           `json:"schema"`
-	Name        string           `json:"name"`
-	Type        string           `json:"type"`                  // "table", "view", "materialized_view", "foreign_table", "partitioned_table"
-	Definition  string           `json:"definition,omitempty"`  // view/matview SQL definition
-	Columns     []ColumnInfo     `json:"columns"`
-	Indexes     []IndexInfo      `json:"indexes"`
-	Constraints []ConstraintInfo `json:"constraints"`
-	ForeignKeys []ForeignKeyInfo `json:"foreign_keys"`
-	Partition   *PartitionInfo   `json:"partition,omitempty"`
-	Error       string           `json:"error,omitempty"`
+	Schema         string                   `json:"schema"`
+	Name           string                   `json:"name"`
+	QuotedName     string                   `json:"quoted_name,omitempty"`   // e.g. public."MixedCase"; only with query.include_quoted_names
+	ResolvedName   string                   `json:"resolved_name,omitempty"` // catalog name, when found by case_insensitive_table_lookup
+	Type           string                   `json:"type"`                    // "table", "view", "materialized_view", "foreign_table", "partitioned_table"
+	Definition     string                   `json:"definition,omitempty"`    // view/matview SQL definition
+	Columns        []ColumnInfo             `json:"columns"`
+	Indexes        []IndexInfo              `json:"indexes"`
+	Constraints    []ConstraintInfo         `json:"constraints"`
+	ForeignKeys    []ForeignKeyInfo         `json:"foreign_keys"`
+	Partition      *PartitionInfo           `json:"partition,omitempty"`
+	SampleRows     []map[string]interface{} `json:"sample_rows,omitempty"`      // when DescribeTableInput.SampleRows or query.default_sample_rows > 0
+	SampleRowsNote string                   `json:"sample_rows_note,omitempty"` // set when DescribeTableInput.SampleRows was clamped to query.max_sample_rows
+	Error          string                   `json:"error,omitempty"`
 }
@@ -0,0 +1,59 @@
+package pgmcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// registerVectorType registers pgvector's vector type, and vector[], on conn when the
+// extension is installed, so values decode to []float32 instead of their text form.
+// Without the extension nothing is registered. TypeCodecs registered afterwards win.
+func registerVectorType(ctx context.Context, conn *pgx.Conn) error {
+	var oid, arrayOID uint32
+	err := conn.QueryRow(ctx, `
+		SELECT t.oid, t.typarray
+		FROM pg_catalog.pg_type t
+		JOIN pg_catalog.pg_depend d ON d.classid = 'pg_catalog.pg_type'::regclass AND d.objid = t.oid AND d.deptype = 'e'
+		JOIN pg_catalog.pg_extension e ON e.oid = d.refobjid
+		WHERE e.extname = 'vector' AND t.typname = 'vector'`).Scan(&oid, &arrayOID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up pgvector type: %w", err)
+	}
+	vectorType := &pgtype.Type{Name: "vector", OID: oid, Codec: &customCodec{decode: parseVector}}
+	conn.TypeMap().RegisterType(vectorType)
+	if arrayOID != 0 {
+		conn.TypeMap().RegisterType(&pgtype.Type{Name: "_vector", OID: arrayOID, Codec: &pgtype.ArrayCodec{ElementType: vectorType}})
+	}
+	return nil
+}
+
+// parseVector parses pgvector's text representation, e.g. "[1,2.5,-3]".
+func parseVector(raw []byte) (interface{}, error) {
+	s := string(raw)
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return nil, fmt.Errorf("invalid vector %q", s)
+	}
+	s = s[1 : len(s)-1]
+	if s == "" {
+		return []float32{}, nil
+	}
+	parts := strings.Split(s, ",")
+	vec := make([]float32, len(parts))
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vector element %q: %w", part, err)
+		}
+		vec[i] = float32(f)
+	}
+	return vec, nil
+}